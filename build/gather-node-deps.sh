@@ -8,7 +8,7 @@ DEPS=(
     /etc/netconfig
     /etc/mke2fs.conf /sbin/{fsck,mkfs,mount,umount}.{ext{2,3,4},xfs,nfs}
     /usr/bin/{mount,umount,chmod,grep,tail,partx}
-    /usr/sbin/{fsck,mkfs,sfdisk,losetup}
+    /usr/sbin/{fsck,mkfs,sfdisk,losetup,cryptsetup}
     /sbin/resize2fs
     /usr/sbin/xfs_growfs
 )
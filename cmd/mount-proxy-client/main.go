@@ -3,8 +3,14 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	flag "github.com/spf13/pflag"
 
@@ -13,32 +19,249 @@ import (
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
 )
 
+const usage = `Usage: mount-proxy-client [flags] <command> [args]
+
+Commands:
+  mount            mount the JSON MountRequest read from stdin
+  unmount TARGET   unmount a target mounted through mount-proxy-server
+  list             list the targets managed by mount-proxy-server
+  status TARGET    show the state of a managed target
+  ping             check that mount-proxy-server is serving
+  hello            show the protocol version, methods and drivers of mount-proxy-server
+  wait ID          wait for an async mount operation
+  cancel ID        cancel an async mount operation
+
+Flags:
+`
+
+var (
+	socketPath string
+	output     string
+)
+
 func main() {
-	var (
-		socketPath string
-	)
-	flag.StringVar(&socketPath, "socket", "", "socket path")
+	flag.StringVar(&socketPath, "socket", "/var/run/csi/mounter.sock", "socket path")
+	flag.StringVarP(&output, "output", "o", "table", "output format, table or json")
 	utils.AddKlogFlags(flag.CommandLine)
 	utils.AddGoFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	var req proxy.MountRequest
-	err := json.NewDecoder(os.Stdin).Decode(&req)
+	if output != "table" && output != "json" {
+		fmt.Fprintf(os.Stderr, "invalid output format %q\n", output)
+		os.Exit(2)
+	}
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := run(context.Background(), client.NewClient(socketPath), args[0], args[1:])
 	if err != nil {
-		fmt.Println(err.Error())
+		var uerr usageError
+		if errors.As(err, &uerr) {
+			fmt.Fprintln(os.Stderr, err.Error())
+			flag.Usage()
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
+}
 
-	data, _ := json.MarshalIndent(req, "", "\t")
-	fmt.Println(string(data))
+type usageError string
 
-	dclient := client.NewClient(socketPath)
-	resp, err := dclient.Mount(context.Background(), &req)
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func singleArg(command, name string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", usageError(fmt.Sprintf("%s requires exactly one %s", command, name))
+	}
+	return args[0], nil
+}
+
+type proxyClient interface {
+	Mount(context.Context, *proxy.MountRequest) (*proxy.Response, error)
+	Unmount(context.Context, *proxy.UnmountRequest) (*proxy.Response, error)
+	Ping(context.Context) (*proxy.Response, error)
+	List(context.Context) (*proxy.ListResponse, error)
+	Status(context.Context, *proxy.StatusRequest) (*proxy.MountStatus, error)
+	Hello(context.Context) (*proxy.HelloResponse, error)
+	Wait(context.Context, string) (*proxy.OperationStatus, error)
+	Cancel(context.Context, string) (*proxy.OperationStatus, error)
+}
+
+func run(ctx context.Context, c proxyClient, command string, args []string) error {
+	switch command {
+	case "mount":
+		var req proxy.MountRequest
+		if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+			return fmt.Errorf("decode mount request: %w", err)
+		}
+		return printResponse(c.Mount(ctx, &req))
+	case "unmount":
+		target, err := singleArg(command, "TARGET", args)
+		if err != nil {
+			return err
+		}
+		return printResponse(c.Unmount(ctx, &proxy.UnmountRequest{Target: target}))
+	case "ping":
+		return printResponse(c.Ping(ctx))
+	case "list":
+		list, err := c.List(ctx)
+		if err != nil {
+			return err
+		}
+		if output == "json" {
+			return printJSON(list)
+		}
+		return printTable(os.Stdout, list.Mounts)
+	case "status":
+		target, err := singleArg(command, "TARGET", args)
+		if err != nil {
+			return err
+		}
+		status, err := c.Status(ctx, &proxy.StatusRequest{Target: target})
+		if err != nil {
+			return err
+		}
+		if output == "json" {
+			return printJSON(status)
+		}
+		return printStatus(os.Stdout, status)
+	case "hello":
+		hello, err := c.Hello(ctx)
+		if err != nil {
+			return err
+		}
+		if output == "json" {
+			return printJSON(hello)
+		}
+		return printHello(os.Stdout, hello)
+	case "wait", "cancel":
+		id, err := singleArg(command, "ID", args)
+		if err != nil {
+			return err
+		}
+		var status *proxy.OperationStatus
+		if command == "wait" {
+			status, err = c.Wait(ctx, id)
+		} else {
+			status, err = c.Cancel(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+		if output == "json" {
+			return printJSON(status)
+		}
+		switch {
+		case !status.Done:
+			fmt.Println("running")
+		case status.Error != "":
+			fmt.Printf("failed: %s\n", status.Error)
+		default:
+			fmt.Println("done")
+		}
+		return nil
+	default:
+		return usageError(fmt.Sprintf("unknown command %q", command))
+	}
+}
+
+func printResponse(resp *proxy.Response, err error) error {
 	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+		return err
 	}
+	if err := resp.ToError(); err != nil {
+		return err
+	}
+	if output == "json" {
+		return printJSON(resp)
+	}
+	fmt.Println("ok")
+	return nil
+}
 
-	data, _ = json.MarshalIndent(resp, "", "\t")
-	fmt.Println(string(data))
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
+
+func printTable(out io.Writer, mounts []proxy.MountStatus) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tDRIVER\tFSTYPE\tSOURCE\tPID\tALIVE\tRETRIES\tFAILOVERS\tAGE")
+	now := time.Now()
+	for _, m := range mounts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			m.Target, m.Driver, m.Fstype, m.Source, pidString(m.PID), aliveString(m),
+			monitoredCount(m, m.RetryCount), monitoredCount(m, m.FailoverCount),
+			now.Sub(m.MountTime).Round(time.Second))
+	}
+	return w.Flush()
+}
+
+func printStatus(out io.Writer, m *proxy.MountStatus) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Target:\t%s\n", m.Target)
+	fmt.Fprintf(w, "Driver:\t%s\n", m.Driver)
+	fmt.Fprintf(w, "Source:\t%s\n", m.Source)
+	fmt.Fprintf(w, "Fstype:\t%s\n", m.Fstype)
+	fmt.Fprintf(w, "Options:\t%s\n", strings.Join(m.Options, ","))
+	fmt.Fprintf(w, "PID:\t%s\n", pidString(m.PID))
+	fmt.Fprintf(w, "Mount time:\t%s\n", m.MountTime.Format(time.RFC3339))
+	fmt.Fprintf(w, "Alive:\t%s\n", aliveString(*m))
+	fmt.Fprintf(w, "Retries:\t%s\n", monitoredCount(*m, m.RetryCount))
+	fmt.Fprintf(w, "Failovers:\t%s\n", monitoredCount(*m, m.FailoverCount))
+	return w.Flush()
+}
+
+func printHello(out io.Writer, hello *proxy.HelloResponse) error {
+	fmt.Fprintf(out, "Protocol version: %d\n", hello.ProtocolVersion)
+	methods := make([]string, 0, len(hello.Methods))
+	for _, m := range hello.Methods {
+		methods = append(methods, string(m))
+	}
+	fmt.Fprintf(out, "Methods: %s\n\n", strings.Join(methods, ","))
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "DRIVER\tFSTYPES\tCAPABILITIES")
+	for _, d := range hello.Drivers {
+		capabilities := make([]string, 0, len(d.Capabilities))
+		for _, c := range d.Capabilities {
+			capabilities = append(capabilities, string(c))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Name, strings.Join(d.Fstypes, ","), strings.Join(capabilities, ","))
+	}
+	return w.Flush()
+}
+
+func pidString(pid int) string {
+	if pid == 0 {
+		return "-"
+	}
+	return strconv.Itoa(pid)
+}
+
+// aliveString tells whether the liveness comes from the monitor or only from the mount table.
+func aliveString(m proxy.MountStatus) string {
+	alive := strconv.FormatBool(m.Alive)
+	if !m.Monitored {
+		alive += " (unmonitored)"
+	}
+	return alive
+}
+
+// monitoredCount reports counters as unknown for unmonitored targets.
+func monitoredCount(m proxy.MountStatus, count int) string {
+	if !m.Monitored {
+		return "-"
+	}
+	return strconv.Itoa(count)
 }
@@ -29,6 +29,8 @@ var (
 	handleTimeout    time.Duration
 	enableNftables   bool
 	cleanupNASMounts bool
+	stateDir         string
+	authPolicyPath   string
 )
 
 func main() {
@@ -37,12 +39,31 @@ func main() {
 	flag.DurationVar(&handleTimeout, "timeout", time.Second*30, "timeout for connection")
 	flag.BoolVar(&enableNftables, "enable-nftables", false, "enable nftables rules to restrict mount proxy port access (default: false)")
 	flag.BoolVar(&cleanupNASMounts, "cleanup-nas-mounts-on-exit", false, "unmount all NAS mount points inside the pod on SIGTERM")
+	flag.StringVar(&stateDir, "state-dir", "", "directory outliving the container (e.g. an emptyDir) to persist the served mounts across restarts, disabled if empty")
+	flag.StringVar(&authPolicyPath, "auth-policy", "", "JSON file of the rules authorizing requests by the credentials of the peer process, all requests are allowed if empty")
 	utils.AddKlogFlags(flag.CommandLine)
 	utils.AddGoFlags(flag.CommandLine)
 	flag.Parse()
 
+	var authPolicy *server.AuthPolicy
+	if authPolicyPath != "" {
+		var err error
+		authPolicy, err = server.LoadAuthPolicy(authPolicyPath)
+		if err != nil {
+			klog.ErrorS(err, "Failed to load auth policy")
+			os.Exit(1)
+		}
+	}
+
 	_ = os.Remove(socketPath)
 	server.SetCleanupNASMountsOnExit(cleanupNASMounts)
+	if stateDir != "" {
+		if err := os.MkdirAll(stateDir, 0o700); err != nil {
+			klog.ErrorS(err, "Failed to create state directory", "path", stateDir)
+			os.Exit(1)
+		}
+	}
+	server.SetStateDir(stateDir)
 	server.Init(drivers)
 
 	listener, err := listen(socketPath)
@@ -65,6 +86,12 @@ func main() {
 	}
 
 	srv := server.NewServer(listener, handleTimeout)
+	if authPolicy != nil {
+		srv.SetAuthPolicy(authPolicy)
+		klog.InfoS("Authorizing requests", "policy", authPolicyPath, "rules", len(authPolicy.Rules))
+	} else {
+		klog.InfoS("No auth policy, any process able to connect to the socket is allowed")
+	}
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
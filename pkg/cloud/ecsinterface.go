@@ -1,7 +1,9 @@
 package cloud
 
 import (
+	openapiutil "github.com/alibabacloud-go/darabonba-openapi/v2/utils"
 	ecs20140526 "github.com/alibabacloud-go/ecs-20140526/v7/client"
+	"github.com/alibabacloud-go/tea/dara"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 )
 
@@ -17,6 +19,11 @@ type ECSInterface interface {
 	ResizeDisk(request *ecs.ResizeDiskRequest) (response *ecs.ResizeDiskResponse, err error)
 	CreateSnapshot(request *ecs.CreateSnapshotRequest) (response *ecs.CreateSnapshotResponse, err error)
 	DescribeSnapshots(request *ecs.DescribeSnapshotsRequest) (response *ecs.DescribeSnapshotsResponse, err error)
+	DeleteSnapshot(request *ecs.DeleteSnapshotRequest) (response *ecs.DeleteSnapshotResponse, err error)
+	CopySnapshot(request *ecs.CopySnapshotRequest) (response *ecs.CopySnapshotResponse, err error)
+	DescribeSnapshotGroups(request *ecs.DescribeSnapshotGroupsRequest) (response *ecs.DescribeSnapshotGroupsResponse, err error)
+	CreateSnapshotGroup(request *ecs.CreateSnapshotGroupRequest) (response *ecs.CreateSnapshotGroupResponse, err error)
+	DeleteSnapshotGroup(request *ecs.DeleteSnapshotGroupRequest) (response *ecs.DeleteSnapshotGroupResponse, err error)
 }
 
 type ECSv2Interface interface {
@@ -29,4 +36,11 @@ type ECSv2Interface interface {
 	ModifyDiskSpec(request *ecs20140526.ModifyDiskSpecRequest) (response *ecs20140526.ModifyDiskSpecResponse, err error)
 	ModifyDiskAttribute(request *ecs20140526.ModifyDiskAttributeRequest) (response *ecs20140526.ModifyDiskAttributeResponse, err error)
 	DescribeTasks(request *ecs20140526.DescribeTasksRequest) (response *ecs20140526.DescribeTasksResponse, err error)
+	CreateAutoSnapshotPolicy(request *ecs20140526.CreateAutoSnapshotPolicyRequest) (response *ecs20140526.CreateAutoSnapshotPolicyResponse, err error)
+	DescribeAutoSnapshotPolicyEx(request *ecs20140526.DescribeAutoSnapshotPolicyExRequest) (response *ecs20140526.DescribeAutoSnapshotPolicyExResponse, err error)
+	ApplyAutoSnapshotPolicy(request *ecs20140526.ApplyAutoSnapshotPolicyRequest) (response *ecs20140526.ApplyAutoSnapshotPolicyResponse, err error)
+	CancelAutoSnapshotPolicy(request *ecs20140526.CancelAutoSnapshotPolicyRequest) (response *ecs20140526.CancelAutoSnapshotPolicyResponse, err error)
+	DeleteAutoSnapshotPolicy(request *ecs20140526.DeleteAutoSnapshotPolicyRequest) (response *ecs20140526.DeleteAutoSnapshotPolicyResponse, err error)
+	// CallApi calls an API with parameters not in the generated SDK yet.
+	CallApi(params *openapiutil.Params, request *openapiutil.OpenApiRequest, runtime *dara.RuntimeOptions) (map[string]any, error)
 }
@@ -7,7 +7,9 @@ package cloud
 import (
 	reflect "reflect"
 
+	utils "github.com/alibabacloud-go/darabonba-openapi/v2/utils"
 	client "github.com/alibabacloud-go/ecs-20140526/v7/client"
+	dara "github.com/alibabacloud-go/tea/dara"
 	ecs "github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 	gomock "github.com/golang/mock/gomock"
 )
@@ -50,6 +52,21 @@ func (mr *MockECSInterfaceMockRecorder) AttachDisk(request interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachDisk", reflect.TypeOf((*MockECSInterface)(nil).AttachDisk), request)
 }
 
+// CopySnapshot mocks base method.
+func (m *MockECSInterface) CopySnapshot(request *ecs.CopySnapshotRequest) (*ecs.CopySnapshotResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CopySnapshot", request)
+	ret0, _ := ret[0].(*ecs.CopySnapshotResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CopySnapshot indicates an expected call of CopySnapshot.
+func (mr *MockECSInterfaceMockRecorder) CopySnapshot(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CopySnapshot", reflect.TypeOf((*MockECSInterface)(nil).CopySnapshot), request)
+}
+
 // CreateDisk mocks base method.
 func (m *MockECSInterface) CreateDisk(request *ecs.CreateDiskRequest) (*ecs.CreateDiskResponse, error) {
 	m.ctrl.T.Helper()
@@ -80,6 +97,21 @@ func (mr *MockECSInterfaceMockRecorder) CreateSnapshot(request interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSnapshot", reflect.TypeOf((*MockECSInterface)(nil).CreateSnapshot), request)
 }
 
+// CreateSnapshotGroup mocks base method.
+func (m *MockECSInterface) CreateSnapshotGroup(request *ecs.CreateSnapshotGroupRequest) (*ecs.CreateSnapshotGroupResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSnapshotGroup", request)
+	ret0, _ := ret[0].(*ecs.CreateSnapshotGroupResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSnapshotGroup indicates an expected call of CreateSnapshotGroup.
+func (mr *MockECSInterfaceMockRecorder) CreateSnapshotGroup(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSnapshotGroup", reflect.TypeOf((*MockECSInterface)(nil).CreateSnapshotGroup), request)
+}
+
 // DeleteDisk mocks base method.
 func (m *MockECSInterface) DeleteDisk(request *ecs.DeleteDiskRequest) (*ecs.DeleteDiskResponse, error) {
 	m.ctrl.T.Helper()
@@ -95,6 +127,36 @@ func (mr *MockECSInterfaceMockRecorder) DeleteDisk(request interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDisk", reflect.TypeOf((*MockECSInterface)(nil).DeleteDisk), request)
 }
 
+// DeleteSnapshot mocks base method.
+func (m *MockECSInterface) DeleteSnapshot(request *ecs.DeleteSnapshotRequest) (*ecs.DeleteSnapshotResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSnapshot", request)
+	ret0, _ := ret[0].(*ecs.DeleteSnapshotResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteSnapshot indicates an expected call of DeleteSnapshot.
+func (mr *MockECSInterfaceMockRecorder) DeleteSnapshot(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSnapshot", reflect.TypeOf((*MockECSInterface)(nil).DeleteSnapshot), request)
+}
+
+// DeleteSnapshotGroup mocks base method.
+func (m *MockECSInterface) DeleteSnapshotGroup(request *ecs.DeleteSnapshotGroupRequest) (*ecs.DeleteSnapshotGroupResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSnapshotGroup", request)
+	ret0, _ := ret[0].(*ecs.DeleteSnapshotGroupResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteSnapshotGroup indicates an expected call of DeleteSnapshotGroup.
+func (mr *MockECSInterfaceMockRecorder) DeleteSnapshotGroup(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSnapshotGroup", reflect.TypeOf((*MockECSInterface)(nil).DeleteSnapshotGroup), request)
+}
+
 // DescribeAvailableResource mocks base method.
 func (m *MockECSInterface) DescribeAvailableResource(request *ecs.DescribeAvailableResourceRequest) (*ecs.DescribeAvailableResourceResponse, error) {
 	m.ctrl.T.Helper()
@@ -155,6 +217,21 @@ func (mr *MockECSInterfaceMockRecorder) DescribeInstances(request interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeInstances", reflect.TypeOf((*MockECSInterface)(nil).DescribeInstances), request)
 }
 
+// DescribeSnapshotGroups mocks base method.
+func (m *MockECSInterface) DescribeSnapshotGroups(request *ecs.DescribeSnapshotGroupsRequest) (*ecs.DescribeSnapshotGroupsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeSnapshotGroups", request)
+	ret0, _ := ret[0].(*ecs.DescribeSnapshotGroupsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeSnapshotGroups indicates an expected call of DescribeSnapshotGroups.
+func (mr *MockECSInterfaceMockRecorder) DescribeSnapshotGroups(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeSnapshotGroups", reflect.TypeOf((*MockECSInterface)(nil).DescribeSnapshotGroups), request)
+}
+
 // DescribeSnapshots mocks base method.
 func (m *MockECSInterface) DescribeSnapshots(request *ecs.DescribeSnapshotsRequest) (*ecs.DescribeSnapshotsResponse, error) {
 	m.ctrl.T.Helper()
@@ -223,6 +300,96 @@ func (m *MockECSv2Interface) EXPECT() *MockECSv2InterfaceMockRecorder {
 	return m.recorder
 }
 
+// ApplyAutoSnapshotPolicy mocks base method.
+func (m *MockECSv2Interface) ApplyAutoSnapshotPolicy(request *client.ApplyAutoSnapshotPolicyRequest) (*client.ApplyAutoSnapshotPolicyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApplyAutoSnapshotPolicy", request)
+	ret0, _ := ret[0].(*client.ApplyAutoSnapshotPolicyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApplyAutoSnapshotPolicy indicates an expected call of ApplyAutoSnapshotPolicy.
+func (mr *MockECSv2InterfaceMockRecorder) ApplyAutoSnapshotPolicy(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApplyAutoSnapshotPolicy", reflect.TypeOf((*MockECSv2Interface)(nil).ApplyAutoSnapshotPolicy), request)
+}
+
+// CallApi mocks base method.
+func (m *MockECSv2Interface) CallApi(params *utils.Params, request *utils.OpenApiRequest, runtime *dara.RuntimeOptions) (map[string]any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CallApi", params, request, runtime)
+	ret0, _ := ret[0].(map[string]any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CallApi indicates an expected call of CallApi.
+func (mr *MockECSv2InterfaceMockRecorder) CallApi(params, request, runtime interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CallApi", reflect.TypeOf((*MockECSv2Interface)(nil).CallApi), params, request, runtime)
+}
+
+// CancelAutoSnapshotPolicy mocks base method.
+func (m *MockECSv2Interface) CancelAutoSnapshotPolicy(request *client.CancelAutoSnapshotPolicyRequest) (*client.CancelAutoSnapshotPolicyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelAutoSnapshotPolicy", request)
+	ret0, _ := ret[0].(*client.CancelAutoSnapshotPolicyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelAutoSnapshotPolicy indicates an expected call of CancelAutoSnapshotPolicy.
+func (mr *MockECSv2InterfaceMockRecorder) CancelAutoSnapshotPolicy(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelAutoSnapshotPolicy", reflect.TypeOf((*MockECSv2Interface)(nil).CancelAutoSnapshotPolicy), request)
+}
+
+// CreateAutoSnapshotPolicy mocks base method.
+func (m *MockECSv2Interface) CreateAutoSnapshotPolicy(request *client.CreateAutoSnapshotPolicyRequest) (*client.CreateAutoSnapshotPolicyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAutoSnapshotPolicy", request)
+	ret0, _ := ret[0].(*client.CreateAutoSnapshotPolicyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAutoSnapshotPolicy indicates an expected call of CreateAutoSnapshotPolicy.
+func (mr *MockECSv2InterfaceMockRecorder) CreateAutoSnapshotPolicy(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAutoSnapshotPolicy", reflect.TypeOf((*MockECSv2Interface)(nil).CreateAutoSnapshotPolicy), request)
+}
+
+// DeleteAutoSnapshotPolicy mocks base method.
+func (m *MockECSv2Interface) DeleteAutoSnapshotPolicy(request *client.DeleteAutoSnapshotPolicyRequest) (*client.DeleteAutoSnapshotPolicyResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAutoSnapshotPolicy", request)
+	ret0, _ := ret[0].(*client.DeleteAutoSnapshotPolicyResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteAutoSnapshotPolicy indicates an expected call of DeleteAutoSnapshotPolicy.
+func (mr *MockECSv2InterfaceMockRecorder) DeleteAutoSnapshotPolicy(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAutoSnapshotPolicy", reflect.TypeOf((*MockECSv2Interface)(nil).DeleteAutoSnapshotPolicy), request)
+}
+
+// DescribeAutoSnapshotPolicyEx mocks base method.
+func (m *MockECSv2Interface) DescribeAutoSnapshotPolicyEx(request *client.DescribeAutoSnapshotPolicyExRequest) (*client.DescribeAutoSnapshotPolicyExResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DescribeAutoSnapshotPolicyEx", request)
+	ret0, _ := ret[0].(*client.DescribeAutoSnapshotPolicyExResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeAutoSnapshotPolicyEx indicates an expected call of DescribeAutoSnapshotPolicyEx.
+func (mr *MockECSv2InterfaceMockRecorder) DescribeAutoSnapshotPolicyEx(request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeAutoSnapshotPolicyEx", reflect.TypeOf((*MockECSv2Interface)(nil).DescribeAutoSnapshotPolicyEx), request)
+}
+
 // DescribeAvailableResource mocks base method.
 func (m *MockECSv2Interface) DescribeAvailableResource(request *client.DescribeAvailableResourceRequest) (*client.DescribeAvailableResourceResponse, error) {
 	m.ctrl.T.Helper()
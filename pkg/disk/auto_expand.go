@@ -0,0 +1,410 @@
+package disk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/ttlcache"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	k8smount "k8s.io/mount-utils"
+)
+
+const (
+	defaultAutoExpandThreshold   = 85
+	defaultAutoExpandStepPercent = 20
+	defaultAutoExpandCooldown    = 15 * time.Minute
+
+	// how long the StorageClass and PVC used to find the policy are cached
+	autoExpandCacheTTL = time.Minute
+	// how often the usage of the volumes is checked against their policy
+	autoExpandInterval = time.Minute
+
+	autoExpandReason        = "AutoExpand"
+	autoExpandFailedReason  = "AutoExpandFailed"
+	autoExpandLimitedReason = "AutoExpandLimitReached"
+)
+
+type autoExpandPolicy struct {
+	threshold   float64 // percentage of used bytes
+	stepBytes   int64
+	stepPercent float64
+	maxBytes    int64 // 0 for the size limit of the disk category
+	cooldown    time.Duration
+}
+
+// parseAutoExpandPolicy parses the auto expansion policy from StorageClass parameters, overridden by the PVC annotations.
+// It returns nil if auto expansion is not enabled, but any invalid value is still reported.
+func parseAutoExpandPolicy(params, annotations map[string]string) (*autoExpandPolicy, error) {
+	get := func(key, annotation string) (string, string, bool) {
+		if v, ok := annotations[annotation]; ok {
+			return annotation, v, true
+		}
+		v, ok := params[key]
+		return key, v, ok
+	}
+
+	p := &autoExpandPolicy{
+		threshold:   defaultAutoExpandThreshold,
+		stepPercent: defaultAutoExpandStepPercent,
+		cooldown:    defaultAutoExpandCooldown,
+	}
+	enabled := false
+	if k, v, ok := get(AutoExpandKey, AutoExpandAnnotation); ok {
+		var err error
+		enabled, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", k, v, err)
+		}
+	}
+	if k, v, ok := get(AutoExpandThresholdKey, AutoExpandThresholdAnnotation); ok {
+		threshold, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil || threshold <= 0 || threshold >= 100 {
+			return nil, fmt.Errorf("invalid %s %q, must be a percentage between 0 and 100", k, v)
+		}
+		p.threshold = threshold
+	}
+	if k, v, ok := get(AutoExpandStepKey, AutoExpandStepAnnotation); ok {
+		if percent, found := strings.CutSuffix(v, "%"); found {
+			step, err := strconv.ParseFloat(percent, 64)
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid %s %q, must be a positive percentage", k, v)
+			}
+			p.stepPercent = step
+		} else {
+			step, err := resource.ParseQuantity(v)
+			if err != nil || step.Sign() <= 0 {
+				return nil, fmt.Errorf("invalid %s %q, must be a positive quantity or percentage", k, v)
+			}
+			p.stepPercent = 0
+			p.stepBytes = step.Value()
+		}
+	}
+	if k, v, ok := get(AutoExpandMaxSizeKey, AutoExpandMaxSizeAnnotation); ok {
+		maxSize, err := resource.ParseQuantity(v)
+		if err != nil || maxSize.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid %s %q, must be a positive quantity", k, v)
+		}
+		p.maxBytes = maxSize.Value()
+	}
+	if k, v, ok := get(AutoExpandCooldownKey, AutoExpandCooldownAnnotation); ok {
+		cooldown, err := time.ParseDuration(v)
+		if err != nil || cooldown < 0 {
+			return nil, fmt.Errorf("invalid %s %q, must be a non-negative duration", k, v)
+		}
+		p.cooldown = cooldown
+	}
+	if !enabled {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// nextSize returns the size to expand a disk of the current size to, in bytes, rounded up to GiB.
+// The result is not larger than the size limit, and may not be larger than current if the limit is reached.
+func (p *autoExpandPolicy) nextSize(current int64, limit SizeRange) int64 {
+	step := p.stepBytes
+	if p.stepPercent > 0 {
+		step = int64(float64(current) * p.stepPercent / 100)
+	}
+	size := (current + step + GBSIZE - 1) / GBSIZE * GBSIZE
+	size = max(size, MinimumDiskSizeInGB*GBSIZE, int64(limit.Min)*GBSIZE)
+
+	maxSize := p.maxBytes
+	if limit.Max > 0 && (maxSize == 0 || maxSize > int64(limit.Max)*GBSIZE) {
+		maxSize = int64(limit.Max) * GBSIZE
+	}
+	if maxSize > 0 {
+		size = min(size, maxSize)
+	}
+	return size
+}
+
+// autoExpander expands the PVC of a disk volume on this node by patching its request,
+// when the usage of its filesystem crosses the threshold of its policy.
+// run checks the usage of the volumes mounted in the pods on the node periodically.
+type autoExpander struct {
+	clientSet kubernetes.Interface
+	recorder  record.EventRecorder
+
+	storageClasses *ttlcache.TTLCache[string, *storagev1.StorageClass]
+	pvcs           *ttlcache.TTLCache[types.NamespacedName, *v1.PersistentVolumeClaim]
+	pvs            *ttlcache.TTLCache[string, *v1.PersistentVolume]
+
+	podsDir string
+	mounter k8smount.Interface
+	// getMetrics returns the usage of the filesystem mounted at path
+	getMetrics func(path string) (*csi.NodeGetVolumeStatsResponse, error)
+
+	now func() time.Time
+}
+
+type diskUsage struct {
+	diskID string
+	pvcRef *v1.ObjectReference
+	used   float64 // percentage of used bytes
+}
+
+// mountedVolume is a disk volume with a filesystem mounted in a pod.
+type mountedVolume struct {
+	pvName     string
+	diskID     string
+	mountPoint string
+}
+
+func newAutoExpander(clientSet kubernetes.Interface, recorder record.EventRecorder, mounter k8smount.Interface) *autoExpander {
+	return &autoExpander{
+		clientSet:      clientSet,
+		recorder:       recorder,
+		storageClasses: ttlcache.NewTTLCache[string, *storagev1.StorageClass](autoExpandCacheTTL),
+		pvcs:           ttlcache.NewTTLCache[types.NamespacedName, *v1.PersistentVolumeClaim](autoExpandCacheTTL),
+		pvs:            ttlcache.NewTTLCache[string, *v1.PersistentVolume](autoExpandCacheTTL),
+		podsDir:        filepath.Join(utils.KubeletRootDir, "pods"),
+		mounter:        mounter,
+		getMetrics:     utils.GetMetrics,
+		now:            time.Now,
+	}
+}
+
+func (e *autoExpander) getStorageClass(ctx context.Context, name string) (*storagev1.StorageClass, error) {
+	return e.storageClasses.Get(ctx, name, func() (*storagev1.StorageClass, error) {
+		return e.clientSet.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+	})
+}
+
+// policy returns the auto expansion policy of pvc, or nil if not enabled.
+func (e *autoExpander) policy(ctx context.Context, pvc *v1.PersistentVolumeClaim) (*autoExpandPolicy, *storagev1.StorageClass, error) {
+	var sc *storagev1.StorageClass
+	var params map[string]string
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		var err error
+		sc, err = e.getStorageClass(ctx, *pvc.Spec.StorageClassName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get StorageClass: %w", err)
+		}
+		params = sc.Parameters
+	}
+	p, err := parseAutoExpandPolicy(params, pvc.Annotations)
+	return p, sc, err
+}
+
+// run checks the usage of the mounted volumes every autoExpandInterval, until ctx is done.
+func (e *autoExpander) run(ctx context.Context) {
+	wait.UntilWithContext(ctx, e.checkAll, autoExpandInterval)
+}
+
+// checkAll checks the usage of every disk volume mounted in the pods on the node.
+func (e *autoExpander) checkAll(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	volumes, err := e.mountedVolumes()
+	if err != nil {
+		logger.Error(err, "failed to list mounted volumes for auto expansion")
+		return
+	}
+	for _, vol := range volumes {
+		if ctx.Err() != nil {
+			return
+		}
+		u, err := e.usage(ctx, vol)
+		if err != nil {
+			logger.Error(err, "failed to get usage for auto expansion", "pv", vol.pvName)
+			continue
+		}
+		if u.pvcRef != nil && u.used > 0 {
+			e.check(ctx, vol.pvName, u)
+		}
+	}
+}
+
+// mountedVolumes returns the disk volumes with a filesystem mounted in the pods on the node, once for each PV.
+// Block volumes are not mounted in the pods, and never listed.
+func (e *autoExpander) mountedVolumes() ([]mountedVolume, error) {
+	paths, err := filepath.Glob(filepath.Join(e.podsDir, "*", "volumes", "kubernetes.io~csi", "*", utils.VolDataFileName))
+	if err != nil {
+		return nil, err
+	}
+	var volumes []mountedVolume
+	seen := sets.New[string]()
+	for _, path := range paths {
+		data, err := utils.ReadJSONFile(path)
+		if err != nil {
+			klog.ErrorS(err, "failed to read volume data", "path", path)
+			continue
+		}
+		pvName := data["specVolID"]
+		if data["driverName"] != DriverName || seen.Has(pvName) {
+			continue
+		}
+		mountPoint := filepath.Join(filepath.Dir(path), "mount")
+		notMounted, err := e.mounter.IsLikelyNotMountPoint(mountPoint)
+		if err != nil || notMounted {
+			continue
+		}
+		seen.Insert(pvName)
+		volumes = append(volumes, mountedVolume{pvName: pvName, diskID: data["volumeHandle"], mountPoint: mountPoint})
+	}
+	return volumes, nil
+}
+
+// usage returns the usage of the filesystem of vol, and the PVC bound to it, if any.
+func (e *autoExpander) usage(ctx context.Context, vol mountedVolume) (diskUsage, error) {
+	u := diskUsage{diskID: vol.diskID}
+	stats, err := e.getMetrics(vol.mountPoint)
+	if err != nil {
+		return u, err
+	}
+	for _, s := range stats.Usage {
+		if s.Unit == csi.VolumeUsage_BYTES && s.Total > 0 {
+			u.used = float64(s.Used) / float64(s.Total) * 100
+		}
+	}
+	pv, err := e.pvs.Get(ctx, vol.pvName, func() (*v1.PersistentVolume, error) {
+		return e.clientSet.CoreV1().PersistentVolumes().Get(ctx, vol.pvName, metav1.GetOptions{})
+	})
+	if err != nil {
+		return u, fmt.Errorf("failed to get PV: %w", err)
+	}
+	if pv.Status.Phase == v1.VolumeBound {
+		u.pvcRef = pv.Spec.ClaimRef
+	}
+	return u, nil
+}
+
+func (e *autoExpander) check(ctx context.Context, pvName string, u diskUsage) {
+	pvcRef, used := u.pvcRef, u.used
+	logger := klog.FromContext(ctx).WithValues("pv", pvName, "disk", u.diskID, "pvc", klog.KRef(pvcRef.Namespace, pvcRef.Name))
+	ctx = klog.NewContext(ctx, logger)
+
+	key := types.NamespacedName{Namespace: pvcRef.Namespace, Name: pvcRef.Name}
+	pvc, err := e.pvcs.Get(ctx, key, func() (*v1.PersistentVolumeClaim, error) {
+		return e.clientSet.CoreV1().PersistentVolumeClaims(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+	})
+	if err != nil {
+		logger.Error(err, "failed to get PVC for auto expansion")
+		return
+	}
+	p, _, err := e.policy(ctx, pvc)
+	if err != nil {
+		logger.Error(err, "invalid auto expansion policy")
+		return
+	}
+	if p == nil || used < p.threshold {
+		return
+	}
+
+	// The cached PVC may be stale, check again with the latest one before expanding.
+	pvc, err = e.clientSet.CoreV1().PersistentVolumeClaims(key.Namespace).Get(ctx, key.Name, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(err, "failed to get PVC for auto expansion")
+		return
+	}
+	e.pvcs.Store(key, pvc)
+	if err := e.expand(ctx, pvc, used); err != nil {
+		logger.Error(err, "auto expansion failed")
+		e.recorder.Eventf(pvc, v1.EventTypeWarning, autoExpandFailedReason, "Failed to expand PVC automatically: %v", err)
+	}
+}
+
+func resizeInProgress(pvc *v1.PersistentVolumeClaim) bool {
+	requested := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	capacity := pvc.Status.Capacity[v1.ResourceStorage]
+	if requested.Cmp(capacity) > 0 {
+		return true
+	}
+	for _, c := range pvc.Status.Conditions {
+		if (c.Type == v1.PersistentVolumeClaimResizing || c.Type == v1.PersistentVolumeClaimFileSystemResizePending) &&
+			c.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return len(pvc.Status.AllocatedResourceStatuses) > 0
+}
+
+func (e *autoExpander) expand(ctx context.Context, pvc *v1.PersistentVolumeClaim, used float64) error {
+	logger := klog.FromContext(ctx)
+	p, sc, err := e.policy(ctx, pvc)
+	if err != nil || p == nil {
+		return err
+	}
+	if resizeInProgress(pvc) {
+		logger.V(2).Info("resize in progress, skip auto expansion")
+		return nil
+	}
+	if last, ok := pvc.Annotations[AutoExpandLastExpandAnnotation]; ok {
+		t, err := time.Parse(time.RFC3339, last)
+		if err != nil {
+			logger.Error(err, "ignoring invalid annotation", "annotation", AutoExpandLastExpandAnnotation)
+		} else if e.now().Before(t.Add(p.cooldown)) {
+			logger.V(2).Info("in cooldown, skip auto expansion", "lastExpand", t)
+			return nil
+		}
+	}
+	if sc == nil || sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		return fmt.Errorf("StorageClass of the PVC does not allow volume expansion")
+	}
+
+	pv, err := e.clientSet.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get PV: %w", err)
+	}
+	var limit SizeRange
+	if pv.Spec.CSI != nil {
+		attrs := pv.Spec.CSI.VolumeAttributes
+		limit = GetSizeRange(Category(attrs["type"]), PerformanceLevel(attrs[ESSD_PERFORMANCE_LEVEL]))
+	}
+
+	capacity := pvc.Status.Capacity[v1.ResourceStorage]
+	current := capacity.Value()
+	size := p.nextSize(current, limit)
+	if size <= current {
+		e.recorder.Eventf(pvc, v1.EventTypeWarning, autoExpandLimitedReason,
+			"PVC used %.2f%%, exceeding threshold %.2f%%, but it has reached the max size %s",
+			used, p.threshold, resource.NewQuantity(current, resource.BinarySI))
+		return nil
+	}
+
+	newSize := resource.NewQuantity(size, resource.BinarySI)
+	patch := map[string]any{
+		"metadata": map[string]any{
+			"resourceVersion": pvc.ResourceVersion,
+			"annotations": map[string]string{
+				AutoExpandLastExpandAnnotation: e.now().UTC().Format(time.RFC3339),
+			},
+		},
+		"spec": map[string]any{
+			"resources": map[string]any{
+				"requests": map[string]string{
+					string(v1.ResourceStorage): newSize.String(),
+				},
+			},
+		},
+	}
+	patchData, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = e.clientSet.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.StrategicMergePatchType, patchData, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch PVC: %w", err)
+	}
+	logger.Info("expanding PVC automatically", "used", used, "from", current, "to", size)
+	e.recorder.Eventf(pvc, v1.EventTypeNormal, autoExpandReason,
+		"PVC used %.2f%%, exceeding threshold %.2f%%, expanding from %s to %s",
+		used, p.threshold, &capacity, newSize)
+	return nil
+}
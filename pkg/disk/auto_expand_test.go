@@ -0,0 +1,343 @@
+package disk
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2/ktesting"
+	k8smount "k8s.io/mount-utils"
+	"k8s.io/utils/ptr"
+)
+
+func TestParseAutoExpandPolicy(t *testing.T) {
+	cases := []struct {
+		name        string
+		params      map[string]string
+		annotations map[string]string
+		expected    *autoExpandPolicy
+		err         bool
+	}{
+		{
+			name: "not enabled",
+		},
+		{
+			name:   "disabled",
+			params: map[string]string{AutoExpandKey: "false", AutoExpandThresholdKey: "90"},
+		},
+		{
+			name:   "defaults",
+			params: map[string]string{AutoExpandKey: "true"},
+			expected: &autoExpandPolicy{
+				threshold: 85, stepPercent: 20, cooldown: 15 * time.Minute,
+			},
+		},
+		{
+			name: "storage class",
+			params: map[string]string{
+				AutoExpandKey: "true", AutoExpandThresholdKey: "90%", AutoExpandStepKey: "10Gi",
+				AutoExpandMaxSizeKey: "1Ti", AutoExpandCooldownKey: "1h",
+			},
+			expected: &autoExpandPolicy{
+				threshold: 90, stepBytes: 10 * GBSIZE, maxBytes: 1024 * GBSIZE, cooldown: time.Hour,
+			},
+		},
+		{
+			name:        "annotations override",
+			params:      map[string]string{AutoExpandKey: "false", AutoExpandStepKey: "10Gi"},
+			annotations: map[string]string{AutoExpandAnnotation: "true", AutoExpandStepAnnotation: "50%"},
+			expected: &autoExpandPolicy{
+				threshold: 85, stepPercent: 50, cooldown: 15 * time.Minute,
+			},
+		},
+		{
+			name:   "invalid enabled",
+			params: map[string]string{AutoExpandKey: "yes"},
+			err:    true,
+		},
+		{
+			name:   "invalid threshold when disabled",
+			params: map[string]string{AutoExpandThresholdKey: "100"},
+			err:    true,
+		},
+		{
+			name:   "invalid step",
+			params: map[string]string{AutoExpandKey: "true", AutoExpandStepKey: "-1Gi"},
+			err:    true,
+		},
+		{
+			name:   "invalid step percentage",
+			params: map[string]string{AutoExpandKey: "true", AutoExpandStepKey: "0%"},
+			err:    true,
+		},
+		{
+			name:        "invalid max size",
+			annotations: map[string]string{AutoExpandMaxSizeAnnotation: "lots"},
+			err:         true,
+		},
+		{
+			name:   "invalid cooldown",
+			params: map[string]string{AutoExpandKey: "true", AutoExpandCooldownKey: "15"},
+			err:    true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := parseAutoExpandPolicy(c.params, c.annotations)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, p)
+		})
+	}
+}
+
+func TestAutoExpandNextSize(t *testing.T) {
+	essd := SizeRange{Min: 20, Max: 65536}
+	cases := []struct {
+		name     string
+		policy   autoExpandPolicy
+		current  int64
+		limit    SizeRange
+		expected int64
+	}{
+		{
+			name:     "percentage rounded up",
+			policy:   autoExpandPolicy{stepPercent: 10},
+			current:  25 * GBSIZE,
+			limit:    essd,
+			expected: 28 * GBSIZE,
+		},
+		{
+			name:     "fixed step",
+			policy:   autoExpandPolicy{stepBytes: 10 * GBSIZE},
+			current:  100 * GBSIZE,
+			limit:    essd,
+			expected: 110 * GBSIZE,
+		},
+		{
+			name:     "minimum size",
+			policy:   autoExpandPolicy{stepBytes: GBSIZE},
+			current:  5 * GBSIZE,
+			expected: MinimumDiskSizeInGB * GBSIZE,
+		},
+		{
+			name:     "category minimum",
+			policy:   autoExpandPolicy{stepBytes: GBSIZE},
+			current:  20 * GBSIZE,
+			limit:    SizeRange{Min: 40, Max: 32768},
+			expected: 40 * GBSIZE,
+		},
+		{
+			name:     "policy max",
+			policy:   autoExpandPolicy{stepPercent: 50, maxBytes: 120 * GBSIZE},
+			current:  100 * GBSIZE,
+			limit:    essd,
+			expected: 120 * GBSIZE,
+		},
+		{
+			name:     "category max",
+			policy:   autoExpandPolicy{stepPercent: 50, maxBytes: 100000 * GBSIZE},
+			current:  60000 * GBSIZE,
+			limit:    essd,
+			expected: 65536 * GBSIZE,
+		},
+		{
+			name:     "max reached",
+			policy:   autoExpandPolicy{stepPercent: 50, maxBytes: 100 * GBSIZE},
+			current:  100 * GBSIZE,
+			limit:    essd,
+			expected: 100 * GBSIZE,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, c.policy.nextSize(c.current, c.limit))
+		})
+	}
+}
+
+func TestAutoExpander(t *testing.T) {
+	now := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		request     string
+		capacity    string
+		conditions  []v1.PersistentVolumeClaimCondition
+		category    string
+		used        int64
+		expected    string
+		event       string
+	}{
+		{
+			name:     "below threshold",
+			used:     80,
+			expected: "100Gi",
+		},
+		{
+			name:     "expand",
+			used:     90,
+			expected: "120Gi",
+			event:    autoExpandReason,
+		},
+		{
+			name:     "resize in flight",
+			request:  "120Gi",
+			capacity: "100Gi",
+			used:     90,
+			expected: "120Gi",
+		},
+		{
+			name:     "filesystem resize pending",
+			used:     90,
+			expected: "100Gi",
+			conditions: []v1.PersistentVolumeClaimCondition{
+				{Type: v1.PersistentVolumeClaimFileSystemResizePending, Status: v1.ConditionTrue},
+			},
+		},
+		{
+			name:        "cooldown",
+			annotations: map[string]string{AutoExpandLastExpandAnnotation: now.Add(-time.Minute).Format(time.RFC3339)},
+			used:        90,
+			expected:    "100Gi",
+		},
+		{
+			name:        "cooldown elapsed",
+			annotations: map[string]string{AutoExpandLastExpandAnnotation: now.Add(-time.Hour).Format(time.RFC3339)},
+			used:        90,
+			expected:    "120Gi",
+			event:       autoExpandReason,
+		},
+		{
+			name:     "category max",
+			category: string(DiskSSD),
+			request:  "65536Gi",
+			used:     90,
+			expected: "65536Gi",
+			event:    autoExpandLimitedReason,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, ctx := ktesting.NewTestContext(t)
+			request := c.request
+			if request == "" {
+				request = "100Gi"
+			}
+			capacity := c.capacity
+			if capacity == "" {
+				capacity = request
+			}
+			category := c.category
+			if category == "" {
+				category = string(DiskESSD)
+			}
+			pvc := &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default", Annotations: c.annotations},
+				Spec: v1.PersistentVolumeClaimSpec{
+					StorageClassName: ptr.To("alicloud-disk-auto-expand"),
+					VolumeName:       "pv-1",
+					Resources: v1.VolumeResourceRequirements{
+						Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(request)},
+					},
+				},
+				Status: v1.PersistentVolumeClaimStatus{
+					Capacity:   v1.ResourceList{v1.ResourceStorage: resource.MustParse(capacity)},
+					Conditions: c.conditions,
+				},
+			}
+			sc := &storagev1.StorageClass{
+				ObjectMeta:           metav1.ObjectMeta{Name: "alicloud-disk-auto-expand"},
+				Parameters:           map[string]string{AutoExpandKey: "true", AutoExpandThresholdKey: "85", AutoExpandCooldownKey: "30m"},
+				AllowVolumeExpansion: ptr.To(true),
+			}
+			pv := &v1.PersistentVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: "pv-1"},
+				Spec: v1.PersistentVolumeSpec{
+					PersistentVolumeSource: v1.PersistentVolumeSource{
+						CSI: &v1.CSIPersistentVolumeSource{
+							Driver:           "diskplugin.csi.alibabacloud.com",
+							VolumeHandle:     "d-1",
+							VolumeAttributes: map[string]string{"type": category},
+						},
+					},
+					ClaimRef: &v1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: "default", Name: "data"},
+				},
+				Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+			}
+			client := fake.NewClientset(pvc, sc, pv)
+			recorder := record.NewFakeRecorder(10)
+			e := newAutoExpander(client, recorder, nil)
+			e.now = func() time.Time { return now }
+
+			e.podsDir = t.TempDir()
+			mountPoint := mountedVolumeDir(t, e.podsDir, "pod-1", "pv-1", "d-1")
+			e.mounter = k8smount.NewFakeMounter([]k8smount.MountPoint{{Path: mountPoint}})
+			e.getMetrics = func(path string) (*csi.NodeGetVolumeStatsResponse, error) {
+				assert.Equal(t, mountPoint, path)
+				return &csi.NodeGetVolumeStatsResponse{Usage: []*csi.VolumeUsage{
+					{Unit: csi.VolumeUsage_BYTES, Total: 100, Used: c.used},
+					{Unit: csi.VolumeUsage_INODES, Total: 100, Used: 1},
+				}}, nil
+			}
+			e.checkAll(ctx)
+
+			pvc, err := client.CoreV1().PersistentVolumeClaims("default").Get(ctx, "data", metav1.GetOptions{})
+			require.NoError(t, err)
+			expected := resource.MustParse(c.expected)
+			actual := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+			assert.Equal(t, expected.Value(), actual.Value())
+			if c.expected != request {
+				assert.Equal(t, now.Format(time.RFC3339), pvc.Annotations[AutoExpandLastExpandAnnotation])
+			}
+
+			select {
+			case event := <-recorder.Events:
+				assert.Contains(t, event, c.event)
+				assert.NotEmpty(t, c.event, "unexpected event: %s", event)
+			default:
+				assert.Empty(t, c.event, "expected event not recorded")
+			}
+		})
+	}
+}
+
+// mountedVolumeDir creates the directory of a volume in a pod as kubelet does, and returns its mount point.
+func mountedVolumeDir(t *testing.T, podsDir, podUID, pvName, volumeHandle string) string {
+	dir := filepath.Join(podsDir, podUID, "volumes", "kubernetes.io~csi", pvName)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "mount"), 0o755))
+	data, err := json.Marshal(map[string]string{"driverName": DriverName, "specVolID": pvName, "volumeHandle": volumeHandle})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, utils.VolDataFileName), data, 0o644))
+	return filepath.Join(dir, "mount")
+}
+
+func TestAutoExpanderMountedVolumes(t *testing.T) {
+	e := newAutoExpander(fake.NewClientset(), record.NewFakeRecorder(10), nil)
+	e.podsDir = t.TempDir()
+	mountPoint := mountedVolumeDir(t, e.podsDir, "pod-1", "pv-1", "d-1")
+	// the same volume in another pod
+	other := mountedVolumeDir(t, e.podsDir, "pod-2", "pv-1", "d-1")
+	// not mounted yet
+	mountedVolumeDir(t, e.podsDir, "pod-2", "pv-2", "d-2")
+	e.mounter = k8smount.NewFakeMounter([]k8smount.MountPoint{{Path: mountPoint}, {Path: other}})
+
+	volumes, err := e.mountedVolumes()
+	require.NoError(t, err)
+	assert.Equal(t, []mountedVolume{{pvName: "pv-1", diskID: "d-1", mountPoint: mountPoint}}, volumes)
+}
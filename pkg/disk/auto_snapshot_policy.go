@@ -0,0 +1,484 @@
+package disk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ecs20140526 "github.com/alibabacloud-go/ecs-20140526/v7/client"
+	"github.com/go-logr/logr"
+	snapClientset "github.com/kubernetes-csi/external-snapshotter/client/v8/clientset/versioned"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud/wrap"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// how often the driver-owned policies not referenced by any volume are garbage-collected
+	autoSnapshotPolicyGCInterval = time.Hour
+	// policies and disks younger than this are left alone by GC:
+	// the policy may be about to be applied, and the PV of the disk may not be created yet.
+	autoSnapshotPolicyGCGracePeriod = time.Hour
+)
+
+// autoSnapshotPolicyParams is the automatic snapshot policy requested in StorageClass parameters.
+type autoSnapshotPolicyParams struct {
+	TimePoints     []int
+	RepeatWeekdays []int
+	// -1 to keep the snapshots forever, 0 if not specified
+	RetentionDays int
+	Tags          map[string]string
+	// retention days and snapshotTags/ not specified in StorageClass are taken from this VolumeSnapshotClass
+	VolumeSnapshotClass string
+}
+
+// parseAutoSnapshotPolicyParams returns nil if no policy is requested.
+func parseAutoSnapshotPolicyParams(params map[string]string) (*autoSnapshotPolicyParams, error) {
+	p := &autoSnapshotPolicyParams{Tags: map[string]string{}}
+	var err error
+	configured := false
+	for k, v := range params {
+		switch k {
+		case AutoSnapshotPolicyTimePointsKey:
+			p.TimePoints, err = parseIntList(v, 0, 23)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", k, v, err)
+			}
+		case AutoSnapshotPolicyRepeatWeekdaysKey:
+			p.RepeatWeekdays, err = parseIntList(v, 1, 7)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", k, v, err)
+			}
+			configured = true
+		case AutoSnapshotPolicyRetentionDaysKey:
+			p.RetentionDays, err = strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", k, v, err)
+			}
+			if p.RetentionDays != -1 && (p.RetentionDays < SNAPSHOT_MIN_RETENTION_DAYS || p.RetentionDays > SNAPSHOT_MAX_RETENTION_DAYS) {
+				return nil, fmt.Errorf("invalid %s %q: should be -1 or in range [%d, %d]", k, v, SNAPSHOT_MIN_RETENTION_DAYS, SNAPSHOT_MAX_RETENTION_DAYS)
+			}
+			configured = true
+		case AutoSnapshotPolicyInstantAccessRetentionDaysKey:
+			if _, err := strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", k, v, err)
+			}
+			// no-op: InstantAccess is no longer needed
+			klog.Warningf("%s is no longer needed, please remove it from parameters", k)
+		case AutoSnapshotPolicyVolumeSnapshotClassKey:
+			p.VolumeSnapshotClass = v
+			configured = true
+		default:
+			if strings.HasPrefix(k, SNAPSHOT_TAG_PREFIX) {
+				p.Tags[k[len(SNAPSHOT_TAG_PREFIX):]] = v
+			}
+		}
+	}
+	if p.TimePoints == nil {
+		if configured {
+			return nil, fmt.Errorf("%s is required for automatic snapshot policy", AutoSnapshotPolicyTimePointsKey)
+		}
+		return nil, nil
+	}
+	if p.RepeatWeekdays == nil {
+		p.RepeatWeekdays = []int{1, 2, 3, 4, 5, 6, 7}
+	}
+	return p, nil
+}
+
+// parseIntList parses comma separated integers in range [min, max], sorted and deduplicated.
+func parseIntList(v string, min, max int) ([]int, error) {
+	var res []int
+	for s := range strings.SplitSeq(v, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		if i < min || i > max {
+			return nil, fmt.Errorf("%d out of range [%d, %d]", i, min, max)
+		}
+		res = append(res, i)
+	}
+	slices.Sort(res)
+	return slices.Compact(res), nil
+}
+
+// hash identifies the policies implementing the same schedule, so that they are shared by the disks.
+func (p *autoSnapshotPolicyParams) hash() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "timePoints=%v\nrepeatWeekdays=%v\nretentionDays=%d\n", p.TimePoints, p.RepeatWeekdays, p.RetentionDays)
+	for _, k := range slices.Sorted(maps.Keys(p.Tags)) {
+		fmt.Fprintf(&b, "tag:%s=%s\n", k, p.Tags[k])
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+func jsonIntList(l []int) *string {
+	b, _ := json.Marshal(l) // never fails
+	return new(string(b))
+}
+
+// autoSnapshotPolicyManager creates automatic snapshot policies for the disks, and deletes them when no longer used.
+// The policies are tagged the same as the disks created by the driver, see autoSnapshotPolicyOwnerTags.
+type autoSnapshotPolicyManager struct {
+	ecs        cloud.ECSv2Interface
+	clientSet  kubernetes.Interface
+	snapClient snapClientset.Interface
+	now        func() time.Time
+
+	// applied is set once a policy is applied by this replica, GC is skipped until the feature is used
+	applied atomic.Bool
+
+	// serialize the creation, avoid duplicated policies from concurrent CreateVolume
+	lock sync.Mutex
+}
+
+func newAutoSnapshotPolicyManager(ecs cloud.ECSv2Interface, clientSet kubernetes.Interface, snapClient snapClientset.Interface) *autoSnapshotPolicyManager {
+	return &autoSnapshotPolicyManager{
+		ecs:        ecs,
+		clientSet:  clientSet,
+		snapClient: snapClient,
+		now:        time.Now,
+	}
+}
+
+func autoSnapshotPolicyOwnerTags() map[string]string {
+	tags := map[string]string{DISKTAGKEY2: DISKTAGVALUE2}
+	if GlobalConfigVar.ClusterID != "" {
+		tags[DISKTAGKEY3] = GlobalConfigVar.ClusterID
+	}
+	return tags
+}
+
+// resolve fills in the parameters from the referenced VolumeSnapshotClass.
+func (m *autoSnapshotPolicyManager) resolve(ctx context.Context, p *autoSnapshotPolicyParams) (*autoSnapshotPolicyParams, error) {
+	resolved := *p
+	resolved.Tags = maps.Clone(p.Tags)
+	if p.VolumeSnapshotClass != "" {
+		vsc, err := m.snapClient.SnapshotV1().VolumeSnapshotClasses().Get(ctx, p.VolumeSnapshotClass, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get VolumeSnapshotClass %s: %w", p.VolumeSnapshotClass, err)
+		}
+		var snapParams createSnapshotParams
+		if err := parseSnapshotParameters(vsc.Parameters, &snapParams); err != nil {
+			return nil, fmt.Errorf("invalid parameters in VolumeSnapshotClass %s: %w", p.VolumeSnapshotClass, err)
+		}
+		if resolved.RetentionDays == 0 {
+			resolved.RetentionDays = snapParams.RetentionDays
+		}
+		for _, t := range snapParams.SnapshotTags {
+			if _, ok := resolved.Tags[t.Key]; !ok {
+				resolved.Tags[t.Key] = t.Value
+			}
+		}
+	}
+	if resolved.RetentionDays == 0 {
+		resolved.RetentionDays = -1 // same as ECS default
+	}
+	return &resolved, nil
+}
+
+// ensure returns the ID of the policy implementing p, creating it if not exists.
+func (m *autoSnapshotPolicyManager) ensure(logger logr.Logger, p *autoSnapshotPolicyParams) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	hash := p.hash()
+	ownerTags := autoSnapshotPolicyOwnerTags()
+	describeReq := &ecs20140526.DescribeAutoSnapshotPolicyExRequest{
+		RegionId: &GlobalConfigVar.Region,
+		Tag:      []*ecs20140526.DescribeAutoSnapshotPolicyExRequestTag{{Key: new(AutoSnapshotPolicyHashTag), Value: &hash}},
+	}
+	for k, v := range ownerTags {
+		describeReq.Tag = append(describeReq.Tag, &ecs20140526.DescribeAutoSnapshotPolicyExRequestTag{Key: &k, Value: &v})
+	}
+	resp, err := wrap.V2(logger, m.ecs.DescribeAutoSnapshotPolicyEx)(describeReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to describe auto snapshot policies: %w", err)
+	}
+	if policies := resp.Body.AutoSnapshotPolicies; policies != nil {
+		for _, policy := range policies.AutoSnapshotPolicy {
+			// The tag filter also matches the policies of other clusters if ClusterID is empty
+			if policyOwnedByCluster(policy) {
+				return *policy.AutoSnapshotPolicyId, nil
+			}
+		}
+	}
+
+	createReq := &ecs20140526.CreateAutoSnapshotPolicyRequest{
+		RegionId:               &GlobalConfigVar.Region,
+		AutoSnapshotPolicyName: new("csi-auto-snapshot-" + hash),
+		TimePoints:             jsonIntList(p.TimePoints),
+		RepeatWeekdays:         jsonIntList(p.RepeatWeekdays),
+		RetentionDays:          new(int32(p.RetentionDays)),
+	}
+	tags := maps.Clone(p.Tags)
+	maps.Copy(tags, ownerTags) // don't override built-in tags
+	tags[AutoSnapshotPolicyHashTag] = hash
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		createReq.Tag = append(createReq.Tag, &ecs20140526.CreateAutoSnapshotPolicyRequestTag{Key: new(k), Value: new(tags[k])})
+	}
+	createResp, err := wrap.V2(logger, m.ecs.CreateAutoSnapshotPolicy)(createReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to create auto snapshot policy: %w", err)
+	}
+	logger.Info("created auto snapshot policy", "policyID", *createResp.Body.AutoSnapshotPolicyId)
+	return *createResp.Body.AutoSnapshotPolicyId, nil
+}
+
+// apply applies the policy described by p to the disk.
+func (m *autoSnapshotPolicyManager) apply(ctx context.Context, diskID string, p *autoSnapshotPolicyParams) error {
+	logger := klog.FromContext(ctx).WithValues("diskID", diskID)
+	resolved, err := m.resolve(ctx, p)
+	if err != nil {
+		return err
+	}
+	policyID, err := m.ensure(logger, resolved)
+	if err != nil {
+		return err
+	}
+	_, err = wrap.V2(logger, m.ecs.ApplyAutoSnapshotPolicy)(&ecs20140526.ApplyAutoSnapshotPolicyRequest{
+		RegionId:             &GlobalConfigVar.Region,
+		AutoSnapshotPolicyId: &policyID,
+		DiskIds:              new(fmt.Sprintf("[%q]", diskID)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply auto snapshot policy %s: %w", policyID, err)
+	}
+	m.applied.Store(true)
+	logger.V(2).Info("applied auto snapshot policy", "policyID", policyID)
+	return nil
+}
+
+// run garbage-collects the policies periodically until ctx is done.
+// It is run by the leader replica only, see runAsLeader.
+func (m *autoSnapshotPolicyManager) run(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	if GlobalConfigVar.ClusterID == "" {
+		// The policies of other clusters in the same account can not be told apart
+		logger.Info("CLUSTER_ID not set, auto snapshot policies will not be garbage-collected")
+		return
+	}
+	ticker := time.NewTicker(autoSnapshotPolicyGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			inUse, err := m.inUse(ctx)
+			if err != nil {
+				logger.Error(err, "failed to check whether auto snapshot policies are used")
+				continue
+			}
+			if !inUse {
+				continue
+			}
+			if err := m.gc(ctx); err != nil {
+				logger.Error(err, "failed to garbage-collect auto snapshot policies")
+			}
+		}
+	}
+}
+
+// inUse reports whether automatic snapshot policies are applied by this replica or requested by any StorageClass.
+func (m *autoSnapshotPolicyManager) inUse(ctx context.Context) (bool, error) {
+	if m.applied.Load() {
+		return true, nil
+	}
+	scs, err := m.clientSet.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to list StorageClasses: %w", err)
+	}
+	for _, sc := range scs.Items {
+		if sc.Provisioner == DriverName && sc.Parameters[AutoSnapshotPolicyTimePointsKey] != "" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// gc detaches the driver-owned policies from the disks whose PV is deleted (e.g. retained disks),
+// then deletes the policies not applied to any disk.
+func (m *autoSnapshotPolicyManager) gc(ctx context.Context) error {
+	logger := klog.FromContext(ctx)
+	if GlobalConfigVar.ClusterID == "" {
+		return errors.New("CLUSTER_ID not set, can not tell the policies of this cluster")
+	}
+	req := &ecs20140526.DescribeAutoSnapshotPolicyExRequest{
+		RegionId:   &GlobalConfigVar.Region,
+		PageSize:   new(int32(100)),
+		PageNumber: new(int32(1)),
+	}
+	for k, v := range autoSnapshotPolicyOwnerTags() {
+		req.Tag = append(req.Tag, &ecs20140526.DescribeAutoSnapshotPolicyExRequestTag{Key: &k, Value: &v})
+	}
+	var policies []*ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPoliciesAutoSnapshotPolicy
+	for {
+		resp, err := wrap.V2(logger, m.ecs.DescribeAutoSnapshotPolicyEx)(req)
+		if err != nil {
+			return fmt.Errorf("failed to describe auto snapshot policies: %w", err)
+		}
+		if resp.Body.AutoSnapshotPolicies == nil || len(resp.Body.AutoSnapshotPolicies.AutoSnapshotPolicy) == 0 {
+			break
+		}
+		policies = append(policies, resp.Body.AutoSnapshotPolicies.AutoSnapshotPolicy...)
+		if int32(len(policies)) >= *resp.Body.TotalCount {
+			break
+		}
+		*req.PageNumber++
+	}
+
+	for _, policy := range policies {
+		if !policyOwnedByCluster(policy) {
+			continue
+		}
+		l := logger.WithValues("policyID", *policy.AutoSnapshotPolicyId)
+		if err := m.gcPolicy(ctx, l, policy); err != nil {
+			l.Error(err, "failed to garbage-collect auto snapshot policy")
+		}
+	}
+	return nil
+}
+
+// policyOwnedByCluster reports whether the policy is created by the driver in this cluster.
+// The cluster tag must match exactly: absent if ClusterID is empty.
+func policyOwnedByCluster(policy *ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPoliciesAutoSnapshotPolicy) bool {
+	if policy.Tags == nil {
+		return false
+	}
+	createdByDriver := false
+	clusterID := ""
+	for _, t := range policy.Tags.Tag {
+		if t.TagKey == nil || t.TagValue == nil {
+			continue
+		}
+		switch *t.TagKey {
+		case AutoSnapshotPolicyHashTag:
+			createdByDriver = true
+		case DISKTAGKEY3:
+			clusterID = *t.TagValue
+		}
+	}
+	return createdByDriver && clusterID == GlobalConfigVar.ClusterID
+}
+
+func (m *autoSnapshotPolicyManager) gcPolicy(ctx context.Context, logger logr.Logger, policy *ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPoliciesAutoSnapshotPolicy) error {
+	policyID := *policy.AutoSnapshotPolicyId
+	remaining := 0
+	if policy.DiskNums != nil && *policy.DiskNums > 0 {
+		orphans, total, err := m.orphanDisks(ctx, logger, policyID)
+		if err != nil {
+			return err
+		}
+		if len(orphans) > 0 {
+			ids, _ := json.Marshal(orphans) // never fails
+			_, err := wrap.V2(logger, m.ecs.CancelAutoSnapshotPolicy)(&ecs20140526.CancelAutoSnapshotPolicyRequest{
+				RegionId:             &GlobalConfigVar.Region,
+				AutoSnapshotPolicyId: &policyID,
+				DiskIds:              new(string(ids)),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to cancel auto snapshot policy from disks %v: %w", orphans, err)
+			}
+			logger.Info("cancelled auto snapshot policy from disks without PV", "diskIDs", orphans)
+		}
+		remaining = total - len(orphans)
+	}
+	if remaining > 0 || !m.olderThanGracePeriod(policy.CreationTime) {
+		return nil
+	}
+	_, err := wrap.V2(logger, m.ecs.DeleteAutoSnapshotPolicy)(&ecs20140526.DeleteAutoSnapshotPolicyRequest{
+		RegionId:             &GlobalConfigVar.Region,
+		AutoSnapshotPolicyId: &policyID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete auto snapshot policy: %w", err)
+	}
+	logger.Info("deleted unused auto snapshot policy")
+	return nil
+}
+
+// orphanDisks returns the disks created by the driver whose PV no longer exists, and the total number of disks the policy is applied to.
+func (m *autoSnapshotPolicyManager) orphanDisks(ctx context.Context, logger logr.Logger, policyID string) ([]string, int, error) {
+	req := &ecs20140526.DescribeDisksRequest{
+		RegionId:             &GlobalConfigVar.Region,
+		AutoSnapshotPolicyId: &policyID,
+		MaxResults:           new(int32(100)),
+	}
+	var orphans []string
+	total := 0
+	for {
+		resp, err := wrap.V2(logger, m.ecs.DescribeDisks)(req)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to describe disks: %w", err)
+		}
+		if resp.Body.Disks == nil {
+			break
+		}
+		for _, disk := range resp.Body.Disks.Disk {
+			total++
+			pvName := diskTagValue(disk, common.VolumeNameTag)
+			if pvName == "" || !m.olderThanGracePeriod(disk.CreationTime) {
+				continue // not created by the driver, or PV may not be created yet
+			}
+			_, err := m.clientSet.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+			if err == nil {
+				continue
+			}
+			if !apierrors.IsNotFound(err) {
+				return nil, 0, fmt.Errorf("failed to get PV %s: %w", pvName, err)
+			}
+			orphans = append(orphans, *disk.DiskId)
+		}
+		if resp.Body.NextToken == nil || *resp.Body.NextToken == "" {
+			break
+		}
+		req.NextToken = resp.Body.NextToken
+	}
+	return orphans, total, nil
+}
+
+func diskTagValue(disk *ecs20140526.DescribeDisksResponseBodyDisksDisk, key string) string {
+	if disk.Tags == nil {
+		return ""
+	}
+	for _, t := range disk.Tags.Tag {
+		if t.TagKey != nil && *t.TagKey == key && t.TagValue != nil {
+			return *t.TagValue
+		}
+	}
+	return ""
+}
+
+// olderThanGracePeriod returns false if the creation time is unknown.
+func (m *autoSnapshotPolicyManager) olderThanGracePeriod(creationTime *string) bool {
+	if creationTime == nil {
+		return false
+	}
+	var t time.Time
+	var err error
+	// ECS returns both with and without seconds
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00"} {
+		t, err = time.Parse(layout, *creationTime)
+		if err == nil {
+			return m.now().Sub(t) > autoSnapshotPolicyGCGracePeriod
+		}
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package disk
+
+import (
+	"testing"
+	"time"
+
+	ecs20140526 "github.com/alibabacloud-go/ecs-20140526/v7/client"
+	"github.com/golang/mock/gomock"
+	volumesnapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	snapfake "github.com/kubernetes-csi/external-snapshotter/client/v8/clientset/versioned/fake"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func TestParseAutoSnapshotPolicyParams(t *testing.T) {
+	cases := []struct {
+		name     string
+		params   map[string]string
+		expected *autoSnapshotPolicyParams
+		err      bool
+	}{
+		{
+			name:   "not requested",
+			params: map[string]string{"type": "cloud_essd", "snapshotTags/env": "prod"},
+		},
+		{
+			name:   "defaults",
+			params: map[string]string{AutoSnapshotPolicyTimePointsKey: "14, 2,2"},
+			expected: &autoSnapshotPolicyParams{
+				TimePoints:     []int{2, 14},
+				RepeatWeekdays: []int{1, 2, 3, 4, 5, 6, 7},
+				Tags:           map[string]string{},
+			},
+		},
+		{
+			name: "full",
+			params: map[string]string{
+				AutoSnapshotPolicyTimePointsKey:                 "0",
+				AutoSnapshotPolicyRepeatWeekdaysKey:             "7,1",
+				AutoSnapshotPolicyRetentionDaysKey:              "30",
+				AutoSnapshotPolicyInstantAccessRetentionDaysKey: "1",
+				AutoSnapshotPolicyVolumeSnapshotClassKey:        "alicloud-disk-snapshot",
+				"snapshotTags/env":                              "prod",
+			},
+			expected: &autoSnapshotPolicyParams{
+				TimePoints:          []int{0},
+				RepeatWeekdays:      []int{1, 7},
+				RetentionDays:       30,
+				Tags:                map[string]string{"env": "prod"},
+				VolumeSnapshotClass: "alicloud-disk-snapshot",
+			},
+		},
+		{
+			name:   "missing time points",
+			params: map[string]string{AutoSnapshotPolicyRetentionDaysKey: "30"},
+			err:    true,
+		},
+		{
+			name:   "invalid time point",
+			params: map[string]string{AutoSnapshotPolicyTimePointsKey: "24"},
+			err:    true,
+		},
+		{
+			name:   "invalid weekday",
+			params: map[string]string{AutoSnapshotPolicyTimePointsKey: "2", AutoSnapshotPolicyRepeatWeekdaysKey: "0"},
+			err:    true,
+		},
+		{
+			name:   "invalid retention days",
+			params: map[string]string{AutoSnapshotPolicyTimePointsKey: "2", AutoSnapshotPolicyRetentionDaysKey: "0"},
+			err:    true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := parseAutoSnapshotPolicyParams(c.params)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, p)
+		})
+	}
+}
+
+func TestAutoSnapshotPolicyHash(t *testing.T) {
+	p := autoSnapshotPolicyParams{TimePoints: []int{2}, RepeatWeekdays: []int{1}, RetentionDays: 7}
+	q := p
+	assert.Equal(t, p.hash(), q.hash())
+	q.RetentionDays = 8
+	assert.NotEqual(t, p.hash(), q.hash())
+	q = p
+	q.Tags = map[string]string{"env": "prod"}
+	assert.NotEqual(t, p.hash(), q.hash())
+}
+
+func newTestAutoSnapshotPolicyManager(t *testing.T, objects ...*volumesnapshotv1.VolumeSnapshotClass) (*cloud.MockECSv2Interface, *fake.Clientset, *autoSnapshotPolicyManager) {
+	ctrl := gomock.NewController(t)
+	c := cloud.NewMockECSv2Interface(ctrl)
+	client := fake.NewClientset()
+	snapClient := snapfake.NewSimpleClientset()
+	for _, o := range objects {
+		require.NoError(t, snapClient.Tracker().Add(o))
+	}
+	return c, client, newAutoSnapshotPolicyManager(c, client, snapClient)
+}
+
+func TestAutoSnapshotPolicyApply_Create(t *testing.T) {
+	vsc := &volumesnapshotv1.VolumeSnapshotClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "alicloud-disk-snapshot"},
+		Driver:     "diskplugin.csi.alibabacloud.com",
+		Parameters: map[string]string{RETENTIONDAYS: "7", "snapshotTags/env": "test", "snapshotTags/team": "storage"},
+	}
+	c, _, m := newTestAutoSnapshotPolicyManager(t, vsc)
+	_, ctx := ktesting.NewTestContext(t)
+	p := &autoSnapshotPolicyParams{
+		TimePoints:          []int{2, 14},
+		RepeatWeekdays:      []int{1, 2, 3, 4, 5, 6, 7},
+		Tags:                map[string]string{"env": "prod"},
+		VolumeSnapshotClass: "alicloud-disk-snapshot",
+	}
+	resolved := autoSnapshotPolicyParams{
+		TimePoints:     p.TimePoints,
+		RepeatWeekdays: p.RepeatWeekdays,
+		RetentionDays:  7,
+		Tags:           map[string]string{"env": "prod", "team": "storage"},
+	}
+	hash := resolved.hash()
+
+	c.EXPECT().DescribeAutoSnapshotPolicyEx(gomock.Any()).DoAndReturn(func(req *ecs20140526.DescribeAutoSnapshotPolicyExRequest) (*ecs20140526.DescribeAutoSnapshotPolicyExResponse, error) {
+		assert.Contains(t, req.Tag, &ecs20140526.DescribeAutoSnapshotPolicyExRequestTag{Key: new(AutoSnapshotPolicyHashTag), Value: &hash})
+		return &ecs20140526.DescribeAutoSnapshotPolicyExResponse{Body: &ecs20140526.DescribeAutoSnapshotPolicyExResponseBody{}}, nil
+	})
+	c.EXPECT().CreateAutoSnapshotPolicy(gomock.Any()).DoAndReturn(func(req *ecs20140526.CreateAutoSnapshotPolicyRequest) (*ecs20140526.CreateAutoSnapshotPolicyResponse, error) {
+		assert.Equal(t, "[2,14]", *req.TimePoints)
+		assert.Equal(t, "[1,2,3,4,5,6,7]", *req.RepeatWeekdays)
+		assert.Equal(t, int32(7), *req.RetentionDays)
+		assert.Contains(t, req.Tag, &ecs20140526.CreateAutoSnapshotPolicyRequestTag{Key: new("env"), Value: new("prod")})
+		assert.Contains(t, req.Tag, &ecs20140526.CreateAutoSnapshotPolicyRequestTag{Key: new("team"), Value: new("storage")})
+		assert.Contains(t, req.Tag, &ecs20140526.CreateAutoSnapshotPolicyRequestTag{Key: new(DISKTAGKEY2), Value: new(DISKTAGVALUE2)})
+		assert.Contains(t, req.Tag, &ecs20140526.CreateAutoSnapshotPolicyRequestTag{Key: new(AutoSnapshotPolicyHashTag), Value: &hash})
+		return &ecs20140526.CreateAutoSnapshotPolicyResponse{Body: &ecs20140526.CreateAutoSnapshotPolicyResponseBody{
+			AutoSnapshotPolicyId: new("sp-new"),
+		}}, nil
+	})
+	c.EXPECT().ApplyAutoSnapshotPolicy(&ecs20140526.ApplyAutoSnapshotPolicyRequest{
+		RegionId:             &GlobalConfigVar.Region,
+		AutoSnapshotPolicyId: new("sp-new"),
+		DiskIds:              new(`["d-test"]`),
+	}).Return(&ecs20140526.ApplyAutoSnapshotPolicyResponse{}, nil)
+
+	require.NoError(t, m.apply(ctx, "d-test", p))
+}
+
+func TestAutoSnapshotPolicyApply_Reuse(t *testing.T) {
+	withClusterID(t, "")
+	c, _, m := newTestAutoSnapshotPolicyManager(t)
+	_, ctx := ktesting.NewTestContext(t)
+
+	c.EXPECT().DescribeAutoSnapshotPolicyEx(gomock.Any()).Return(&ecs20140526.DescribeAutoSnapshotPolicyExResponse{
+		Body: &ecs20140526.DescribeAutoSnapshotPolicyExResponseBody{
+			AutoSnapshotPolicies: &ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPolicies{
+				AutoSnapshotPolicy: []*ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPoliciesAutoSnapshotPolicy{
+					// matched by the tag filter, but owned by a cluster while this one has no ClusterID
+					{AutoSnapshotPolicyId: new("sp-other-cluster"), Tags: policyTags("c-other")},
+					{AutoSnapshotPolicyId: new("sp-existing"), Tags: policyTags("")},
+				},
+			},
+		},
+	}, nil)
+	c.EXPECT().ApplyAutoSnapshotPolicy(gomock.Any()).DoAndReturn(func(req *ecs20140526.ApplyAutoSnapshotPolicyRequest) (*ecs20140526.ApplyAutoSnapshotPolicyResponse, error) {
+		assert.Equal(t, "sp-existing", *req.AutoSnapshotPolicyId)
+		return &ecs20140526.ApplyAutoSnapshotPolicyResponse{}, nil
+	})
+
+	require.NoError(t, m.apply(ctx, "d-test", &autoSnapshotPolicyParams{TimePoints: []int{2}, RepeatWeekdays: []int{1}}))
+}
+
+// policyTags are the tags of a policy created by the driver in clusterID.
+func policyTags(clusterID string) *ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPoliciesAutoSnapshotPolicyTags {
+	tags := &ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPoliciesAutoSnapshotPolicyTags{
+		Tag: []*ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPoliciesAutoSnapshotPolicyTagsTag{
+			{TagKey: new(DISKTAGKEY2), TagValue: new(DISKTAGVALUE2)},
+			{TagKey: new(AutoSnapshotPolicyHashTag), TagValue: new("0123456789abcdef")},
+		},
+	}
+	if clusterID != "" {
+		tags.Tag = append(tags.Tag, &ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPoliciesAutoSnapshotPolicyTagsTag{
+			TagKey: new(DISKTAGKEY3), TagValue: new(clusterID),
+		})
+	}
+	return tags
+}
+
+func withClusterID(t *testing.T, clusterID string) {
+	prev := GlobalConfigVar.ClusterID
+	GlobalConfigVar.ClusterID = clusterID
+	t.Cleanup(func() { GlobalConfigVar.ClusterID = prev })
+}
+
+func TestAutoSnapshotPolicyGC_NoClusterID(t *testing.T) {
+	withClusterID(t, "")
+	_, _, m := newTestAutoSnapshotPolicyManager(t)
+	_, ctx := ktesting.NewTestContext(t)
+	// no ECS call expected
+	assert.ErrorContains(t, m.gc(ctx), "CLUSTER_ID not set")
+}
+
+func TestAutoSnapshotPolicyInUse(t *testing.T) {
+	_, client, m := newTestAutoSnapshotPolicyManager(t)
+	_, ctx := ktesting.NewTestContext(t)
+
+	inUse, err := m.inUse(ctx)
+	require.NoError(t, err)
+	assert.False(t, inUse)
+
+	_, err = client.StorageV1().StorageClasses().Create(ctx, &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: "auto-snapshot"},
+		Provisioner: DriverName,
+		Parameters:  map[string]string{AutoSnapshotPolicyTimePointsKey: "2"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	inUse, err = m.inUse(ctx)
+	require.NoError(t, err)
+	assert.True(t, inUse)
+}
+
+func TestAutoSnapshotPolicyGC(t *testing.T) {
+	withClusterID(t, "c-test")
+	c, client, m := newTestAutoSnapshotPolicyManager(t)
+	_, ctx := ktesting.NewTestContext(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	old := new("2025-12-31T08:00Z")
+	recent := new(now.Add(-time.Minute).Format(time.RFC3339))
+	hashTag := policyTags("c-test")
+	_, err := client.CoreV1().PersistentVolumes().Create(ctx, &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-alive"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	c.EXPECT().DescribeAutoSnapshotPolicyEx(gomock.Any()).Return(&ecs20140526.DescribeAutoSnapshotPolicyExResponse{
+		Body: &ecs20140526.DescribeAutoSnapshotPolicyExResponseBody{
+			TotalCount: new(int32(6)),
+			AutoSnapshotPolicies: &ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPolicies{
+				AutoSnapshotPolicy: []*ecs20140526.DescribeAutoSnapshotPolicyExResponseBodyAutoSnapshotPoliciesAutoSnapshotPolicy{
+					// unused, deleted
+					{AutoSnapshotPolicyId: new("sp-unused"), CreationTime: old, DiskNums: new(int32(0)), Tags: hashTag},
+					// just created, may be about to be applied
+					{AutoSnapshotPolicyId: new("sp-recent"), CreationTime: recent, DiskNums: new(int32(0)), Tags: hashTag},
+					// not created by the driver, only tagged the same
+					{AutoSnapshotPolicyId: new("sp-user"), CreationTime: old, DiskNums: new(int32(0))},
+					// created by the driver in another cluster of the same account
+					{AutoSnapshotPolicyId: new("sp-other-cluster"), CreationTime: old, DiskNums: new(int32(1)), Tags: policyTags("c-other")},
+					// only applied to a disk whose PV is deleted, cancelled and deleted
+					{AutoSnapshotPolicyId: new("sp-orphan"), CreationTime: old, DiskNums: new(int32(1)), Tags: hashTag},
+					// still in use
+					{AutoSnapshotPolicyId: new("sp-used"), CreationTime: old, DiskNums: new(int32(2)), Tags: hashTag},
+				},
+			},
+		},
+	}, nil)
+
+	disk := func(id, pv string, creationTime *string) *ecs20140526.DescribeDisksResponseBodyDisksDisk {
+		return &ecs20140526.DescribeDisksResponseBodyDisksDisk{
+			DiskId:       new(id),
+			CreationTime: creationTime,
+			Tags: &ecs20140526.DescribeDisksResponseBodyDisksDiskTags{
+				Tag: []*ecs20140526.DescribeDisksResponseBodyDisksDiskTagsTag{
+					{TagKey: new(common.VolumeNameTag), TagValue: new(pv)},
+				},
+			},
+		}
+	}
+	c.EXPECT().DescribeDisks(gomock.Any()).DoAndReturn(func(req *ecs20140526.DescribeDisksRequest) (*ecs20140526.DescribeDisksResponse, error) {
+		var disks []*ecs20140526.DescribeDisksResponseBodyDisksDisk
+		switch *req.AutoSnapshotPolicyId {
+		case "sp-orphan":
+			disks = append(disks, disk("d-orphan", "pv-deleted", old))
+		case "sp-used":
+			disks = append(disks, disk("d-alive", "pv-alive", old), disk("d-new", "pv-not-created", recent))
+		default:
+			t.Fatalf("unexpected DescribeDisks for %s", *req.AutoSnapshotPolicyId)
+		}
+		return &ecs20140526.DescribeDisksResponse{Body: &ecs20140526.DescribeDisksResponseBody{
+			Disks: &ecs20140526.DescribeDisksResponseBodyDisks{Disk: disks},
+		}}, nil
+	}).Times(2)
+	c.EXPECT().CancelAutoSnapshotPolicy(&ecs20140526.CancelAutoSnapshotPolicyRequest{
+		RegionId:             &GlobalConfigVar.Region,
+		AutoSnapshotPolicyId: new("sp-orphan"),
+		DiskIds:              new(`["d-orphan"]`),
+	}).Return(&ecs20140526.CancelAutoSnapshotPolicyResponse{}, nil)
+	var deleted []string
+	c.EXPECT().DeleteAutoSnapshotPolicy(gomock.Any()).DoAndReturn(func(req *ecs20140526.DeleteAutoSnapshotPolicyRequest) (*ecs20140526.DeleteAutoSnapshotPolicyResponse, error) {
+		deleted = append(deleted, *req.AutoSnapshotPolicyId)
+		return &ecs20140526.DeleteAutoSnapshotPolicyResponse{}, nil
+	}).Times(2)
+
+	require.NoError(t, m.gc(ctx))
+	assert.Equal(t, []string{"sp-unused", "sp-orphan"}, deleted)
+}
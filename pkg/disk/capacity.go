@@ -0,0 +1,114 @@
+package disk
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"time"
+
+	ecs20140526 "github.com/alibabacloud-go/ecs-20140526/v7/client"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+// diskStockCacheTTL is how long we trust a DescribeAvailableResource result.
+// external-provisioner polls GetCapacity for every topology segment and StorageClass,
+// most of them share the same zone.
+const diskStockCacheTTL = 5 * time.Minute
+
+// diskStock is the disk categories that can be created in a zone (or region), with the size range in GiB
+type diskStock map[Category]SizeRange
+
+func parseDiskStock(resp *ecs20140526.DescribeAvailableResourceResponse, zoneID string) diskStock {
+	stock := diskStock{}
+	for supported := range supportedDiskResources(resp, zoneID) {
+		// Status is SoldOut when there is no stock
+		if ptr.Deref(supported.Status, "") != "Available" {
+			continue
+		}
+		stock[Category(*supported.Value)] = SizeRange{
+			Min: int64(ptr.Deref(supported.Min, 0)),
+			Max: int64(ptr.Deref(supported.Max, 0)),
+		}
+	}
+	return stock
+}
+
+// getDiskStock queries ECS for the disk categories in stock in zoneID.
+// Empty zoneID queries the regional disk categories.
+func getDiskStock(ctx context.Context, c cloud.ECSv2Interface, regionID, zoneID string) (diskStock, error) {
+	req := &ecs20140526.DescribeAvailableResourceRequest{
+		DestinationResource: new(describeResourceType),
+		ResourceType:        new("disk"),
+		RegionId:            &regionID,
+	}
+	if zoneID == "" {
+		req.Scope = new("region")
+	} else {
+		req.ZoneId = &zoneID
+	}
+	resp, err := c.DescribeAvailableResource(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to DescribeAvailableResource(%q): %w", zoneID, err)
+	}
+	klog.FromContext(ctx).V(4).Info("DescribeAvailableResource", "zoneID", zoneID, "response", resp)
+	return parseDiskStock(resp, zoneID), nil
+}
+
+// GetCapacity reports the maximum disk size that can be created with the StorageClass parameters in the requested zone.
+// Zero capacity is reported if all the requested categories are sold out.
+func (cs *controllerServer) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
+	logger := klog.FromContext(ctx)
+
+	categories, err := validateDiskType(req.Parameters)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	pls, err := validateDiskPerformanceLevel(req.Parameters)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	segments := req.GetAccessibleTopology().GetSegments()
+	zoneID := cmp.Or(segments[TopologyZoneKey], segments[v1.LabelTopologyZone])
+
+	for _, attempt := range generateCreateAttempts(&diskVolumeArgs{Type: categories, PerformanceLevel: pls}) {
+		scope := zoneID
+		if AllCategories[attempt.Category].Regional {
+			scope = ""
+		} else if zoneID == "" {
+			// zonal disks always need a zone
+			continue
+		}
+		stock, err := cs.diskStock.Get(ctx, scope, func() (diskStock, error) {
+			return getDiskStock(ctx, cs.ecsV2, GlobalConfigVar.Region, scope)
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "get disk stock failed: %v", err)
+		}
+		size, ok := stock[attempt.Category]
+		if !ok {
+			logger.V(4).Info("disk category not in stock", "zoneID", scope, "category", attempt)
+			continue
+		}
+		limit := GetSizeRange(attempt.Category, attempt.PerformanceLevel)
+		maxSize := size.Max
+		if limit.Max > 0 && (maxSize == 0 || limit.Max < maxSize) {
+			maxSize = limit.Max
+		}
+		minSize := max(size.Min, limit.Min)
+		logger.V(4).Info("disk category in stock", "zoneID", scope, "category", attempt, "maxGiB", maxSize)
+		return &csi.GetCapacityResponse{
+			AvailableCapacity: utils.Gi2Bytes(maxSize),
+			MaximumVolumeSize: wrapperspb.Int64(utils.Gi2Bytes(maxSize)),
+			MinimumVolumeSize: wrapperspb.Int64(utils.Gi2Bytes(minSize)),
+		}, nil
+	}
+	return &csi.GetCapacityResponse{AvailableCapacity: 0}, nil
+}
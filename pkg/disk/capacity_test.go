@@ -0,0 +1,144 @@
+//go:build !windows
+
+package disk
+
+import (
+	"testing"
+
+	ecs20140526 "github.com/alibabacloud-go/ecs-20140526/v7/client"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/ttlcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2/ktesting"
+	"k8s.io/utils/ptr"
+)
+
+const stockJson = `{
+	"AvailableZones": {
+		"AvailableZone": [
+			{
+				"Status": "Available",
+				"ZoneId": "cn-beijing-g",
+				"AvailableResources": {
+					"AvailableResource": [
+						{
+							"Type": "DataDisk",
+							"SupportedResources": {
+								"SupportedResource": [
+									{
+										"Status": "SoldOut",
+										"Min": 20,
+										"Max": 32768,
+										"Value": "cloud_ssd",
+										"Unit": "GiB"
+									},
+									{
+										"Status": "Available",
+										"Min": 20,
+										"Max": 32768,
+										"Value": "cloud_efficiency",
+										"Unit": "GiB"
+									},
+									{
+										"Status": "Available",
+										"Min": 1,
+										"Max": 65536,
+										"Value": "cloud_essd",
+										"Unit": "GiB"
+									}
+								]
+							}
+						}
+					]
+				},
+				"RegionId": "cn-beijing"
+			}
+		]
+	}
+}`
+
+func TestParseDiskStock(t *testing.T) {
+	var resp ecs20140526.DescribeAvailableResourceResponse
+	cloud.UnmarshalV2Response([]byte(stockJson), &resp)
+
+	assert.Equal(t, diskStock{
+		DiskEfficiency: {Min: 20, Max: 32768},
+		DiskESSD:       {Min: 1, Max: 65536},
+	}, parseDiskStock(&resp, "cn-beijing-g"))
+	assert.Empty(t, parseDiskStock(&resp, "cn-beijing-h"))
+}
+
+func TestGetCapacity(t *testing.T) {
+	cases := []struct {
+		name     string
+		params   map[string]string
+		zone     string
+		code     codes.Code
+		capacity int64
+		minSize  int64
+	}{
+		{
+			name:     "sold out fallback",
+			params:   map[string]string{"type": "cloud_ssd,cloud_efficiency"},
+			zone:     "cn-beijing-g",
+			capacity: 32768,
+			minSize:  20,
+		},
+		{
+			name:     "performance level limit",
+			params:   map[string]string{"type": "cloud_essd", "performanceLevel": "PL2"},
+			zone:     "cn-beijing-g",
+			capacity: 65536,
+			minSize:  461,
+		},
+		{
+			name:   "all sold out",
+			params: map[string]string{"type": "cloud_ssd"},
+			zone:   "cn-beijing-g",
+		},
+		{
+			name:   "no zone",
+			params: map[string]string{"type": "cloud_essd"},
+		},
+		{
+			name:   "invalid type",
+			params: map[string]string{"type": "cloud_unknown"},
+			code:   codes.InvalidArgument,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, ctx := ktesting.NewTestContext(t)
+			ctrl := gomock.NewController(t)
+			ecsV2 := cloud.NewMockECSv2Interface(ctrl)
+			ecsV2.EXPECT().DescribeAvailableResource(gomock.Any()).DoAndReturn(func(req *ecs20140526.DescribeAvailableResourceRequest) (*ecs20140526.DescribeAvailableResourceResponse, error) {
+				assert.Equal(t, c.zone, ptr.Deref(req.ZoneId, ""))
+				var resp ecs20140526.DescribeAvailableResourceResponse
+				cloud.UnmarshalV2Response([]byte(stockJson), &resp)
+				return &resp, nil
+			}).MaxTimes(1)
+
+			cs := &controllerServer{ecsV2: ecsV2, diskStock: ttlcache.NewTTLCache[string, diskStock](diskStockCacheTTL)}
+			req := &csi.GetCapacityRequest{Parameters: c.params}
+			if c.zone != "" {
+				req.AccessibleTopology = &csi.Topology{Segments: map[string]string{TopologyZoneKey: c.zone}}
+			}
+			resp, err := cs.GetCapacity(ctx, req)
+			if c.code != codes.OK {
+				assert.Equal(t, c.code, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.capacity*GBSIZE, resp.AvailableCapacity)
+			if c.capacity > 0 {
+				assert.Equal(t, c.capacity*GBSIZE, resp.MaximumVolumeSize.GetValue())
+				assert.Equal(t, c.minSize*GBSIZE, resp.MinimumVolumeSize.GetValue())
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package disk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	alicloudErr "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/waitstatus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// cloneTargetTagKey is tagged on the intermediate snapshot taken for cloning a disk.
+	// Its value is the name of the volume being created, so a retried CreateVolume
+	// (e.g. after controller restart) reuses the same snapshot.
+	cloneTargetTagKey = "csi.alibabacloud.com/clone-target"
+	// cloneSnapshotRetentionDays makes ECS delete the intermediate snapshot automatically
+	// in case we never get a chance to clean it up.
+	cloneSnapshotRetentionDays = 1
+)
+
+func cloneSnapshotName(volumeName string) string {
+	return "clone-" + volumeName
+}
+
+// findCloneSnapshot finds the intermediate snapshot of sourceDiskID taken for volumeName.
+// Failed snapshots are ignored.
+func findCloneSnapshot(ecsClient cloud.ECSInterface, sourceDiskID, volumeName string) (*ecs.Snapshot, error) {
+	req := ecs.CreateDescribeSnapshotsRequest()
+	req.RegionId = GlobalConfigVar.Region
+	req.DiskId = sourceDiskID
+	req.Tag = &[]ecs.DescribeSnapshotsTag{
+		{Key: cloneTargetTagKey, Value: volumeName},
+	}
+	resp, err := ecsClient.DescribeSnapshots(req)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range resp.Snapshots.Snapshot {
+		if s.Status != SnapshotStatusFailed {
+			return &resp.Snapshots.Snapshot[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// prepareCloneSnapshot takes (or reuses) a snapshot of sourceVolumeID from which the disk for volumeName is created,
+// and waits until the snapshot can be used to create disks.
+func (cs *controllerServer) prepareCloneSnapshot(ctx context.Context, volumeName, sourceVolumeID string, requestGB int64) (string, error) {
+	logger := klog.FromContext(ctx).WithValues("sourceVolumeID", sourceVolumeID)
+
+	// The snapshot is deleted once the disk is created, don't take another one on retry.
+	disk, err := findDiskByName(volumeName, cs.ecs)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "find existing disk %s failed: %v", volumeName, err)
+	}
+	if disk != nil {
+		logger.V(2).Info("disk already cloned", "diskID", disk.DiskId, "snapshotID", disk.SourceSnapshotId)
+		return disk.SourceSnapshotId, nil
+	}
+
+	source, err := cs.cd.batcher.Describe(ctx, sourceVolumeID)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "describe source disk %s failed: %v", sourceVolumeID, err)
+	}
+	if source == nil {
+		return "", status.Errorf(codes.NotFound, "source disk %s not found", sourceVolumeID)
+	}
+	if int64(source.Size) > requestGB {
+		return "", status.Errorf(codes.OutOfRange, "requested size %dGiB is less than source disk %s size %dGiB", requestGB, sourceVolumeID, source.Size)
+	}
+
+	snapshot, err := findCloneSnapshot(cs.ecs, sourceVolumeID, volumeName)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "find existing clone snapshot of %s failed: %v", sourceVolumeID, err)
+	}
+	var snapshotID string
+	if snapshot != nil {
+		snapshotID = snapshot.SnapshotId
+		logger.V(2).Info("reusing existing clone snapshot", "snapshotID", snapshotID)
+	} else {
+		resp, err := requestAndCreateSnapshot(cs.ecs, &createSnapshotParams{
+			SourceVolumeID: sourceVolumeID,
+			SnapshotName:   cloneSnapshotName(volumeName),
+			RetentionDays:  cloneSnapshotRetentionDays,
+			SnapshotTags: []ecs.CreateSnapshotTag{
+				{Key: cloneTargetTagKey, Value: volumeName},
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		snapshotID = resp.SnapshotId
+		logger.V(2).Info("created clone snapshot", "snapshotID", snapshotID)
+	}
+
+	// Disks can be created from an instant access snapshot once it is cut,
+	// otherwise we need to wait for the snapshot to be fully available.
+	ready := waitstatus.SnapshotAvailable
+	if AllCategories[Category(source.Category)].InstantAccessSnapshot {
+		ready = waitstatus.SnapshotCut
+	}
+	snap, err := cs.snapshotWaiter.WaitFor(ctx, snapshotID, func(s *ecs.Snapshot) bool {
+		return s.Status == SnapshotStatusFailed || ready(s)
+	})
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed while waiting for clone snapshot %s: %v", snapshotID, err)
+	}
+	if snap.Status == SnapshotStatusFailed {
+		return "", status.Errorf(codes.Internal, "clone snapshot %s of %s failed", snapshotID, sourceVolumeID)
+	}
+	return snapshotID, nil
+}
+
+// cleanupIntermediateSnapshot deletes the intermediate snapshot (taken for cloning, or copied from another region)
+// once the disk is no longer being created from it.
+func (cs *controllerServer) cleanupIntermediateSnapshot(ctx context.Context, diskID, snapshotID string) error {
+	_, err := cs.ad.waiter.WaitFor(ctx, diskID, func(disk *ecs.Disk) bool {
+		return disk.Status != DiskStatusCreating
+	})
+	if err != nil && !errors.Is(err, waitstatus.ErrNotFound) {
+		return fmt.Errorf("wait for disk %s created: %w", diskID, err)
+	}
+
+	_, err = requestAndDeleteSnapshot(cs.ecs, snapshotID)
+	if err != nil {
+		var aliErr *alicloudErr.ServerError
+		if errors.As(err, &aliErr) && aliErr.ErrorCode() == SnapshotNotFound {
+			return nil
+		}
+		return fmt.Errorf("delete intermediate snapshot %s: %w", snapshotID, err)
+	}
+	klog.FromContext(ctx).V(2).Info("deleted intermediate snapshot", "snapshotID", snapshotID)
+	return nil
+}
@@ -0,0 +1,180 @@
+//go:build !windows
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	alicloudErr "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/batcher"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/desc"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/waitstatus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2/ktesting"
+	"k8s.io/utils/clock"
+)
+
+type fakeSnapshotWaiter struct {
+	snapshot ecs.Snapshot
+	waited   []string
+}
+
+func (f *fakeSnapshotWaiter) WaitFor(ctx context.Context, id string, pred waitstatus.StatusPredicate[*ecs.Snapshot]) (*ecs.Snapshot, error) {
+	f.waited = append(f.waited, id)
+	s := f.snapshot
+	s.SnapshotId = id
+	if !pred(&s) {
+		return nil, context.DeadlineExceeded
+	}
+	return &s, nil
+}
+
+func testCloneServer(t *testing.T) (*cloud.MockECSInterface, *fakeSnapshotWaiter, *controllerServer) {
+	ctrl := gomock.NewController(t)
+	c := cloud.NewMockECSInterface(ctrl)
+	client := desc.Disk(c)
+	w := &fakeSnapshotWaiter{snapshot: ecs.Snapshot{Status: "progressing", CreationTime: "2026-01-01T00:00:00Z"}}
+	return c, w, &controllerServer{
+		ecs:            c,
+		cd:             DiskCreateDelete{batcher: batcher.NewPassthrough(client)},
+		ad:             DiskAttachDetach{waiter: waitstatus.NewSimple(client, clock.RealClock{})},
+		snapshotWaiter: w,
+		snapshotGroups: newSnapshotGroupIndexCache(),
+	}
+}
+
+func snapshotsResp(snapshots ...ecs.Snapshot) *ecs.DescribeSnapshotsResponse {
+	return &ecs.DescribeSnapshotsResponse{
+		Snapshots: ecs.SnapshotsInDescribeSnapshots{Snapshot: snapshots},
+	}
+}
+
+// expectNoClonedDisk expects the lookup of the disk already created for pvc-new, and finds none.
+func expectNoClonedDisk(c *cloud.MockECSInterface) {
+	c.EXPECT().DescribeDisks(gomock.Any()).DoAndReturn(func(req *ecs.DescribeDisksRequest) (*ecs.DescribeDisksResponse, error) {
+		if req.Tag == nil || len(*req.Tag) != 1 || (*req.Tag)[0].Value != "pvc-new" {
+			return nil, fmt.Errorf("unexpected DescribeDisks request %+v", req)
+		}
+		return &ecs.DescribeDisksResponse{}, nil
+	})
+}
+
+func TestPrepareCloneSnapshot(t *testing.T) {
+	source := ecs.Disk{DiskId: "d-source", Size: 40, Category: string(DiskESSD)}
+
+	t.Run("disk already cloned", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		c.EXPECT().DescribeDisks(gomock.Any()).DoAndReturn(func(req *ecs.DescribeDisksRequest) (*ecs.DescribeDisksResponse, error) {
+			assert.Equal(t, []ecs.DescribeDisksTag{{Key: common.VolumeNameTag, Value: "pvc-new"}}, *req.Tag)
+			return diskResp(ecs.Disk{DiskId: "d-new", SourceSnapshotId: "s-deleted"}), nil
+		})
+
+		id, err := cs.prepareCloneSnapshot(ctx, "pvc-new", "d-source", 40)
+		require.NoError(t, err)
+		assert.Equal(t, "s-deleted", id)
+		assert.Empty(t, w.waited)
+	})
+
+	t.Run("source not found", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		expectNoClonedDisk(c)
+		cs.cd.batcher = &fakeBatcher{disk: nil}
+
+		_, err := cs.prepareCloneSnapshot(ctx, "pvc-new", "d-source", 40)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("smaller than source", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		expectNoClonedDisk(c)
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(source), nil)
+
+		_, err := cs.prepareCloneSnapshot(ctx, "pvc-new", "d-source", 20)
+		assert.Equal(t, codes.OutOfRange, status.Code(err))
+	})
+
+	t.Run("create new snapshot", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		expectNoClonedDisk(c)
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(source), nil)
+		c.EXPECT().DescribeSnapshots(gomock.Any()).DoAndReturn(func(req *ecs.DescribeSnapshotsRequest) (*ecs.DescribeSnapshotsResponse, error) {
+			assert.Equal(t, "d-source", req.DiskId)
+			assert.Equal(t, []ecs.DescribeSnapshotsTag{{Key: cloneTargetTagKey, Value: "pvc-new"}}, *req.Tag)
+			return snapshotsResp(ecs.Snapshot{SnapshotId: "s-failed", Status: SnapshotStatusFailed}), nil
+		})
+		c.EXPECT().CreateSnapshot(gomock.Any()).DoAndReturn(func(req *ecs.CreateSnapshotRequest) (*ecs.CreateSnapshotResponse, error) {
+			assert.Equal(t, "d-source", req.DiskId)
+			assert.Equal(t, "clone-pvc-new", req.SnapshotName)
+			assert.Contains(t, *req.Tag, ecs.CreateSnapshotTag{Key: cloneTargetTagKey, Value: "pvc-new"})
+			return &ecs.CreateSnapshotResponse{SnapshotId: "s-new"}, nil
+		})
+
+		id, err := cs.prepareCloneSnapshot(ctx, "pvc-new", "d-source", 40)
+		require.NoError(t, err)
+		assert.Equal(t, "s-new", id)
+		assert.Equal(t, []string{"s-new"}, w.waited)
+	})
+
+	t.Run("reuse existing snapshot", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		expectNoClonedDisk(c)
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(source), nil)
+		c.EXPECT().DescribeSnapshots(gomock.Any()).Return(snapshotsResp(ecs.Snapshot{SnapshotId: "s-existing", Status: "progressing"}), nil)
+
+		id, err := cs.prepareCloneSnapshot(ctx, "pvc-new", "d-source", 40)
+		require.NoError(t, err)
+		assert.Equal(t, "s-existing", id)
+		assert.Equal(t, []string{"s-existing"}, w.waited)
+	})
+
+	t.Run("snapshot failed", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		w.snapshot = ecs.Snapshot{Status: SnapshotStatusFailed}
+		expectNoClonedDisk(c)
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(source), nil)
+		c.EXPECT().DescribeSnapshots(gomock.Any()).Return(snapshotsResp(), nil)
+		c.EXPECT().CreateSnapshot(gomock.Any()).Return(&ecs.CreateSnapshotResponse{SnapshotId: "s-new"}, nil)
+
+		_, err := cs.prepareCloneSnapshot(ctx, "pvc-new", "d-source", 40)
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
+
+func TestCleanupCloneSnapshot(t *testing.T) {
+	t.Run("deleted", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(ecs.Disk{DiskId: "d-new", Status: DiskStatusAvailable}), nil)
+		c.EXPECT().DeleteSnapshot(gomock.Any()).DoAndReturn(func(req *ecs.DeleteSnapshotRequest) (*ecs.DeleteSnapshotResponse, error) {
+			assert.Equal(t, "s-new", req.SnapshotId)
+			return &ecs.DeleteSnapshotResponse{}, nil
+		})
+
+		assert.NoError(t, cs.cleanupIntermediateSnapshot(ctx, "d-new", "s-new"))
+	})
+
+	t.Run("already deleted", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(ecs.Disk{DiskId: "d-new", Status: DiskStatusAvailable}), nil)
+		notFound := alicloudErr.NewServerError(404, `{"Code": "InvalidSnapshotId.NotFound"}`, "")
+		c.EXPECT().DeleteSnapshot(gomock.Any()).Return(nil, notFound)
+
+		assert.NoError(t, cs.cleanupIntermediateSnapshot(ctx, "d-new", "s-new"))
+	})
+}
@@ -65,10 +65,12 @@ const (
 	DiskStatusAttaching = "Attaching"
 	DiskStatusDetaching = "Detaching"
 	DiskStatusAvailable = "Available"
+	DiskStatusCreating  = "Creating"
 )
 
 const (
 	SnapshotStatusAccomplished = "accomplished"
+	SnapshotStatusFailed       = "failed"
 	DiskMultiAttachDisabled    = "Disabled"
 	DiskMultiAttachEnabled     = "Enabled"
 
@@ -441,6 +443,15 @@ func (ad *DiskAttachDetach) detachDisk(ctx context.Context, ecsClient cloud.ECSI
 	return nil
 }
 
+// attachedInstances returns the IDs of the instances the disk is attached to
+func attachedInstances(disk *ecs.Disk) []string {
+	ids := make([]string, 0, len(disk.Attachments.Attachment))
+	for _, a := range disk.Attachments.Attachment {
+		ids = append(ids, a.InstanceId)
+	}
+	return ids
+}
+
 func getDiskDescribeRequest(diskIDs []string) *ecs.DescribeDisksRequest {
 	var idList string
 	for _, id := range diskIDs {
@@ -589,22 +600,16 @@ func findDiskByName(name string, ecsClient cloud.ECSInterface) (*ecs.Disk, error
 	}
 	return &disks[0], err
 }
+
+// findDiskSnapshotByID finds the snapshot by its CSI snapshot ID, which may refer to a snapshot in another region.
+// The SnapshotId of the returned snapshot is set to id.
 func findDiskSnapshotByID(id string) (*ecs.Snapshot, error) {
-	describeSnapShotRequest := ecs.CreateDescribeSnapshotsRequest()
-	describeSnapShotRequest.RegionId = GlobalConfigVar.Region
-	describeSnapShotRequest.SnapshotIds = "[\"" + id + "\"]"
-	snapshots, err := GlobalConfigVar.EcsClient.DescribeSnapshots(describeSnapShotRequest)
-	if err != nil {
-		return nil, err
-	}
-	s := snapshots.Snapshots.Snapshot
-	if len(s) == 0 {
-		return nil, nil
-	}
-	if len(s) > 1 {
-		return nil, fmt.Errorf("find more than one snapshot with id %s", id)
+	regionID, snapshotID := parseSnapshotHandle(id)
+	s, err := findSnapshotInRegion(GlobalConfigVar.EcsClient, regionID, snapshotID)
+	if s != nil {
+		s.SnapshotId = id
 	}
-	return &s[0], nil
+	return s, err
 }
 
 // findSnapshotGroup finds groupSnapshot by name or/and id
@@ -644,17 +649,23 @@ func StopDiskOperationRetry(instanceId string, ecsClient cloud.ECSInterface) boo
 	if err != nil {
 		return false
 	}
-	for _, vmEvent := range DEFAULT_VMFATAL_EVENTS {
-		if _, ok := eventMaps[vmEvent]; ok {
-			return true
+	return len(fatalInstanceEvents(eventMaps)) > 0
+}
+
+// fatalInstanceEvents returns the fatal events found in the result of DescribeDiskInstanceEvents
+func fatalInstanceEvents(eventMaps map[string]string) []string {
+	var events []string
+	for _, e := range DEFAULT_VMFATAL_EVENTS {
+		if _, ok := eventMaps[e]; ok {
+			events = append(events, e)
 		}
 	}
-	for _, addonEvent := range GlobalConfigVar.AddonVMFatalEvents {
-		if _, ok := eventMaps[addonEvent]; ok {
-			return true
+	for _, e := range GlobalConfigVar.AddonVMFatalEvents {
+		if _, ok := eventMaps[e]; ok {
+			events = append(events, e)
 		}
 	}
-	return false
+	return events
 }
 
 func DescribeDiskInstanceEvents(instanceId string, ecsClient cloud.ECSInterface) (eventMaps map[string]string, err error) {
@@ -689,6 +700,7 @@ type createSnapshotParams struct {
 	ResourceGroupID string
 	RetentionDays   int
 	SnapshotTags    []ecs.CreateSnapshotTag
+	FsFreeze        bool
 }
 
 func requestAndCreateSnapshot(ecsClient cloud.ECSInterface, params *createSnapshotParams) (*ecs.CreateSnapshotResponse, error) {
@@ -732,12 +744,16 @@ func requestAndCreateSnapshot(ecsClient cloud.ECSInterface, params *createSnapsh
 	return snapshotResponse, nil
 }
 
-func requestAndDeleteSnapshot(snapshotID string) (*ecs.DeleteSnapshotResponse, error) {
+func requestAndDeleteSnapshot(ecsClient cloud.ECSInterface, snapshotID string) (*ecs.DeleteSnapshotResponse, error) {
 	// Delete Snapshot
 	deleteSnapshotRequest := ecs.CreateDeleteSnapshotRequest()
-	deleteSnapshotRequest.SnapshotId = snapshotID
+	regionID, id := parseSnapshotHandle(snapshotID)
+	if regionID != "" {
+		deleteSnapshotRequest.RegionId = regionID
+	}
+	deleteSnapshotRequest.SnapshotId = id
 	deleteSnapshotRequest.Force = requests.NewBoolean(true)
-	response, err := GlobalConfigVar.EcsClient.DeleteSnapshot(deleteSnapshotRequest)
+	response, err := ecsClient.DeleteSnapshot(deleteSnapshotRequest)
 	if err != nil {
 		return response, err
 	}
@@ -750,6 +766,7 @@ type createGroupSnapshotParams struct {
 	ResourceGroupID string
 	//RetentionDays   int
 	SnapshotTags []ecs.CreateSnapshotGroupTag
+	FsFreeze     bool
 }
 
 func requestAndCreateSnapshotGroup(ecsClient *ecs.Client, params *createGroupSnapshotParams) (*ecs.CreateSnapshotGroupResponse, error) {
@@ -1199,3 +1216,46 @@ func listSnapshots(ecsClient cloud.ECSInterface, diskID, clusterID, nextToken st
 	}
 	return snapshots, nextToken, nil
 }
+
+// describeDisksMaxResults is the maximum MaxResults allowed by DescribeDisks
+const describeDisksMaxResults = 100
+
+// listDisks list all disks created by this driver in clusterID (if specified)
+func listDisks(ecsClient cloud.ECSInterface, clusterID, nextToken string, maxEntries int) ([]ecs.Disk, string, error) {
+	pos, token, err := parseNextToken(nextToken)
+	if err != nil {
+		return nil, "", status.Errorf(codes.Aborted, "Invalid StartingToken %s: %v", nextToken, err)
+	}
+	describeRequest := ecs.CreateDescribeDisksRequest()
+	describeRequest.RegionId = GlobalConfigVar.Region
+	tags := []ecs.DescribeDisksTag{
+		{Key: DISKTAGKEY2, Value: DISKTAGVALUE2},
+	}
+	if clusterID != "" {
+		tags = append(tags, ecs.DescribeDisksTag{Key: DISKTAGKEY3, Value: clusterID})
+	}
+	describeRequest.Tag = &tags
+	describeRequest.NextToken = token
+	if maxEntries > 0 {
+		// If the page is capped, fewer than maxEntries disks are returned, the rest are in the next page.
+		describeRequest.MaxResults = requests.NewInteger(min(maxEntries+pos, describeDisksMaxResults))
+	}
+	response, err := ecsClient.DescribeDisks(describeRequest)
+	if err != nil {
+		return nil, "", status.Errorf(codes.Internal, "ListVolumes:: Request describeDisks error: %v", err)
+	}
+	if pos > len(response.Disks.Disk) {
+		return nil, "", status.Errorf(codes.Aborted, "Invalid StartingToken %s: position out of range", nextToken)
+	}
+	nextToken = ""
+	if response.NextToken != "" {
+		nextToken = encodeNextToken(0, response.NextToken)
+	}
+	disks := response.Disks.Disk[pos:]
+	if maxEntries > 0 && len(disks) > maxEntries {
+		// See listSnapshots for why we need to record the position
+		nextToken = encodeNextToken(pos+maxEntries, token)
+		disks = disks[:maxEntries]
+	}
+	return disks, nextToken, nil
+}
@@ -205,6 +205,84 @@ func TestListSnapshotsInvalidToken(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestListDisks(t *testing.T) {
+	cases := []struct {
+		name          string
+		numRemaining  int
+		maxEntries    int
+		nextToken     string
+		expectedNum   int
+		firstID       string
+		expectedToken string
+		maxResults    int
+		respToken     string
+	}{
+		{
+			name:         "empty",
+			numRemaining: 0, maxEntries: 0, nextToken: "", expectedNum: 0, firstID: "",
+		}, {
+			name:         "skip one",
+			numRemaining: 2, maxEntries: 0, nextToken: "1@", expectedNum: 1, firstID: "d-1",
+		}, {
+			name:         "paged",
+			numRemaining: 13, maxEntries: 5, nextToken: "", expectedNum: 5, firstID: "d-0",
+			expectedToken: "5@",
+		}, {
+			name:         "middle of page",
+			numRemaining: 3, maxEntries: 1, nextToken: "1@next-page", expectedNum: 1, firstID: "d-1",
+			expectedToken: "2@next-page",
+		}, {
+			name:         "capped page",
+			numRemaining: 100, maxEntries: 80, nextToken: "50@next-page", expectedNum: 50, firstID: "d-50",
+			expectedToken: "0@page-3", maxResults: 100, respToken: "page-3",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := cloud.NewMockECSInterface(ctrl)
+
+			client.EXPECT().DescribeDisks(gomock.Any()).DoAndReturn(func(req *ecs.DescribeDisksRequest) (*ecs.DescribeDisksResponse, error) {
+				assert.Equal(t, []ecs.DescribeDisksTag{
+					{Key: DISKTAGKEY2, Value: DISKTAGVALUE2},
+					{Key: DISKTAGKEY3, Value: "my-cluster"},
+				}, *req.Tag)
+				disks := make([]ecs.Disk, c.numRemaining)
+				for i := range c.numRemaining {
+					disks[i] = ecs.Disk{DiskId: fmt.Sprintf("d-%d", i)}
+				}
+				if req.NextToken != "" {
+					assert.Equal(t, "next-page", req.NextToken, "n@ should not be passed to the API")
+				}
+				if c.maxResults > 0 {
+					assert.Equal(t, requests.NewInteger(c.maxResults), req.MaxResults)
+				}
+				return &ecs.DescribeDisksResponse{
+					Disks:     ecs.DisksInDescribeDisks{Disk: disks},
+					NextToken: c.respToken,
+				}, nil
+			})
+
+			disks, nextToken, err := listDisks(client, "my-cluster", c.nextToken, c.maxEntries)
+			assert.NoError(t, err)
+			assert.Len(t, disks, c.expectedNum)
+			if c.expectedNum > 0 {
+				assert.Equal(t, c.firstID, disks[0].DiskId)
+			}
+			assert.Equal(t, c.expectedToken, nextToken)
+		})
+	}
+}
+
+func TestListDisksInvalidToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := cloud.NewMockECSInterface(ctrl)
+
+	_, _, err := listDisks(client, "my-cluster", "invalid-token", 0)
+	assert.Error(t, err)
+}
+
 func TestClientToken(t *testing.T) {
 	// we should keep the token stable across versions
 	assert.Equal(t, "n:disk-dcd6fdde-8c1e-45eb-8ec7-786a8b2e0b61", clientToken("disk-dcd6fdde-8c1e-45eb-8ec7-786a8b2e0b61"))
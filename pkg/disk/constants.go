@@ -24,6 +24,12 @@ const (
 	DISK_TAG_PREFIX        = "diskTags/"
 	REMOVE_DISK_TAG_PREFIX = "-diskTags/"
 	RESTRICT_TO_HPN_ZONE   = "restrictToHpnZone"
+	MULTI_ATTACH_KEY       = "multiAttach"
+	DELETE_AUTO_SNAPSHOT   = "deleteAutoSnapshot"
+	DELETE_WITH_INSTANCE   = "deleteWithInstance"
+
+	// PV annotation of the IO limits in MutableParameters, overriding those in the volume context.
+	annIOLimits = "csi.alibabacloud.com/io-limits"
 )
 
 // Disk tags with special meaning for LingJun (灵骏) nodes.
@@ -51,6 +57,8 @@ const (
 	INSTANTACCESSRETENTIONDAYS = "instantAccessRetentionDays"
 	SNAPSHOTRESOURCEGROUPID    = "resourceGroupId"
 	SNAPSHOT_TAG_PREFIX        = "snapshotTags/"
+	// FSFREEZE freezes the filesystem on the node while the snapshot is cut, for application-consistent snapshots
+	FSFREEZE = "fsFreeze"
 )
 
 const (
@@ -58,6 +66,12 @@ const (
 	DiskSnapshotID = "csi.alibabacloud.com/disk-snapshot-id"
 	// IAVolumeSnapshotKey tag
 	IAVolumeSnapshotKey = "csi.alibabacloud.com/snapshot-ia"
+	// GroupSnapshotIDTag is tagged on disks restored from a member of a snapshot group, also set in volume context
+	GroupSnapshotIDTag = "csi.alibabacloud.com/group-snapshot-id"
+	// GroupSnapshotMembersKey in volume context lists all the snapshots in the group, so that a complete restore can be verified
+	GroupSnapshotMembersKey = "csi.alibabacloud.com/group-snapshot-members"
+	// StrictGroupRestoreKey in StorageClass parameters rejects restoring a snapshot group inconsistently in a namespace
+	StrictGroupRestoreKey = "strictGroupRestore"
 	// annDiskID tag
 	annDiskID = "volume.alibabacloud.com/disk-id"
 	// MinimumDiskSizeInGB ...
@@ -132,6 +146,52 @@ const (
 	labelVolumeType   = "csi.alibabacloud.com/disktype"
 	annAppendPrefix   = "csi.alibabacloud.com/annotation-prefix/"
 
+	// Auto expansion policy, in StorageClass parameters, overridden by the annotations on PVC
+	AutoExpandKey                  = "autoExpand"
+	AutoExpandThresholdKey         = "autoExpandThreshold"
+	AutoExpandStepKey              = "autoExpandStep"
+	AutoExpandMaxSizeKey           = "autoExpandMaxSize"
+	AutoExpandCooldownKey          = "autoExpandCooldown"
+	AutoExpandAnnotation           = "csi.alibabacloud.com/auto-expand"
+	AutoExpandThresholdAnnotation  = "csi.alibabacloud.com/auto-expand-threshold"
+	AutoExpandStepAnnotation       = "csi.alibabacloud.com/auto-expand-step"
+	AutoExpandMaxSizeAnnotation    = "csi.alibabacloud.com/auto-expand-max-size"
+	AutoExpandCooldownAnnotation   = "csi.alibabacloud.com/auto-expand-cooldown"
+	AutoExpandLastExpandAnnotation = "csi.alibabacloud.com/auto-expand-last-time"
+
+	// Automatic snapshot policy applied to the created disk, in StorageClass parameters
+	AutoSnapshotPolicyTimePointsKey                 = "autoSnapshotPolicyTimePoints"
+	AutoSnapshotPolicyRepeatWeekdaysKey             = "autoSnapshotPolicyRepeatWeekdays"
+	AutoSnapshotPolicyRetentionDaysKey              = "autoSnapshotPolicyRetentionDays"
+	AutoSnapshotPolicyInstantAccessRetentionDaysKey = "autoSnapshotPolicyInstantAccessRetentionDays"
+	AutoSnapshotPolicyVolumeSnapshotClassKey        = "autoSnapshotPolicyVolumeSnapshotClass"
+	// AutoSnapshotPolicyHashTag identifies the policies created by the driver, and the schedule they implement
+	AutoSnapshotPolicyHashTag = "csi.alibabacloud.com/auto-snapshot-policy-hash"
+
+	// LuksEncrypted tag, encrypt the volume on node with LUKS
+	LuksEncrypted = "luksEncrypted"
+	// LuksPassphraseKey is the key of the LUKS passphrase in nodeStageSecretRef
+	LuksPassphraseKey = "luksPassphrase"
+
+	// Striped volumes backed by several disks, in StorageClass parameters
+	StripeCountKey = "stripeCount"
+	StripeModeKey  = "stripeMode"
+	// StripeVolumeTag is tagged on the member disks of a striped volume, its value is the volume name
+	StripeVolumeTag = "csi.alibabacloud.com/stripe-volume"
+	// StripeMemberTag is tagged on the member disks of a striped volume, its value is <mode>:<index>/<count>
+	StripeMemberTag = "csi.alibabacloud.com/stripe-member"
+
+	// Subpath volumes on a disk shared by the volumes on a node, in StorageClass parameters
+	VolumeAsKey       = "volumeAs"
+	VolumeAsSubpath   = "subpath"
+	SharedDiskSizeKey = "sharedDiskSize"
+	// SubpathQuotaKey in the volume context is the initial quota of a subpath volume in bytes
+	SubpathQuotaKey = "subpathQuota"
+	// SharedDiskOwnerTag is tagged on the shared disk of subpath volumes, its value is the ECS instance owning it
+	SharedDiskOwnerTag = "csi.alibabacloud.com/shared-disk-owner"
+	// SharedDiskLabel is set on the ledger ConfigMap of a shared disk, its value is the disk ID
+	SharedDiskLabel = "csi.alibabacloud.com/shared-disk"
+
 	VolumeDeleteAutoSnapshotKey                    = "csi.alibabacloud.com/volume-delete-autosnapshot-retentiondays"
 	VOLUME_DELETE_AUTO_SNAPSHOT_OP_RETENT_DAYS_KEY = "volumeDeleteSnapshotRetentionDays"
 
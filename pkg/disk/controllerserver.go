@@ -17,10 +17,12 @@ limitations under the License.
 package disk
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"strconv"
 	"strings"
@@ -40,6 +42,7 @@ import (
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/waitstatus"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/features"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/ttlcache"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -61,9 +64,17 @@ type controllerServer struct {
 	cd             DiskCreateDelete
 	meta           metadata.MetadataProvider
 	ecs            cloud.ECSInterface
+	ecsV2          cloud.ECSv2Interface
 	clientSet      kubernetes.Interface
 	snapshotWaiter waitstatus.StatusWaiter[ecs.Snapshot]
 	modify         ModifyServer
+	diskStock      *ttlcache.TTLCache[string, diskStock]
+	fsFreeze       *fsFreezeClient
+	autoSnapshots  *autoSnapshotPolicyManager
+	subpaths       *subpathAllocator
+	snapshotGroups *snapshotGroupIndexCache
+	attachments    *volumeAttachments
+	instanceEvents *ttlcache.TTLCache[string, map[string]string]
 	common.GenericControllerServer
 }
 
@@ -87,6 +98,14 @@ type diskVolumeArgs struct {
 	BurstingEnabled  bool
 	RequestGB        int64
 	DataCache        datacache.Opts
+	// AutoSnapshotPolicy is applied to the disk after creation, nil if not requested
+	AutoSnapshotPolicy *autoSnapshotPolicyParams
+	// Stripe creates several disks striped together on the node, Count is 0 if not requested
+	Stripe stripeParams
+	// Subpath allocates a directory on the shared disk of the node instead of a disk, SharedDiskGB is 0 if not requested
+	Subpath subpathParams
+	// StrictGroupRestore checks the disks already restored from the snapshot group in the namespace
+	StrictGroupRestore bool
 
 	// ExtraTopology is extra PV nodeAffinity resolved in getDiskVolumeOptions.
 	ExtraTopology map[string]string
@@ -111,12 +130,13 @@ func newSnapshotStatusWaiter() waitstatus.StatusWaiter[ecs.Snapshot] {
 }
 
 // NewControllerServer is to create controller server
-func NewControllerServer(csiCfg utils.Config, ecs cloud.ECSInterface, ecsV2 cloud.ECSv2Interface, m metadata.MetadataProvider) csi.ControllerServer {
+func NewControllerServer(csiCfg utils.Config, ecs cloud.ECSInterface, ecsV2 cloud.ECSv2Interface, m metadata.MetadataProvider, snapshotGroups *snapshotGroupIndexCache) csi.ControllerServer {
 	waiter, batcher := newBatcher(false)
 	c := &controllerServer{
 		recorder:  utils.NewEventRecorder(utils.EventComponentController),
 		meta:      m,
 		ecs:       ecs,
+		ecsV2:     ecsV2,
 		clientSet: GlobalConfigVar.ClientSet,
 		ad: DiskAttachDetach{
 			ecs:     ecs,
@@ -137,7 +157,16 @@ func NewControllerServer(csiCfg utils.Config, ecs cloud.ECSInterface, ecsV2 clou
 			ecsClient:  ecsV2,
 			taskWaiter: newTaskStatusWaiter(ecsV2),
 		},
+		diskStock: ttlcache.NewTTLCache[string, diskStock](diskStockCacheTTL),
+		fsFreeze:  newFsFreezeClient(csiCfg, GlobalConfigVar.ClientSet),
+
+		autoSnapshots:  newAutoSnapshotPolicyManager(ecsV2, GlobalConfigVar.ClientSet, GlobalConfigVar.SnapClient),
+		subpaths:       newSubpathAllocator(GlobalConfigVar.ClientSet),
+		snapshotGroups: snapshotGroups,
+		attachments:    newVolumeAttachments(GlobalConfigVar.ClientSet),
+		instanceEvents: ttlcache.NewTTLCache[string, map[string]string](instanceEventsCacheTTL),
 	}
+	go runAsLeader(context.Background(), GlobalConfigVar.ClientSet, c.autoSnapshots.run, c.runSharedDiskGC)
 	detachConcurrency := 1
 	attachConcurrency := 1
 	if features.FunctionalMutableFeatureGate.Enabled(features.DiskParallelDetach) {
@@ -161,6 +190,12 @@ func (cs *controllerServer) ControllerGetCapabilities(ctx context.Context, req *
 			csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
 			csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
 			csi.ControllerServiceCapability_RPC_MODIFY_VOLUME,
+			csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
+			csi.ControllerServiceCapability_RPC_GET_VOLUME,
+			csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+			csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+			csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
+			csi.ControllerServiceCapability_RPC_GET_CAPACITY,
 		),
 	}, nil
 }
@@ -181,25 +216,66 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		return &csi.CreateVolumeResponse{Volume: csiVolume}, nil
 	}
 
-	snapshotID, err := parseSnapshotID(req)
+	snapshotID, sourceVolumeID, err := parseVolumeSource(req)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	diskVol, err := getDiskVolumeOptions(req, cs.meta, cs.recorder, snapshotID)
+	diskVol, err := getDiskVolumeOptions(req, cs.meta, cs.recorder, cmp.Or(snapshotID, sourceVolumeID))
 	if err != nil {
 		klog.Errorf("CreateVolume: error parameters from input: %v, with error: %v", req.Name, err)
 		return nil, status.Errorf(codes.InvalidArgument, "Invalid parameters from input: %v, with error: %v", req.Name, err)
 	}
+	if _, _, ok := parseStripeVolumeID(sourceVolumeID); ok {
+		return nil, status.Errorf(codes.InvalidArgument, "cloning striped volume %s is not supported", sourceVolumeID)
+	}
+	if _, _, ok := parseSubpathVolumeID(sourceVolumeID); ok {
+		return nil, status.Errorf(codes.InvalidArgument, "cloning subpath volume %s is not supported", sourceVolumeID)
+	}
 
+	var mutable ModifyParameters
 	if len(req.MutableParameters) > 0 {
-		mutable, err := parseMutableParameters(req.MutableParameters)
+		mutable, err = parseMutableParameters(req.MutableParameters)
 		if err != nil {
 			return nil, status.Errorf(codes.InvalidArgument, "Invalid mutable parameters: %v", err)
 		}
+		if mutable.MultiAttach != nil && !*mutable.MultiAttach {
+			if required, _ := validateCapabilities(req.VolumeCapabilities); required {
+				return nil, status.Errorf(codes.InvalidArgument, "%s is required for this access mode", MULTI_ATTACH_KEY)
+			}
+		}
+		if mutable.DeleteWithInstance != nil {
+			for _, t := range diskVol.Type {
+				if AllCategories[t].SingleInstance {
+					return nil, status.Errorf(codes.InvalidArgument, "%s is managed by the driver for %s disk", DELETE_WITH_INSTANCE, t)
+				}
+			}
+		}
 		importMutableParameters(diskVol, &mutable)
 	}
 
+	if diskVol.Stripe.Count > 0 && (sourceVolumeID != "" || snapshotID != "" && !isSnapshotGroupID(snapshotID)) {
+		return nil, status.Errorf(codes.InvalidArgument, "%s only supports restoring from a snapshot group of a striped volume, not from %s",
+			StripeCountKey, cmp.Or(snapshotID, sourceVolumeID))
+	}
+
+	var group *ecs.SnapshotGroup
+	var memberSnapshots []string
+	if isSnapshotGroupID(snapshotID) {
+		memberSnapshots, err = cs.stripedSnapshotMembers(snapshotID, diskVol.Stripe)
+		if err != nil {
+			return nil, err
+		}
+	} else if snapshotID != "" {
+		group, err = cs.validateGroupRestore(ctx, req, snapshotID, diskVol.StrictGroupRestore)
+		if err != nil {
+			return nil, err
+		}
+		if group != nil {
+			diskVol.DiskTags[GroupSnapshotIDTag] = group.SnapshotGroupId
+		}
+	}
+
 	var supportedTypes sets.Set[Category]
 	var selectedInstance string
 	var isVirtualNode bool
@@ -218,44 +294,144 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 		isVirtualNode = node.Labels[common.NodeTypeLabelKey] == common.VirtualNodeType
 	}
 
-	diskID, attempt, err := cs.cd.createDisk(ctx, req.GetName(), snapshotID, diskVol, supportedTypes, selectedInstance, isVirtualNode)
+	if diskVol.Subpath.SharedDiskGB > 0 {
+		return cs.createSubpathVolume(ctx, req, diskVol, mutable, supportedTypes, selectedInstance)
+	}
+
+	if diskVol.Stripe.Count > 0 {
+		volumeID, capacity, attempt, err := cs.createStripedDisks(ctx, req.GetName(), diskVol, mutable, supportedTypes, selectedInstance, isVirtualNode, memberSnapshots)
+		if err != nil {
+			return nil, err
+		}
+		volumeContext := updateVolumeContext(createdVolumeContext(req, mutable, attempt))
+		klog.Infof("CreateVolume: Successfully created striped volume %s: id[%s], zone[%s], disktype[%s], snapshotID[%s]", req.GetName(), volumeID, diskVol.ZoneID, attempt, snapshotID)
+		return &csi.CreateVolumeResponse{Volume: volumeCreate(attempt, volumeID, capacity, volumeContext, diskVol.ZoneID, diskVol.ExtraTopology, volumeContentSource(snapshotID, ""))}, nil
+	}
+
+	if sourceVolumeID != "" {
+		// Clone by creating the new disk from a temporary snapshot of the source disk.
+		snapshotID, err = cs.prepareCloneSnapshot(ctx, req.GetName(), sourceVolumeID, diskVol.RequestGB)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A snapshot in another region is copied to this region first.
+	var copiedSnapshotID string
+	if regionID, id := parseSnapshotHandle(snapshotID); regionID != "" {
+		copiedSnapshotID, err = cs.prepareCopiedSnapshot(ctx, req.GetName(), regionID, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	diskID, attempt, err := cs.cd.createDisk(ctx, req.GetName(), cmp.Or(copiedSnapshotID, snapshotID), diskVol, supportedTypes, selectedInstance, isVirtualNode)
 	if err != nil {
-		if errors.Is(err, ErrParameterMismatch) {
-			return nil, status.Errorf(codes.AlreadyExists, "volume %s already created but %v", req.Name, err)
+		return nil, mapCreateDiskError(err, req.Name, snapshotID)
+	}
+
+	if sourceVolumeID != "" {
+		if err := cs.cleanupIntermediateSnapshot(ctx, diskID, snapshotID); err != nil {
+			// The disk is created, a retry finds it and deletes the snapshot again.
+			return nil, status.Errorf(codes.Internal, "disk %s cloned from %s, but cleanup failed: %v", diskID, sourceVolumeID, err)
 		}
-		var aliErr *alicloudErr.ServerError
-		if errors.As(err, &aliErr) {
-			switch aliErr.ErrorCode() {
-			case SnapshotNotFound:
-				return nil, status.Errorf(codes.NotFound, "snapshot %s not found", snapshotID)
-			default:
-				return nil, status.Error(codes.Internal, err.Error())
-			}
+		snapshotID = ""
+	}
+	if copiedSnapshotID != "" {
+		if err := cs.cleanupIntermediateSnapshot(ctx, diskID, copiedSnapshotID); err != nil {
+			return nil, status.Errorf(codes.Internal, "disk %s restored from %s, but cleanup of the copied snapshot failed: %v", diskID, snapshotID, err)
 		}
+	}
+
+	if err := cs.setupCreatedDisk(ctx, diskID, diskVol, mutable); err != nil {
 		return nil, err
 	}
 
+	volumeContext := createdVolumeContext(req, mutable, attempt)
+	if group != nil {
+		groupRestoreVolumeContext(volumeContext, group)
+	}
+
+	volumeContext = updateVolumeContext(volumeContext)
+
+	klog.Infof("CreateVolume: Successfully created Disk %s: id[%s], zone[%s], disktype[%s], snapshotID[%s], sourceVolumeID[%s]", req.GetName(), diskID, diskVol.ZoneID, attempt, snapshotID, sourceVolumeID)
+
+	tmpVol := volumeCreate(attempt, diskID, utils.Gi2Bytes(int64(diskVol.RequestGB)), volumeContext, diskVol.ZoneID, diskVol.ExtraTopology, volumeContentSource(snapshotID, sourceVolumeID))
+
+	return &csi.CreateVolumeResponse{Volume: tmpVol}, nil
+}
+
+func mapCreateDiskError(err error, volumeName, snapshotID string) error {
+	if errors.Is(err, ErrParameterMismatch) {
+		return status.Errorf(codes.AlreadyExists, "volume %s already created but %v", volumeName, err)
+	}
+	var aliErr *alicloudErr.ServerError
+	if errors.As(err, &aliErr) {
+		switch aliErr.ErrorCode() {
+		case SnapshotNotFound:
+			return status.Errorf(codes.NotFound, "snapshot %s not found", snapshotID)
+		default:
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	return err
+}
+
+// setupCreatedDisk sets the attributes not supported by CreateDisk, after the disk is created.
+func (cs *controllerServer) setupCreatedDisk(ctx context.Context, diskID string, diskVol *diskVolumeArgs, mutable ModifyParameters) error {
+	if mutable.DeleteAutoSnapshot != nil || mutable.DeleteWithInstance != nil {
+		err := cs.modify.Modify(ctx, diskID, ModifyParameters{
+			DeleteAutoSnapshot: mutable.DeleteAutoSnapshot,
+			DeleteWithInstance: mutable.DeleteWithInstance,
+		})
+		if err != nil {
+			return fmt.Errorf("disk %s created, but failed to modify attributes: %w", diskID, err)
+		}
+	}
+	if diskVol.AutoSnapshotPolicy != nil {
+		if err := cs.autoSnapshots.apply(ctx, diskID, diskVol.AutoSnapshotPolicy); err != nil {
+			return fmt.Errorf("disk %s created, but %w", diskID, err)
+		}
+	}
+	return nil
+}
+
+func createdVolumeContext(req *csi.CreateVolumeRequest, mutable ModifyParameters, attempt createAttempt) map[string]string {
 	volumeContext := req.GetParameters()
 	if volumeContext == nil {
 		volumeContext = make(map[string]string)
 	}
+	if mutable.IOLimits != nil {
+		for _, k := range utils.IOLimitKeys {
+			delete(volumeContext, k)
+		}
+		maps.Copy(volumeContext, mutable.IOLimits)
+	}
 	volumeContext["type"] = string(attempt.Category)
 	if attempt.PerformanceLevel != "" {
 		volumeContext[ESSD_PERFORMANCE_LEVEL] = string(attempt.PerformanceLevel)
 	}
-
-	volumeContext = updateVolumeContext(volumeContext)
-
-	klog.Infof("CreateVolume: Successfully created Disk %s: id[%s], zone[%s], disktype[%s], snapshotID[%s]", req.GetName(), diskID, diskVol.ZoneID, attempt, snapshotID)
-
-	tmpVol := volumeCreate(attempt, diskID, utils.Gi2Bytes(int64(diskVol.RequestGB)), volumeContext, diskVol.ZoneID, diskVol.ExtraTopology, volumeContentSource(snapshotID))
-
-	return &csi.CreateVolumeResponse{Volume: tmpVol}, nil
+	return volumeContext
 }
 
 // call ecs api to delete disk
 func (cs *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
 	klog.Infof("DeleteVolume: Starting deleting volume %s", req.VolumeId)
+	if _, diskIDs, ok := parseStripeVolumeID(req.VolumeId); ok {
+		for _, diskID := range diskIDs {
+			if _, err := cs.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: diskID, Secrets: req.Secrets}); err != nil {
+				return nil, err
+			}
+		}
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+	if _, _, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		// The directory is on the node, which removes it once it finds the volume deleted in the ledger.
+		if err := cs.subpaths.release(ctx, req.VolumeId); err != nil {
+			return nil, err
+		}
+		return &csi.DeleteVolumeResponse{}, nil
+	}
 
 	// For now the image get unconditionally deleted, but here retention policy can be checked
 	var disk *ecs.Disk
@@ -326,7 +502,12 @@ func (cs *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVol
 }
 
 func (cs *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
-	disk, err := findDiskByID(req.VolumeId, cs.ecs)
+	// members of a striped volume are identical
+	diskID := volumeDisks(req.VolumeId)[0]
+	if sharedDiskID, _, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		diskID = sharedDiskID
+	}
+	disk, err := findDiskByID(diskID, cs.ecs)
 	if err != nil {
 		return nil, err
 	}
@@ -361,6 +542,12 @@ func (cs *controllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 	}
 
 	klog.Infof("ControllerPublishVolume: start attach disk: %s to node: %s", req.VolumeId, req.NodeId)
+	if _, diskIDs, ok := parseStripeVolumeID(req.VolumeId); ok {
+		return cs.publishStripedVolume(ctx, diskIDs, req.NodeId)
+	}
+	if sharedDiskID, _, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		return cs.publishSubpathVolume(ctx, sharedDiskID, req.NodeId)
+	}
 
 	r, err := cs.ad.attachDisk(ctx, req.VolumeId, req.NodeId)
 	if err != nil {
@@ -387,11 +574,18 @@ func (cs *controllerServer) ControllerUnpublishVolume(ctx context.Context, req *
 		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	}
 
+	if _, _, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		klog.Infof("ControllerUnpublishVolume: kept the shared disk of subpath volume %s on node %s", req.VolumeId, req.NodeId)
+		return &csi.ControllerUnpublishVolumeResponse{}, nil
+	}
+
 	klog.Infof("ControllerUnpublishVolume: detach disk: %s from node: %s", req.VolumeId, req.NodeId)
-	err := cs.ad.detachDisk(ctx, cs.ecs, req.VolumeId, req.NodeId, false)
-	if err != nil {
-		klog.Errorf("ControllerUnpublishVolume: detach disk: %s from node: %s with error: %s", req.VolumeId, req.NodeId, err.Error())
-		return nil, err
+	for _, diskID := range volumeDisks(req.VolumeId) {
+		err := cs.ad.detachDisk(ctx, cs.ecs, diskID, req.NodeId, false)
+		if err != nil {
+			klog.Errorf("ControllerUnpublishVolume: detach disk: %s from node: %s with error: %s", diskID, req.NodeId, err.Error())
+			return nil, err
+		}
 	}
 	klog.Infof("ControllerUnpublishVolume: Successful detach disk: %s from node: %s", req.VolumeId, req.NodeId)
 	return &csi.ControllerUnpublishVolumeResponse{}, nil
@@ -440,6 +634,11 @@ func parseSnapshotParameters(params map[string]string, ecsParams *createSnapshot
 			klog.Warningf("InstantAccessRetentionDays is no longer needed, please remove it from parameters")
 		case SNAPSHOTRESOURCEGROUPID:
 			ecsParams.ResourceGroupID = v
+		case FSFREEZE:
+			ecsParams.FsFreeze, err = strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("failed to parse fsFreeze: %w", err)
+			}
 		case common.VolumeSnapshotNameKey:
 			tags[common.VolumeSnapshotNameTag] = v
 		case common.VolumeSnapshotNamespaceKey:
@@ -495,6 +694,12 @@ func (cs *controllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateS
 
 	klog.Infof("CreateSnapshot:: Starting to create snapshot: %+v", req)
 	sourceVolumeID := strings.Trim(req.GetSourceVolumeId(), " ")
+	if mode, diskIDs, ok := parseStripeVolumeID(sourceVolumeID); ok {
+		return cs.createStripedSnapshot(ctx, req, params, mode, diskIDs)
+	}
+	if _, _, ok := parseSubpathVolumeID(sourceVolumeID); ok {
+		return nil, status.Errorf(codes.InvalidArgument, "snapshot of subpath volume %s is not supported", sourceVolumeID)
+	}
 
 	disks := getDisks([]string{sourceVolumeID}, cs.ecs)
 	if len(disks) == 0 {
@@ -503,6 +708,18 @@ func (cs *controllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateS
 		return nil, status.Errorf(codes.Internal, "CreateSnapshot:: failed to get disk from sourceVolumeID: %v", sourceVolumeID)
 	}
 
+	waitCtx := ctx
+	if params.FsFreeze {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, cs.fsFreeze.timeout)
+		defer cancel()
+		thaw, err := cs.fsFreeze.freeze(waitCtx, disks)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "CreateSnapshot:: failed to freeze filesystem: %v", err)
+		}
+		defer thaw()
+	}
+
 	// init createSnapshotRequest and parameters
 	params.SourceVolumeID = sourceVolumeID
 	params.SnapshotName = req.Name
@@ -514,8 +731,17 @@ func (cs *controllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateS
 
 	klog.Infof("CreateSnapshot:: Snapshot create successful: snapshotName[%s], sourceId[%s], snapshotId[%s]", req.Name, req.GetSourceVolumeId(), snapshotResponse.SnapshotId)
 
-	snap, err := cs.snapshotWaiter.WaitFor(ctx, snapshotResponse.SnapshotId, waitstatus.SnapshotCut)
+	snap, err := cs.snapshotWaiter.WaitFor(waitCtx, snapshotResponse.SnapshotId, waitstatus.SnapshotCut)
 	if err != nil {
+		if params.FsFreeze && ctx.Err() == nil {
+			// A retry with the same ClientToken would return this snapshot as if it were consistent.
+			if _, delErr := requestAndDeleteSnapshot(cs.ecs, snapshotResponse.SnapshotId); delErr != nil {
+				return nil, status.Errorf(codes.Internal, "snapshot %s is not cut within the filesystem freeze timeout %v, and failed to delete it: %v",
+					snapshotResponse.SnapshotId, cs.fsFreeze.timeout, delErr)
+			}
+			return nil, status.Errorf(codes.DeadlineExceeded, "snapshot %s is not cut within the filesystem freeze timeout %v, deleted it as it may not be application-consistent: %v",
+				snapshotResponse.SnapshotId, cs.fsFreeze.timeout, err)
+		}
 		return nil, status.Errorf(codes.Internal, "failed while waiting for snapshot cut: %v", err)
 	}
 
@@ -573,6 +799,9 @@ func (cs *controllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteS
 	// Check arguments
 	snapshotID := req.GetSnapshotId()
 	klog.Infof("DeleteSnapshot:: starting delete snapshot %s", snapshotID)
+	if isSnapshotGroupID(snapshotID) {
+		return cs.deleteStripedSnapshot(ctx, snapshotID)
+	}
 
 	// Check Snapshot exist
 	snapshot, err := findDiskSnapshotByID(req.SnapshotId)
@@ -593,7 +822,7 @@ func (cs *controllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteS
 	klog.Infof("DeleteSnapshot: Snapshot %s exist with Info: %+v, %+v", snapshotID, snapshot, err)
 
 	var reqId string
-	response, err := requestAndDeleteSnapshot(snapshotID)
+	response, err := requestAndDeleteSnapshot(cs.ecs, snapshotID)
 	if response != nil {
 		reqId = response.RequestId
 	}
@@ -613,6 +842,9 @@ func (cs *controllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteS
 func (cs *controllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
 	klog.Infof("ListSnapshots:: called with args: %+v", req)
 	snapshotID := req.GetSnapshotId()
+	if isSnapshotGroupID(snapshotID) {
+		return cs.listStripedSnapshot(snapshotID)
+	}
 	if len(snapshotID) > 0 {
 		snapshot, err := findDiskSnapshotByID(snapshotID)
 		if err != nil {
@@ -622,7 +854,7 @@ func (cs *controllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnap
 		if snapshot != nil {
 			snapshots = append(snapshots, *snapshot)
 		}
-		return newListSnapshotsResponse(snapshots, "")
+		return cs.listSnapshotsResponse(ctx, snapshots, "")
 	}
 	volumeID := req.GetSourceVolumeId()
 	if volumeID == "" && GlobalConfigVar.ClusterID == "" {
@@ -635,12 +867,71 @@ func (cs *controllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnap
 		// pass through error with error code
 		return nil, err
 	}
-	return newListSnapshotsResponse(snapshots, nextToken)
+	return cs.listSnapshotsResponse(ctx, snapshots, nextToken)
+}
+
+func (cs *controllerServer) listSnapshotsResponse(ctx context.Context, snapshots []ecs.Snapshot, nextToken string) (*csi.ListSnapshotsResponse, error) {
+	resp, err := newListSnapshotsResponse(snapshots, nextToken)
+	if err != nil {
+		return nil, err
+	}
+	cs.fillGroupSnapshotIDs(ctx, resp)
+	return resp, nil
+}
+
+// ListVolumes lists disks created by this driver
+func (cs *controllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
+	klog.Infof("ListVolumes:: called with args: %+v", req)
+	skip, diskToken, err := parseListVolumesToken(req.GetStartingToken())
+	if err != nil {
+		return nil, status.Errorf(codes.Aborted, "Invalid StartingToken %s: %v", req.GetStartingToken(), err)
+	}
+	maxEntries := int(req.GetMaxEntries())
+	disks, nextToken, err := listDisks(cs.ecs, GlobalConfigVar.ClusterID, diskToken, maxEntries)
+	if err != nil {
+		// pass through error with error code
+		return nil, err
+	}
+	// listDisks validated it
+	pos, token, _ := parseNextToken(diskToken)
+	return cs.newListVolumesResponse(ctx, disks, skip, maxEntries, func(i, skip int) string {
+		if i == len(disks) {
+			return nextToken
+		}
+		return encodeListVolumesToken(skip, encodeNextToken(pos+i, token))
+	})
+}
+
+// The token of ListVolumes is that of listDisks, prefixed by "<n>+"
+// if the first n volumes on the first disk have been returned, see newListVolumesResponse.
+func encodeListVolumesToken(skip int, diskToken string) string {
+	if skip == 0 {
+		return diskToken
+	}
+	return fmt.Sprintf("%d+%s", skip, diskToken)
+}
+
+func parseListVolumesToken(t string) (skip int, diskToken string, err error) {
+	skipStr, diskToken, found := strings.Cut(t, "+")
+	if !found {
+		return 0, t, nil
+	}
+	skip, err = strconv.Atoi(skipStr)
+	if err == nil && skip < 0 {
+		err = fmt.Errorf("negative skip %d", skip)
+	}
+	return skip, diskToken, err
 }
 
 func (cs *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest,
 ) (*csi.ControllerExpandVolumeResponse, error) {
 	klog.Infof("ControllerExpandVolume:: Starting expand disk with: %v", req)
+	if mode, diskIDs, ok := parseStripeVolumeID(req.VolumeId); ok {
+		return cs.expandStripedVolume(ctx, req, mode, diskIDs)
+	}
+	if sharedDiskID, _, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		return cs.expandSubpathVolume(ctx, req, sharedDiskID)
+	}
 
 	// check resize conditions
 	volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
@@ -711,6 +1002,75 @@ func newListSnapshotsResponse(snapshots []ecs.Snapshot, nextToken string) (*csi.
 	}, nil
 }
 
+// newListVolumesResponse reports the volumes backed by disks, with the nodes they are published to,
+// skipping the first skip volumes on the first disk.
+// A striped volume is reported for its first member only, and a shared disk is reported as the subpath volumes on it,
+// so that every volume ID is one the CO knows. A shared disk may have more volumes than maxEntries,
+// in which case the response stops in the middle of the disk. nextToken returns the token to resume
+// at the skip-th volume of the i-th disk, and the next token of the whole list for i == len(disks).
+func (cs *controllerServer) newListVolumesResponse(ctx context.Context, disks []ecs.Disk, skip, maxEntries int, nextToken func(i, skip int) string) (*csi.ListVolumesResponse, error) {
+	entries := make([]*csi.ListVolumesResponse_Entry, 0, len(disks))
+	for i := range disks {
+		disk := &disks[i]
+		segments := map[string]string{}
+		if AllCategories[Category(disk.Category)].Regional {
+			segments[RegionalDiskTopologyKey] = disk.RegionId
+		} else {
+			segments[ZonalDiskTopologyKey] = disk.ZoneId
+		}
+		nodeIDs := attachedInstances(disk)
+		entry := func(volumeID string, capacity int64) *csi.ListVolumesResponse_Entry {
+			return &csi.ListVolumesResponse_Entry{
+				Volume: &csi.Volume{
+					VolumeId:           volumeID,
+					CapacityBytes:      capacity,
+					AccessibleTopology: []*csi.Topology{{Segments: segments}},
+				},
+				Status: &csi.ListVolumesResponse_VolumeStatus{
+					PublishedNodeIds: nodeIDs,
+				},
+			}
+		}
+		var diskEntries []*csi.ListVolumesResponse_Entry
+		switch {
+		case diskTag(disk, SharedDiskOwnerTag) != "":
+			volumes, err := cs.subpaths.volumes(ctx, disk.DiskId)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "ListVolumes:: list subpath volumes on shared disk %s: %v", disk.DiskId, err)
+			}
+			for _, volumeID := range slices.Sorted(maps.Keys(volumes)) {
+				diskEntries = append(diskEntries, entry(volumeID, volumes[volumeID]))
+			}
+		case diskTag(disk, StripeMemberTag) != "":
+			volumeID, capacity, err := cs.stripedVolumeOf(ctx, disk)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "ListVolumes:: find striped volume of disk %s: %v", disk.DiskId, err)
+			}
+			if volumeID != "" {
+				diskEntries = append(diskEntries, entry(volumeID, capacity))
+			}
+		default:
+			diskEntries = append(diskEntries, entry(disk.DiskId, utils.Gi2Bytes(int64(disk.Size))))
+		}
+		if i > 0 {
+			skip = 0
+		}
+		diskEntries = diskEntries[min(skip, len(diskEntries)):]
+		if maxEntries > 0 && len(entries)+len(diskEntries) > maxEntries {
+			n := maxEntries - len(entries)
+			return &csi.ListVolumesResponse{
+				Entries:   append(entries, diskEntries[:n]...),
+				NextToken: nextToken(i, skip+n),
+			}, nil
+		}
+		entries = append(entries, diskEntries...)
+	}
+	return &csi.ListVolumesResponse{
+		Entries:   entries,
+		NextToken: nextToken(len(disks), 0),
+	}, nil
+}
+
 func formatCSISnapshot(ecsSnapshot *ecs.Snapshot) (*csi.Snapshot, error) {
 	// creationTime == "" if created by snapshotGroup
 	var creationTime *timestamppb.Timestamp
@@ -749,27 +1109,67 @@ func formatCSISnapshot(ecsSnapshot *ecs.Snapshot) (*csi.Snapshot, error) {
 }
 
 func (cs *controllerServer) ControllerModifyVolume(ctx context.Context, req *csi.ControllerModifyVolumeRequest) (*csi.ControllerModifyVolumeResponse, error) {
+	if _, _, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		return nil, status.Errorf(codes.InvalidArgument, "modifying subpath volume %s is not supported", req.VolumeId)
+	}
 	params, err := parseMutableParameters(req.MutableParameters)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	err = cs.modify.Modify(ctx, req.VolumeId, params)
-	if err != nil {
-		return nil, err
+	pvName := req.MutableParameters[common.PVNameKey]
+	if params.IOLimits != nil && pvName == "" {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"IO limits are stored on the PV, but its name is unknown. Start external-resizer with --extra-modify-metadata")
+	}
+	diskIDs := volumeDisks(req.VolumeId)
+	for _, diskID := range diskIDs {
+		err = cs.modify.Modify(ctx, diskID, params)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if pvName := req.MutableParameters[common.PVNameKey]; pvName != "" {
-		if err := cs.updatePVDiskType(ctx, req.VolumeId, pvName, params); err != nil {
+	if pvName != "" {
+		if err := cs.updatePVDiskType(ctx, diskIDs[0], pvName, params); err != nil {
 			// The disk is already modified, so this must be a non-final error:
 			// external-resizer keeps re-driving this same target instead of
 			// switching to a different VAC.
 			return nil, status.Errorf(codes.Aborted, "update disktype metadata: %v", err)
 		}
+		if err := cs.updatePVIOLimits(ctx, pvName, params.IOLimits); err != nil {
+			return nil, status.Errorf(codes.Aborted, "update IO limits: %v", err)
+		}
 	}
 
 	return &csi.ControllerModifyVolumeResponse{}, nil
 }
 
+// updatePVIOLimits stores the IO limits on the PV, where the node picks them up and
+// re-applies them to the running pods. See nodeServer.reapplyIOLimits.
+func (cs *controllerServer) updatePVIOLimits(ctx context.Context, pvName string, limits map[string]string) error {
+	if limits == nil {
+		return nil
+	}
+	value, err := json.Marshal(limits)
+	if err != nil {
+		return fmt.Errorf("marshal IO limits: %w", err)
+	}
+	patch, err := json.Marshal(v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{annIOLimits: string(value)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("build patch: %w", err)
+	}
+	_, err = cs.clientSet.CoreV1().PersistentVolumes().Patch(ctx, pvName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("patch PV %s: %w", pvName, err)
+	}
+	klog.FromContext(ctx).V(2).Info("updated PV IO limits", "pv", pvName, "limits", limits)
+	return nil
+}
+
 // updatePVDiskType keeps the disktype label and volume-topology annotation on the
 // PV consistent with the disk type after a successful ControllerModifyVolume.
 //
@@ -24,8 +24,12 @@ import (
 	"testing"
 
 	ecs "github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -158,3 +162,25 @@ func TestUpdatePVDiskType(t *testing.T) {
 		require.NoError(t, cs.updatePVDiskType(ctx, "d-x", pvName, ModifyParameters{Category: "cloud_auto"}))
 	})
 }
+
+func TestControllerModifyVolumeIOLimits(t *testing.T) {
+	const pvName = "test-pv"
+	_, ctx := ktesting.NewTestContext(t)
+	client := fake.NewSimpleClientset(&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: pvName}})
+	cs := &controllerServer{clientSet: client}
+
+	_, err := cs.ControllerModifyVolume(ctx, &csi.ControllerModifyVolumeRequest{
+		VolumeId:          "d-x",
+		MutableParameters: map[string]string{"readIOPS": "1000"},
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), err)
+
+	_, err = cs.ControllerModifyVolume(ctx, &csi.ControllerModifyVolumeRequest{
+		VolumeId:          "d-x",
+		MutableParameters: map[string]string{"readIOPS": "1000", "writeBPS": "1M", common.PVNameKey: pvName},
+	})
+	require.NoError(t, err)
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"readIOPS":"1000","writeBPS":"1M"}`, pv.Annotations[annIOLimits])
+}
@@ -0,0 +1,148 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/waitstatus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// copyTargetTagKey is tagged on the snapshot copied from another region to restore a disk from.
+	// Its value is the name of the volume being created, so a retried CreateVolume reuses the same copy.
+	copyTargetTagKey = "csi.alibabacloud.com/copy-target"
+	// copySnapshotRetentionDays makes ECS delete the copied snapshot automatically
+	// in case we never get a chance to clean it up.
+	copySnapshotRetentionDays = 1
+)
+
+// parseSnapshotHandle parses a snapshot ID in the form of <regionID>/<snapshotID>, which refers to a snapshot in another region.
+// regionID is empty if the snapshot is in the current region.
+func parseSnapshotHandle(handle string) (regionID, snapshotID string) {
+	regionID, snapshotID, found := strings.Cut(handle, "/")
+	if !found {
+		return "", handle
+	}
+	if regionID == GlobalConfigVar.Region {
+		return "", snapshotID
+	}
+	return regionID, snapshotID
+}
+
+func copySnapshotName(volumeName string) string {
+	return "copy-" + volumeName
+}
+
+// findSnapshotInRegion describes the snapshot in regionID, or in the current region if regionID is empty.
+func findSnapshotInRegion(ecsClient cloud.ECSInterface, regionID, snapshotID string) (*ecs.Snapshot, error) {
+	req := ecs.CreateDescribeSnapshotsRequest()
+	req.RegionId = regionID
+	if regionID == "" {
+		req.RegionId = GlobalConfigVar.Region
+	}
+	req.SnapshotIds = "[\"" + snapshotID + "\"]"
+	resp, err := ecsClient.DescribeSnapshots(req)
+	if err != nil {
+		return nil, err
+	}
+	s := resp.Snapshots.Snapshot
+	if len(s) == 0 {
+		return nil, nil
+	}
+	if len(s) > 1 {
+		return nil, fmt.Errorf("find more than one snapshot with id %s", snapshotID)
+	}
+	return &s[0], nil
+}
+
+// findCopiedSnapshot finds the snapshot copied to the current region for volumeName.
+// Failed snapshots are ignored.
+func findCopiedSnapshot(ecsClient cloud.ECSInterface, volumeName string) (*ecs.Snapshot, error) {
+	req := ecs.CreateDescribeSnapshotsRequest()
+	req.RegionId = GlobalConfigVar.Region
+	req.Tag = &[]ecs.DescribeSnapshotsTag{
+		{Key: copyTargetTagKey, Value: volumeName},
+	}
+	resp, err := ecsClient.DescribeSnapshots(req)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range resp.Snapshots.Snapshot {
+		if s.Status != SnapshotStatusFailed {
+			return &resp.Snapshots.Snapshot[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// prepareCopiedSnapshot copies (or reuses the copy of) snapshot sourceSnapshotID in regionID to the current region,
+// and waits until the copy can be used to create the disk for volumeName.
+func (cs *controllerServer) prepareCopiedSnapshot(ctx context.Context, volumeName, regionID, sourceSnapshotID string) (string, error) {
+	logger := klog.FromContext(ctx).WithValues("sourceRegionID", regionID, "sourceSnapshotID", sourceSnapshotID)
+
+	// The copy is deleted once the disk is created, don't copy again on retry.
+	disk, err := findDiskByName(volumeName, cs.ecs)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "find existing disk %s failed: %v", volumeName, err)
+	}
+	if disk != nil {
+		logger.V(2).Info("disk already restored", "diskID", disk.DiskId, "snapshotID", disk.SourceSnapshotId)
+		return disk.SourceSnapshotId, nil
+	}
+
+	snapshot, err := findCopiedSnapshot(cs.ecs, volumeName)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "find existing copy of snapshot %s failed: %v", sourceSnapshotID, err)
+	}
+	var snapshotID string
+	if snapshot != nil {
+		snapshotID = snapshot.SnapshotId
+		logger.V(2).Info("reusing existing copied snapshot", "snapshotID", snapshotID)
+	} else {
+		source, err := findSnapshotInRegion(cs.ecs, regionID, sourceSnapshotID)
+		if err != nil {
+			return "", status.Errorf(codes.Internal, "describe snapshot %s in %s failed: %v", sourceSnapshotID, regionID, err)
+		}
+		if source == nil {
+			return "", status.Errorf(codes.NotFound, "snapshot %s not found in %s", sourceSnapshotID, regionID)
+		}
+		if !source.Available {
+			return "", status.Errorf(codes.Unavailable, "snapshot %s in %s is not available for copy yet", sourceSnapshotID, regionID)
+		}
+
+		req := ecs.CreateCopySnapshotRequest()
+		req.RegionId = regionID
+		req.SnapshotId = sourceSnapshotID
+		req.DestinationRegionId = GlobalConfigVar.Region
+		req.DestinationSnapshotName = copySnapshotName(volumeName)
+		req.RetentionDays = requests.NewInteger(copySnapshotRetentionDays)
+		req.Tag = &[]ecs.CopySnapshotTag{
+			{Key: copyTargetTagKey, Value: volumeName},
+		}
+		resp, err := cs.ecs.CopySnapshot(req)
+		if err != nil {
+			return "", status.Errorf(codes.Internal, "copy snapshot %s from %s failed: %v", sourceSnapshotID, regionID, err)
+		}
+		snapshotID = resp.SnapshotId
+		logger.V(2).Info("copying snapshot", "snapshotID", snapshotID)
+	}
+
+	// Copied snapshots are not instant access, wait for the data to be fully copied.
+	snap, err := cs.snapshotWaiter.WaitFor(ctx, snapshotID, func(s *ecs.Snapshot) bool {
+		return s.Status == SnapshotStatusFailed || waitstatus.SnapshotAvailable(s)
+	})
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed while waiting for copied snapshot %s: %v", snapshotID, err)
+	}
+	if snap.Status == SnapshotStatusFailed {
+		return "", status.Errorf(codes.Internal, "copy %s of snapshot %s in %s failed", snapshotID, sourceSnapshotID, regionID)
+	}
+	return snapshotID, nil
+}
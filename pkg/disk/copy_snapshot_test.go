@@ -0,0 +1,133 @@
+//go:build !windows
+
+package disk
+
+import (
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func TestParseSnapshotHandle(t *testing.T) {
+	GlobalConfigVar.Region = "cn-hangzhou"
+	cases := []struct {
+		handle     string
+		regionID   string
+		snapshotID string
+	}{
+		{handle: "s-123", snapshotID: "s-123"},
+		{handle: "cn-hangzhou/s-123", snapshotID: "s-123"},
+		{handle: "cn-shanghai/s-123", regionID: "cn-shanghai", snapshotID: "s-123"},
+	}
+	for _, c := range cases {
+		t.Run(c.handle, func(t *testing.T) {
+			regionID, snapshotID := parseSnapshotHandle(c.handle)
+			assert.Equal(t, c.regionID, regionID)
+			assert.Equal(t, c.snapshotID, snapshotID)
+		})
+	}
+}
+
+func TestPrepareCopiedSnapshot(t *testing.T) {
+	GlobalConfigVar.Region = "cn-hangzhou"
+	noDisk := &ecs.DescribeDisksResponse{}
+	expectFindDisk := func(c *gomock.Call) {
+		c.DoAndReturn(func(req *ecs.DescribeDisksRequest) (*ecs.DescribeDisksResponse, error) {
+			assert.Equal(t, []ecs.DescribeDisksTag{{Key: common.VolumeNameTag, Value: "pvc-new"}}, *req.Tag)
+			return noDisk, nil
+		})
+	}
+
+	t.Run("copy", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		w.snapshot = ecs.Snapshot{Status: SnapshotStatusAccomplished, Available: true}
+		expectFindDisk(c.EXPECT().DescribeDisks(gomock.Any()))
+		c.EXPECT().DescribeSnapshots(gomock.Any()).DoAndReturn(func(req *ecs.DescribeSnapshotsRequest) (*ecs.DescribeSnapshotsResponse, error) {
+			assert.Equal(t, "cn-hangzhou", req.RegionId)
+			assert.Equal(t, []ecs.DescribeSnapshotsTag{{Key: copyTargetTagKey, Value: "pvc-new"}}, *req.Tag)
+			return snapshotsResp(ecs.Snapshot{SnapshotId: "s-failed", Status: SnapshotStatusFailed}), nil
+		})
+		c.EXPECT().DescribeSnapshots(gomock.Any()).DoAndReturn(func(req *ecs.DescribeSnapshotsRequest) (*ecs.DescribeSnapshotsResponse, error) {
+			assert.Equal(t, "cn-shanghai", req.RegionId)
+			assert.Equal(t, `["s-source"]`, req.SnapshotIds)
+			return snapshotsResp(ecs.Snapshot{SnapshotId: "s-source", Available: true}), nil
+		})
+		c.EXPECT().CopySnapshot(gomock.Any()).DoAndReturn(func(req *ecs.CopySnapshotRequest) (*ecs.CopySnapshotResponse, error) {
+			assert.Equal(t, "cn-shanghai", req.RegionId)
+			assert.Equal(t, "s-source", req.SnapshotId)
+			assert.Equal(t, "cn-hangzhou", req.DestinationRegionId)
+			assert.Equal(t, "copy-pvc-new", req.DestinationSnapshotName)
+			assert.Equal(t, []ecs.CopySnapshotTag{{Key: copyTargetTagKey, Value: "pvc-new"}}, *req.Tag)
+			return &ecs.CopySnapshotResponse{SnapshotId: "s-copy"}, nil
+		})
+
+		id, err := cs.prepareCopiedSnapshot(ctx, "pvc-new", "cn-shanghai", "s-source")
+		require.NoError(t, err)
+		assert.Equal(t, "s-copy", id)
+		assert.Equal(t, []string{"s-copy"}, w.waited)
+	})
+
+	t.Run("reuse existing copy", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		w.snapshot = ecs.Snapshot{Status: SnapshotStatusAccomplished, Available: true}
+		expectFindDisk(c.EXPECT().DescribeDisks(gomock.Any()))
+		c.EXPECT().DescribeSnapshots(gomock.Any()).Return(snapshotsResp(ecs.Snapshot{SnapshotId: "s-copy", Status: "progressing"}), nil)
+
+		id, err := cs.prepareCopiedSnapshot(ctx, "pvc-new", "cn-shanghai", "s-source")
+		require.NoError(t, err)
+		assert.Equal(t, "s-copy", id)
+		assert.Equal(t, []string{"s-copy"}, w.waited)
+	})
+
+	t.Run("already restored", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(ecs.Disk{DiskId: "d-new", SourceSnapshotId: "s-copy"}), nil)
+
+		id, err := cs.prepareCopiedSnapshot(ctx, "pvc-new", "cn-shanghai", "s-source")
+		require.NoError(t, err)
+		assert.Equal(t, "s-copy", id)
+		assert.Empty(t, w.waited)
+	})
+
+	t.Run("source not found", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		expectFindDisk(c.EXPECT().DescribeDisks(gomock.Any()))
+		c.EXPECT().DescribeSnapshots(gomock.Any()).Return(snapshotsResp(), nil).Times(2)
+
+		_, err := cs.prepareCopiedSnapshot(ctx, "pvc-new", "cn-shanghai", "s-source")
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("source not available", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		expectFindDisk(c.EXPECT().DescribeDisks(gomock.Any()))
+		c.EXPECT().DescribeSnapshots(gomock.Any()).Return(snapshotsResp(), nil)
+		c.EXPECT().DescribeSnapshots(gomock.Any()).Return(snapshotsResp(ecs.Snapshot{SnapshotId: "s-source", Status: "progressing"}), nil)
+
+		_, err := cs.prepareCopiedSnapshot(ctx, "pvc-new", "cn-shanghai", "s-source")
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("copy failed", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		w.snapshot = ecs.Snapshot{Status: SnapshotStatusFailed}
+		expectFindDisk(c.EXPECT().DescribeDisks(gomock.Any()))
+		c.EXPECT().DescribeSnapshots(gomock.Any()).Return(snapshotsResp(ecs.Snapshot{SnapshotId: "s-copy", Status: "progressing"}), nil)
+
+		_, err := cs.prepareCopiedSnapshot(ctx, "pvc-new", "cn-shanghai", "s-source")
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/datacache"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/luks"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/mounter"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
 	"google.golang.org/grpc/codes"
@@ -113,10 +114,13 @@ func (a *CSIAgent) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpubli
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to cleanup %s: %v", req.TargetPath, err)
 	}
-	// NodePublishVolume set up the data cache (via setupDisk); tear it down
+	// NodePublishVolume set up the data cache and LUKS (via setupDisk); tear them down
 	// here, its symmetric counterpart.
 	if err := datacache.Teardown(ctx, a.ns.dmControl, req.VolumeId); err != nil {
 		return nil, status.Errorf(codes.Internal, "teardown DataCache for %s: %v", req.VolumeId, err)
 	}
+	if err := luks.Close(ctx, req.VolumeId); err != nil {
+		return nil, status.Errorf(codes.Internal, "close LUKS device for %s: %v", req.VolumeId, err)
+	}
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
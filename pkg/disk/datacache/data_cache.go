@@ -127,32 +127,28 @@ func Setup(ctx context.Context, ctrl *DmControl, d *Opts, device, volumeID strin
 	}
 
 	cacheSize := d.Size.Value()
-	meta, data := cacheFilePath(volumeID)
-
-	// Invariant: never truncate or delete an existing .meta/.data while it may
-	// hold un-flushed writeback data. allocCacheFile opens without O_TRUNC and
-	// only fallocate()s, so existing bytes survive. After a reboot (device gone,
-	// files persist) this lets the dm-cache constructor re-open the superblock
-	// instead of reformatting, so the recorded dirty blocks are written back to
-	// the origin on the next teardown (after an unclean crash the superblock
-	// lacks the CLEAN_SHUTDOWN flag and the kernel conservatively treats every
-	// cached block as dirty). Zeroing/recreating the meta file here would
-	// silently discard that data.
-	data, dataFd, err := allocCacheFile(logger, data, cacheSize)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			logger.V(1).Info("data cache not exist on node, proceed without cache")
-			return device, nil
+	var meta, data string
+	if d.VolumeGroup != "" {
+		meta, data, err = allocCacheLVs(ctx, d, volumeID, cacheSize)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logger.V(1).Info("data cache volume group not exist on node, proceed without cache", "vg", d.VolumeGroup, "err", err)
+				return device, nil
+			}
+			return "", fmt.Errorf("failed to allocate cache LVs: %w", err)
 		}
-		return "", fmt.Errorf("failed to allocate cache file: %v", err)
-	}
-	defer loggedClose(logger, dataFd) // be sure to close these FDs after the table load, or the loop device will be removed
-
-	meta, metaFd, err := allocCacheFile(logger, meta, metaSize(cacheSize))
-	if err != nil {
-		return "", fmt.Errorf("failed to allocate meta file: %v", err)
+	} else {
+		var closeFiles func()
+		meta, data, closeFiles, err = allocCacheFiles(logger, volumeID, cacheSize)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				logger.V(1).Info("data cache not exist on node, proceed without cache")
+				return device, nil
+			}
+			return "", err
+		}
+		defer closeFiles() // be sure to close these FDs after the table load, or the loop device will be removed
 	}
-	defer loggedClose(logger, metaFd)
 
 	// create is idempotent: EBUSY means our namespaced device already exists,
 	// left by an earlier attempt that died before resume (its /dev/mapper node
@@ -174,6 +170,38 @@ func Setup(ctx context.Context, ctrl *DmControl, d *Opts, device, volumeID strin
 	return mapperDev, nil
 }
 
+// allocCacheFiles backs the cache with files under DataCachePath on loop devices.
+// closeFiles must be called after the table is loaded.
+func allocCacheFiles(logger klog.Logger, volumeID string, cacheSize int64) (meta, data string, closeFiles func(), err error) {
+	meta, data = cacheFilePath(volumeID)
+
+	// Invariant: never truncate or delete an existing .meta/.data while it may
+	// hold un-flushed writeback data. allocCacheFile opens without O_TRUNC and
+	// only fallocate()s, so existing bytes survive. After a reboot (device gone,
+	// files persist) this lets the dm-cache constructor re-open the superblock
+	// instead of reformatting, so the recorded dirty blocks are written back to
+	// the origin on the next teardown (after an unclean crash the superblock
+	// lacks the CLEAN_SHUTDOWN flag and the kernel conservatively treats every
+	// cached block as dirty). Zeroing/recreating the meta file here would
+	// silently discard that data.
+	data, dataFd, err := allocCacheFile(logger, data, cacheSize)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", "", nil, err
+		}
+		return "", "", nil, fmt.Errorf("failed to allocate cache file: %w", err)
+	}
+	meta, metaFd, err := allocCacheFile(logger, meta, metaSize(cacheSize))
+	if err != nil {
+		loggedClose(logger, dataFd)
+		return "", "", nil, fmt.Errorf("failed to allocate meta file: %w", err)
+	}
+	return meta, data, func() {
+		loggedClose(logger, metaFd)
+		loggedClose(logger, dataFd)
+	}, nil
+}
+
 type cacheStatus struct {
 	dirty     uint64
 	writeback bool
@@ -222,6 +250,90 @@ func parseCacheStatus(status string) (cacheStatus, error) {
 	return s, nil
 }
 
+// Stats is the runtime statistics of a dm-cache device, for monitoring.
+type Stats struct {
+	// BlockSize is the size of a cache block in bytes.
+	BlockSize  uint64
+	UsedBlocks uint64
+	// TotalBlocks is the capacity of the cache in blocks.
+	TotalBlocks uint64
+	Dirty       uint64
+
+	ReadHits    uint64
+	ReadMisses  uint64
+	WriteHits   uint64
+	WriteMisses uint64
+	Demotions   uint64
+	Promotions  uint64
+
+	// Mode is "writeback" or "writethrough", or "cleaner" while the cache is being flushed before teardown.
+	Mode string
+}
+
+// parseStats parses the counters from a dm-cache INFO status line, see parseCacheStatus for the format.
+func parseStats(status string) (*Stats, error) {
+	st, err := parseCacheStatus(status)
+	if err != nil {
+		return nil, err
+	}
+	f := strings.Fields(status)
+	num := func(i int) (uint64, error) {
+		v, err := strconv.ParseUint(f[i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse field %d from %q: %w", i, status, err)
+		}
+		return v, nil
+	}
+	s := &Stats{Dirty: st.dirty}
+	sectors, err := num(2)
+	if err != nil {
+		return nil, err
+	}
+	s.BlockSize = sectors * 512
+	used, total, ok := strings.Cut(f[3], "/")
+	if !ok {
+		return nil, fmt.Errorf("failed to parse cache blocks from %q", status)
+	}
+	if s.UsedBlocks, err = strconv.ParseUint(used, 10, 64); err != nil {
+		return nil, fmt.Errorf("failed to parse used cache blocks from %q: %w", status, err)
+	}
+	if s.TotalBlocks, err = strconv.ParseUint(total, 10, 64); err != nil {
+		return nil, fmt.Errorf("failed to parse total cache blocks from %q: %w", status, err)
+	}
+	for i, p := range []*uint64{&s.ReadHits, &s.ReadMisses, &s.WriteHits, &s.WriteMisses, &s.Demotions, &s.Promotions} {
+		if *p, err = num(4 + i); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case st.policy == cleanerPolicy:
+		s.Mode = cleanerPolicy
+	case st.writeback:
+		s.Mode = string(Writeback)
+	default:
+		s.Mode = string(Writethrough)
+	}
+	return s, nil
+}
+
+// GetStats reads the statistics of the cache of volumeID.
+// A nil ctrl (device-mapper unavailable) or a missing or inactive cache device is (nil, nil),
+// the volume is not cache-backed.
+func GetStats(logger klog.Logger, ctrl *DmControl, volumeID string) (*Stats, error) {
+	if ctrl == nil {
+		return nil, nil
+	}
+	_, info, err := ctrl.device(logger, volumeID).tableStatus(0)
+	if errors.Is(err, unix.ENXIO) || errors.Is(err, errNotActive) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseStats(info)
+}
+
 const cleanerPolicy = "cleaner"
 
 // flushPollInterval is how often flushToClean re-checks the dirty count while
@@ -381,25 +493,53 @@ func clean(path string) error {
 }
 
 // Teardown flushes any dirty blocks back to the origin, removes the
-// cache device, and deletes the backing files. A nil ctrl (device-mapper
+// cache device, and deletes the backing files or LVs. A nil ctrl (device-mapper
 // unavailable on the node) or a missing device (ENXIO) means there is nothing to
 // remove; the backing files are still cleaned up in case they linger. A
 // half-set-up device with no active table is removed without flushing.
+// LVM is only touched if the cache is known to be LV-backed, by its marker or its table.
 func Teardown(ctx context.Context, ctrl *DmControl, volumeID string) error {
 	logger := klog.FromContext(ctx)
+	lvBacked, err := isMarkedLVBacked(volumeID)
+	if err != nil {
+		return err
+	}
 	if ctrl == nil {
 		logger.V(2).Info("device-mapper unavailable, no dm-cache to tear down")
-	} else if err := flushAndRemoveDmCache(ctx, logger, ctrl.device(logger, volumeID)); errors.Is(err, unix.ENXIO) {
-		logger.V(2).Info("no dm-cache to tear down")
-	} else if err != nil {
-		return err
 	} else {
-		logger.V(2).Info("teardown dm-cache")
+		d := ctrl.device(logger, volumeID)
+		lvBacked = lvBacked || isOnLVs(d)
+		if err := flushAndRemoveDmCache(ctx, logger, d); errors.Is(err, unix.ENXIO) {
+			logger.V(2).Info("no dm-cache to tear down")
+		} else if err != nil {
+			return err
+		} else {
+			logger.V(2).Info("teardown dm-cache")
+		}
 	}
 	// Note: loop device has LO_FLAGS_AUTOCLEAR set, so it is auto removed after removing the dm device.
 
 	meta, data := cacheFilePath(volumeID)
-	return errors.Join(clean(meta), clean(data))
+	errs := []error{clean(meta), clean(data)}
+	if lvBacked {
+		errs = append(errs, removeCacheLVs(ctx, volumeID))
+	}
+	return errors.Join(errs...)
+}
+
+// isOnLVs reports whether the metadata device in the active table of d is an LV.
+// A missing device or table is not.
+func isOnLVs(d dmDevice) bool {
+	_, table, err := d.tableStatus(dmStatusTableFlag)
+	if err != nil {
+		return false
+	}
+	return tableOnLVs(table)
+}
+
+func tableOnLVs(table string) bool {
+	meta, _, _ := strings.Cut(table, " ")
+	return isLVMDevice(meta)
 }
 
 // errNotActive is returned by tableStatus when the device exists but has no
@@ -421,14 +561,28 @@ func flushAndRemoveDmCache(ctx context.Context, logger klog.Logger, d dmDevice)
 // is in 512b sectors. It reports whether the volume is cache-backed: a nil ctrl
 // (device-mapper unavailable) or a missing cache device (ENXIO) is (false, nil),
 // so the caller keeps using the origin device.
-func Resize(logger klog.Logger, ctrl *DmControl, volumeID string, size uint64) (bool, error) {
+//
+// The reload also picks up a grown cache device. For a cache backed by LVs,
+// the metadata LV is grown first to fit the cache LV, if it was extended.
+func Resize(ctx context.Context, ctrl *DmControl, volumeID string, size uint64) (bool, error) {
+	logger := klog.FromContext(ctx)
 	if ctrl == nil {
 		return false, nil
 	}
-	err := resize(logger, ctrl.device(logger, volumeID), size)
+	d := ctrl.device(logger, volumeID)
+	_, table, err := d.tableStatus(dmStatusTableFlag)
 	if errors.Is(err, unix.ENXIO) {
 		return false, nil
 	}
+	if err != nil {
+		return false, err
+	}
+	if tableOnLVs(table) {
+		if err := growCacheMetaLV(ctx, volumeID); err != nil {
+			return false, fmt.Errorf("failed to grow cache metadata LV: %w", err)
+		}
+	}
+	err = resize(logger, d, size)
 	return err == nil, err
 }
 
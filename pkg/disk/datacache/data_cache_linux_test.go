@@ -283,7 +283,7 @@ func TestDataCacheResize(t *testing.T) {
 	require.NoError(t, os.Truncate(originFile, newSize), "grow origin file")
 	require.NoError(t, unix.IoctlSetInt(originLoopFd, unix.LOOP_SET_CAPACITY, 0), "LOOP_SET_CAPACITY")
 
-	cached, err := Resize(logger, ctrl, volumeID, newSize/512)
+	cached, err := Resize(ctx, ctrl, volumeID, newSize/512)
 	require.NoError(t, err)
 	require.True(t, cached, "expected volume to be cache-backed")
 	require.Equal(t, int64(newSize), deviceCapacity(t, cacheDev), "size after resize")
@@ -347,6 +347,8 @@ func testDmControl(t *testing.T) *DmControl {
 	require.NoError(t, err, "open dm control")
 	require.NotNil(t, ctrl, "device-mapper unavailable")
 	t.Cleanup(func() { _ = ctrl.close() })
+	// file-backed caches only, keep off the LVM of the host
+	fakeLVM(t, nil)
 	return ctrl
 }
 
@@ -22,6 +22,8 @@ func TestGetOpts(t *testing.T) {
 		opts     map[string]string
 		wantMode Mode
 		wantSize string // empty => zero
+		wantVG   string
+		wantDevs []string
 		wantErr  string // substring; empty => no error
 	}{
 		{
@@ -66,6 +68,44 @@ func TestGetOpts(t *testing.T) {
 			opts:    map[string]string{sizeKey: "10Gi", modeKey: "bogus"},
 			wantErr: "unrecognized " + modeKey,
 		},
+		{
+			name:     "volume group",
+			opts:     map[string]string{sizeKey: "10Gi", volumeGroupKey: "vg-nvme", devicesKey: "/dev/disk/by-id/nvme-local1, /dev/disk/by-id/nvme-local2"},
+			wantMode: Writethrough,
+			wantSize: "10Gi",
+			wantVG:   "vg-nvme",
+			wantDevs: []string{"/dev/disk/by-id/nvme-local1", "/dev/disk/by-id/nvme-local2"},
+		},
+		{
+			name:    "volume group without size",
+			opts:    map[string]string{volumeGroupKey: "vg-nvme"},
+			wantErr: "must specify non-zero",
+		},
+		{
+			name:    "invalid volume group",
+			opts:    map[string]string{sizeKey: "10Gi", volumeGroupKey: "-vg"},
+			wantErr: "invalid " + volumeGroupKey,
+		},
+		{
+			name:    "devices without volume group",
+			opts:    map[string]string{sizeKey: "10Gi", devicesKey: "/dev/disk/by-id/nvme-local1"},
+			wantErr: devicesKey + " requires " + volumeGroupKey,
+		},
+		{
+			name:    "invalid device",
+			opts:    map[string]string{sizeKey: "10Gi", volumeGroupKey: "vg-nvme", devicesKey: "/dev/../etc/passwd"},
+			wantErr: "invalid device",
+		},
+		{
+			name:    "device not by id",
+			opts:    map[string]string{sizeKey: "10Gi", volumeGroupKey: "vg-nvme", devicesKey: "/dev/nvme1n1"},
+			wantErr: "invalid device",
+		},
+		{
+			name:    "device escapes by id",
+			opts:    map[string]string{sizeKey: "10Gi", volumeGroupKey: "vg-nvme", devicesKey: "/dev/disk/by-id/../../vdb"},
+			wantErr: "invalid device",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -77,6 +117,8 @@ func TestGetOpts(t *testing.T) {
 			}
 			require.NoError(t, err)
 			assert.Equal(t, tt.wantMode, d.Mode)
+			assert.Equal(t, tt.wantVG, d.VolumeGroup)
+			assert.Equal(t, tt.wantDevs, d.Devices)
 			wantSize := resource.Quantity{}
 			if tt.wantSize != "" {
 				wantSize = resource.MustParse(tt.wantSize)
@@ -128,6 +170,32 @@ func TestParseCacheStatus(t *testing.T) {
 	}
 }
 
+func TestParseStats(t *testing.T) {
+	const wbMqNdp = "8 9/4096 512 9/256 14 34 3 5 1 9 9 3 metadata2 writeback no_discard_passdown 2 migration_threshold 4096 mq 10 random_threshold 0 sequential_threshold 0 discard_promote_adjustment 0 read_promote_adjustment 0 write_promote_adjustment 0 rw -"
+	s, err := parseStats(wbMqNdp)
+	require.NoError(t, err)
+	assert.Equal(t, &Stats{
+		BlockSize:   256 << 10,
+		UsedBlocks:  9,
+		TotalBlocks: 256,
+		Dirty:       9,
+		ReadHits:    14,
+		ReadMisses:  34,
+		WriteHits:   3,
+		WriteMisses: 5,
+		Demotions:   1,
+		Promotions:  9,
+		Mode:        "writeback",
+	}, s)
+
+	s, err = parseStats("8 12/4096 512 0/256 0 96 0 0 0 0 0 2 metadata2 writethrough 2 migration_threshold 2048 cleaner 0 rw -")
+	require.NoError(t, err)
+	assert.Equal(t, cleanerPolicy, s.Mode)
+
+	_, err = parseStats("8 12/4096 512 0-256 0 96 0 0 0 0 0 2 metadata2 writethrough 2 migration_threshold 2048 mq 0 rw -")
+	assert.Error(t, err)
+}
+
 func TestSplitCacheTable(t *testing.T) {
 	devices, tail, err := splitCacheTable("7:0 7:1 7:2 512 2 metadata2 writeback mq 2 migration_threshold 4096")
 	require.NoError(t, err)
@@ -0,0 +1,299 @@
+//go:build !windows
+
+package datacache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	utilsos "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/os"
+	"k8s.io/klog/v2"
+)
+
+// The cache and its metadata can be carved from an LVM volume group on local
+// (NVMe) disks instead of files on loop devices, saving the loop overhead.
+// Each volume gets two linear LVs named after its dm-cache device, so they can
+// be found for teardown without knowing the options the volume was staged with.
+//
+// LVM commands run on the host, which owns the LVM metadata, locks and udev rules.
+// LVM is only touched for volumes known to be LV-backed: a marker file is written under lvMarkerPath
+// before the LVs are created, and removed with them.
+
+// errNoLVM is returned by runLVM if lvm is not installed on the node.
+var errNoLVM = errors.New("lvm not found on node")
+
+const (
+	// lvmTimeout bounds each lvm command, which can hang on a stuck device or a held lock.
+	lvmTimeout = 2 * time.Minute
+	// lvmKillDelay is how long an lvm command interrupted by SIGTERM has to leave its critical section before it is killed.
+	lvmKillDelay = 30 * time.Second
+)
+
+// runLVM runs an lvm subcommand on the node, returning its stdout.
+// A variable so the tests can fake it.
+var runLVM = func(ctx context.Context, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, lvmTimeout)
+	defer cancel()
+	cmd := utils.CommandOnNodeContext(ctx, append([]string{"lvm"}, args...)...)
+	cmd.Env = append(os.Environ(), "LVM_SUPPRESS_FD_WARNINGS=1")
+	// nsenter execs lvm, which blocks signals while updating the metadata, so ask it to stop first.
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = lvmKillDelay
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 127 { // nsenter: command not found
+			return "", errNoLVM
+		}
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("lvm %s aborted: %w: %w", args[0], ctx.Err(), utilsos.ErrWithStderr(err))
+		}
+		return "", fmt.Errorf("lvm %s failed: %w", args[0], utilsos.ErrWithStderr(err))
+	}
+	klog.FromContext(ctx).V(4).Info("lvm success", "args", args, "output", string(out))
+	return string(out), nil
+}
+
+func cacheLVNames(volumeID string) (meta, data string) {
+	// not _cmeta/_cdata, which are reserved by LVM for cache pools
+	return deviceName(volumeID) + "-meta", deviceName(volumeID) + "-data"
+}
+
+// lvMarkerPath is where the markers of the LV-backed volumes are. A variable so the tests can change it.
+var lvMarkerPath = "/var/alibaba-cloud-csi/data-cache-lvm"
+
+func lvMarker(volumeID string) string {
+	return filepath.Join(lvMarkerPath, volumeID)
+}
+
+func markLVBacked(volumeID string) error {
+	if err := os.MkdirAll(lvMarkerPath, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(lvMarker(volumeID), nil, 0o600)
+}
+
+// isMarkedLVBacked reports whether the cache of volumeID may have LVs.
+func isMarkedLVBacked(volumeID string) (bool, error) {
+	_, err := os.Stat(lvMarker(volumeID))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// sysfsDevBlockPath is /sys/dev/block, a variable so the tests can fake it.
+var sysfsDevBlockPath = "/sys/dev/block"
+
+// isLVMDevice reports whether the block device majMin (e.g. "253:3", as in a device-mapper table) is an LVM LV.
+// It covers volumes staged before the markers were written.
+func isLVMDevice(majMin string) bool {
+	uuid, err := os.ReadFile(filepath.Join(sysfsDevBlockPath, majMin, "dm/uuid"))
+	return err == nil && strings.HasPrefix(string(uuid), "LVM-")
+}
+
+func lvPath(vg, lv string) string {
+	return "/dev/" + vg + "/" + lv
+}
+
+type logicalVolume struct {
+	vg     string
+	name   string
+	size   int64 // in bytes
+	active bool
+}
+
+func (lv *logicalVolume) fullName() string {
+	return lv.vg + "/" + lv.name
+}
+
+// listCacheLVs lists the cache LVs of volumeID, in any volume group.
+func listCacheLVs(ctx context.Context, volumeID string) ([]logicalVolume, error) {
+	meta, data := cacheLVNames(volumeID)
+	out, err := runLVM(ctx, "lvs", "--noheadings", "--separator", "|", "--units", "b", "--nosuffix",
+		"-o", "vg_name,lv_name,lv_size,lv_active", "-S", "lv_name="+meta+"||lv_name="+data)
+	if err != nil {
+		return nil, err
+	}
+	var lvs []logicalVolume
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		f := strings.Split(line, "|")
+		if len(f) != 4 {
+			return nil, fmt.Errorf("unexpected lvs output: %q", line)
+		}
+		size, err := strconv.ParseInt(f[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected size in lvs output %q: %w", line, err)
+		}
+		lvs = append(lvs, logicalVolume{vg: f[0], name: f[1], size: size, active: f[3] == "active"})
+	}
+	return lvs, nil
+}
+
+// ensureVolumeGroup creates vg from devices if it does not exist yet, after checkDevice accepted all of them.
+// Without devices, a missing vg (or LVM not installed on the node) is reported as os.ErrNotExist,
+// the same as a missing cache directory.
+func ensureVolumeGroup(ctx context.Context, vg string, devices []string, checkDevice func(ctx context.Context, device string) error) error {
+	out, err := runLVM(ctx, "vgs", "--noheadings", "-o", "vg_name")
+	if err != nil {
+		if errors.Is(err, errNoLVM) {
+			return fmt.Errorf("%w: %w", errNoLVM, os.ErrNotExist)
+		}
+		return err
+	}
+	if slices.Contains(strings.Fields(out), vg) {
+		return nil
+	}
+	if len(devices) == 0 {
+		return fmt.Errorf("volume group %s: %w", vg, os.ErrNotExist)
+	}
+	if checkDevice == nil {
+		return fmt.Errorf("cannot verify devices %v for volume group %s", devices, vg)
+	}
+	for _, dev := range devices {
+		if err := checkDevice(ctx, dev); err != nil {
+			return fmt.Errorf("refuse to create volume group %s on %s: %w", vg, dev, err)
+		}
+	}
+	// Not forced: vgcreate refuses devices with an existing signature, so we never wipe data we don't own.
+	if _, err := runLVM(ctx, append([]string{"vgcreate", "--yes", vg}, devices...)...); err != nil {
+		return err
+	}
+	klog.FromContext(ctx).Info("created volume group for data cache", "vg", vg, "devices", devices)
+	return nil
+}
+
+// ensureLV creates or activates the LV, growing it if smaller than size.
+// Like the cache files, an existing LV is never recreated, it may hold un-flushed writeback data.
+func ensureLV(ctx context.Context, vg, name string, size int64, existing []logicalVolume) (string, error) {
+	logger := klog.FromContext(ctx)
+	sizeArg := strconv.FormatInt(size, 10) + "b"
+	i := slices.IndexFunc(existing, func(lv logicalVolume) bool { return lv.name == name })
+	if i < 0 {
+		// zero the head so dm-cache formats new metadata instead of reading garbage
+		_, err := runLVM(ctx, "lvcreate", "--yes", "--zero", "y", "--wipesignatures", "y", "--activate", "y",
+			"--name", name, "--size", sizeArg, vg)
+		if err != nil {
+			return "", err
+		}
+		logger.V(2).Info("created LV for data cache", "lv", vg+"/"+name, "size", size)
+		return lvPath(vg, name), nil
+	}
+	lv := existing[i]
+	if lv.vg != vg {
+		return "", fmt.Errorf("LV %s already exists in volume group %s, not %s", name, lv.vg, vg)
+	}
+	if !lv.active {
+		if _, err := runLVM(ctx, "lvchange", "--activate", "y", lv.fullName()); err != nil {
+			return "", err
+		}
+	}
+	if lv.size < size {
+		if _, err := runLVM(ctx, "lvextend", "--size", sizeArg, lv.fullName()); err != nil {
+			return "", err
+		}
+		logger.V(2).Info("extended LV for data cache", "lv", lv.fullName(), "from", lv.size, "to", size)
+	}
+	return lvPath(vg, name), nil
+}
+
+// allocCacheLVs returns the paths of the metadata and cache LVs of volumeID in d.VolumeGroup.
+func allocCacheLVs(ctx context.Context, d *Opts, volumeID string, cacheSize int64) (meta, data string, err error) {
+	vg := d.VolumeGroup
+	if err := ensureVolumeGroup(ctx, vg, d.Devices, d.CheckDevice); err != nil {
+		return "", "", err
+	}
+	// before any LV is created, so teardown always finds them
+	if err := markLVBacked(volumeID); err != nil {
+		return "", "", fmt.Errorf("failed to mark volume as LV-backed: %w", err)
+	}
+	existing, err := listCacheLVs(ctx, volumeID)
+	if err != nil {
+		return "", "", err
+	}
+	metaName, dataName := cacheLVNames(volumeID)
+	if data, err = ensureLV(ctx, vg, dataName, cacheSize, existing); err != nil {
+		return "", "", err
+	}
+	for _, lv := range existing {
+		if lv.name == dataName {
+			cacheSize = max(cacheSize, lv.size) // never shrunk
+		}
+	}
+	if meta, err = ensureLV(ctx, vg, metaName, metaSize(cacheSize), existing); err != nil {
+		return "", "", err
+	}
+	return meta, data, nil
+}
+
+// removeCacheLVs removes the cache LVs of volumeID, if any, then its marker.
+// The dm-cache device on top of them must be removed first.
+func removeCacheLVs(ctx context.Context, volumeID string) error {
+	if err := removeLVs(ctx, volumeID); err != nil {
+		return err
+	}
+	return clean(lvMarker(volumeID))
+}
+
+func removeLVs(ctx context.Context, volumeID string) error {
+	lvs, err := listCacheLVs(ctx, volumeID)
+	if err != nil {
+		if errors.Is(err, errNoLVM) {
+			return nil // no lvm, no LVs
+		}
+		return err
+	}
+	if len(lvs) == 0 {
+		return nil
+	}
+	args := []string{"lvremove", "--yes"}
+	for _, lv := range lvs {
+		args = append(args, lv.fullName())
+	}
+	if _, err := runLVM(ctx, args...); err != nil {
+		return err
+	}
+	klog.FromContext(ctx).V(2).Info("removed LVs of data cache", "lvs", args[2:])
+	return nil
+}
+
+// growCacheMetaLV grows the metadata LV to fit the cache LV, which may have been extended by an admin,
+// so the next table load can use the whole cache. It is a no-op if the LVs of volumeID are not found.
+func growCacheMetaLV(ctx context.Context, volumeID string) error {
+	lvs, err := listCacheLVs(ctx, volumeID)
+	if err != nil {
+		if errors.Is(err, errNoLVM) {
+			return nil
+		}
+		return err
+	}
+	metaName, dataName := cacheLVNames(volumeID)
+	var meta, data *logicalVolume
+	for i := range lvs {
+		switch lvs[i].name {
+		case metaName:
+			meta = &lvs[i]
+		case dataName:
+			data = &lvs[i]
+		}
+	}
+	if meta == nil || data == nil {
+		return nil
+	}
+	_, err = ensureLV(ctx, meta.vg, meta.name, metaSize(data.size), lvs)
+	return err
+}
@@ -0,0 +1,202 @@
+//go:build !windows
+
+package datacache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+)
+
+// fakeLVMState models the volume groups and LVs on the node, just enough for the lvm commands we run.
+// A nil state means lvm is not installed.
+type fakeLVMState struct {
+	vgs   map[string][]string // devices
+	lvs   map[string]*logicalVolume
+	calls []string
+}
+
+func fakeLVM(t *testing.T, state *fakeLVMState) {
+	orig, origMarkerPath := runLVM, lvMarkerPath
+	t.Cleanup(func() { runLVM, lvMarkerPath = orig, origMarkerPath })
+	lvMarkerPath = t.TempDir()
+	runLVM = func(ctx context.Context, args ...string) (string, error) {
+		if state == nil {
+			return "", errNoLVM
+		}
+		state.calls = append(state.calls, strings.Join(args, " "))
+		flag := func(name string) string {
+			i := slices.Index(args, name)
+			require.GreaterOrEqual(t, i, 0, "missing %s in %v", name, args)
+			return args[i+1]
+		}
+		parseSize := func(s string) int64 {
+			size, err := strconv.ParseInt(strings.TrimSuffix(s, "b"), 10, 64)
+			require.NoError(t, err)
+			return size
+		}
+		switch args[0] {
+		case "vgs":
+			var out strings.Builder
+			for vg := range state.vgs {
+				fmt.Fprintf(&out, "  %s\n", vg)
+			}
+			return out.String(), nil
+		case "vgcreate":
+			state.vgs[args[2]] = args[3:]
+			return "", nil
+		case "lvs":
+			var out strings.Builder
+			for _, name := range strings.Split(flag("-S"), "||") {
+				if lv, ok := state.lvs[strings.TrimPrefix(name, "lv_name=")]; ok {
+					active := ""
+					if lv.active {
+						active = "active"
+					}
+					fmt.Fprintf(&out, "  %s|%s|%d|%s\n", lv.vg, lv.name, lv.size, active)
+				}
+			}
+			return out.String(), nil
+		case "lvcreate":
+			vg := args[len(args)-1]
+			if _, ok := state.vgs[vg]; !ok {
+				return "", errors.New("volume group not found")
+			}
+			name := flag("--name")
+			state.lvs[name] = &logicalVolume{vg: vg, name: name, size: parseSize(flag("--size")), active: true}
+			return "", nil
+		case "lvchange":
+			state.lvs[strings.Split(args[len(args)-1], "/")[1]].active = true
+			return "", nil
+		case "lvextend":
+			state.lvs[strings.Split(args[len(args)-1], "/")[1]].size = parseSize(flag("--size"))
+			return "", nil
+		case "lvremove":
+			for _, fullName := range args[2:] {
+				delete(state.lvs, strings.Split(fullName, "/")[1])
+			}
+			return "", nil
+		}
+		return "", fmt.Errorf("unexpected lvm command %v", args)
+	}
+}
+
+func TestAllocCacheLVs(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	const volumeID = "d-test"
+	const cacheSize = 1 << 30
+	const device = "/dev/disk/by-id/nvme-local1"
+	metaName, dataName := cacheLVNames(volumeID)
+	acceptAll := func(ctx context.Context, device string) error { return nil }
+	opts := func(devices ...string) *Opts {
+		return &Opts{VolumeGroup: "vg-nvme", Devices: devices, CheckDevice: acceptAll}
+	}
+	marked := func(t *testing.T) bool {
+		ok, err := isMarkedLVBacked(volumeID)
+		require.NoError(t, err)
+		return ok
+	}
+
+	t.Run("no lvm", func(t *testing.T) {
+		fakeLVM(t, nil)
+		_, _, err := allocCacheLVs(ctx, opts(), volumeID, cacheSize)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.False(t, marked(t))
+		assert.NoError(t, removeCacheLVs(ctx, volumeID))
+	})
+	t.Run("no volume group", func(t *testing.T) {
+		fakeLVM(t, &fakeLVMState{vgs: map[string][]string{}, lvs: map[string]*logicalVolume{}})
+		_, _, err := allocCacheLVs(ctx, opts(), volumeID, cacheSize)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.False(t, marked(t))
+	})
+	t.Run("refused device", func(t *testing.T) {
+		state := &fakeLVMState{vgs: map[string][]string{}, lvs: map[string]*logicalVolume{}}
+		fakeLVM(t, state)
+		d := opts(device, "/dev/disk/by-id/virtio-bp1cloud")
+		d.CheckDevice = func(ctx context.Context, device string) error {
+			if strings.Contains(device, "cloud") {
+				return errors.New("cloud disk attached to this node")
+			}
+			return nil
+		}
+		_, _, err := allocCacheLVs(ctx, d, volumeID, cacheSize)
+		assert.ErrorContains(t, err, "cloud disk attached to this node")
+		assert.Empty(t, state.vgs)
+
+		d.CheckDevice = nil
+		_, _, err = allocCacheLVs(ctx, d, volumeID, cacheSize)
+		assert.ErrorContains(t, err, "cannot verify devices")
+		assert.Empty(t, state.vgs)
+	})
+	t.Run("create volume group", func(t *testing.T) {
+		state := &fakeLVMState{vgs: map[string][]string{}, lvs: map[string]*logicalVolume{}}
+		fakeLVM(t, state)
+		meta, data, err := allocCacheLVs(ctx, opts(device), volumeID, cacheSize)
+		require.NoError(t, err)
+		assert.Equal(t, "/dev/vg-nvme/"+metaName, meta)
+		assert.Equal(t, "/dev/vg-nvme/"+dataName, data)
+		assert.Equal(t, []string{device}, state.vgs["vg-nvme"])
+		assert.Equal(t, int64(cacheSize), state.lvs[dataName].size)
+		assert.Equal(t, metaSize(cacheSize), state.lvs[metaName].size)
+		assert.True(t, marked(t))
+
+		// restage after reboot: reuse the LVs, never recreate
+		state.lvs[dataName].active = false
+		state.lvs[metaName].active = false
+		state.calls = nil
+		_, _, err = allocCacheLVs(ctx, opts(device), volumeID, cacheSize)
+		require.NoError(t, err)
+		for _, call := range state.calls {
+			assert.NotContains(t, call, "create")
+		}
+		assert.True(t, state.lvs[dataName].active)
+		assert.True(t, state.lvs[metaName].active)
+
+		// cache LV extended by admin, grow the metadata to fit
+		state.lvs[dataName].size = 4 * cacheSize
+		require.NoError(t, growCacheMetaLV(ctx, volumeID))
+		assert.Equal(t, metaSize(4*cacheSize), state.lvs[metaName].size)
+
+		require.NoError(t, removeCacheLVs(ctx, volumeID))
+		assert.Empty(t, state.lvs)
+		assert.False(t, marked(t))
+		require.NoError(t, removeCacheLVs(ctx, volumeID))
+	})
+	t.Run("LV in another volume group", func(t *testing.T) {
+		fakeLVM(t, &fakeLVMState{
+			vgs: map[string][]string{"vg-nvme": nil, "vg-other": nil},
+			lvs: map[string]*logicalVolume{dataName: {vg: "vg-other", name: dataName, size: cacheSize, active: true}},
+		})
+		_, _, err := allocCacheLVs(ctx, opts(), volumeID, cacheSize)
+		assert.ErrorContains(t, err, "already exists in volume group vg-other")
+	})
+}
+
+func TestTableOnLVs(t *testing.T) {
+	orig := sysfsDevBlockPath
+	t.Cleanup(func() { sysfsDevBlockPath = orig })
+	sysfsDevBlockPath = t.TempDir()
+	writeUUID := func(majMin, uuid string) {
+		dir := filepath.Join(sysfsDevBlockPath, majMin, "dm")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "uuid"), []byte(uuid+"\n"), 0o644))
+	}
+	writeUUID("253:3", "LVM-abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUV")
+	writeUUID("253:4", "CRYPT-LUKS2-0123456789abcdef-d-test")
+
+	tail := cacheArgsTail(Writeback)
+	assert.True(t, tableOnLVs("253:3 253:5 253:0 "+tail))
+	assert.False(t, tableOnLVs("253:4 253:5 253:0 "+tail), "not an LV")
+	assert.False(t, tableOnLVs("7:1 7:2 253:0 "+tail), "loop devices of cache files")
+}
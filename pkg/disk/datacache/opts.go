@@ -1,7 +1,11 @@
 package datacache
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/resource"
 )
@@ -16,6 +20,15 @@ const (
 type Opts struct {
 	Size resource.Quantity
 	Mode Mode
+	// VolumeGroup is the LVM volume group on the node to carve the cache from.
+	// If empty, the cache is backed by files under DataCachePath.
+	VolumeGroup string
+	// Devices are the local block devices to create VolumeGroup from, if it does not exist on the node,
+	// as links under /dev/disk/by-id so they are stable across reboots.
+	Devices []string
+	// CheckDevice refuses a device of Devices that must not be used for the cache, e.g. a cloud disk attached to the node.
+	// Not parsed from the options, the volume group is never created from Devices if it is not set.
+	CheckDevice func(ctx context.Context, device string) error
 }
 
 func (o *Opts) Enabled() bool {
@@ -23,10 +36,19 @@ func (o *Opts) Enabled() bool {
 }
 
 const (
-	modeKey = "dataCacheMode"
-	sizeKey = "dataCacheSize"
+	modeKey        = "dataCacheMode"
+	sizeKey        = "dataCacheSize"
+	volumeGroupKey = "dataCacheVolumeGroup"
+	devicesKey     = "dataCacheDevices"
 )
 
+// devicesByIDPath is the only place dataCacheDevices can be from: unlike /dev/vdb or /dev/nvme1n1,
+// these names never refer to another disk after a reboot or a hot plug.
+const devicesByIDPath = "/dev/disk/by-id/"
+
+// vgNameRegexp is the characters allowed in LVM names
+var vgNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9+_.][a-zA-Z0-9+_.-]*$`)
+
 func GetOpts(opts map[string]string, d *Opts) error {
 	if s := opts[sizeKey]; s != "" {
 		size, err := resource.ParseQuantity(s)
@@ -43,7 +65,27 @@ func GetOpts(opts map[string]string, d *Opts) error {
 		return fmt.Errorf("unrecognized %s: %s", modeKey, m)
 	}
 
-	if d.Mode != "" || !d.Size.IsZero() {
+	if vg := opts[volumeGroupKey]; vg != "" {
+		if len(vg) > 127 || !vgNameRegexp.MatchString(vg) {
+			return fmt.Errorf("invalid %s: %s", volumeGroupKey, vg)
+		}
+		d.VolumeGroup = vg
+	}
+	if devices := opts[devicesKey]; devices != "" {
+		if d.VolumeGroup == "" {
+			return fmt.Errorf("%s requires %s", devicesKey, volumeGroupKey)
+		}
+		d.Devices = nil
+		for _, dev := range strings.Split(devices, ",") {
+			dev = strings.TrimSpace(dev)
+			if !strings.HasPrefix(dev, devicesByIDPath) || filepath.Clean(dev) != dev {
+				return fmt.Errorf("invalid device in %s: %q", devicesKey, dev)
+			}
+			d.Devices = append(d.Devices, dev)
+		}
+	}
+
+	if d.Mode != "" || !d.Size.IsZero() || d.VolumeGroup != "" {
 		if d.Size.IsZero() {
 			return fmt.Errorf("must specify non-zero %s for dataCache", sizeKey)
 		}
@@ -119,6 +119,28 @@ func (m *DeviceManager) deviceName(devicePath string) (string, error) {
 	return filepath.Base(link), nil
 }
 
+// GetDeviceSerialByPath returns the serial of the disk that devicePath (e.g. a link under /dev/disk/by-id) refers to.
+// For a partition, it is the serial of the disk the partition is on.
+func (m *DeviceManager) GetDeviceSerialByPath(devicePath string) (string, error) {
+	major, minor, err := m.DevTmpFS.DevFor(devicePath)
+	if err != nil {
+		return "", err
+	}
+	sysfsDir := m.sysfsDir(major, minor)
+	link, err := os.Readlink(sysfsDir)
+	if err != nil {
+		return "", err
+	}
+	blockName := filepath.Base(link)
+	if _, err := os.Stat(sysfsDir + "/partition"); err == nil {
+		// partitions are in the directory of their disk, e.g. .../nvme1/nvme1n1/nvme1n1p1
+		blockName = filepath.Base(filepath.Dir(link))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	return m.GetDeviceSerial(blockName)
+}
+
 // We only support static volume with exactly one partition, and is manually formatted.
 // Return the root or partition block device path if it is OK to use.
 func (m *DeviceManager) adaptDevicePartition(rootDevicePath string) (string, error) {
@@ -254,6 +254,24 @@ func TestAdaptDevicePartitionMultiplePartitions(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestGetDeviceSerialByPath(t *testing.T) {
+	m := testingManager(t)
+	sysfsDev := setupNVMeBlockDevice(t, m.SysfsPath)
+	sysfsSetupPartition(t, m.SysfsPath, sysfsDev, "nvme1n1p27", &nvmePart, 27)
+	m.DevTmpFS.(*fakeDevTmpFS).Devs = []fakeDev{nvmeDev, nvmeLink, nvmePart, otherDev}
+
+	for _, dev := range []string{"nvme1n1", nvmeLink.Path, "nvme1n1p27"} {
+		t.Run(dev, func(t *testing.T) {
+			serial, err := m.GetDeviceSerialByPath(filepath.Join(m.DevicePath, dev))
+			assert.NoError(t, err)
+			assert.Equal(t, "mydiskserial", serial)
+		})
+	}
+
+	_, err := m.GetDeviceSerialByPath(filepath.Join(m.DevicePath, "other0"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
 func TestGetRootBlockByVolumeID_Link(t *testing.T) {
 	tc := []struct {
 		name       string
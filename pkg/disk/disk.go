@@ -114,14 +114,15 @@ func NewServers(m metadata.MetadataProvider, ecsV2 cloud.ECSv2Interface, endpoin
 	// Create GRPC servers
 	var servers common.Servers
 	servers.IdentityServer = NewIdentityServer()
+	snapshotGroups := newSnapshotGroupIndexCache()
 	if serviceType&utils.Controller != 0 {
-		servers.ControllerServer = NewControllerServer(csiCfg, client, ecsV2, m)
+		servers.ControllerServer = NewControllerServer(csiCfg, client, ecsV2, m, snapshotGroups)
 	}
 	if serviceType&utils.Node != 0 {
 		servers.NodeServer = NewNodeServer(csiCfg, client, ecsV2, m, useLabeler)
 	}
 	if features.FunctionalMutableFeatureGate.Enabled(features.EnableVolumeGroupSnapshots) {
-		servers.GroupControllerServer = NewGroupControllerServer()
+		servers.GroupControllerServer = NewGroupControllerServer(csiCfg, snapshotGroups)
 	}
 
 	return &servers
@@ -0,0 +1,263 @@
+package disk
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// Application-consistent snapshots:
+// the controller asks the node plugins to freeze the filesystems on the source disks,
+// cuts the snapshots, then asks them to thaw.
+// The nodes thaw automatically after the timeout in the request, so a crashed controller never leaves a filesystem frozen.
+// The requests carry the service account token of the controller, so they are sent over TLS to a port of their own.
+// Each node plugin generates a self-signed certificate on startup and publishes it in fsFreezeCertAnnotation
+// of its Node, which the controller trusts for that node only.
+const (
+	fsFreezePath = "/disk/fsfreeze"
+	fsThawPath   = "/disk/fsthaw"
+
+	// fsFreezeTimeout is the hard limit of a freeze, including creating the snapshots and waiting for them to be cut.
+	fsFreezeTimeout = 30 * time.Second
+	// fsFreezeRequestTimeout limits each HTTP request to the node plugins.
+	fsFreezeRequestTimeout = 10 * time.Second
+
+	// fsFreezeTokenPath is the projected service account token presented to the node plugins, with DriverName as the audience.
+	fsFreezeTokenPath = "/var/run/secrets/" + DriverName + "/token"
+
+	fsFreezeCertAnnotation = "csi.alibabacloud.com/fsfreeze-certificate"
+)
+
+func fsFreezePort(csiCfg utils.Config) string {
+	return csiCfg.Get("disk-fsfreeze-port", "DISK_FSFREEZE_PORT", "11261")
+}
+
+// fsFreezeRequest is the body of fsFreezePath and fsThawPath requests.
+type fsFreezeRequest struct {
+	VolumeIDs []string `json:"volumeIDs"`
+	// TimeoutSeconds is when the node should thaw the volumes by itself. Only used by freeze.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// fsFreezeClient freezes and thaws the filesystems of disks through the node plugins they are attached to.
+type fsFreezeClient struct {
+	clientSet kubernetes.Interface
+	// port of the fsfreeze service of the node plugins
+	port      string
+	tokenPath string
+	timeout   time.Duration
+}
+
+func newFsFreezeClient(csiCfg utils.Config, clientSet kubernetes.Interface) *fsFreezeClient {
+	return &fsFreezeClient{
+		clientSet: clientSet,
+		port:      fsFreezePort(csiCfg),
+		tokenPath: fsFreezeTokenPath,
+		timeout:   fsFreezeTimeout,
+	}
+}
+
+// fsFreezeNode is a node plugin to send freeze requests to.
+type fsFreezeNode struct {
+	addr      string
+	volumeIDs []string
+	// client trusts only the certificate published by the node plugin
+	client *http.Client
+}
+
+// volumesByNode groups the volumes by the node plugins their disks are attached to, keyed by node name.
+// Disks not attached anywhere are skipped, nobody is writing to them.
+func (c *fsFreezeClient) volumesByNode(ctx context.Context, volumes map[string][]ecs.Disk) (map[string]*fsFreezeNode, error) {
+	instances := map[string][]string{}
+	for volumeID, disks := range volumes {
+		for i := range disks {
+			for _, instanceID := range attachedInstances(&disks[i]) {
+				// the members of a striped volume are attached to the same node
+				if !slices.Contains(instances[instanceID], volumeID) {
+					instances[instanceID] = append(instances[instanceID], volumeID)
+				}
+			}
+		}
+	}
+	for _, volumeIDs := range instances {
+		slices.Sort(volumeIDs)
+	}
+	if len(instances) == 0 {
+		return nil, nil
+	}
+	if c.clientSet == nil {
+		return nil, fmt.Errorf("kubernetes client is not available")
+	}
+
+	csiNodes, err := c.clientSet.StorageV1().CSINodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list CSINodes: %w", err)
+	}
+	nodes := map[string][]string{}
+	for _, csiNode := range csiNodes.Items {
+		for _, d := range csiNode.Spec.Drivers {
+			if volumes, ok := instances[d.NodeID]; d.Name == DriverName && ok {
+				nodes[csiNode.Name] = volumes
+				delete(instances, d.NodeID)
+			}
+		}
+	}
+	for instanceID, volumes := range instances {
+		return nil, fmt.Errorf("disks %v are attached to instance %s which is not a node of this cluster", volumes, instanceID)
+	}
+
+	freezeNodes := make(map[string]*fsFreezeNode, len(nodes))
+	for nodeName, volumes := range nodes {
+		node, err := c.clientSet.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get node %s: %w", nodeName, err)
+		}
+		ip := nodeInternalIP(node)
+		if ip == "" {
+			return nil, fmt.Errorf("node %s has no InternalIP", nodeName)
+		}
+		client, err := fsFreezeHTTPClient(node)
+		if err != nil {
+			return nil, err
+		}
+		freezeNodes[nodeName] = &fsFreezeNode{
+			addr:      net.JoinHostPort(ip, c.port),
+			volumeIDs: volumes,
+			client:    client,
+		}
+	}
+	return freezeNodes, nil
+}
+
+// fsFreezeHTTPClient returns a client that only trusts the certificate published on node by its node plugin.
+// The certificate is issued for the node name, see newFsFreezeCertificate.
+func fsFreezeHTTPClient(node *v1.Node) (*http.Client, error) {
+	block, _ := pem.Decode([]byte(node.Annotations[fsFreezeCertAnnotation]))
+	if block == nil {
+		return nil, fmt.Errorf("node %s has no fsfreeze certificate in annotation %s, is csi-plugin up to date?", node.Name, fsFreezeCertAnnotation)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse fsfreeze certificate of node %s: %w", node.Name, err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return &http.Client{
+		Timeout: fsFreezeRequestTimeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:    roots,
+				ServerName: node.Name,
+				MinVersion: tls.VersionTLS13,
+			},
+		},
+	}, nil
+}
+
+func nodeInternalIP(node *v1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == v1.NodeInternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+// freeze freezes the filesystems on disks, each a volume by itself. See freezeVolumes.
+func (c *fsFreezeClient) freeze(ctx context.Context, disks []ecs.Disk) (func(), error) {
+	volumes := make(map[string][]ecs.Disk, len(disks))
+	for _, disk := range disks {
+		volumes[disk.DiskId] = []ecs.Disk{disk}
+	}
+	return c.freezeVolumes(ctx, volumes)
+}
+
+// freezeVolumes freezes the filesystems of volumes, keyed by the volume ID the node knows them by,
+// with the disks backing each volume. On success, the returned thaw function must be called
+// after the snapshots are cut. On failure, everything already frozen is thawed.
+func (c *fsFreezeClient) freezeVolumes(ctx context.Context, volumes map[string][]ecs.Disk) (func(), error) {
+	logger := klog.FromContext(ctx)
+
+	nodes, err := c.volumesByNode(ctx, volumes)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return func() {}, nil
+	}
+	token, err := os.ReadFile(c.tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("read service account token: %w", err)
+	}
+
+	var frozen []string
+	thaw := func() {
+		// the request context may be already canceled, but we must thaw anyway
+		ctx, cancel := context.WithTimeout(context.Background(), fsFreezeRequestTimeout)
+		defer cancel()
+		for _, name := range frozen {
+			node := nodes[name]
+			err := c.call(ctx, node, fsThawPath, token, &fsFreezeRequest{VolumeIDs: node.volumeIDs})
+			if err != nil {
+				logger.Error(err, "failed to thaw filesystems, node will thaw them after timeout", "node", name, "volumes", node.volumeIDs, "timeout", c.timeout)
+				continue
+			}
+			logger.V(2).Info("thawed filesystems", "node", name, "volumes", node.volumeIDs)
+		}
+	}
+	for name, node := range nodes {
+		// the node may have frozen them even if we did not get the response
+		frozen = append(frozen, name)
+		err := c.call(ctx, node, fsFreezePath, token, &fsFreezeRequest{
+			VolumeIDs:      node.volumeIDs,
+			TimeoutSeconds: int(c.timeout / time.Second),
+		})
+		if err != nil {
+			thaw()
+			return nil, fmt.Errorf("freeze filesystems of %v on node %s: %w", node.volumeIDs, name, err)
+		}
+		logger.V(2).Info("froze filesystems", "node", name, "volumes", node.volumeIDs)
+	}
+	return thaw, nil
+}
+
+func (c *fsFreezeClient) call(ctx context.Context, node *fsFreezeNode, path string, token []byte, body *fsFreezeRequest) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+node.addr+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := node.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
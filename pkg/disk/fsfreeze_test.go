@@ -0,0 +1,374 @@
+//go:build !windows
+
+package disk
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	authv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/klog/v2/ktesting"
+)
+
+const testControllerUser = "system:serviceaccount:kube-system:alicloud-csi-provisioner"
+
+func attachedDisk(diskID string, instanceIDs ...string) ecs.Disk {
+	d := ecs.Disk{DiskId: diskID}
+	for _, id := range instanceIDs {
+		d.Attachments.Attachment = append(d.Attachments.Attachment, ecs.Attachment{InstanceId: id})
+	}
+	return d
+}
+
+func fakeClusterNode(name, instanceID, ip string, certPEM []byte) []runtime.Object {
+	return []runtime.Object{
+		&storagev1.CSINode{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: storagev1.CSINodeSpec{Drivers: []storagev1.CSINodeDriver{
+				{Name: DriverName, NodeID: instanceID},
+			}},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Annotations: map[string]string{fsFreezeCertAnnotation: string(certPEM)},
+			},
+			Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: name},
+				{Type: v1.NodeInternalIP, Address: ip},
+			}},
+		},
+	}
+}
+
+// newFsFreezeNodeServer starts a TLS server with the certificate of nodeName, returning its host, port and certificate.
+func newFsFreezeNodeServer(t *testing.T, nodeName string, handler http.Handler) (string, string, []byte) {
+	cert, certPEM, err := newFsFreezeCertificate(nodeName)
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+	return host, port, certPEM
+}
+
+type fakeIoctl struct {
+	mu     sync.Mutex
+	frozen map[string]bool
+	calls  []string
+}
+
+func (f *fakeIoctl) ioctl(mountPoint string, req uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch req {
+	case ioctlFIFREEZE:
+		f.calls = append(f.calls, "freeze "+mountPoint)
+		f.frozen[mountPoint] = true
+	case ioctlFITHAW:
+		f.calls = append(f.calls, "thaw "+mountPoint)
+		delete(f.frozen, mountPoint)
+	}
+	return nil
+}
+
+func newTestFsFreezeServer(t *testing.T, mountPoints map[string]string) (*fsFreezeServer, *fakeIoctl) {
+	clientSet := fake.NewClientset()
+	clientSet.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenReview)
+		assert.Equal(t, []string{DriverName}, review.Spec.Audiences)
+		switch review.Spec.Token {
+		case "controller-token":
+			review.Status.Authenticated = true
+			review.Status.User.Username = testControllerUser
+		case "other-token":
+			review.Status.Authenticated = true
+			review.Status.User.Username = "system:serviceaccount:default:default"
+		}
+		return true, review, nil
+	})
+	f := &fakeIoctl{frozen: map[string]bool{}}
+	return &fsFreezeServer{
+		clientSet:   clientSet,
+		allowedUser: testControllerUser,
+		findMountPoint: func(ctx context.Context, volumeID string) (string, error) {
+			return mountPoints[volumeID], nil
+		},
+		ioctl:     f.ioctl,
+		statePath: t.TempDir(),
+		frozen:    map[string]*frozenFS{},
+		freezing:  map[string]bool{},
+	}, f
+}
+
+func TestFsFreezeClient(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	host, port, certPEM := newFsFreezeNodeServer(t, "node-1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer controller-token", r.Header.Get("Authorization"))
+		var req fsFreezeRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.URL.Path+" "+strings.Join(req.VolumeIDs, ","))
+	}))
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("controller-token\n"), 0o600))
+
+	c := &fsFreezeClient{
+		clientSet: fake.NewClientset(fakeClusterNode("node-1", "i-1", host, certPEM)...),
+		port:      port,
+		tokenPath: tokenPath,
+		timeout:   fsFreezeTimeout,
+	}
+	_, ctx := ktesting.NewTestContext(t)
+
+	thaw, err := c.freeze(ctx, []ecs.Disk{attachedDisk("d-1", "i-1"), attachedDisk("d-2", "i-1"), attachedDisk("d-3")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{fsFreezePath + " d-1,d-2"}, requests)
+	thaw()
+	assert.Equal(t, []string{fsFreezePath + " d-1,d-2", fsThawPath + " d-1,d-2"}, requests)
+
+	_, err = c.freeze(ctx, []ecs.Disk{attachedDisk("d-4", "i-unknown")})
+	assert.ErrorContains(t, err, "not a node of this cluster")
+
+	// nothing attached, nothing to freeze
+	requests = nil
+	thaw, err = c.freeze(ctx, []ecs.Disk{attachedDisk("d-3")})
+	require.NoError(t, err)
+	thaw()
+	assert.Empty(t, requests)
+}
+
+func TestFsFreezeClientThawOnFailure(t *testing.T) {
+	var paths []string
+	host, port, certPEM := newFsFreezeNodeServer(t, "node-1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == fsFreezePath {
+			http.Error(w, "FIFREEZE failed", http.StatusInternalServerError)
+		}
+	}))
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("controller-token"), 0o600))
+
+	c := &fsFreezeClient{
+		clientSet: fake.NewClientset(fakeClusterNode("node-1", "i-1", host, certPEM)...),
+		port:      port,
+		tokenPath: tokenPath,
+		timeout:   fsFreezeTimeout,
+	}
+	_, ctx := ktesting.NewTestContext(t)
+	_, err := c.freeze(ctx, []ecs.Disk{attachedDisk("d-1", "i-1")})
+	assert.ErrorContains(t, err, "FIFREEZE failed")
+	assert.Equal(t, []string{fsFreezePath, fsThawPath}, paths)
+}
+
+func TestFsFreezeClientPinnedCertificate(t *testing.T) {
+	var paths []string
+	host, port, _ := newFsFreezeNodeServer(t, "node-1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	_, otherPEM, err := newFsFreezeCertificate("node-1")
+	require.NoError(t, err)
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("controller-token"), 0o600))
+	_, ctx := ktesting.NewTestContext(t)
+
+	c := &fsFreezeClient{
+		clientSet: fake.NewClientset(fakeClusterNode("node-1", "i-1", host, otherPEM)...),
+		port:      port,
+		tokenPath: tokenPath,
+		timeout:   fsFreezeTimeout,
+	}
+	_, err = c.freeze(ctx, []ecs.Disk{attachedDisk("d-1", "i-1")})
+	assert.ErrorContains(t, err, "certificate")
+
+	c.clientSet = fake.NewClientset(fakeClusterNode("node-1", "i-1", host, nil)...)
+	_, err = c.freeze(ctx, []ecs.Disk{attachedDisk("d-1", "i-1")})
+	assert.ErrorContains(t, err, "no fsfreeze certificate")
+
+	// the token is never sent to a server not presenting the published certificate
+	assert.Empty(t, paths)
+}
+
+func TestCreateSnapshotFreezeTimeout(t *testing.T) {
+	c, w, cs := testCloneServer(t)
+	_, ctx := ktesting.NewTestContext(t)
+	cs.fsFreeze = &fsFreezeClient{timeout: fsFreezeTimeout}
+	// never cut
+	w.snapshot.CreationTime = ""
+	// not attached, nothing to freeze
+	c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(ecs.Disk{DiskId: "d-1"}), nil)
+	c.EXPECT().CreateSnapshot(gomock.Any()).Return(&ecs.CreateSnapshotResponse{SnapshotId: "s-1"}, nil)
+	c.EXPECT().DeleteSnapshot(gomock.Any()).DoAndReturn(func(r *ecs.DeleteSnapshotRequest) (*ecs.DeleteSnapshotResponse, error) {
+		assert.Equal(t, "s-1", r.SnapshotId)
+		return &ecs.DeleteSnapshotResponse{}, nil
+	})
+
+	_, err := cs.CreateSnapshot(ctx, &csi.CreateSnapshotRequest{
+		Name:           "snap-1",
+		SourceVolumeId: "d-1",
+		Parameters:     map[string]string{FSFREEZE: "true"},
+	})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, []string{"s-1"}, w.waited)
+}
+
+func TestFsFreezeServerAuth(t *testing.T) {
+	s, f := newTestFsFreezeServer(t, map[string]string{"d-1": "/mnt/d-1"})
+	mux := s.handler()
+
+	cases := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{name: "no token", status: http.StatusUnauthorized},
+		{name: "invalid token", token: "bad-token", status: http.StatusUnauthorized},
+		{name: "other user", token: "other-token", status: http.StatusUnauthorized},
+		{name: "controller", token: "controller-token", status: http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, fsFreezePath, strings.NewReader(`{"volumeIDs":["d-1"],"timeoutSeconds":30}`))
+			if c.token != "" {
+				r.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			assert.Equal(t, c.status, w.Code, w.Body.String())
+		})
+	}
+	assert.Equal(t, []string{"freeze /mnt/d-1"}, f.calls)
+
+	r := httptest.NewRequest(http.MethodPost, fsThawPath, strings.NewReader(`{"volumeIDs":["d-1"]}`))
+	r.Header.Set("Authorization", "Bearer controller-token")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, f.frozen)
+}
+
+func TestFsFreezeServer(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	s, f := newTestFsFreezeServer(t, map[string]string{"d-1": "/mnt/d-1", "d-2": "/mnt/d-2", "d-block": ""})
+
+	require.NoError(t, s.freeze(ctx, []string{"d-1", "d-2", "d-block"}, time.Minute))
+	assert.Equal(t, map[string]bool{"/mnt/d-1": true, "/mnt/d-2": true}, f.frozen)
+	// retry is idempotent
+	require.NoError(t, s.freeze(ctx, []string{"d-1"}, time.Minute))
+	assert.Len(t, f.calls, 2)
+
+	require.NoError(t, s.thaw(ctx, "d-1"))
+	require.NoError(t, s.thaw(ctx, "d-1"))
+	require.NoError(t, s.thaw(ctx, "d-unknown"))
+	assert.Equal(t, map[string]bool{"/mnt/d-2": true}, f.frozen)
+
+	// auto thaw after timeout
+	require.NoError(t, s.freeze(ctx, []string{"d-1"}, 10*time.Millisecond))
+	assert.Eventually(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return !f.frozen["/mnt/d-1"]
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestFsFreezeServerState(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	s, f := newTestFsFreezeServer(t, map[string]string{"stripe-lvm:d-1,d-2": "/mnt/stripe"})
+
+	require.NoError(t, s.freeze(ctx, []string{"stripe-lvm:d-1,d-2"}, time.Minute))
+	entries, err := os.ReadDir(s.statePath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// the plugin restarts while frozen
+	restarted, _ := newTestFsFreezeServer(t, nil)
+	restarted.ioctl, restarted.statePath = f.ioctl, s.statePath
+	restarted.thawStale(ctx)
+	assert.Empty(t, f.frozen)
+	entries, err = os.ReadDir(s.statePath)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestFsFreezeServerThawWhileFreezing(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	s, f := newTestFsFreezeServer(t, map[string]string{"d-1": "/mnt/d-1", "d-2": "/mnt/d-2"})
+	freezing, release := make(chan struct{}), make(chan struct{})
+	s.ioctl = func(mountPoint string, req uint) error {
+		if mountPoint == "/mnt/d-1" && req == ioctlFIFREEZE {
+			close(freezing)
+			<-release
+		}
+		return f.ioctl(mountPoint, req)
+	}
+
+	done := make(chan error)
+	go func() { done <- s.freeze(ctx, []string{"d-1"}, time.Minute) }()
+	<-freezing
+	// other volumes are not blocked by a slow FIFREEZE
+	require.NoError(t, s.freeze(ctx, []string{"d-2"}, time.Minute))
+	assert.Error(t, s.freeze(ctx, []string{"d-1"}, time.Minute), "concurrent freeze of the same volume")
+	require.NoError(t, s.thaw(ctx, "d-1"))
+	close(release)
+	require.NoError(t, <-done)
+	assert.Equal(t, map[string]bool{"/mnt/d-2": true}, f.frozen, "thawed once frozen")
+}
+
+func TestFindFilesystemMount(t *testing.T) {
+	mnts := parseMountinfo(t, strings.Join([]string{
+		"1001 97 253:16 / " + testStagingPath + " rw,relatime shared:300 - ext4 /dev/vdb rw",
+		"1002 97 252:0 / /var/lib/kubelet/other/globalmount rw,relatime shared:301 - ext4 /dev/mapper/csi-datacache-d-2 rw",
+		"1003 97 0:5 /vdc /var/lib/kubelet/block/d-3 rw,nosuid shared:21 - devtmpfs devtmpfs rw",
+	}, "\n"))
+	devTmpFS := &fakeDevTmpFS{DevicePath: "/dev", Devs: []fakeDev{
+		{Major: 253, Minor: 16, Path: "vdb"},
+		{Major: 252, Minor: 0, Path: "mapper/csi-datacache-d-2"},
+		{Major: 253, Minor: 32, Path: "vdd"},
+		{Major: 253, Minor: 48, Path: "vdc"},
+	}}
+
+	cases := []struct {
+		name       string
+		devices    []string
+		mountPoint string
+	}{
+		{name: "raw", devices: []string{"/dev/mapper/csi-datacache-d-1", "/dev/mapper/csi-luks-d-1", "/dev/vdb"}, mountPoint: testStagingPath},
+		{name: "datacache", devices: []string{"/dev/mapper/csi-datacache-d-2", "/dev/mapper/csi-luks-d-2", "/dev/vdd"}, mountPoint: "/var/lib/kubelet/other/globalmount"},
+		{name: "block", devices: []string{"/dev/mapper/csi-datacache-d-3", "/dev/mapper/csi-luks-d-3", "/dev/vdc"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mountPoint, err := findFilesystemMount(mnts, devTmpFS, c.devices)
+			require.NoError(t, err)
+			assert.Equal(t, c.mountPoint, mountPoint)
+		})
+	}
+}
@@ -0,0 +1,433 @@
+//go:build !windows
+
+package disk
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/datacache"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/luks"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	utilsio "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/io"
+	"golang.org/x/sys/unix"
+	authv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	k8smount "k8s.io/mount-utils"
+)
+
+const (
+	// ioctl numbers from linux/fs.h, not exported by x/sys/unix
+	ioctlFIFREEZE = 0xc0045877 // _IOWR('X', 119, int)
+	ioctlFITHAW   = 0xc0045878 // _IOWR('X', 120, int)
+
+	// maxFsFreezeTimeout caps the timeout requested by the controller. Applications are blocked while frozen.
+	maxFsFreezeTimeout = 2 * time.Minute
+
+	// fsFreezeStatePath records the mount point of each frozen filesystem before it is frozen,
+	// so that it is thawed on startup if the plugin exits before thawing it.
+	fsFreezeStatePath = "/var/alibaba-cloud-csi/fsfreeze"
+)
+
+type frozenFS struct {
+	mountPoint string
+	// autoThaw fires if the controller does not thaw in time
+	autoThaw *time.Timer
+}
+
+// fsFreezeServer serves the freeze/thaw requests from the controller, see fsFreezeClient.
+type fsFreezeServer struct {
+	clientSet kubernetes.Interface
+	// only tokens of this user are accepted, normally the service account of the controller
+	allowedUser    string
+	findMountPoint func(ctx context.Context, volumeID string) (string, error)
+	ioctl          func(mountPoint string, req uint) error
+
+	statePath string
+
+	mu     sync.Mutex
+	frozen map[string]*frozenFS // by volume ID
+	// freezing holds the volumes being frozen, with whether a thaw was requested meanwhile.
+	// FIFREEZE syncs the filesystem, so it is not called with mu held.
+	freezing map[string]bool
+}
+
+func newFsFreezeServer(csiCfg utils.Config, clientSet kubernetes.Interface, findMountPoint func(ctx context.Context, volumeID string) (string, error)) *fsFreezeServer {
+	return &fsFreezeServer{
+		clientSet:      clientSet,
+		allowedUser:    csiCfg.Get("disk-fsfreeze-allowed-user", "DISK_FSFREEZE_ALLOWED_USER", "system:serviceaccount:"+pluginNamespace()+":alicloud-csi-provisioner"),
+		findMountPoint: findMountPoint,
+		ioctl:          fsIoctl,
+		statePath:      fsFreezeStatePath,
+		frozen:         map[string]*frozenFS{},
+		freezing:       map[string]bool{},
+	}
+}
+
+func fsIoctl(mountPoint string, req uint) error {
+	f, err := os.Open(mountPoint)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return unix.IoctlSetInt(int(f.Fd()), req, 0)
+}
+
+// handler returns the handler of the freeze requests.
+func (s *fsFreezeServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+fsFreezePath, s.handleFreeze)
+	mux.HandleFunc("POST "+fsThawPath, s.handleThaw)
+	return mux
+}
+
+// serve publishes a new certificate in the annotation of the Node,
+// then serves the freeze requests over TLS on port until it fails.
+func (s *fsFreezeServer) serve(ctx context.Context, nodeName, port string) error {
+	cert, certPEM, err := newFsFreezeCertificate(nodeName)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{fsFreezeCertAnnotation: string(certPEM)},
+		},
+	})
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	_, err = s.clientSet.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		ln.Close()
+		return fmt.Errorf("publish fsfreeze certificate on node %s: %w", nodeName, err)
+	}
+	server := &http.Server{
+		Handler: s.handler(),
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS13,
+		},
+	}
+	klog.FromContext(ctx).Info("serving fsfreeze requests", "port", port)
+	return server.ServeTLS(ln, "", "")
+}
+
+// newFsFreezeCertificate generates a self-signed certificate for nodeName.
+// The private key never leaves the memory of the node plugin, a new one is generated on every start.
+func newFsFreezeCertificate(nodeName string) (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano()),
+		Subject:      pkix.Name{CommonName: nodeName},
+		DNSNames:     []string{nodeName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		// self-signed, trusted as its own root by the controller
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("create fsfreeze certificate: %w", err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+func (s *fsFreezeServer) authenticate(ctx context.Context, r *http.Request) error {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return errors.New("bearer token required")
+	}
+	review, err := s.clientSet.AuthenticationV1().TokenReviews().Create(ctx, &authv1.TokenReview{
+		Spec: authv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: []string{DriverName},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("TokenReview failed: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return fmt.Errorf("token not authenticated: %s", review.Status.Error)
+	}
+	if review.Status.User.Username != s.allowedUser {
+		return fmt.Errorf("user %s is not allowed", review.Status.User.Username)
+	}
+	return nil
+}
+
+func (s *fsFreezeServer) parseRequest(w http.ResponseWriter, r *http.Request) (*fsFreezeRequest, bool) {
+	if err := s.authenticate(r.Context(), r); err != nil {
+		klog.FromContext(r.Context()).Error(err, "rejected fsfreeze request", "remote", r.RemoteAddr)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	req := &fsFreezeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return req, true
+}
+
+func (s *fsFreezeServer) handleFreeze(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.parseRequest(w, r)
+	if !ok {
+		return
+	}
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	if timeout <= 0 || timeout > maxFsFreezeTimeout {
+		timeout = maxFsFreezeTimeout
+	}
+	if err := s.freeze(r.Context(), req.VolumeIDs, timeout); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *fsFreezeServer) handleThaw(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.parseRequest(w, r)
+	if !ok {
+		return
+	}
+	var errs []error
+	for _, volumeID := range req.VolumeIDs {
+		errs = append(errs, s.thaw(r.Context(), volumeID))
+	}
+	if err := errors.Join(errs...); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// freeze freezes all the volumes, or none of them.
+func (s *fsFreezeServer) freeze(ctx context.Context, volumeIDs []string, timeout time.Duration) error {
+	logger := klog.FromContext(ctx)
+	for i, volumeID := range volumeIDs {
+		err := s.freezeVolume(ctx, volumeID, timeout)
+		if err != nil {
+			for _, id := range volumeIDs[:i] {
+				if err := s.thaw(ctx, id); err != nil {
+					logger.Error(err, "failed to thaw", "volumeID", id)
+				}
+			}
+			return fmt.Errorf("freeze %s: %w", volumeID, err)
+		}
+	}
+	return nil
+}
+
+func (s *fsFreezeServer) freezeVolume(ctx context.Context, volumeID string, timeout time.Duration) error {
+	logger := klog.FromContext(ctx)
+
+	s.mu.Lock()
+	if f, ok := s.frozen[volumeID]; ok {
+		// retried by the controller
+		f.autoThaw.Reset(timeout)
+		s.mu.Unlock()
+		return nil
+	}
+	if _, ok := s.freezing[volumeID]; ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%s is being frozen", volumeID)
+	}
+	s.freezing[volumeID] = false
+	s.mu.Unlock()
+
+	mountPoint, err := s.freezeFilesystem(ctx, volumeID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	thawRequested := s.freezing[volumeID]
+	delete(s.freezing, volumeID)
+	if err != nil || mountPoint == "" {
+		return err
+	}
+	f := &frozenFS{
+		mountPoint: mountPoint,
+		autoThaw: time.AfterFunc(timeout, func() {
+			logger.Info("freeze timeout, thawing", "volumeID", volumeID, "timeout", timeout)
+			if err := s.thaw(context.Background(), volumeID); err != nil {
+				logger.Error(err, "failed to thaw", "volumeID", volumeID)
+			}
+		}),
+	}
+	s.frozen[volumeID] = f
+	logger.V(2).Info("froze filesystem", "volumeID", volumeID, "mountPoint", mountPoint)
+	if thawRequested {
+		return s.thawLocked(ctx, volumeID, f)
+	}
+	return nil
+}
+
+// freezeFilesystem freezes the filesystem of the volume, and returns its mount point.
+// The mount point is recorded before freezing, see fsFreezeStatePath.
+func (s *fsFreezeServer) freezeFilesystem(ctx context.Context, volumeID string) (string, error) {
+	mountPoint, err := s.findMountPoint(ctx, volumeID)
+	if err != nil {
+		return "", err
+	}
+	if mountPoint == "" {
+		// block volume, or not staged on this node. Nothing we can do
+		klog.FromContext(ctx).V(2).Info("no filesystem to freeze", "volumeID", volumeID)
+		return "", nil
+	}
+	if err := os.MkdirAll(s.statePath, 0o700); err != nil {
+		return "", err
+	}
+	stateFile := s.stateFile(volumeID)
+	if err := os.WriteFile(stateFile, []byte(mountPoint), 0o600); err != nil {
+		return "", err
+	}
+	if err := s.ioctl(mountPoint, ioctlFIFREEZE); err != nil {
+		if err := os.Remove(stateFile); err != nil {
+			klog.FromContext(ctx).Error(err, "failed to remove fsfreeze state", "volumeID", volumeID)
+		}
+		return "", fmt.Errorf("FIFREEZE %s: %w", mountPoint, err)
+	}
+	return mountPoint, nil
+}
+
+func (s *fsFreezeServer) stateFile(volumeID string) string {
+	// IDs of striped and subpath volumes contain ':' and ','
+	return filepath.Join(s.statePath, url.PathEscape(volumeID))
+}
+
+// thaw only thaws filesystems frozen by us. It is a no-op if volumeID is not frozen.
+// A volume being frozen is thawed as soon as it is frozen.
+func (s *fsFreezeServer) thaw(ctx context.Context, volumeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.freezing[volumeID]; ok {
+		s.freezing[volumeID] = true
+		return nil
+	}
+	f, ok := s.frozen[volumeID]
+	if !ok {
+		return nil
+	}
+	return s.thawLocked(ctx, volumeID, f)
+}
+
+func (s *fsFreezeServer) thawLocked(ctx context.Context, volumeID string, f *frozenFS) error {
+	f.autoThaw.Stop()
+	if err := thawFilesystem(s.ioctl, f.mountPoint); err != nil {
+		return err
+	}
+	delete(s.frozen, volumeID)
+	if err := os.Remove(s.stateFile(volumeID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		klog.FromContext(ctx).Error(err, "failed to remove fsfreeze state", "volumeID", volumeID)
+	}
+	klog.FromContext(ctx).V(2).Info("thawed filesystem", "volumeID", volumeID, "mountPoint", f.mountPoint)
+	return nil
+}
+
+func thawFilesystem(ioctl func(mountPoint string, req uint) error, mountPoint string) error {
+	err := ioctl(mountPoint, ioctlFITHAW)
+	if err != nil && !errors.Is(err, unix.EINVAL) { // EINVAL: not frozen
+		return fmt.Errorf("FITHAW %s: %w", mountPoint, err)
+	}
+	return nil
+}
+
+// thawStale thaws the filesystems left frozen by a previous run of the plugin, see fsFreezeStatePath.
+func (s *fsFreezeServer) thawStale(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	entries, err := os.ReadDir(s.statePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Error(err, "failed to read fsfreeze state")
+		}
+		return
+	}
+	for _, e := range entries {
+		stateFile := filepath.Join(s.statePath, e.Name())
+		mountPoint, err := os.ReadFile(stateFile)
+		if err != nil {
+			logger.Error(err, "failed to read fsfreeze state", "file", stateFile)
+			continue
+		}
+		err = thawFilesystem(s.ioctl, string(mountPoint))
+		if err != nil && !errors.Is(err, os.ErrNotExist) { // unmounted since
+			logger.Error(err, "failed to thaw filesystem left frozen", "mountPoint", string(mountPoint))
+			continue
+		}
+		logger.Info("thawed filesystem left frozen", "file", e.Name(), "mountPoint", string(mountPoint))
+		if err := os.Remove(stateFile); err != nil {
+			logger.Error(err, "failed to remove fsfreeze state", "file", stateFile)
+		}
+	}
+}
+
+// stagedMountPoint returns where the filesystem of the volume is mounted,
+// or "" if the volume has no filesystem mounted on this node.
+func (ns *nodeServer) stagedMountPoint(ctx context.Context, volumeID string) (string, error) {
+	mnts, err := k8smount.ParseMountInfo(mountInfoPath)
+	if err != nil {
+		return "", err
+	}
+	if device, ok := stripeDevicePath(volumeID); ok {
+		// no LUKS or data cache on a striped volume
+		return findFilesystemMount(mnts, DefaultDeviceManager.DevTmpFS, []string{device})
+	}
+	device, err := ns.ad.repo.GetVolumeDeviceName(klog.FromContext(ctx), volumeID)
+	if err != nil {
+		return "", fmt.Errorf("get device of %s: %w", volumeID, err)
+	}
+	// from the top of the stack, see setupDisk
+	devices := []string{datacache.DevicePath(volumeID), luks.DevicePath(volumeID), device}
+	return findFilesystemMount(mnts, DefaultDeviceManager.DevTmpFS, devices)
+}
+
+// findFilesystemMount returns a mount point of the first existing device in devices.
+// Any mount point will do, the whole filesystem is frozen.
+func findFilesystemMount(mnts []k8smount.MountInfo, devTmpFS utilsio.DevTmpFS, devices []string) (string, error) {
+	for _, device := range devices {
+		major, minor, err := devTmpFS.DevFor(device)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return "", err
+		}
+		for _, m := range mnts {
+			if m.Major == int(major) && m.Minor == int(minor) {
+				return m.MountPoint, nil
+			}
+		}
+		// the topmost device is not mounted, e.g. a block volume
+		return "", nil
+	}
+	return "", nil
+}
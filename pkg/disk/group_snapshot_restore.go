@@ -0,0 +1,229 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/ttlcache"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// snapshotGroupIndexTTL is how long we trust the list of snapshot groups.
+// Snapshot groups are rarely created, but ListSnapshots may be called for every VolumeSnapshotContent.
+const snapshotGroupIndexTTL = time.Minute
+
+// snapshotGroupIndexCache caches the snapshotGroupIndex of the cluster.
+// It is shared by controllerServer and groupControllerServer, which invalidate it when creating a snapshot group.
+type snapshotGroupIndexCache struct {
+	cache *ttlcache.TTLCache[string, *snapshotGroupIndex]
+}
+
+func newSnapshotGroupIndexCache() *snapshotGroupIndexCache {
+	return &snapshotGroupIndexCache{
+		cache: ttlcache.NewTTLCache[string, *snapshotGroupIndex](snapshotGroupIndexTTL),
+	}
+}
+
+func (c *snapshotGroupIndexCache) get(ctx context.Context, ecsClient cloud.ECSInterface) (*snapshotGroupIndex, error) {
+	clusterID := GlobalConfigVar.ClusterID
+	return c.cache.Get(ctx, clusterID, func() (*snapshotGroupIndex, error) {
+		return listSnapshotGroups(ecsClient, clusterID)
+	})
+}
+
+// invalidate makes the next lookup list the snapshot groups again,
+// after one is created or its status is stale.
+func (c *snapshotGroupIndexCache) invalidate() {
+	c.cache.Delete(GlobalConfigVar.ClusterID)
+}
+
+// snapshotGroupIndex maps snapshots to the snapshot groups created by this driver,
+// since DescribeSnapshots does not tell which group a snapshot belongs to.
+type snapshotGroupIndex struct {
+	groups     map[string]*ecs.SnapshotGroup
+	bySnapshot map[string]string
+}
+
+func (idx *snapshotGroupIndex) groupOf(snapshotID string) *ecs.SnapshotGroup {
+	return idx.groups[idx.bySnapshot[snapshotID]]
+}
+
+// listSnapshotGroups lists all snapshot groups created by this driver in clusterID (if specified)
+func listSnapshotGroups(ecsClient cloud.ECSInterface, clusterID string) (*snapshotGroupIndex, error) {
+	tags := []ecs.DescribeSnapshotGroupsTag{{Key: DISKTAGKEY2, Value: DISKTAGVALUE2}}
+	if clusterID != "" {
+		tags = append(tags, ecs.DescribeSnapshotGroupsTag{Key: DISKTAGKEY3, Value: clusterID})
+	}
+	idx := &snapshotGroupIndex{
+		groups:     map[string]*ecs.SnapshotGroup{},
+		bySnapshot: map[string]string{},
+	}
+	nextToken := ""
+	for {
+		req := ecs.CreateDescribeSnapshotGroupsRequest()
+		req.RegionId = GlobalConfigVar.Region
+		req.Tag = &tags
+		req.MaxResults = requests.NewInteger(100)
+		req.NextToken = nextToken
+		resp, err := ecsClient.DescribeSnapshotGroups(req)
+		if err != nil {
+			return nil, fmt.Errorf("DescribeSnapshotGroups failed: %w", err)
+		}
+		for i := range resp.SnapshotGroups.SnapshotGroup {
+			group := &resp.SnapshotGroups.SnapshotGroup[i]
+			idx.groups[group.SnapshotGroupId] = group
+			for _, snapshot := range group.Snapshots.Snapshot {
+				idx.bySnapshot[snapshot.SnapshotId] = group.SnapshotGroupId
+			}
+		}
+		if resp.NextToken == "" {
+			return idx, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+// groupMembers returns the snapshot IDs in the group, sorted
+func groupMembers(group *ecs.SnapshotGroup) []string {
+	ids := make([]string, 0, len(group.Snapshots.Snapshot))
+	for _, snapshot := range group.Snapshots.Snapshot {
+		ids = append(ids, snapshot.SnapshotId)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func groupSourceDisks(group *ecs.SnapshotGroup) sets.Set[string] {
+	disks := sets.New[string]()
+	for _, snapshot := range group.Snapshots.Snapshot {
+		disks.Insert(snapshot.SourceDiskId)
+	}
+	return disks
+}
+
+// fillGroupSnapshotIDs sets GroupSnapshotId for the snapshots in a snapshot group,
+// if it cannot be guessed from the snapshot itself.
+func (cs *controllerServer) fillGroupSnapshotIDs(ctx context.Context, resp *csi.ListSnapshotsResponse) {
+	var idx *snapshotGroupIndex
+	for _, entry := range resp.Entries {
+		if entry.Snapshot.GroupSnapshotId != "" {
+			continue
+		}
+		if idx == nil {
+			var err error
+			idx, err = cs.snapshotGroups.get(ctx, cs.ecs)
+			if err != nil {
+				klog.FromContext(ctx).Error(err, "failed to list snapshot groups, group_snapshot_id may be missing")
+				return
+			}
+		}
+		if group := idx.groupOf(entry.Snapshot.SnapshotId); group != nil {
+			entry.Snapshot.GroupSnapshotId = group.SnapshotGroupId
+		}
+	}
+}
+
+// listRestoredDisks lists the disks in namespace that are restored from any snapshot group.
+func listRestoredDisks(ecsClient cloud.ECSInterface, namespace, clusterID string) ([]ecs.Disk, error) {
+	tags := []ecs.DescribeDisksTag{
+		{Key: DISKTAGKEY2, Value: DISKTAGVALUE2},
+		{Key: common.PVCNamespaceTag, Value: namespace},
+	}
+	if clusterID != "" {
+		tags = append(tags, ecs.DescribeDisksTag{Key: DISKTAGKEY3, Value: clusterID})
+	}
+	var disks []ecs.Disk
+	nextToken := ""
+	for {
+		req := ecs.CreateDescribeDisksRequest()
+		req.RegionId = GlobalConfigVar.Region
+		req.Tag = &tags
+		req.MaxResults = requests.NewInteger(100)
+		req.NextToken = nextToken
+		resp, err := ecsClient.DescribeDisks(req)
+		if err != nil {
+			return nil, fmt.Errorf("DescribeDisks failed: %w", err)
+		}
+		for _, disk := range resp.Disks.Disk {
+			if exists, _ := utils.HasSpecificTagKey(GroupSnapshotIDTag, &disk); exists {
+				disks = append(disks, disk)
+			}
+		}
+		if resp.NextToken == "" {
+			return disks, nil
+		}
+		nextToken = resp.NextToken
+	}
+}
+
+// validateGroupRestore returns the snapshot group of snapshotID, or nil if it is not in a snapshot group.
+//
+// If strict, it also checks restoring snapshotID against the other members of its snapshot group
+// already restored in the same namespace: each member of a group can be restored only once,
+// and members of groups of the same disks cannot be mixed, or the restored set is not consistent.
+func (cs *controllerServer) validateGroupRestore(ctx context.Context, req *csi.CreateVolumeRequest, snapshotID string, strict bool) (*ecs.SnapshotGroup, error) {
+	idx, err := cs.snapshotGroups.get(ctx, cs.ecs)
+	if err != nil {
+		// restoring a member of a snapshot group without its group is not consistent
+		return nil, status.Errorf(codes.Unavailable, "failed to list snapshot groups to validate restoring snapshot %s: %v", snapshotID, err)
+	}
+	group := idx.groupOf(snapshotID)
+	if group == nil {
+		return nil, nil
+	}
+	if group.Status != SnapshotStatusAccomplished {
+		// the retry should see the new status
+		cs.snapshotGroups.invalidate()
+		return nil, status.Errorf(codes.Unavailable, "snapshot group %s of snapshot %s is %s", group.SnapshotGroupId, snapshotID, group.Status)
+	}
+
+	namespace := req.Parameters[common.PVCNamespaceKey]
+	if !strict || namespace == "" {
+		// don't know which restored disks belong together
+		return group, nil
+	}
+	disks, err := listRestoredDisks(cs.ecs, namespace, GlobalConfigVar.ClusterID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "list disks restored in namespace %s: %v", namespace, err)
+	}
+	sources := groupSourceDisks(group)
+	for i := range disks {
+		disk := &disks[i]
+		if _, name := utils.HasSpecificTagKey(common.VolumeNameTag, disk); name == req.Name {
+			continue // retry of this request
+		}
+		_, pvc := utils.HasSpecificTagKey(common.PVCNameTag, disk)
+		_, otherID := utils.HasSpecificTagKey(GroupSnapshotIDTag, disk)
+		if otherID == group.SnapshotGroupId {
+			if disk.SourceSnapshotId == snapshotID {
+				return nil, status.Errorf(codes.FailedPrecondition, "snapshot %s of group %s is already restored to disk %s (PVC %s/%s)",
+					snapshotID, group.SnapshotGroupId, disk.DiskId, namespace, pvc)
+			}
+			continue
+		}
+		other := idx.groups[otherID]
+		if other != nil && groupSourceDisks(other).HasAny(sources.UnsortedList()...) {
+			return nil, status.Errorf(codes.FailedPrecondition, "disk %s (PVC %s/%s) is restored from snapshot group %s of the same disks, mixing it with group %s is not consistent",
+				disk.DiskId, namespace, pvc, otherID, group.SnapshotGroupId)
+		}
+	}
+	return group, nil
+}
+
+// groupRestoreVolumeContext reports the group a restored volume belongs to, with all the members of the group.
+func groupRestoreVolumeContext(volumeContext map[string]string, group *ecs.SnapshotGroup) {
+	volumeContext[GroupSnapshotIDTag] = group.SnapshotGroupId
+	volumeContext[GroupSnapshotMembersKey] = strings.Join(groupMembers(group), ",")
+}
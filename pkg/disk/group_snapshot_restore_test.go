@@ -0,0 +1,255 @@
+//go:build !windows
+
+package disk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func testSnapshotGroup(id, status string, members map[string]string) ecs.SnapshotGroup {
+	g := ecs.SnapshotGroup{SnapshotGroupId: id, Status: status}
+	for snapshotID, diskID := range members {
+		g.Snapshots.Snapshot = append(g.Snapshots.Snapshot, ecs.Snapshot{SnapshotId: snapshotID, SourceDiskId: diskID})
+	}
+	return g
+}
+
+func restoredDisk(diskID, pvName, pvcName, groupID, snapshotID string) ecs.Disk {
+	d := ecs.Disk{DiskId: diskID, SourceSnapshotId: snapshotID}
+	d.Tags.Tag = []ecs.Tag{
+		{TagKey: common.VolumeNameTag, TagValue: pvName},
+		{TagKey: common.PVCNameTag, TagValue: pvcName},
+		{TagKey: common.PVCNamespaceTag, TagValue: "default"},
+		{TagKey: GroupSnapshotIDTag, TagValue: groupID},
+	}
+	return d
+}
+
+func expectSnapshotGroups(c *cloud.MockECSInterface, groups ...ecs.SnapshotGroup) {
+	c.EXPECT().DescribeSnapshotGroups(gomock.Any()).DoAndReturn(func(req *ecs.DescribeSnapshotGroupsRequest) (*ecs.DescribeSnapshotGroupsResponse, error) {
+		// one group per page
+		i := 0
+		if req.NextToken != "" {
+			i = int(req.NextToken[0] - '0')
+		}
+		resp := &ecs.DescribeSnapshotGroupsResponse{}
+		if i < len(groups) {
+			resp.SnapshotGroups.SnapshotGroup = groups[i : i+1]
+		}
+		if i+1 < len(groups) {
+			resp.NextToken = string(rune('0' + i + 1))
+		}
+		return resp, nil
+	}).MinTimes(1)
+}
+
+func TestListSnapshotGroups(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c := cloud.NewMockECSInterface(ctrl)
+	expectSnapshotGroups(c,
+		testSnapshotGroup("ssg-1", SnapshotStatusAccomplished, map[string]string{"s-1a": "d-a", "s-1b": "d-b"}),
+		testSnapshotGroup("ssg-2", SnapshotStatusAccomplished, map[string]string{"s-2a": "d-a"}),
+	)
+
+	idx, err := listSnapshotGroups(c, "my-cluster")
+	require.NoError(t, err)
+	assert.Len(t, idx.groups, 2)
+	assert.Equal(t, "ssg-1", idx.groupOf("s-1b").SnapshotGroupId)
+	assert.Equal(t, "ssg-2", idx.groupOf("s-2a").SnapshotGroupId)
+	assert.Nil(t, idx.groupOf("s-other"))
+	assert.Equal(t, []string{"s-1a", "s-1b"}, groupMembers(idx.groups["ssg-1"]))
+}
+
+func TestFillGroupSnapshotIDs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c := cloud.NewMockECSInterface(ctrl)
+	expectSnapshotGroups(c, testSnapshotGroup("ssg-1", SnapshotStatusAccomplished, map[string]string{"s-1a": "d-a"}))
+	cs := &controllerServer{ecs: c, snapshotGroups: newSnapshotGroupIndexCache()}
+	_, ctx := ktesting.NewTestContext(t)
+
+	resp, err := cs.listSnapshotsResponse(ctx, []ecs.Snapshot{
+		{SnapshotId: "s-1a"},
+		{SnapshotId: "s-2", SnapshotName: "Created_from_ssg-2"},
+		{SnapshotId: "s-3"},
+	}, "")
+	require.NoError(t, err)
+	var groups []string
+	for _, e := range resp.Entries {
+		groups = append(groups, e.Snapshot.GroupSnapshotId)
+	}
+	assert.Equal(t, []string{"ssg-1", "ssg-2", ""}, groups)
+}
+
+func TestValidateGroupRestore(t *testing.T) {
+	groups := []ecs.SnapshotGroup{
+		testSnapshotGroup("ssg-1", SnapshotStatusAccomplished, map[string]string{"s-1a": "d-a", "s-1b": "d-b"}),
+		testSnapshotGroup("ssg-2", SnapshotStatusAccomplished, map[string]string{"s-2a": "d-a", "s-2b": "d-b"}),
+		testSnapshotGroup("ssg-3", SnapshotStatusAccomplished, map[string]string{"s-3c": "d-c"}),
+		testSnapshotGroup("ssg-4", "progressing", map[string]string{"s-4a": "d-a"}),
+	}
+	cases := []struct {
+		name       string
+		snapshotID string
+		namespace  string
+		strict     bool
+		restored   []ecs.Disk
+		group      string
+		code       codes.Code
+	}{
+		{
+			name:       "not in group",
+			snapshotID: "s-other",
+		},
+		{
+			name:       "no namespace",
+			snapshotID: "s-1a",
+			group:      "ssg-1",
+		},
+		{
+			name:       "first member",
+			snapshotID: "s-1a",
+			namespace:  "default",
+			strict:     true,
+			group:      "ssg-1",
+		},
+		{
+			name:       "second member",
+			snapshotID: "s-1b",
+			namespace:  "default",
+			strict:     true,
+			restored:   []ecs.Disk{restoredDisk("d-r0", "pv-0", "data-0", "ssg-1", "s-1a")},
+			group:      "ssg-1",
+		},
+		{
+			name:       "retry",
+			snapshotID: "s-1a",
+			namespace:  "default",
+			strict:     true,
+			restored:   []ecs.Disk{restoredDisk("d-r1", "pv-1", "data-1", "ssg-1", "s-1a")},
+			group:      "ssg-1",
+		},
+		{
+			name:       "member restored twice",
+			snapshotID: "s-1a",
+			namespace:  "default",
+			strict:     true,
+			restored:   []ecs.Disk{restoredDisk("d-r0", "pv-0", "data-0", "ssg-1", "s-1a")},
+			code:       codes.FailedPrecondition,
+		},
+		{
+			name:       "mixed groups",
+			snapshotID: "s-1b",
+			namespace:  "default",
+			strict:     true,
+			restored:   []ecs.Disk{restoredDisk("d-r0", "pv-0", "data-0", "ssg-2", "s-2a")},
+			code:       codes.FailedPrecondition,
+		},
+		{
+			name:       "unrelated group",
+			snapshotID: "s-1b",
+			namespace:  "default",
+			strict:     true,
+			restored:   []ecs.Disk{restoredDisk("d-r0", "pv-0", "data-0", "ssg-3", "s-3c")},
+			group:      "ssg-1",
+		},
+		{
+			name:       "not strict",
+			snapshotID: "s-1a",
+			namespace:  "default",
+			restored:   []ecs.Disk{restoredDisk("d-r0", "pv-0", "data-0", "ssg-1", "s-1a")},
+			group:      "ssg-1",
+		},
+		{
+			name:       "in progress",
+			snapshotID: "s-4a",
+			code:       codes.Unavailable,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := cloud.NewMockECSInterface(ctrl)
+			expectSnapshotGroups(client, groups...)
+			if c.strict {
+				client.EXPECT().DescribeDisks(gomock.Any()).DoAndReturn(func(req *ecs.DescribeDisksRequest) (*ecs.DescribeDisksResponse, error) {
+					assert.Contains(t, *req.Tag, ecs.DescribeDisksTag{Key: common.PVCNamespaceTag, Value: c.namespace})
+					return &ecs.DescribeDisksResponse{Disks: ecs.DisksInDescribeDisks{Disk: c.restored}}, nil
+				})
+			}
+			cs := &controllerServer{ecs: client, snapshotGroups: newSnapshotGroupIndexCache()}
+			_, ctx := ktesting.NewTestContext(t)
+
+			req := &csi.CreateVolumeRequest{Name: "pv-1", Parameters: map[string]string{}}
+			if c.namespace != "" {
+				req.Parameters[common.PVCNamespaceKey] = c.namespace
+			}
+			group, err := cs.validateGroupRestore(ctx, req, c.snapshotID, c.strict)
+			if c.code != codes.OK {
+				assert.Equal(t, c.code, status.Code(err), err)
+				return
+			}
+			require.NoError(t, err)
+			if c.group == "" {
+				assert.Nil(t, group)
+			} else {
+				assert.Equal(t, c.group, group.SnapshotGroupId)
+			}
+		})
+	}
+}
+
+func TestInvalidateSnapshotGroupIndex(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c := cloud.NewMockECSInterface(ctrl)
+	cs := &controllerServer{ecs: c, snapshotGroups: newSnapshotGroupIndexCache()}
+	_, ctx := ktesting.NewTestContext(t)
+
+	groups := []ecs.SnapshotGroup{testSnapshotGroup("ssg-1", "progressing", map[string]string{"s-1a": "d-a"})}
+	c.EXPECT().DescribeSnapshotGroups(gomock.Any()).DoAndReturn(func(req *ecs.DescribeSnapshotGroupsRequest) (*ecs.DescribeSnapshotGroupsResponse, error) {
+		resp := &ecs.DescribeSnapshotGroupsResponse{}
+		resp.SnapshotGroups.SnapshotGroup = groups
+		return resp, nil
+	}).Times(3)
+	req := &csi.CreateVolumeRequest{Name: "pv-1"}
+
+	// the status of a group in progress is not trusted
+	_, err := cs.validateGroupRestore(ctx, req, "s-1a", false)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	groups[0].Status = SnapshotStatusAccomplished
+	group, err := cs.validateGroupRestore(ctx, req, "s-1a", false)
+	require.NoError(t, err)
+	assert.Equal(t, "ssg-1", group.SnapshotGroupId)
+
+	// cached until a group is created
+	groups = append(groups, testSnapshotGroup("ssg-2", SnapshotStatusAccomplished, map[string]string{"s-2a": "d-a"}))
+	group, err = cs.validateGroupRestore(ctx, req, "s-2a", false)
+	require.NoError(t, err)
+	assert.Nil(t, group)
+	cs.snapshotGroups.invalidate()
+	group, err = cs.validateGroupRestore(ctx, req, "s-2a", false)
+	require.NoError(t, err)
+	assert.Equal(t, "ssg-2", group.SnapshotGroupId)
+}
+
+func TestValidateGroupRestoreListFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c := cloud.NewMockECSInterface(ctrl)
+	cs := &controllerServer{ecs: c, snapshotGroups: newSnapshotGroupIndexCache()}
+	_, ctx := ktesting.NewTestContext(t)
+	c.EXPECT().DescribeSnapshotGroups(gomock.Any()).Return(nil, errors.New("throttled"))
+
+	_, err := cs.validateGroupRestore(ctx, &csi.CreateVolumeRequest{Name: "pv-1"}, "s-1a", false)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
@@ -3,6 +3,7 @@ package disk
 import (
 	"fmt"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,6 +22,12 @@ func parseGroupSnapshotParameters(params map[string]string) (*createGroupSnapsho
 			return nil, fmt.Errorf("groupSnapshot do not support retentionDays")
 		case SNAPSHOTRESOURCEGROUPID:
 			ecsParams.ResourceGroupID = v
+		case FSFREEZE:
+			freeze, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse fsFreeze: %w", err)
+			}
+			ecsParams.FsFreeze = freeze
 		case common.VolumeGroupSnapshotNameKey:
 			tags[common.VolumeGroupSnapshotNameTag] = v
 		case common.VolumeGroupSnapshotNamespaceKey:
@@ -416,6 +416,21 @@ func Test_parseGroupSnapshotParameters(t *testing.T) {
 			wantError:     false,
 			wantECSParams: &createGroupSnapshotParams{},
 		},
+		{
+			name: "fsFreeze",
+			params: map[string]string{
+				FSFREEZE: "true",
+			},
+			wantError:     false,
+			wantECSParams: &createGroupSnapshotParams{FsFreeze: true},
+		},
+		{
+			name: "invalid fsFreeze",
+			params: map[string]string{
+				FSFREEZE: "maybe",
+			},
+			wantError: true,
+		},
 		{
 			name: "name and namespace",
 			params: map[string]string{
@@ -6,8 +6,10 @@ import (
 	"strings"
 
 	alicloudErr "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/waitstatus"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -26,14 +28,20 @@ var groupControllerCaps = []csi.GroupControllerServiceCapability_RPC_Type{
 
 // groupcontroller server try to create/delete group snapshots
 type groupControllerServer struct {
-	recorder record.EventRecorder
+	recorder       record.EventRecorder
+	snapshotWaiter waitstatus.StatusWaiter[ecs.Snapshot]
+	fsFreeze       *fsFreezeClient
+	snapshotGroups *snapshotGroupIndexCache
 	common.GenericGroupControllerServer
 }
 
 // NewGroupControllerServer is to create controller server
-func NewGroupControllerServer() csi.GroupControllerServer {
+func NewGroupControllerServer(csiCfg utils.Config, snapshotGroups *snapshotGroupIndexCache) csi.GroupControllerServer {
 	c := &groupControllerServer{
-		recorder: utils.NewEventRecorder(utils.EventComponentController),
+		recorder:       utils.NewEventRecorder(utils.EventComponentController),
+		snapshotWaiter: newSnapshotStatusWaiter(),
+		fsFreeze:       newFsFreezeClient(csiCfg, GlobalConfigVar.ClientSet),
+		snapshotGroups: snapshotGroups,
 	}
 	return c
 }
@@ -107,6 +115,23 @@ func (cs *groupControllerServer) CreateVolumeGroupSnapshot(ctx context.Context,
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "get volumeGroupSnapshot %s config failed: %v", req.GetName(), err)
 	}
+	waitCtx := ctx
+	if params.FsFreeze {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, cs.fsFreeze.timeout)
+		defer cancel()
+		disks := getDisks(sourceVolumeIds, GlobalConfigVar.EcsClient)
+		if len(disks) != len(sourceVolumeIds) {
+			return nil, status.Errorf(codes.Internal, "CreateVolumeGroupSnapshot:: failed to get disks %v", sourceVolumeIds)
+		}
+		// freeze all the members together, so that the group is consistent across disks
+		thaw, err := cs.fsFreeze.freeze(waitCtx, disks)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "CreateVolumeGroupSnapshot:: failed to freeze filesystems: %v", err)
+		}
+		defer thaw()
+	}
+
 	createAt := timestamppb.Now()
 	params.SourceVolumeIDs = sourceVolumeIds
 	params.SnapshotName = req.GetName()
@@ -114,6 +139,20 @@ func (cs *groupControllerServer) CreateVolumeGroupSnapshot(ctx context.Context,
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "create groupSnapshot %s failed: %v", req.GetName(), err)
 	}
+	cs.snapshotGroups.invalidate()
+	if params.FsFreeze {
+		err := waitSnapshotGroupCut(waitCtx, GlobalConfigVar.EcsClient, cs.snapshotWaiter, snapshotResponse.SnapshotGroupId, len(sourceVolumeIds))
+		if err != nil {
+			// A retry would find this group by name and return it as if it were consistent.
+			if _, delErr := requestAndDeleteGroupSnapshot(snapshotResponse.SnapshotGroupId); delErr != nil {
+				return nil, status.Errorf(codes.Internal, "groupSnapshot %s is not cut within the filesystem freeze timeout %v, and failed to delete it: %v",
+					snapshotResponse.SnapshotGroupId, cs.fsFreeze.timeout, delErr)
+			}
+			cs.snapshotGroups.invalidate()
+			return nil, status.Errorf(codes.DeadlineExceeded, "groupSnapshot %s is not cut within the filesystem freeze timeout %v, deleted it as it may not be application-consistent: %v",
+				snapshotResponse.SnapshotGroupId, cs.fsFreeze.timeout, err)
+		}
+	}
 
 	klog.Infof("CreateVolumeGroupSnapshot:: groupSnapshot create successful: snapshotName[%s], sourceIds[%s], snapshotGroupId[%s]", req.GetName(), sourceVolumeIds, snapshotResponse.SnapshotGroupId)
 	csiSnapshot := &csi.VolumeGroupSnapshot{
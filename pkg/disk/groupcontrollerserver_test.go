@@ -6,12 +6,13 @@ import (
 	"testing"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGroupControllerGetCapabilities(t *testing.T) {
-	cs := NewGroupControllerServer()
+	cs := NewGroupControllerServer(utils.Config{}, newSnapshotGroupIndexCache())
 
 	resp, err := cs.GroupControllerGetCapabilities(t.Context(), &csi.GroupControllerGetCapabilitiesRequest{})
 
@@ -0,0 +1,82 @@
+package disk
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+const (
+	serviceAccountNamespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+	// controllerLeaderLockName is the Lease held by the controller replica running the background loops
+	controllerLeaderLockName = "disk-controller-alibabacloud-csi-driver"
+)
+
+// pluginNamespace returns the namespace the plugin is running in.
+func pluginNamespace() string {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns
+	}
+	if ns, err := os.ReadFile(serviceAccountNamespacePath); err == nil {
+		return strings.TrimSpace(string(ns))
+	}
+	return "kube-system"
+}
+
+// runAsLeader runs the background loops of the controller while this replica holds the leader Lease,
+// so that they never run concurrently on several replicas. It campaigns again after losing the lease,
+// and returns when ctx is done. Each loop must return when its context is done.
+func runAsLeader(ctx context.Context, clientSet kubernetes.Interface, loops ...func(ctx context.Context)) {
+	logger := klog.FromContext(ctx).WithName("leader-election")
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		var err error
+		identity, err = os.Hostname()
+		if err != nil {
+			logger.Error(err, "cannot determine identity, background loops disabled")
+			return
+		}
+	}
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, pluginNamespace(), controllerLeaderLockName,
+		clientSet.CoreV1(), clientSet.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: identity})
+	if err != nil {
+		logger.Error(err, "failed to create resource lock, background loops disabled")
+		return
+	}
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: 30 * time.Second,
+		RenewDeadline: 20 * time.Second,
+		RetryPeriod:   5 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				logger.Info("became leader, starting background loops", "identity", identity)
+				var wg sync.WaitGroup
+				for _, loop := range loops {
+					wg.Go(func() { loop(leaderCtx) })
+				}
+				wg.Wait()
+			},
+			OnStoppedLeading: func() {
+				logger.Info("stopped leading, background loops stopped", "identity", identity)
+			},
+		},
+		Name:            controllerLeaderLockName,
+		ReleaseOnCancel: true,
+	})
+	if err != nil {
+		logger.Error(err, "failed to create leader elector, background loops disabled")
+		return
+	}
+	for ctx.Err() == nil {
+		le.Run(ctx)
+	}
+}
@@ -0,0 +1,111 @@
+// Package luks manages dm-crypt/LUKS mappings of disk volumes with cryptsetup.
+package luks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	utilsos "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/os"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// FsType is reported by blkid for LUKS devices
+	FsType = "crypto_LUKS"
+
+	mapperPrefix = "csi-luks-"
+)
+
+// MapperName namespaces our crypt mappings so they can be recognized in dmsetup.
+func MapperName(volumeID string) string {
+	return mapperPrefix + volumeID
+}
+
+func DevicePath(volumeID string) string {
+	return "/dev/mapper/" + MapperName(volumeID)
+}
+
+// IsOpen reports whether the crypt mapping of volumeID exists.
+func IsOpen(volumeID string) (bool, error) {
+	_, err := os.Stat(DevicePath(volumeID))
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+func cryptsetup(ctx context.Context, passphrase []byte, args ...string) error {
+	cmd := exec.CommandContext(ctx, "cryptsetup", args...)
+	// We are in a container, udev on the host may not be reachable.
+	// Let libdevmapper create the device node itself instead of waiting for udev forever.
+	cmd.Env = append(os.Environ(), "DM_DISABLE_UDEV=1")
+	if passphrase != nil {
+		// read the whole stdin as the passphrase, including any trailing newline
+		cmd.Args = append(cmd.Args, "--key-file=-")
+		cmd.Stdin = bytes.NewReader(passphrase)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("cryptsetup %s failed: %w", args[0], utilsos.ErrWithStderr(err))
+	}
+	klog.FromContext(ctx).V(4).Info("cryptsetup success", "args", args, "output", string(output))
+	return nil
+}
+
+// Format initializes a LUKS2 header on device. All data on device is lost.
+func Format(ctx context.Context, device string, passphrase []byte) error {
+	return cryptsetup(ctx, passphrase, "luksFormat", "--batch-mode", "--type", "luks2", device)
+}
+
+// Open opens the LUKS device and returns the path of the plaintext device.
+// It is a no-op if the mapping already exists.
+func Open(ctx context.Context, device, volumeID string, passphrase []byte) (string, error) {
+	path := DevicePath(volumeID)
+	opened, err := IsOpen(volumeID)
+	if err != nil {
+		return "", err
+	}
+	if opened {
+		return path, nil
+	}
+	// Keep the volume key out of the kernel keyring, so that Resize does not need the passphrase.
+	err = cryptsetup(ctx, passphrase, "open", "--type", "luks", "--disable-keyring", device, MapperName(volumeID))
+	if err != nil {
+		return "", err
+	}
+	klog.FromContext(ctx).V(2).Info("opened LUKS device", "device", device, "mapper", path)
+	return path, nil
+}
+
+// Close removes the crypt mapping of volumeID. An absent mapping is not an error.
+func Close(ctx context.Context, volumeID string) error {
+	opened, err := IsOpen(volumeID)
+	if err != nil || !opened {
+		return err
+	}
+	if err := cryptsetup(ctx, nil, "close", MapperName(volumeID)); err != nil {
+		return err
+	}
+	klog.FromContext(ctx).V(2).Info("closed LUKS device", "mapper", MapperName(volumeID))
+	return nil
+}
+
+// Resize grows the crypt mapping of volumeID to fill the underlying device.
+// Returns false if the mapping does not exist.
+func Resize(ctx context.Context, volumeID string) (bool, error) {
+	opened, err := IsOpen(volumeID)
+	if err != nil || !opened {
+		return false, err
+	}
+	if err := cryptsetup(ctx, nil, "resize", MapperName(volumeID)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
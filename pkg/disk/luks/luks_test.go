@@ -0,0 +1,39 @@
+package luks
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func TestDevicePath(t *testing.T) {
+	assert.Equal(t, "/dev/mapper/csi-luks-d-123", DevicePath("d-123"))
+}
+
+func TestFormat(t *testing.T) {
+	path, err := exec.LookPath("cryptsetup")
+	if errors.Is(err, exec.ErrNotFound) {
+		t.Skip("cryptsetup not found")
+	}
+	require.NoError(t, err)
+	t.Logf("cryptsetup found at: %s", path)
+	_, ctx := ktesting.NewTestContext(t)
+
+	testImage := t.TempDir() + "/test.img"
+	f, err := os.Create(testImage)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Truncate(testImage, 1<<25)) // 32MB, larger than the LUKS2 header
+
+	require.NoError(t, Format(ctx, testImage, []byte("passphrase")))
+	assert.NoError(t, exec.Command("cryptsetup", "isLuks", testImage).Run())
+
+	// wrong passphrase is rejected before creating any mapping
+	err = cryptsetup(ctx, []byte("wrong"), "open", "--test-passphrase", testImage)
+	assert.ErrorContains(t, err, "cryptsetup open failed")
+}
@@ -7,7 +7,9 @@ import (
 	"sync"
 	"time"
 
+	openapiutil "github.com/alibabacloud-go/darabonba-openapi/v2/utils"
 	ecs20140526 "github.com/alibabacloud-go/ecs-20140526/v7/client"
+	"github.com/alibabacloud-go/tea/dara"
 	"github.com/go-logr/logr"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud/wrap"
@@ -30,6 +32,26 @@ type ModifyParameters struct {
 	BurstingEnabled  *bool
 	Tags             []*ecs20140526.TagResourcesRequestTag
 	RemoveTags       []*string
+
+	MultiAttach        *bool
+	DeleteAutoSnapshot *bool
+	DeleteWithInstance *bool
+
+	// IOLimits replaces all the IO limits applied to the pods on node if not nil, see utils.IOLimitKeys.
+	// Stored on the PV, not the disk.
+	IOLimits map[string]string
+}
+
+func (p *ModifyParameters) buildModifyAttributeRequest(diskID string) *ecs20140526.ModifyDiskAttributeRequest {
+	if p.BurstingEnabled == nil && p.DeleteAutoSnapshot == nil && p.DeleteWithInstance == nil {
+		return nil
+	}
+	return &ecs20140526.ModifyDiskAttributeRequest{
+		DiskId:             &diskID,
+		BurstingEnabled:    p.BurstingEnabled,
+		DeleteAutoSnapshot: p.DeleteAutoSnapshot,
+		DeleteWithInstance: p.DeleteWithInstance,
+	}
 }
 
 func (p *ModifyParameters) buildModifySpecRequest(diskID string) *ecs20140526.ModifyDiskSpecRequest {
@@ -224,17 +246,14 @@ func (m *ModifyServer) modifyDiskSpec(ctx context.Context, logger logr.Logger, r
 	return nil
 }
 
-func (m *ModifyServer) modifyDiskAttribute(ctx context.Context, logger logr.Logger, diskID string, burstingEnabled bool) error {
+func (m *ModifyServer) modifyDiskAttribute(ctx context.Context, logger logr.Logger, req *ecs20140526.ModifyDiskAttributeRequest) error {
+	diskID := ptr.Deref(req.DiskId, "")
 	var err error
 	for range 3 {
-		req := &ecs20140526.ModifyDiskAttributeRequest{
-			DiskId:          &diskID,
-			BurstingEnabled: &burstingEnabled,
-		}
-
 		_, err = wrap.V2(logger, m.ecsClient.ModifyDiskAttribute)(req)
 		if err == nil {
-			logger.V(2).Info("modified disk bursting enabled", "enabled", burstingEnabled)
+			logger.V(2).Info("modified disk attribute", "burstingEnabled", req.BurstingEnabled,
+				"deleteAutoSnapshot", req.DeleteAutoSnapshot, "deleteWithInstance", req.DeleteWithInstance)
 			return nil
 		}
 		if errors.Is(err, wrap.ErrorCode("BurstingEnabledForModifyingDiskUnsupported")) {
@@ -247,12 +266,120 @@ func (m *ModifyServer) modifyDiskAttribute(ctx context.Context, logger logr.Logg
 				continue
 			}
 		} else {
-			return fmt.Errorf("error while modifying disk %s bursting enabled: %w", diskID, err)
+			return fmt.Errorf("error while modifying disk %s attribute: %w", diskID, err)
 		}
 	}
 	return err
 }
 
+func (m *ModifyServer) describeDisk(logger logr.Logger, diskID string) (*ecs20140526.DescribeDisksResponseBodyDisksDisk, error) {
+	req := &ecs20140526.DescribeDisksRequest{
+		RegionId: &GlobalConfigVar.Region,
+		DiskIds:  new(fmt.Sprintf("[%q]", diskID)),
+	}
+	resp, err := wrap.V2(logger, m.ecsClient.DescribeDisks)(req)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to describe disk %s: %v", diskID, err)
+	}
+	if resp.Body == nil || resp.Body.Disks == nil || len(resp.Body.Disks.Disk) == 0 {
+		return nil, status.Errorf(codes.NotFound, "disk %s not found", diskID)
+	}
+	return resp.Body.Disks.Disk[0], nil
+}
+
+// validateDiskState checks the parameters that can only be changed in some state of the disk.
+// Returns whether MultiAttach needs to be changed.
+func (m *ModifyServer) validateDiskState(logger logr.Logger, diskID string, params *ModifyParameters) (bool, error) {
+	if params.MultiAttach == nil && params.DeleteWithInstance == nil {
+		return false, nil
+	}
+	disk, err := m.describeDisk(logger, diskID)
+	if err != nil {
+		return false, err
+	}
+	if params.DeleteWithInstance != nil {
+		category := params.Category
+		if category == "" {
+			category = Category(ptr.Deref(disk.Category, ""))
+		}
+		if AllCategories[category].SingleInstance {
+			return false, status.Errorf(codes.FailedPrecondition,
+				"DeleteWithInstance of %s disk is managed by the driver, and cannot be modified", category)
+		}
+	}
+	modifyMultiAttach := false
+	if params.MultiAttach != nil {
+		enabled := ptr.Deref(disk.MultiAttach, "") == DiskMultiAttachEnabled
+		if enabled != *params.MultiAttach {
+			diskStatus := ptr.Deref(disk.Status, "")
+			if diskStatus != DiskStatusAvailable {
+				return false, status.Errorf(codes.FailedPrecondition,
+					"disk %s is %s, MultiAttach can only be changed when the disk is detached from all instances", diskID, diskStatus)
+			}
+			modifyMultiAttach = true
+		}
+	}
+	return modifyMultiAttach, nil
+}
+
+// modifyDiskMultiAttachRequest is ModifyDiskAttribute with MultiAttach, which is not in the generated SDK yet.
+type modifyDiskMultiAttachRequest struct {
+	DiskId      *string
+	MultiAttach *string
+}
+
+func (m *ModifyServer) callModifyDiskMultiAttach(req *modifyDiskMultiAttachRequest) (*ecs20140526.ModifyDiskAttributeResponse, error) {
+	params := &openapiutil.Params{
+		Action:      new("ModifyDiskAttribute"),
+		Version:     new("2014-05-26"),
+		Protocol:    new("HTTPS"),
+		Pathname:    new("/"),
+		Method:      new("POST"),
+		AuthType:    new("AK"),
+		Style:       new("RPC"),
+		ReqBodyType: new("formData"),
+		BodyType:    new("json"),
+	}
+	body, err := m.ecsClient.CallApi(params, &openapiutil.OpenApiRequest{
+		Query: map[string]*string{
+			"DiskId":      req.DiskId,
+			"MultiAttach": req.MultiAttach,
+		},
+	}, &dara.RuntimeOptions{})
+	if err != nil {
+		return nil, err
+	}
+	resp := &ecs20140526.ModifyDiskAttributeResponse{}
+	return resp, dara.Convert(body, resp)
+}
+
+// modifyDiskMultiAttach enables or disables MultiAttach of a detached disk, and verifies it is applied.
+func (m *ModifyServer) modifyDiskMultiAttach(logger logr.Logger, diskID string, enabled bool) error {
+	value := DiskMultiAttachDisabled
+	if enabled {
+		value = DiskMultiAttachEnabled
+	}
+	req := &modifyDiskMultiAttachRequest{DiskId: &diskID, MultiAttach: &value}
+	if _, err := wrap.V2(logger, m.callModifyDiskMultiAttach)(req); err != nil {
+		grpcCode := codes.Internal
+		if code, ok := errors.AsType[wrap.ErrorCode](err); ok {
+			grpcCode = mapModifySpecCode(code)
+		}
+		return status.Errorf(grpcCode, "failed to modify MultiAttach of disk %s: %v", diskID, err)
+	}
+
+	disk, err := m.describeDisk(logger, diskID)
+	if err != nil {
+		return err
+	}
+	if actual := ptr.Deref(disk.MultiAttach, ""); actual != value {
+		return status.Errorf(codes.FailedPrecondition,
+			"MultiAttach of disk %s is still %s, changing it may not be supported for this disk", diskID, actual)
+	}
+	logger.V(2).Info("modified disk MultiAttach", "multiAttach", value)
+	return nil
+}
+
 func (m *ModifyServer) Modify(ctx context.Context, diskID string, params ModifyParameters) error {
 	logger := klog.FromContext(ctx)
 
@@ -268,6 +395,11 @@ func (m *ModifyServer) Modify(ctx context.Context, diskID string, params ModifyP
 		}
 	}
 
+	modifyMultiAttach, err := m.validateDiskState(logger, diskID, &params)
+	if err != nil {
+		return err
+	}
+
 	specReq := params.buildModifySpecRequest(diskID)
 	if specReq != nil {
 		dirty, err := m.verifyModifyDiskSpec(ctx, logger, specReq)
@@ -308,6 +440,12 @@ func (m *ModifyServer) Modify(ctx context.Context, diskID string, params ModifyP
 		logger.V(2).Info("tagged disk")
 	}
 
+	if modifyMultiAttach {
+		if err := m.modifyDiskMultiAttach(logger, diskID, *params.MultiAttach); err != nil {
+			return err
+		}
+	}
+
 	if specReq != nil {
 		specReq = params.buildModifySpecRequest(diskID) // not dry-run
 		if err := m.modifyDiskSpec(ctx, logger, specReq); err != nil {
@@ -316,8 +454,8 @@ func (m *ModifyServer) Modify(ctx context.Context, diskID string, params ModifyP
 	}
 
 	// Should goes after ModifyDiskSpec, because we may need to modify category to cloud_auto first
-	if params.BurstingEnabled != nil {
-		err := m.modifyDiskAttribute(ctx, logger, diskID, *params.BurstingEnabled)
+	if attrReq := params.buildModifyAttributeRequest(diskID); attrReq != nil {
+		err := m.modifyDiskAttribute(ctx, logger, attrReq)
 		if err != nil {
 			return err
 		}
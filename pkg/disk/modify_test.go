@@ -5,7 +5,9 @@ import (
 	"errors"
 	"testing"
 
+	openapiutil "github.com/alibabacloud-go/darabonba-openapi/v2/utils"
 	ecs20140526 "github.com/alibabacloud-go/ecs-20140526/v7/client"
+	"github.com/alibabacloud-go/tea/dara"
 	"github.com/alibabacloud-go/tea/tea"
 	"github.com/golang/mock/gomock"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
@@ -354,3 +356,132 @@ func TestBuildModifySpecRequest(t *testing.T) {
 		})
 	}
 }
+
+func describedDisk(status, multiAttach, category string) *ecs20140526.DescribeDisksResponse {
+	return &ecs20140526.DescribeDisksResponse{
+		Body: &ecs20140526.DescribeDisksResponseBody{
+			Disks: &ecs20140526.DescribeDisksResponseBodyDisks{
+				Disk: []*ecs20140526.DescribeDisksResponseBodyDisksDisk{{
+					DiskId:      new("d-test"),
+					Status:      new(status),
+					MultiAttach: new(multiAttach),
+					Category:    new(category),
+				}},
+			},
+		},
+	}
+}
+
+func TestModify_MultiAttach(t *testing.T) {
+	c, m := newTestModifyServer(t)
+
+	gomock.InOrder(
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(describedDisk(DiskStatusAvailable, DiskMultiAttachDisabled, "cloud_essd"), nil),
+		c.EXPECT().CallApi(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(params *openapiutil.Params, req *openapiutil.OpenApiRequest, _ *dara.RuntimeOptions) (map[string]any, error) {
+				assert.Equal(t, "ModifyDiskAttribute", *params.Action)
+				assert.Equal(t, "d-test", *req.Query["DiskId"])
+				assert.Equal(t, DiskMultiAttachEnabled, *req.Query["MultiAttach"])
+				return map[string]any{"body": map[string]any{"RequestId": "r-1"}}, nil
+			}),
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(describedDisk(DiskStatusAvailable, DiskMultiAttachEnabled, "cloud_essd"), nil),
+	)
+
+	_, ctx := ktesting.NewTestContext(t)
+	err := m.Modify(ctx, "d-test", ModifyParameters{MultiAttach: new(true)})
+	require.NoError(t, err)
+}
+
+func TestModify_MultiAttachNotApplied(t *testing.T) {
+	c, m := newTestModifyServer(t)
+
+	c.EXPECT().DescribeDisks(gomock.Any()).Return(describedDisk(DiskStatusAvailable, DiskMultiAttachDisabled, "cloud_essd"), nil).Times(2)
+	c.EXPECT().CallApi(gomock.Any(), gomock.Any(), gomock.Any()).Return(map[string]any{}, nil)
+
+	_, ctx := ktesting.NewTestContext(t)
+	err := m.Modify(ctx, "d-test", ModifyParameters{MultiAttach: new(true)})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err), err)
+}
+
+func TestModify_AttributeState(t *testing.T) {
+	cases := []struct {
+		name   string
+		disk   *ecs20140526.DescribeDisksResponse
+		params ModifyParameters
+		code   codes.Code
+	}{
+		{
+			name:   "MultiAttach unchanged",
+			disk:   describedDisk(DiskStatusInuse, DiskMultiAttachEnabled, "cloud_essd"),
+			params: ModifyParameters{MultiAttach: new(true)},
+		},
+		{
+			name:   "MultiAttach of attached disk",
+			disk:   describedDisk(DiskStatusInuse, DiskMultiAttachDisabled, "cloud_essd"),
+			params: ModifyParameters{MultiAttach: new(true)},
+			code:   codes.FailedPrecondition,
+		},
+		{
+			name:   "DeleteWithInstance managed by driver",
+			disk:   describedDisk(DiskStatusInuse, DiskMultiAttachDisabled, string(DiskEEDStandard)),
+			params: ModifyParameters{DeleteWithInstance: new(false)},
+			code:   codes.FailedPrecondition,
+		},
+		{
+			name:   "disk not found",
+			disk:   &ecs20140526.DescribeDisksResponse{Body: &ecs20140526.DescribeDisksResponseBody{}},
+			params: ModifyParameters{DeleteWithInstance: new(false)},
+			code:   codes.NotFound,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, m := newTestModifyServer(t)
+			c.EXPECT().DescribeDisks(gomock.Any()).Return(tc.disk, nil)
+
+			_, ctx := ktesting.NewTestContext(t)
+			err := m.Modify(ctx, "d-test", tc.params)
+			assert.Equal(t, tc.code, status.Code(err), err)
+		})
+	}
+}
+
+func TestModify_DeleteFlags(t *testing.T) {
+	c, m := newTestModifyServer(t)
+
+	c.EXPECT().DescribeDisks(gomock.Any()).Return(describedDisk(DiskStatusInuse, DiskMultiAttachDisabled, "cloud_essd"), nil)
+	c.EXPECT().ModifyDiskAttribute(&ecs20140526.ModifyDiskAttributeRequest{
+		DiskId:             new("d-test"),
+		DeleteAutoSnapshot: new(true),
+		DeleteWithInstance: new(false),
+	}).Return(&ecs20140526.ModifyDiskAttributeResponse{}, nil)
+
+	_, ctx := ktesting.NewTestContext(t)
+	err := m.Modify(ctx, "d-test", ModifyParameters{DeleteAutoSnapshot: new(true), DeleteWithInstance: new(false)})
+	require.NoError(t, err)
+}
+
+func TestParseMutableParameters(t *testing.T) {
+	params, err := parseMutableParameters(map[string]string{
+		MULTI_ATTACH_KEY:     "Enabled",
+		DELETE_AUTO_SNAPSHOT: "true",
+		DELETE_WITH_INSTANCE: "false",
+		"readIOPS":           "1000",
+		"writeBPS":           "10M",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, new(true), params.MultiAttach)
+	assert.Equal(t, new(true), params.DeleteAutoSnapshot)
+	assert.Equal(t, new(false), params.DeleteWithInstance)
+	assert.Equal(t, map[string]string{"readIOPS": "1000", "writeBPS": "10M"}, params.IOLimits)
+
+	for k, v := range map[string]string{
+		MULTI_ATTACH_KEY:     "maybe",
+		DELETE_AUTO_SNAPSHOT: "1x",
+		"writeIOPS":          "1k",
+		"readBPS":            "fast",
+	} {
+		_, err := parseMutableParameters(map[string]string{k: v})
+		assert.Error(t, err, k)
+	}
+}
@@ -0,0 +1,102 @@
+//go:build !windows
+
+package disk
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/ttlcache"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	k8smount "k8s.io/mount-utils"
+)
+
+// how long the IO limits on PV are cached, the delay before a change is applied to the running pods
+const ioLimitsCacheTTL = time.Minute
+
+// e.g. /var/lib/kubelet/pods/<pod UID>/volumes/kubernetes.io~csi/<PV name>/mount
+var podVolumePathRegexp = regexp.MustCompile(`/pods/([^/]+)/volumes/kubernetes\.io~csi/([^/]+)/mount$`)
+
+type ioLimitsUpdater interface {
+	UpdateIOLimits(podUID string, major, minor uint32, params map[string]string) error
+}
+
+// ioLimitsReconciler re-applies the IO limits modified by ControllerModifyVolume to the running pods,
+// without remounting. The limits are stored in the annotation of the PV.
+type ioLimitsReconciler struct {
+	clientSet kubernetes.Interface
+	cgroup    ioLimitsUpdater
+
+	pvIOLimits *ttlcache.TTLCache[string, string]
+	applied    sync.Map // volume path -> value of the annotation applied
+}
+
+func newIOLimitsReconciler(clientSet kubernetes.Interface, cgroup ioLimitsUpdater) *ioLimitsReconciler {
+	return &ioLimitsReconciler{
+		clientSet:  clientSet,
+		cgroup:     cgroup,
+		pvIOLimits: ttlcache.NewTTLCache[string, string](ioLimitsCacheTTL),
+	}
+}
+
+// reset forgets the limits applied to volumePath, e.g. when it is (re)published with the limits in its volume context.
+func (r *ioLimitsReconciler) reset(volumePath string) {
+	if r == nil {
+		return
+	}
+	r.applied.Delete(volumePath)
+}
+
+// reconcile applies the IO limits on the PV to the pod, if changed since last applied.
+// Called periodically from NodeGetVolumeStats.
+func (r *ioLimitsReconciler) reconcile(ctx context.Context, mnts []k8smount.MountInfo, volumePath string) {
+	if r == nil {
+		return
+	}
+	m := podVolumePathRegexp.FindStringSubmatch(volumePath)
+	if m == nil {
+		return
+	}
+	podUID, pvName := m[1], m[2]
+	logger := klog.FromContext(ctx).WithValues("pv", pvName, "podUID", podUID)
+
+	value, err := r.pvIOLimits.Get(ctx, pvName, func() (string, error) {
+		pv, err := r.clientSet.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return pv.Annotations[annIOLimits], nil
+	})
+	if err != nil {
+		logger.Error(err, "failed to get IO limits of PV")
+		return
+	}
+	if value == "" {
+		return // not modified, the limits in volume context are applied at publish
+	}
+	if applied, ok := r.applied.Load(volumePath); ok && applied.(string) == value {
+		return
+	}
+
+	published := findMountInfo(mnts, volumePath)
+	if published == nil || published.FsType == "devtmpfs" {
+		return // not mounted on host, or a block volume, which has no IO limits
+	}
+	var limits map[string]string
+	if err := json.Unmarshal([]byte(value), &limits); err != nil {
+		logger.Error(err, "invalid IO limits annotation", "annotation", annIOLimits)
+		return
+	}
+	err = r.cgroup.UpdateIOLimits(podUID, uint32(published.Major), uint32(published.Minor), limits)
+	if err != nil {
+		logger.Error(err, "failed to update IO limits")
+		return
+	}
+	r.applied.Store(volumePath, value)
+	logger.Info("updated IO limits", "limits", limits)
+}
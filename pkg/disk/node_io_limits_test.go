@@ -0,0 +1,62 @@
+//go:build !windows
+
+package disk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2/ktesting"
+	k8smount "k8s.io/mount-utils"
+)
+
+type fakeIOLimitsUpdater struct {
+	calls []map[string]string
+}
+
+func (f *fakeIOLimitsUpdater) UpdateIOLimits(podUID string, major, minor uint32, params map[string]string) error {
+	if podUID != "pod-1" || major != 253 || minor != 16 {
+		panic("unexpected device or pod")
+	}
+	f.calls = append(f.calls, params)
+	return nil
+}
+
+func TestIOLimitsReconciler(t *testing.T) {
+	const volumePath = "/var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pv-1/mount"
+	mnts := []k8smount.MountInfo{{MountPoint: volumePath, FsType: "ext4", Major: 253, Minor: 16}}
+	_, ctx := ktesting.NewTestContext(t)
+
+	pv := &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{
+		Name:        "pv-1",
+		Annotations: map[string]string{annIOLimits: `{"readIOPS":"1000"}`},
+	}}
+	updater := &fakeIOLimitsUpdater{}
+	r := newIOLimitsReconciler(fake.NewClientset(pv), updater)
+
+	r.reconcile(ctx, mnts, volumePath)
+	assert.Equal(t, []map[string]string{{"readIOPS": "1000"}}, updater.calls)
+
+	// already applied
+	r.reconcile(ctx, mnts, volumePath)
+	assert.Len(t, updater.calls, 1)
+
+	// re-published with the limits in volume context
+	r.reset(volumePath)
+	r.reconcile(ctx, mnts, volumePath)
+	assert.Len(t, updater.calls, 2)
+
+	// not a pod volume, or not mounted
+	r.reconcile(ctx, mnts, "/mnt/other")
+	r.reset(volumePath)
+	r.reconcile(ctx, nil, volumePath)
+	assert.Len(t, updater.calls, 2)
+
+	// nil reconciler is a no-op
+	var nilReconciler *ioLimitsReconciler
+	nilReconciler.reconcile(ctx, mnts, volumePath)
+	nilReconciler.reset(volumePath)
+}
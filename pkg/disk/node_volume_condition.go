@@ -0,0 +1,127 @@
+//go:build !windows
+
+package disk
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/metric"
+	"github.com/prometheus/procfs/blockdevice"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	k8smount "k8s.io/mount-utils"
+)
+
+const (
+	// same as the disk_stat metric collector
+	hungDiskDuration = 30 * time.Second
+	// sample /proc/diskstats in background, NodeGetVolumeStats is not called frequently enough
+	diskStatsSampleInterval = 5 * time.Second
+)
+
+// startHungDiskDetector samples /proc/diskstats periodically so that hung disks can be found at any time.
+// Returns nil if /proc/diskstats is not available.
+func startHungDiskDetector() *metric.ProcDiskStats {
+	s, err := metric.NewDefaultProcDiskStats()
+	if err != nil {
+		klog.ErrorS(err, "failed to open diskstats, hung disk detection disabled")
+		return nil
+	}
+	s.HungDuration = hungDiskDuration
+	go wait.Forever(func() {
+		if _, err := s.GetStats(); err != nil {
+			klog.ErrorS(err, "failed to sample diskstats")
+		}
+	}, diskStatsSampleInterval)
+	return s
+}
+
+// NodeGetVolumeStats reports the usage of the volume, and its condition so that kubelet can emit events on the pod.
+func (ns *nodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	logger := klog.FromContext(ctx)
+
+	var hung []blockdevice.Info
+	if ns.diskStats != nil {
+		hung = ns.diskStats.GetHungDisks()
+	}
+	mnts, err := k8smount.ParseMountInfo(mountInfoPath)
+	if err != nil {
+		logger.Error(err, "failed to parse mountinfo, skip volume condition")
+		return ns.GenericNodeServer.NodeGetVolumeStats(ctx, req)
+	}
+	problems, isHung := volumeProblems(mnts, hung, req.VolumeId, req.VolumePath, req.StagingTargetPath)
+	ns.ioLimits.reconcile(ctx, mnts, req.VolumePath)
+	condition := &csi.VolumeCondition{Abnormal: false, Message: "volume is healthy"}
+	if len(problems) > 0 {
+		condition = &csi.VolumeCondition{Abnormal: true, Message: strings.Join(problems, "; ")}
+	}
+	if isHung {
+		// statfs would block forever on a hung disk
+		return &csi.NodeGetVolumeStatsResponse{VolumeCondition: condition}, nil
+	}
+
+	// For a subpath volume, statfs on its directory reports the project quota rather than the shared disk.
+	resp, err := ns.GenericNodeServer.NodeGetVolumeStats(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.VolumeCondition = condition
+	return resp, nil
+}
+
+// volumeProblems checks the volume published at volumePath using only mountinfo and diskstats,
+// so it never touches the (maybe hung) filesystem.
+// The second return value is true if the backing device is hung.
+func volumeProblems(mnts []k8smount.MountInfo, hung []blockdevice.Info, volumeID, volumePath, stagingPath string) ([]string, bool) {
+	published := findMountInfo(mnts, volumePath)
+	if published == nil {
+		// e.g. the volume is passed to a kata VM, nothing to check on the host
+		return nil, false
+	}
+
+	var problems []string
+	isBlock := published.FsType == "devtmpfs"
+	if isBlock {
+		// staged as a bind mount of the device file, see setupDisk
+		stagingPath = filepath.Join(stagingPath, volumeID)
+	}
+	if stagingPath != "" {
+		staged := findMountInfo(mnts, stagingPath)
+		if staged == nil {
+			problems = append(problems, fmt.Sprintf("staging path %s is not mounted", stagingPath))
+		} else if !isBlock && slices.Contains(staged.SuperOptions, "ro") && slices.Contains(staged.MountOptions, "rw") {
+			// The filesystem is staged read-write, but turned read-only by the kernel, usually after an I/O error.
+			problems = append(problems, "filesystem is read-only, possibly due to I/O errors")
+		}
+	}
+
+	isHung := false
+	for _, info := range hung {
+		if isBlock {
+			isHung = "/"+info.DeviceName == published.Root
+		} else {
+			isHung = info.MajorNumber == uint32(published.Major) && info.MinorNumber == uint32(published.Minor)
+		}
+		if isHung {
+			problems = append(problems, fmt.Sprintf("I/O hung on device /dev/%s for more than %v", info.DeviceName, hungDiskDuration))
+			break
+		}
+	}
+	return problems, isHung
+}
+
+func findMountInfo(mnts []k8smount.MountInfo, mountPoint string) *k8smount.MountInfo {
+	// the last one is the visible one if mounted multiple times
+	for i := len(mnts) - 1; i >= 0; i-- {
+		if mnts[i].MountPoint == mountPoint {
+			return &mnts[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+//go:build !windows
+
+package disk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/procfs/blockdevice"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testVolumePath  = "/var/lib/kubelet/pods/uid/volumes/kubernetes.io~csi/pv-1/mount"
+	testStagingPath = "/var/lib/kubelet/plugins/kubernetes.io/csi/diskplugin.csi.alibabacloud.com/hash/globalmount"
+	testBlockPath   = "/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/publish/pv-1/uid"
+)
+
+func TestVolumeProblems(t *testing.T) {
+	stagedRW := "1001 97 253:16 / " + testStagingPath + " rw,relatime shared:300 - ext4 /dev/vdb rw"
+	publishedRW := "1002 97 253:16 / " + testVolumePath + " rw,relatime shared:300 - ext4 /dev/vdb rw"
+	stagedBlock := "1003 97 0:5 /vdb " + testStagingPath + "/d-1 rw,nosuid shared:21 - devtmpfs devtmpfs rw,size=7901960k"
+	publishedBlock := "1004 97 0:5 /vdb " + testBlockPath + " rw,nosuid shared:21 - devtmpfs devtmpfs rw,size=7901960k"
+	vdbHung := []blockdevice.Info{{MajorNumber: 253, MinorNumber: 16, DeviceName: "vdb"}}
+
+	cases := []struct {
+		name       string
+		mountInfo  []string
+		volumePath string
+		hung       []blockdevice.Info
+		problems   []string
+		isHung     bool
+	}{
+		{
+			name:       "healthy",
+			mountInfo:  []string{stagedRW, publishedRW},
+			volumePath: testVolumePath,
+		},
+		{
+			name:       "not published",
+			mountInfo:  []string{stagedRW},
+			volumePath: testVolumePath,
+		},
+		{
+			name:       "staging missing",
+			mountInfo:  []string{publishedRW},
+			volumePath: testVolumePath,
+			problems:   []string{"staging path " + testStagingPath + " is not mounted"},
+		},
+		{
+			name: "read-only after error",
+			mountInfo: []string{
+				"1001 97 253:16 / " + testStagingPath + " rw,relatime shared:300 - ext4 /dev/vdb ro",
+				"1002 97 253:16 / " + testVolumePath + " rw,relatime shared:300 - ext4 /dev/vdb ro",
+			},
+			volumePath: testVolumePath,
+			problems:   []string{"filesystem is read-only, possibly due to I/O errors"},
+		},
+		{
+			name: "staged read-only",
+			mountInfo: []string{
+				"1001 97 253:16 / " + testStagingPath + " ro,relatime shared:300 - ext4 /dev/vdb ro",
+				"1002 97 253:16 / " + testVolumePath + " ro,relatime shared:300 - ext4 /dev/vdb ro",
+			},
+			volumePath: testVolumePath,
+		},
+		{
+			name:       "hung",
+			mountInfo:  []string{stagedRW, publishedRW},
+			volumePath: testVolumePath,
+			hung:       vdbHung,
+			problems:   []string{"I/O hung on device /dev/vdb for more than 30s"},
+			isHung:     true,
+		},
+		{
+			name:       "other disk hung",
+			mountInfo:  []string{stagedRW, publishedRW},
+			volumePath: testVolumePath,
+			hung:       []blockdevice.Info{{MajorNumber: 253, MinorNumber: 32, DeviceName: "vdc"}},
+		},
+		{
+			name:       "block healthy",
+			mountInfo:  []string{stagedBlock, publishedBlock},
+			volumePath: testBlockPath,
+		},
+		{
+			name:       "block staging missing and hung",
+			mountInfo:  []string{publishedBlock},
+			volumePath: testBlockPath,
+			hung:       vdbHung,
+			problems: []string{
+				"staging path " + testStagingPath + "/d-1 is not mounted",
+				"I/O hung on device /dev/vdb for more than 30s",
+			},
+			isHung: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mnts := parseMountinfo(t, strings.Join(c.mountInfo, "\n"))
+			problems, isHung := volumeProblems(mnts, c.hung, "d-1", c.volumePath, testStagingPath)
+			assert.Equal(t, c.problems, problems)
+			assert.Equal(t, c.isHung, isHung)
+		})
+	}
+}
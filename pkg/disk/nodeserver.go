@@ -30,6 +30,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -37,9 +38,12 @@ import (
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud/metadata"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/datacache"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/luks"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/mounter"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/sfdisk"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/stripe"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/features"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/metric"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
 	utilsio "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/io"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/rund/directvolume"
@@ -71,7 +75,11 @@ type nodeServer struct {
 	clientSet    *kubernetes.Clientset
 	ad           DiskAttachDetach
 	locks        *utils.VolumeLocks
-	dmControl    *datacache.DmControl // nil if device-mapper is unavailable on this node
+	dmControl    *datacache.DmControl  // nil if device-mapper is unavailable on this node
+	diskStats    *metric.ProcDiskStats // nil if hung disk detection is disabled
+	fsFreeze     *fsFreezeServer
+	ioLimits     *ioLimitsReconciler // nil if NodeGetVolumeStats is not enabled
+	subpathLock  sync.Mutex          // serializes the changes to shared disks of subpath volumes
 	common.GenericNodeServer
 }
 
@@ -204,8 +212,13 @@ func NewNodeServer(csiCfg utils.Config, ecs cloud.ECSInterface, ecsV2 cloud.ECSv
 		klog.Info("device-mapper unavailable, data cache disabled")
 	}
 
+	var diskStats *metric.ProcDiskStats
+	if GlobalConfigVar.MetricEnable {
+		diskStats = startHungDiskDetector()
+	}
+
 	waiter, batcher := newBatcher(true)
-	return &nodeServer{
+	ns := &nodeServer{
 		metadata:     m,
 		useLabeler:   useLabeler,
 		recorder:     utils.NewEventRecorder(utils.EventComponentNode),
@@ -233,10 +246,26 @@ func NewNodeServer(csiCfg utils.Config, ecs cloud.ECSInterface, ecsV2 cloud.ECSv
 		},
 		locks:     utils.NewVolumeLocks(),
 		dmControl: dmControl,
+		diskStats: diskStats,
 		GenericNodeServer: common.GenericNodeServer{
 			NodeID: GlobalConfigVar.NodeID,
 		},
 	}
+	ns.fsFreeze = newFsFreezeServer(csiCfg, GlobalConfigVar.ClientSet, ns.stagedMountPoint)
+	ns.fsFreeze.thawStale(context.Background())
+	if nodeName := os.Getenv(kubeNodeName); nodeName != "" && GlobalConfigVar.ClientSet != nil {
+		go func() {
+			err := ns.fsFreeze.serve(context.Background(), nodeName, fsFreezePort(csiCfg))
+			klog.ErrorS(err, "fsfreeze service stopped, application-consistent snapshots of volumes on this node will fail")
+		}()
+	}
+	if GlobalConfigVar.MetricEnable {
+		ns.ioLimits = newIOLimitsReconciler(GlobalConfigVar.ClientSet, podCgroup)
+	}
+	if features.FunctionalMutableFeatureGate.Enabled(features.DiskAutoExpand) {
+		go newAutoExpander(GlobalConfigVar.ClientSet, ns.recorder, ns.k8smounter).run(context.Background())
+	}
+	return ns
 }
 
 func (ns *nodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
@@ -262,11 +291,18 @@ func (ns *nodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetC
 			},
 		},
 	}
+	nscap4 := &csi.NodeServiceCapability{
+		Type: &csi.NodeServiceCapability_Rpc{
+			Rpc: &csi.NodeServiceCapability_RPC{
+				Type: csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+			},
+		},
+	}
 
 	// Disk Metric enable config
 	nodeSvcCap := []*csi.NodeServiceCapability{nscap, nscap2}
 	if GlobalConfigVar.MetricEnable {
-		nodeSvcCap = []*csi.NodeServiceCapability{nscap, nscap2, nscap3}
+		nodeSvcCap = []*csi.NodeServiceCapability{nscap, nscap2, nscap3, nscap4}
 	}
 
 	return &csi.NodeGetCapabilitiesResponse{
@@ -372,10 +408,18 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
-	if sourceNotMounted {
-		device, err := DefaultDeviceManager.GetDeviceByVolumeID(req.GetVolumeId())
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "sourcePath %s is not mounted, and device not found: %v", sourcePath, err)
+	sharedDiskID, subpathName, subpath := parseSubpathVolumeID(req.VolumeId)
+	if sourceNotMounted && subpath {
+		if err := ns.stageSubpathVolume(ctx, req, sharedDiskID, subpathName, sourcePath); err != nil {
+			return nil, err
+		}
+	} else if sourceNotMounted {
+		device, striped := stripeDevicePath(req.VolumeId)
+		if !striped {
+			device, err = DefaultDeviceManager.GetDeviceByVolumeID(req.GetVolumeId())
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "sourcePath %s is not mounted, and device not found: %v", sourcePath, err)
+			}
 		}
 		// Re-establish the staging mount exactly as NodeStageVolume would, so a
 		// cache-backed volume is remounted on its dm-cache device rather than the
@@ -386,47 +430,83 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		logger.V(2).Info("SourcePath not mounted, remounted with device", "source", sourcePath, "device", device)
 	}
 
-	// check device name available
-	expectName, err := ns.ad.repo.GetVolumeDeviceName(logger, req.VolumeId)
+	var realDevice string
+	if subpath {
+		// bind mounted from the shared disk, checked on NodeStageVolume
+		realDevice, _, err = k8smount.GetDeviceNameFromMount(ns.k8smounter, sourcePath)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "get device name from mount %s: %v", sourcePath, err)
+		}
+	} else {
+		realDevice, err = ns.checkStagedDevice(logger, req, sourcePath, fsType, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Set volume IO Limit
+	ns.ioLimits.reset(targetPath)
+	err = ns.podCGroup.ApplyConfig(realDevice, req)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "get device name: %v", err)
+		return nil, status.Errorf(codes.Internal, "set IO limit: %v", err)
+	}
+
+	logger.V(2).Info("Starting mount", "options", options, "fsType", fsType)
+	if err = ns.k8smounter.Mount(sourcePath, targetPath, fsType, options); err != nil {
+		return nil, status.Errorf(codes.Internal, "mount %s to %s: %v", sourcePath, targetPath, err)
+	}
+
+	logger.V(2).Info("Mount successful", "source", sourcePath, "target", targetPath)
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// checkStagedDevice checks the device mounted at the staging path is the disk of the volume,
+// mounting it if the staging path is empty. It returns the mounted device.
+func (ns *nodeServer) checkStagedDevice(logger klog.Logger, req *csi.NodePublishVolumeRequest, sourcePath, fsType string, options []string) (string, error) {
+	expectName, striped := stripeDevicePath(req.VolumeId)
+	if !striped {
+		var err error
+		expectName, err = ns.ad.repo.GetVolumeDeviceName(logger, req.VolumeId)
+		if err != nil {
+			return "", status.Errorf(codes.Internal, "get device name: %v", err)
+		}
 	}
 
 	realDevice, _, err := k8smount.GetDeviceNameFromMount(ns.k8smounter, sourcePath)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "get device name from mount %s: %v", sourcePath, err)
+		return "", status.Errorf(codes.Internal, "get device name from mount %s: %v", sourcePath, err)
 	}
 	if realDevice == "" {
 		opts := append(options, "shared")
 		if err := ns.k8smounter.Mount(expectName, sourcePath, fsType, opts); err != nil {
-			return nil, status.Errorf(codes.Internal, "mount source %s to %s: %v", expectName, sourcePath, err)
+			return "", status.Errorf(codes.Internal, "mount source %s to %s: %v", expectName, sourcePath, err)
 		}
 		realDevice, _, err = k8smount.GetDeviceNameFromMount(ns.k8smounter, sourcePath)
 		if err != nil {
-			return nil, status.Errorf(codes.Internal, "get device name from mount %s: %v", sourcePath, err)
+			return "", status.Errorf(codes.Internal, "get device name from mount %s: %v", sourcePath, err)
 		}
 	}
-	if realDevice != "tmpfs" && realDevice != datacache.DevicePath(req.VolumeId) {
+	if realDevice != "tmpfs" && realDevice != datacache.DevicePath(req.VolumeId) && realDevice != luks.DevicePath(req.VolumeId) {
 		matched := false
 		if realDevice != "" {
 			realMajor, realMinor, err := DefaultDeviceManager.DevTmpFS.DevFor(realDevice)
 			if err != nil {
-				return nil, status.Errorf(codes.Internal, "stat real device %q: %v", realDevice, err)
+				return "", status.Errorf(codes.Internal, "stat real device %q: %v", realDevice, err)
 			}
 			expectMajor, expectMinor, err := DefaultDeviceManager.DevTmpFS.DevFor(expectName)
 			if err != nil {
-				return nil, status.Errorf(codes.Internal, "stat expected device %q: %v", expectName, err)
+				return "", status.Errorf(codes.Internal, "stat expected device %q: %v", expectName, err)
 			}
 			if realMajor == expectMajor && realMinor == expectMinor {
 				matched = true
 			}
 		}
 		if !matched {
-			return nil, status.Errorf(codes.Internal, "real device %s not same with expected %s", realDevice, expectName)
+			return "", status.Errorf(codes.Internal, "real device %s not same with expected %s", realDevice, expectName)
 		}
 		var d datacache.Opts
 		if err := datacache.GetOpts(req.VolumeContext, &d); err != nil {
-			return nil, status.Errorf(codes.Internal, "data cache options: %v", err)
+			return "", status.Errorf(codes.Internal, "data cache options: %v", err)
 		}
 		if d.Enabled() {
 			// datacache enabled, but we don't see the cache device
@@ -439,20 +519,7 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 			}, v1.EventTypeWarning, "DataCacheFallback", "DataCache enabled but not effective, check cache path exists on node")
 		}
 	}
-
-	// Set volume IO Limit
-	err = ns.podCGroup.ApplyConfig(realDevice, req)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "set IO limit: %v", err)
-	}
-
-	logger.V(2).Info("Starting mount", "options", options, "fsType", fsType)
-	if err = ns.k8smounter.Mount(sourcePath, targetPath, fsType, options); err != nil {
-		return nil, status.Errorf(codes.Internal, "mount %s to %s: %v", sourcePath, targetPath, err)
-	}
-
-	logger.V(2).Info("Mount successful", "source", sourcePath, "target", targetPath)
-	return &csi.NodePublishVolumeResponse{}, nil
+	return realDevice, nil
 }
 
 func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
@@ -464,6 +531,7 @@ func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 		return nil, status.Errorf(codes.Aborted, "There is already an operation for %s", req.VolumeId)
 	}
 	defer ns.locks.Release(req.VolumeId)
+	ns.ioLimits.reset(targetPath)
 
 	// Step 1: check folder exists
 	if !IsFileExisting(targetPath) {
@@ -542,6 +610,12 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 	if err := os.MkdirAll(targetPath, 0755); err != nil {
 		return nil, status.Errorf(codes.Internal, "create target path %s: %v", targetPath, err)
 	}
+	if sharedDiskID, name, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		if err := ns.stageSubpathVolume(ctx, req, sharedDiskID, name, targetPath); err != nil {
+			return nil, err
+		}
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
 
 	isBlock := req.GetVolumeCapability().GetBlock() != nil
 	if isBlock {
@@ -559,39 +633,45 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 	if mounted {
 		return &csi.NodeStageVolumeResponse{}, nil
 	}
+	passphrase, err := getLuksPassphrase(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	stripeMode, diskIDs, striped := parseStripeVolumeID(req.VolumeId)
+	if !striped {
+		diskIDs = []string{req.VolumeId}
+	} else if passphrase != nil {
+		return nil, status.Error(codes.InvalidArgument, "LUKS encryption is not supported for striped volumes")
+	}
 
-	device := ""
 	// Step 4 Attach volume
 	defaultErrCode := codes.Internal
-	serial := req.PublishContext[PUBLISH_CONTEXT_SERIAL]
-	if GlobalConfigVar.ADControllerEnable || serial != "" {
-		if serial == "" {
-			// for capability with old controller
-			serial = strings.TrimPrefix(req.VolumeId, "d-")
-		}
-		device, err = ns.ad.repo.findDevice(ctx, req.VolumeId, serial, nil)
+	serials := strings.Split(req.PublishContext[PUBLISH_CONTEXT_SERIAL], ",")
+	devices := make([]string, len(diskIDs))
+	for i, diskID := range diskIDs {
+		serial := ""
+		if i < len(serials) {
+			serial = serials[i]
+		}
+		device, attached, err := ns.findOrAttachDevice(ctx, diskID, serial, defaultErrCode)
 		if err != nil {
-			if GlobalConfigVar.ADControllerEnable {
-				return nil, status.Errorf(defaultErrCode, "ADController Enabled, but disk can't be found: %v", err)
-			}
-			// This disk should have been attached by controller, but old NodeUnstageVolume detaches it.
-			// So lets try attach it back.
+			return nil, err
 		}
-	}
-	if device == "" {
-		r, err := ns.ad.attachDisk(ctx, req.GetVolumeId(), ns.NodeID)
-		if err != nil {
-			fullErrorMessage := utils.FindSuggestionByErrorMessage(err.Error(), utils.DiskAttachDetach)
-			logger.Error(err, "Attach volume failed", "suggestion", fullErrorMessage)
-			return nil, status.Errorf(codes.Aborted, "attach volume: %v", err)
+		if attached {
+			// Now we have attached the disk, if we fail later, NodeStageVolume is in-progress.
+			// Return Aborted so that the CO will call NodeUnstageVolume later to detach.
+			defaultErrCode = codes.Aborted
 		}
-		// Now we have attached the disk, if we fail later, NodeStageVolume is in-progress.
-		// Return Aborted so that the CO will call NodeUnstageVolume later to detach.
-		defaultErrCode = codes.Aborted
-		device = r.devicePath
+		devices[i] = device
 	}
 
-	if req.VolumeCapability.GetMount() != nil {
+	device := devices[0]
+	if striped {
+		device, err = stripe.Assemble(ctx, stripeMode, stripeName(diskIDs), devices)
+		if err != nil {
+			return nil, status.Errorf(codes.Aborted, "assemble stripe: %v", err)
+		}
+	} else if req.VolumeCapability.GetMount() != nil {
 		device, err = DefaultDeviceManager.adaptDevicePartition(device)
 		if err != nil {
 			return nil, status.Errorf(codes.Aborted, "failed to adapt partition %s: %v", device, err)
@@ -631,10 +711,84 @@ func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVol
 	return &csi.NodeStageVolumeResponse{}, nil
 }
 
+// findOrAttachDevice finds the device of the disk attached by the controller, or attaches it to this node.
+// attached is true if this node attached the disk.
+func (ns *nodeServer) findOrAttachDevice(ctx context.Context, diskID, serial string, errCode codes.Code) (device string, attached bool, err error) {
+	if GlobalConfigVar.ADControllerEnable || serial != "" {
+		if serial == "" {
+			// for capability with old controller
+			serial = strings.TrimPrefix(diskID, "d-")
+		}
+		device, err = ns.ad.repo.findDevice(ctx, diskID, serial, nil)
+		if err == nil {
+			return device, false, nil
+		}
+		if GlobalConfigVar.ADControllerEnable {
+			return "", false, status.Errorf(errCode, "ADController Enabled, but disk can't be found: %v", err)
+		}
+		// This disk should have been attached by controller, but old NodeUnstageVolume detaches it.
+		// So lets try attach it back.
+	}
+	r, err := ns.ad.attachDisk(ctx, diskID, ns.NodeID)
+	if err != nil {
+		fullErrorMessage := utils.FindSuggestionByErrorMessage(err.Error(), utils.DiskAttachDetach)
+		klog.FromContext(ctx).Error(err, "Attach volume failed", "suggestion", fullErrorMessage)
+		return "", false, status.Errorf(codes.Aborted, "attach volume: %v", err)
+	}
+	return r.devicePath, true, nil
+}
+
 type setupRequest interface {
 	GetVolumeId() string
 	GetVolumeContext() map[string]string
 	GetVolumeCapability() *csi.VolumeCapability
+	GetSecrets() map[string]string
+}
+
+// getLuksPassphrase returns the passphrase if LUKS is enabled for the volume, or nil if not.
+func getLuksPassphrase(req setupRequest) ([]byte, error) {
+	value := req.GetVolumeContext()[LuksEncrypted]
+	if value == "" {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", LuksEncrypted, err)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	passphrase := req.GetSecrets()[LuksPassphraseKey]
+	if passphrase == "" {
+		return nil, fmt.Errorf("%s is required in nodeStageSecretRef for LUKS encrypted volume", LuksPassphraseKey)
+	}
+	return []byte(passphrase), nil
+}
+
+// setupLuks opens the LUKS device, formatting it first if the device is blank.
+func setupLuks(ctx context.Context, diskMounter *k8smount.SafeFormatAndMount, device, volumeID string, passphrase []byte) (string, error) {
+	opened, err := luks.IsOpen(volumeID)
+	if err != nil {
+		return "", err
+	}
+	if !opened {
+		format, err := diskMounter.GetDiskFormat(device)
+		if err != nil {
+			return "", fmt.Errorf("failed to get format of %s: %w", device, err)
+		}
+		switch format {
+		case luks.FsType:
+		case "":
+			klog.FromContext(ctx).V(2).Info("formatting device with LUKS", "device", device)
+			if err := luks.Format(ctx, device, passphrase); err != nil {
+				return "", err
+			}
+		default:
+			// never destroy existing data
+			return "", fmt.Errorf("device %s already contains %q, refusing to format it with LUKS", device, format)
+		}
+	}
+	return luks.Open(ctx, device, volumeID, passphrase)
 }
 
 func (ns *nodeServer) setupDisk(ctx context.Context, device, targetPath string, req setupRequest) error {
@@ -645,13 +799,28 @@ func (ns *nodeServer) setupDisk(ctx context.Context, device, targetPath string,
 		omitfsck = true
 	}
 
-	// Setup DataCache
+	diskMounter := &k8smount.SafeFormatAndMount{Interface: ns.k8smounter, Exec: utilexec.New()}
+
+	// Setup LUKS, DataCache is stacked on top of it
 	volumeId := req.GetVolumeId()
+	passphrase, err := getLuksPassphrase(req)
+	if err != nil {
+		return err
+	}
+	if passphrase != nil {
+		device, err = setupLuks(ctx, diskMounter, device, volumeId, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Setup DataCache
 	var d datacache.Opts
 	if err := datacache.GetOpts(volumeContext, &d); err != nil {
 		return err
 	}
-	device, err := datacache.Setup(ctx, ns.dmControl, &d, device, volumeId)
+	d.CheckDevice = ns.checkDataCacheDevice
+	device, err = datacache.Setup(ctx, ns.dmControl, &d, device, volumeId)
 	if err != nil {
 		return err
 	}
@@ -688,7 +857,6 @@ func (ns *nodeServer) setupDisk(ctx context.Context, device, targetPath string,
 	}
 
 	// do format-mount or mount
-	diskMounter := &k8smount.SafeFormatAndMount{Interface: ns.k8smounter, Exec: utilexec.New()}
 	if err := utils.FormatAndMount(diskMounter, device, targetPath, fsType, mkfsOptions, mountOptions, omitfsck); err != nil {
 		return fmt.Errorf("FormatAndMount fail with mkfsOptions %s, %s, %s, %s, %s with error: %w", device, targetPath, fsType, mkfsOptions, mountOptions, err)
 	}
@@ -713,6 +881,27 @@ func ensureUnmounted(mounter k8smount.Interface, target string) error {
 	return nil
 }
 
+// checkDataCacheDevice refuses to create the data cache volume group on a cloud disk attached to this node,
+// which would destroy its data. Cloud disks are recognized by their serial, the disk ID without "d-".
+func (ns *nodeServer) checkDataCacheDevice(ctx context.Context, device string) error {
+	serial, err := ns.ad.repo.dev.GetDeviceSerialByPath(device)
+	if err != nil {
+		return fmt.Errorf("failed to get serial: %w", err)
+	}
+	if serial == "" {
+		return errors.New("unknown serial, cannot tell whether it is a cloud disk")
+	}
+	regionID := metadata.MustGet(ns.metadata.WithSession(ctx), metadata.RegionID)
+	disks, err := GetAttachedCloudDisks(ns.ecsV2, ns.NodeID, regionID)
+	if err != nil {
+		return fmt.Errorf("failed to list cloud disks attached to this node: %w", err)
+	}
+	if diskID := "d-" + serial; slices.Contains(disks, diskID) {
+		return fmt.Errorf("it is cloud disk %s attached to this node", diskID)
+	}
+	return nil
+}
+
 func (ns *nodeServer) unmountTargetPath(logger klog.Logger, targetPath, volumeID string) error {
 	err := ns.unixMounter.Unmount(targetPath)
 	if err != nil {
@@ -753,6 +942,14 @@ func (ns *nodeServer) teardownStageTarget(ctx context.Context, targetPath, volum
 	if cacheErr := datacache.Teardown(ctx, ns.dmControl, volumeID); cacheErr != nil {
 		return fmt.Errorf("teardown DataCache for %s: %v", volumeID, cacheErr)
 	}
+	if err := luks.Close(ctx, volumeID); err != nil {
+		return fmt.Errorf("close LUKS device for %s: %w", volumeID, err)
+	}
+	if mode, diskIDs, ok := parseStripeVolumeID(volumeID); ok {
+		if err := stripe.Stop(ctx, mode, stripeName(diskIDs)); err != nil {
+			return fmt.Errorf("stop stripe of %s: %w", volumeID, err)
+		}
+	}
 	return nil
 }
 
@@ -769,6 +966,10 @@ func (ns *nodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	if _, _, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		// the shared disk stays mounted for the other subpath volumes
+		return &csi.NodeUnstageVolumeResponse{}, nil
+	}
 
 	if IsVFNode() {
 		if err := unbindBdfDisk(req.VolumeId); err != nil {
@@ -788,18 +989,23 @@ func (ns *nodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 	}
 
 	// All device related errors are not fatal, just log it
-	device, err := ns.ad.repo.dev.GetRootBlockBySerial(strings.TrimPrefix(req.VolumeId, "d-"))
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			// devices without serial should already have xattr set on NodeStageVolume
-			logger.V(2).Info("device not found (no serial?)")
-		} else {
-			logger.Error(err, "failed to get device for disk")
-		}
-	} else {
-		err := setDiskXattr(device, req.VolumeId)
+	var detach []string
+	for _, diskID := range volumeDisks(req.VolumeId) {
+		logger := logger.WithValues("diskID", diskID)
+		device, err := ns.ad.repo.dev.GetRootBlockBySerial(strings.TrimPrefix(diskID, "d-"))
 		if err != nil {
-			logger.Error(err, "setDiskXattr failed")
+			if errors.Is(err, os.ErrNotExist) {
+				// devices without serial should already have xattr set on NodeStageVolume
+				logger.V(2).Info("device not found (no serial?)")
+			} else {
+				logger.Error(err, "failed to get device for disk")
+			}
+			detach = append(detach, diskID)
+		} else {
+			err := setDiskXattr(device, diskID)
+			if err != nil {
+				logger.Error(err, "setDiskXattr failed")
+			}
 		}
 	}
 
@@ -811,19 +1017,21 @@ func (ns *nodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstag
 		return &csi.NodeUnstageVolumeResponse{}, nil
 	}
 
-	if device != "" {
+	if len(detach) == 0 {
 		// best effort to avoid OpenAPI call. detachDisk will check again.
 		logger.V(2).Info("locally checked disk has serial number, defer detach to controller")
 		return &csi.NodeUnstageVolumeResponse{}, nil
 	}
 	// Do detach if ADController is disabled and disk has no serial number
 	ecsClient := GlobalConfigVar.EcsClient
-	err = ns.ad.detachDisk(ctx, ecsClient, req.VolumeId, ns.NodeID, true)
-	if err != nil {
-		logger.Error(err, "Detach failed")
-		return nil, err
+	for _, diskID := range detach {
+		err = ns.ad.detachDisk(ctx, ecsClient, diskID, ns.NodeID, true)
+		if err != nil {
+			logger.Error(err, "Detach failed", "diskID", diskID)
+			return nil, err
+		}
+		ns.ad.repo.DeleteAttached(diskID)
 	}
-	ns.ad.repo.DeleteAttached(req.VolumeId)
 
 	return &csi.NodeUnstageVolumeResponse{}, nil
 }
@@ -995,6 +1203,10 @@ func (ns *nodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandV
 	}
 	defer ns.locks.Release(req.VolumeId)
 
+	if sharedDiskID, name, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		return ns.expandSubpathVolume(ctx, req, sharedDiskID, name)
+	}
+
 	// Block volumes have no filesystem to grow, but localExpandVolume still
 	// resizes any dm-cache target. Rund transfers the fs resize into the guest.
 	if req.GetVolumeCapability().GetBlock() == nil {
@@ -1018,7 +1230,55 @@ func (ns *nodeServer) localExpandVolume(ctx context.Context, req *csi.NodeExpand
 	logger := klog.FromContext(ctx)
 
 	var devicePath string
-	cached, err := datacache.Resize(logger, ns.dmControl, diskID, uint64((requestBytes-1)/512+1))
+	cacheSectors := uint64((requestBytes-1)/512 + 1)
+	var rawCapacity int64 // only for LUKS and striped volumes
+	if mode, diskIDs, ok := parseStripeVolumeID(diskID); ok {
+		// Grow the stripe after all the members, then compare the members with the request below.
+		devices := make([]string, len(diskIDs))
+		for i, id := range diskIDs {
+			device, err := ns.ad.repo.GetVolumeDeviceName(logger, id)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "get device name of %s: %v", id, err)
+			}
+			devices[i] = device
+			capacity, err := utilsio.GetBlockDeviceCapacity(devices[i])
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "get device capacity: %v", err)
+			}
+			rawCapacity += capacity
+		}
+		if err := stripe.Grow(ctx, mode, stripeName(diskIDs), devices); err != nil {
+			return nil, status.Errorf(codes.Internal, "grow stripe: %v", err)
+		}
+		devicePath = stripe.DevicePath(mode, stripeName(diskIDs))
+	}
+	encrypted, err := luks.IsOpen(diskID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "check LUKS device: %v", err)
+	}
+	if encrypted {
+		// Read the disk size before resizing the crypt mapping, so that we never report a size it has not grown to.
+		rawDevice, err := ns.ad.repo.GetVolumeDeviceName(logger, diskID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "get device name: %v", err)
+		}
+		rawCapacity, err = utilsio.GetBlockDeviceCapacity(rawDevice)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "get device capacity: %v", err)
+		}
+		// The crypt mapping is below the dm-cache, resize it first.
+		if _, err := luks.Resize(ctx, diskID); err != nil {
+			return nil, status.Errorf(codes.Internal, "resize LUKS device error: %v", err)
+		}
+		devicePath = luks.DevicePath(diskID)
+		// LUKS header takes some space, the dm-cache can only be as large as the crypt device
+		cryptCapacity, err := utilsio.GetBlockDeviceCapacity(devicePath)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "get LUKS device capacity: %v", err)
+		}
+		cacheSectors = uint64(cryptCapacity / 512)
+	}
+	cached, err := datacache.Resize(ctx, ns.dmControl, diskID, cacheSectors)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "resize dm-cache error: %v", err)
 	}
@@ -1027,7 +1287,7 @@ func (ns *nodeServer) localExpandVolume(ctx context.Context, req *csi.NodeExpand
 	}
 
 	// Block volumes have no filesystem or partition to grow; resizing the
-	// dm-cache target above (if any) is the only node-side work needed.
+	// LUKS and dm-cache targets above (if any) is the only node-side work needed.
 	if req.GetVolumeCapability().GetBlock() != nil {
 		logger.V(2).Info("skipping fs expand for block volume")
 		return &csi.NodeExpandVolumeResponse{}, nil
@@ -1074,6 +1334,10 @@ func (ns *nodeServer) localExpandVolume(ctx context.Context, req *csi.NodeExpand
 		return nil, status.Errorf(codes.Internal, "resize %s returned false", volumePath)
 	}
 
+	if rawCapacity > 0 {
+		// the LUKS header or LVM metadata takes some space, check the disks themselves
+		deviceCapacity = rawCapacity
+	}
 	if requestBytes > 0 && deviceCapacity < requestBytes {
 		// After calling OpenAPI to expand cloud disk, the size of the underlying block device may not change immediately.
 		// return error and CO will retry later.
@@ -340,7 +340,7 @@ func TestNodeGetCapabilities(t *testing.T) {
 		{
 			name:          "metrics enabled",
 			metricEnable:  true,
-			expectedCount: 3, // STAGE_UNSTAGE_VOLUME, EXPAND_VOLUME, and GET_VOLUME_STATS
+			expectedCount: 4, // STAGE_UNSTAGE_VOLUME, EXPAND_VOLUME, GET_VOLUME_STATS and VOLUME_CONDITION
 		},
 	}
 
@@ -364,6 +364,7 @@ func TestNodeGetCapabilities(t *testing.T) {
 			hasStageUnstage := false
 			hasExpand := false
 			hasGetStats := false
+			hasCondition := false
 			for _, cap := range resp.Capabilities {
 				if cap.GetRpc().GetType() == csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME {
 					hasStageUnstage = true
@@ -374,10 +375,14 @@ func TestNodeGetCapabilities(t *testing.T) {
 				if cap.GetRpc().GetType() == csi.NodeServiceCapability_RPC_GET_VOLUME_STATS {
 					hasGetStats = true
 				}
+				if cap.GetRpc().GetType() == csi.NodeServiceCapability_RPC_VOLUME_CONDITION {
+					hasCondition = true
+				}
 			}
 			assert.True(t, hasStageUnstage, "STAGE_UNSTAGE_VOLUME should always be present")
 			assert.True(t, hasExpand, "EXPAND_VOLUME should always be present")
 			assert.Equal(t, tt.metricEnable, hasGetStats, "GET_VOLUME_STATS should match metricEnable")
+			assert.Equal(t, tt.metricEnable, hasCondition, "VOLUME_CONDITION should match metricEnable")
 		})
 	}
 }
@@ -403,3 +408,49 @@ func TestNodeExpandVolumeBlockNoCache(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, &csi.NodeExpandVolumeResponse{}, resp)
 }
+
+func TestGetLuksPassphrase(t *testing.T) {
+	tests := []struct {
+		name       string
+		context    map[string]string
+		secrets    map[string]string
+		passphrase []byte
+		err        bool
+	}{
+		{
+			name: "not encrypted",
+		},
+		{
+			name:    "disabled",
+			context: map[string]string{LuksEncrypted: "false"},
+			secrets: map[string]string{LuksPassphraseKey: "secret"},
+		},
+		{
+			name:       "enabled",
+			context:    map[string]string{LuksEncrypted: "true"},
+			secrets:    map[string]string{LuksPassphraseKey: "secret"},
+			passphrase: []byte("secret"),
+		},
+		{
+			name:    "missing secret",
+			context: map[string]string{LuksEncrypted: "true"},
+			err:     true,
+		},
+		{
+			name:    "invalid",
+			context: map[string]string{LuksEncrypted: "yes"},
+			err:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passphrase, err := getLuksPassphrase(&csi.NodeStageVolumeRequest{VolumeContext: tt.context, Secrets: tt.secrets})
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.passphrase, passphrase)
+		})
+	}
+}
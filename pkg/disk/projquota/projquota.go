@@ -0,0 +1,79 @@
+// Package projquota limits the space used under a directory with the project quota of XFS or ext4.
+//
+// The commands run on the host with xfs_quota, which also manages ext4 in its foreign filesystem mode.
+// The filesystem must be mounted with MountOptions, and ext4 formatted with FormatOptions.
+package projquota
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	utilsos "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/os"
+	"k8s.io/klog/v2"
+)
+
+// run runs a command on the node, returning its stdout.
+// A variable so the tests can fake it.
+var run = func(ctx context.Context, args ...string) (string, error) {
+	out, err := utils.CommandOnNode(args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", args[0], utilsos.ErrWithStderr(err))
+	}
+	klog.FromContext(ctx).V(4).Info("command success", "args", args, "output", string(out))
+	return string(out), nil
+}
+
+// Supported reports whether project quotas can be used on fsType.
+func Supported(fsType string) bool {
+	return fsType == "xfs" || fsType == "ext4"
+}
+
+// FormatOptions are the extra mkfs options for fsType to support project quotas.
+func FormatOptions(fsType string) []string {
+	if fsType == "ext4" {
+		return []string{"-O", "quota,project"}
+	}
+	return nil
+}
+
+// MountOptions enforce the project quotas. XFS can not enable them by remount.
+func MountOptions() []string {
+	return []string{"prjquota"}
+}
+
+func xfsQuota(ctx context.Context, fsType, mountPoint, command string) error {
+	args := []string{"xfs_quota", "-x"}
+	if fsType != "xfs" {
+		args = append(args, "-f")
+	}
+	_, err := run(ctx, append(args, "-c", command, mountPoint)...)
+	return err
+}
+
+// Assign marks dir and everything below it as project id. New files under dir inherit the project.
+// It walks the whole tree, so it is only needed once for a new directory.
+func Assign(ctx context.Context, fsType, mountPoint, dir string, id uint32) error {
+	return xfsQuota(ctx, fsType, mountPoint, fmt.Sprintf("project -s -p %s %d", dir, id))
+}
+
+// SetLimit sets the hard limit of the space used by project id.
+// statfs on a directory of the project then reports the limit as the capacity.
+func SetLimit(ctx context.Context, fsType, mountPoint string, id uint32, bytes int64) error {
+	return xfsQuota(ctx, fsType, mountPoint, "limit -p bhard="+strconv.FormatInt(bytes, 10)+" "+strconv.FormatUint(uint64(id), 10))
+}
+
+// Remove deletes dir with all its contents, then drops the limit of project id.
+// A missing dir is not an error.
+func Remove(ctx context.Context, fsType, mountPoint, dir string, id uint32) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := SetLimit(ctx, fsType, mountPoint, id, 0); err != nil {
+		return err
+	}
+	klog.FromContext(ctx).V(2).Info("removed project directory", "dir", dir, "projectID", id)
+	return nil
+}
@@ -0,0 +1,60 @@
+package projquota
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func fake(t *testing.T) *[]string {
+	var calls []string
+	orig := run
+	t.Cleanup(func() { run = orig })
+	run = func(ctx context.Context, args ...string) (string, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return "", nil
+	}
+	return &calls
+}
+
+func TestAssignAndLimit(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	calls := fake(t)
+
+	require.NoError(t, Assign(ctx, "xfs", "/mnt", "/mnt/pvc-1", 3))
+	require.NoError(t, SetLimit(ctx, "xfs", "/mnt", 3, 1<<30))
+	require.NoError(t, SetLimit(ctx, "ext4", "/mnt", 3, 1<<30))
+	assert.Equal(t, []string{
+		"xfs_quota -x -c project -s -p /mnt/pvc-1 3 /mnt",
+		"xfs_quota -x -c limit -p bhard=1073741824 3 /mnt",
+		"xfs_quota -x -f -c limit -p bhard=1073741824 3 /mnt",
+	}, *calls)
+}
+
+func TestRemove(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	calls := fake(t)
+
+	mnt := t.TempDir()
+	dir := filepath.Join(mnt, "pvc-1")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "data"), 0o755))
+	require.NoError(t, Remove(ctx, "ext4", mnt, dir, 3))
+	assert.NoDirExists(t, dir)
+	assert.Equal(t, []string{"xfs_quota -x -f -c limit -p bhard=0 3 " + mnt}, *calls)
+
+	// already removed
+	require.NoError(t, Remove(ctx, "ext4", mnt, dir, 3))
+}
+
+func TestFormatOptions(t *testing.T) {
+	assert.Equal(t, []string{"-O", "quota,project"}, FormatOptions("ext4"))
+	assert.Empty(t, FormatOptions("xfs"))
+	assert.True(t, Supported("xfs"))
+	assert.False(t, Supported("ext3"))
+}
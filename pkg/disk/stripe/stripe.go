@@ -0,0 +1,265 @@
+// Package stripe assembles the member disks of a striped volume into one block device,
+// either a striped LVM logical volume or an md RAID0 array.
+//
+// The commands run on the host, which owns the LVM metadata, md arrays and udev rules.
+package stripe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	utilsos "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/os"
+	"k8s.io/klog/v2"
+)
+
+type Mode string
+
+const (
+	LVM Mode = "lvm"
+	MD  Mode = "md"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case LVM, MD:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unrecognized stripe mode %q, must be %s or %s", s, LVM, MD)
+	}
+}
+
+const (
+	namePrefix = "csi-stripe-"
+	lvName     = "stripe"
+	// chunkKiB is the stripe size. Large enough that a sequential IO is split into
+	// full-size requests on each disk, small enough to spread random IO.
+	chunkKiB = 256
+
+	lvmMemberType = "LVM2_member"
+	mdMemberType  = "linux_raid_member"
+)
+
+// errNotFound is returned by run if the command is not installed on the node.
+var errNotFound = errors.New("command not found on node")
+
+// run runs a command on the node, returning its stdout.
+// A variable so the tests can fake it.
+var run = func(ctx context.Context, args ...string) (string, error) {
+	cmd := utils.CommandOnNode(args...)
+	cmd.Env = append(os.Environ(), "LVM_SUPPRESS_FD_WARNINGS=1")
+	out, err := cmd.Output()
+	if err != nil {
+		if exitCode(err) == 127 { // nsenter: command not found
+			return "", fmt.Errorf("%s: %w", args[0], errNotFound)
+		}
+		return "", fmt.Errorf("%s failed: %w", strings.Join(args[:min(2, len(args))], " "), utilsos.ErrWithStderr(err))
+	}
+	klog.FromContext(ctx).V(4).Info("command success", "args", args, "output", string(out))
+	return string(out), nil
+}
+
+// exists is a variable so the tests can fake the device nodes.
+var exists = func(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+func vgName(name string) string {
+	return namePrefix + name
+}
+
+// DevicePath is the assembled device of the striped volume name.
+func DevicePath(mode Mode, name string) string {
+	if mode == MD {
+		return "/dev/md/" + namePrefix + name
+	}
+	return "/dev/" + vgName(name) + "/" + lvName
+}
+
+func memberType(mode Mode) string {
+	if mode == MD {
+		return mdMemberType
+	}
+	return lvmMemberType
+}
+
+// probe returns the signature type on device, or "" if it is blank.
+func probe(ctx context.Context, device string) (string, error) {
+	out, err := run(ctx, "blkid", "-p", "-s", "TYPE", "-o", "value", device)
+	if err != nil {
+		if exitCode(err) == 2 { // nothing found
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// exitCode returns the exit code of the failed command, or -1 if it did not run.
+func exitCode(err error) int {
+	if e, ok := errors.AsType[utilsos.ExitErrorWithStderr](err); ok {
+		return e.ExitCode()
+	}
+	if e, ok := errors.AsType[*exec.ExitError](err); ok {
+		return e.ExitCode()
+	}
+	return -1
+}
+
+// Assemble creates the striped device from devices, in order, if they are all blank,
+// or activates it if they are already its members. Devices with any other signature are never touched.
+// It returns the path of the striped device.
+func Assemble(ctx context.Context, mode Mode, name string, devices []string) (string, error) {
+	fresh := true
+	for _, dev := range devices {
+		t, err := probe(ctx, dev)
+		if err != nil {
+			return "", fmt.Errorf("probe %s: %w", dev, err)
+		}
+		switch t {
+		case "":
+		case memberType(mode):
+			fresh = false
+		default:
+			return "", fmt.Errorf("device %s already contains %q, refusing to use it for stripe %s", dev, t, name)
+		}
+	}
+	if mode == MD {
+		return assembleMD(ctx, name, devices, fresh)
+	}
+	return assembleLVM(ctx, name, devices, fresh)
+}
+
+func assembleLVM(ctx context.Context, name string, devices []string, fresh bool) (string, error) {
+	logger := klog.FromContext(ctx)
+	vg := vgName(name)
+	if fresh {
+		// Not forced: vgcreate refuses devices with an existing signature.
+		if _, err := run(ctx, append([]string{"lvm", "vgcreate", "--yes", vg}, devices...)...); err != nil {
+			return "", err
+		}
+		logger.Info("created volume group for stripe", "vg", vg, "devices", devices)
+	} else if _, err := run(ctx, "lvm", "vgchange", "--activate", "y", vg); err != nil {
+		return "", err
+	}
+
+	out, err := run(ctx, "lvm", "lvs", "--noheadings", "-o", "lv_name", vg)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(strings.Fields(out), lvName) {
+		_, err := run(ctx, "lvm", "lvcreate", "--yes", "--activate", "y", "--wipesignatures", "y",
+			"--stripes", strconv.Itoa(len(devices)), "--stripesize", strconv.Itoa(chunkKiB)+"k",
+			"--extents", "100%FREE", "--name", lvName, vg)
+		if err != nil {
+			return "", err
+		}
+		logger.V(2).Info("created striped LV", "vg", vg, "stripes", len(devices))
+	}
+	return DevicePath(LVM, name), nil
+}
+
+func assembleMD(ctx context.Context, name string, devices []string, fresh bool) (string, error) {
+	path := DevicePath(MD, name)
+	ok, err := exists(path)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return path, nil
+	}
+	if fresh {
+		// --run skips the confirmation prompt, we have checked the devices are blank.
+		_, err = run(ctx, append([]string{"mdadm", "--create", path, "--run", "--metadata=1.2", "--level=0",
+			"--chunk=" + strconv.Itoa(chunkKiB), "--raid-devices=" + strconv.Itoa(len(devices))}, devices...)...)
+		if err != nil {
+			return "", err
+		}
+		klog.FromContext(ctx).Info("created md RAID0 for stripe", "device", path, "devices", devices)
+		return path, nil
+	}
+	if _, err := run(ctx, append([]string{"mdadm", "--assemble", path}, devices...)...); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Stop deactivates the striped device, leaving the data on its members.
+// An absent device is not an error.
+func Stop(ctx context.Context, mode Mode, name string) error {
+	if mode == MD {
+		path := DevicePath(MD, name)
+		ok, err := exists(path)
+		if err != nil || !ok {
+			return err
+		}
+		if _, err := run(ctx, "mdadm", "--stop", path); err != nil {
+			return err
+		}
+		klog.FromContext(ctx).V(2).Info("stopped md RAID0", "device", path)
+		return nil
+	}
+
+	vg := vgName(name)
+	out, err := run(ctx, "lvm", "vgs", "--noheadings", "-o", "vg_name")
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil // no lvm, no VG
+		}
+		return err
+	}
+	if !slices.Contains(strings.Fields(out), vg) {
+		return nil
+	}
+	if _, err := run(ctx, "lvm", "vgchange", "--activate", "n", vg); err != nil {
+		return err
+	}
+	klog.FromContext(ctx).V(2).Info("deactivated volume group", "vg", vg)
+	return nil
+}
+
+// Grow extends the striped device to fill its members, after the member disks are expanded.
+// An md RAID0 array can not grow its members, only LVM is supported.
+func Grow(ctx context.Context, mode Mode, name string, devices []string) error {
+	if mode != LVM {
+		return fmt.Errorf("growing stripe %s in %s mode is not supported", name, mode)
+	}
+	for _, dev := range devices {
+		if _, err := run(ctx, "lvm", "pvresize", dev); err != nil {
+			return err
+		}
+	}
+	vg := vgName(name)
+	out, err := run(ctx, "lvm", "vgs", "--noheadings", "--units", "b", "--nosuffix", "-o", "vg_free", vg)
+	if err != nil {
+		return err
+	}
+	free, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return fmt.Errorf("unexpected vgs output %q: %w", out, err)
+	}
+	if free == 0 {
+		return nil // already grown
+	}
+	// Keep the stripe count, the free extents are spread evenly over the members.
+	_, err = run(ctx, "lvm", "lvextend", "--yes", "--stripes", strconv.Itoa(len(devices)),
+		"--extents", "+100%FREE", vg+"/"+lvName)
+	if err != nil {
+		return err
+	}
+	klog.FromContext(ctx).V(2).Info("extended striped LV", "vg", vg, "by", free)
+	return nil
+}
@@ -0,0 +1,213 @@
+//go:build !windows
+
+package stripe
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/klog/v2/ktesting"
+)
+
+// fakeNode records the commands run on the node, and answers them from its state.
+type fakeNode struct {
+	signatures map[string]string // device -> blkid TYPE
+	vgs        map[string]bool
+	lvs        map[string]bool // vg -> has the stripe LV
+	vgFree     string
+	devices    map[string]bool
+	calls      []string
+}
+
+// exitErr returns a real *exec.ExitError with the code.
+func exitErr(t *testing.T, code int) error {
+	err := exec.Command("sh", "-c", "exit "+strconv.Itoa(code)).Run()
+	require.Error(t, err)
+	return err
+}
+
+func fake(t *testing.T, n *fakeNode) {
+	origRun, origExists := run, exists
+	t.Cleanup(func() { run, exists = origRun, origExists })
+	exists = func(path string) (bool, error) {
+		return n.devices[path], nil
+	}
+	run = func(ctx context.Context, args ...string) (string, error) {
+		n.calls = append(n.calls, strings.Join(args, " "))
+		last := args[len(args)-1]
+		switch args[0] {
+		case "blkid":
+			if sig := n.signatures[last]; sig != "" {
+				return sig + "\n", nil
+			}
+			return "", exitErr(t, 2)
+		case "mdadm":
+			if args[1] == "--create" || args[1] == "--assemble" {
+				n.devices[args[2]] = true
+			}
+			return "", nil
+		}
+		switch args[1] {
+		case "vgs":
+			if args[len(args)-2] == "vg_free" {
+				return "  " + n.vgFree + "\n", nil
+			}
+			var out strings.Builder
+			for vg := range n.vgs {
+				out.WriteString("  " + vg + "\n")
+			}
+			return out.String(), nil
+		case "vgcreate":
+			n.vgs[args[3]] = true
+		case "lvs":
+			if n.lvs[last] {
+				return "  stripe\n", nil
+			}
+		case "lvcreate":
+			n.lvs[last] = true
+		}
+		return "", nil
+	}
+}
+
+func newFakeNode() *fakeNode {
+	return &fakeNode{
+		signatures: map[string]string{},
+		vgs:        map[string]bool{},
+		lvs:        map[string]bool{},
+		devices:    map[string]bool{},
+	}
+}
+
+func TestAssembleLVM(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	n := newFakeNode()
+	fake(t, n)
+
+	path, err := Assemble(ctx, LVM, "d-1", []string{"/dev/vdb", "/dev/vdc"})
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/csi-stripe-d-1/stripe", path)
+	assert.Equal(t, []string{
+		"blkid -p -s TYPE -o value /dev/vdb",
+		"blkid -p -s TYPE -o value /dev/vdc",
+		"lvm vgcreate --yes csi-stripe-d-1 /dev/vdb /dev/vdc",
+		"lvm lvs --noheadings -o lv_name csi-stripe-d-1",
+		"lvm lvcreate --yes --activate y --wipesignatures y --stripes 2 --stripesize 256k --extents 100%FREE --name stripe csi-stripe-d-1",
+	}, n.calls)
+
+	// staged again after reboot
+	n.calls = nil
+	n.signatures = map[string]string{"/dev/vdb": lvmMemberType, "/dev/vdc": lvmMemberType}
+	path, err = Assemble(ctx, LVM, "d-1", []string{"/dev/vdb", "/dev/vdc"})
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/csi-stripe-d-1/stripe", path)
+	assert.Contains(t, n.calls, "lvm vgchange --activate y csi-stripe-d-1")
+	assert.NotContains(t, strings.Join(n.calls, "\n"), "create")
+}
+
+func TestAssembleMD(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	n := newFakeNode()
+	fake(t, n)
+
+	path, err := Assemble(ctx, MD, "d-1", []string{"/dev/vdb", "/dev/vdc", "/dev/vdd"})
+	require.NoError(t, err)
+	assert.Equal(t, "/dev/md/csi-stripe-d-1", path)
+	assert.Equal(t, "mdadm --create /dev/md/csi-stripe-d-1 --run --metadata=1.2 --level=0 --chunk=256 --raid-devices=3 /dev/vdb /dev/vdc /dev/vdd",
+		n.calls[len(n.calls)-1])
+
+	// stopped, then staged again
+	n.calls = nil
+	n.devices = map[string]bool{}
+	n.signatures = map[string]string{"/dev/vdb": mdMemberType, "/dev/vdc": mdMemberType, "/dev/vdd": mdMemberType}
+	_, err = Assemble(ctx, MD, "d-1", []string{"/dev/vdb", "/dev/vdc", "/dev/vdd"})
+	require.NoError(t, err)
+	assert.Equal(t, "mdadm --assemble /dev/md/csi-stripe-d-1 /dev/vdb /dev/vdc /dev/vdd", n.calls[len(n.calls)-1])
+}
+
+func TestAssembleRefuseForeignData(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	n := newFakeNode()
+	n.signatures["/dev/vdc"] = "ext4"
+	fake(t, n)
+
+	_, err := Assemble(ctx, LVM, "d-1", []string{"/dev/vdb", "/dev/vdc"})
+	assert.ErrorContains(t, err, `device /dev/vdc already contains "ext4"`)
+	assert.Empty(t, n.vgs)
+}
+
+func TestStop(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	n := newFakeNode()
+	fake(t, n)
+
+	// nothing to stop
+	require.NoError(t, Stop(ctx, LVM, "d-1"))
+	require.NoError(t, Stop(ctx, MD, "d-1"))
+	assert.Equal(t, []string{"lvm vgs --noheadings -o vg_name"}, n.calls)
+
+	n.calls = nil
+	n.vgs["csi-stripe-d-1"] = true
+	n.devices["/dev/md/csi-stripe-d-2"] = true
+	require.NoError(t, Stop(ctx, LVM, "d-1"))
+	require.NoError(t, Stop(ctx, MD, "d-2"))
+	assert.Equal(t, []string{
+		"lvm vgs --noheadings -o vg_name",
+		"lvm vgchange --activate n csi-stripe-d-1",
+		"mdadm --stop /dev/md/csi-stripe-d-2",
+	}, n.calls)
+}
+
+func TestStopNoLVM(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	orig := run
+	t.Cleanup(func() { run = orig })
+	run = func(ctx context.Context, args ...string) (string, error) {
+		return "", errNotFound
+	}
+	assert.NoError(t, Stop(ctx, LVM, "d-1"))
+}
+
+func TestGrow(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	n := newFakeNode()
+	n.vgFree = "21474836480"
+	fake(t, n)
+
+	require.NoError(t, Grow(ctx, LVM, "d-1", []string{"/dev/vdb", "/dev/vdc"}))
+	assert.Equal(t, []string{
+		"lvm pvresize /dev/vdb",
+		"lvm pvresize /dev/vdc",
+		"lvm vgs --noheadings --units b --nosuffix -o vg_free csi-stripe-d-1",
+		"lvm lvextend --yes --stripes 2 --extents +100%FREE csi-stripe-d-1/stripe",
+	}, n.calls)
+
+	// already grown
+	n.calls = nil
+	n.vgFree = "0"
+	require.NoError(t, Grow(ctx, LVM, "d-1", []string{"/dev/vdb", "/dev/vdc"}))
+	assert.NotContains(t, strings.Join(n.calls, "\n"), "lvextend")
+
+	err := Grow(ctx, MD, "d-1", []string{"/dev/vdb", "/dev/vdc"})
+	assert.Error(t, err)
+}
+
+func TestParseMode(t *testing.T) {
+	m, err := ParseMode("md")
+	require.NoError(t, err)
+	assert.Equal(t, MD, m)
+
+	_, err = ParseMode("raid5")
+	assert.Error(t, err)
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 2, exitCode(exitErr(t, 2)))
+	assert.Equal(t, -1, exitCode(errors.New("not run")))
+}
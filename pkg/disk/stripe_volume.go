@@ -0,0 +1,546 @@
+package disk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	alicloudErr "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/stripe"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/waitstatus"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// A striped volume is backed by several identical disks, which are assembled into
+// one striped device on the node. Its ID lists the stripe mode and the member disks
+// in stripe order: stripe-<mode>:<diskID>,<diskID>,...
+const stripeVolumeIDPrefix = "stripe-"
+
+const maxStripeCount = 16
+
+type stripeParams struct {
+	Count int
+	Mode  stripe.Mode
+}
+
+func parseStripeParams(opts map[string]string) (stripeParams, error) {
+	var p stripeParams
+	value := opts[StripeCountKey]
+	if value == "" {
+		if opts[StripeModeKey] != "" {
+			return p, fmt.Errorf("%s requires %s", StripeModeKey, StripeCountKey)
+		}
+		return p, nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		return p, fmt.Errorf("invalid %s: %w", StripeCountKey, err)
+	}
+	if count == 1 {
+		return p, nil // a single disk needs no striping
+	}
+	if count < 1 || count > maxStripeCount {
+		return p, fmt.Errorf("%s must be between 1 and %d, got %d", StripeCountKey, maxStripeCount, count)
+	}
+	p.Count = count
+	p.Mode = stripe.LVM
+	if m := opts[StripeModeKey]; m != "" {
+		p.Mode, err = stripe.ParseMode(m)
+		if err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+func stripeVolumeID(mode stripe.Mode, diskIDs []string) string {
+	return stripeVolumeIDPrefix + string(mode) + ":" + strings.Join(diskIDs, ",")
+}
+
+// parseStripeVolumeID returns the mode and member disks of a striped volume.
+// ok is false if volumeID is a plain disk.
+func parseStripeVolumeID(volumeID string) (mode stripe.Mode, diskIDs []string, ok bool) {
+	rest, ok := strings.CutPrefix(volumeID, stripeVolumeIDPrefix)
+	if !ok {
+		return "", nil, false
+	}
+	m, disks, ok := strings.Cut(rest, ":")
+	if !ok || disks == "" {
+		return "", nil, false
+	}
+	return stripe.Mode(m), strings.Split(disks, ","), true
+}
+
+// volumeDisks returns the disks backing volumeID.
+func volumeDisks(volumeID string) []string {
+	if _, diskIDs, ok := parseStripeVolumeID(volumeID); ok {
+		return diskIDs
+	}
+	return []string{volumeID}
+}
+
+// stripeName names the striped device on the node after its first member.
+func stripeName(diskIDs []string) string {
+	return diskIDs[0]
+}
+
+// stripeDevicePath returns the assembled device on the node if volumeID is a striped volume.
+func stripeDevicePath(volumeID string) (string, bool) {
+	mode, diskIDs, ok := parseStripeVolumeID(volumeID)
+	if !ok {
+		return "", false
+	}
+	return stripe.DevicePath(mode, stripeName(diskIDs)), true
+}
+
+func stripeMemberName(volumeName string, i int) string {
+	return fmt.Sprintf("%s-%d", volumeName, i)
+}
+
+func stripeMemberTag(mode stripe.Mode, i, n int) string {
+	return fmt.Sprintf("%s:%d/%d", mode, i, n)
+}
+
+func parseStripeMemberTag(value string) (mode stripe.Mode, i, n int, err error) {
+	m, rest, _ := strings.Cut(value, ":")
+	if _, err := fmt.Sscanf(rest, "%d/%d", &i, &n); err != nil || i < 0 || i >= n {
+		return "", 0, 0, fmt.Errorf("invalid %s %q", StripeMemberTag, value)
+	}
+	return stripe.Mode(m), i, n, nil
+}
+
+// stripedVolumeOf returns the striped volume that disk is the first member of, and its capacity.
+// The volume ID is empty for the other members, or if some members are missing,
+// e.g. while the volume is being created or deleted.
+func (cs *controllerServer) stripedVolumeOf(ctx context.Context, disk *ecs.Disk) (string, int64, error) {
+	mode, i, n, err := parseStripeMemberTag(diskTag(disk, StripeMemberTag))
+	if err != nil || i != 0 {
+		return "", 0, err
+	}
+	req := ecs.CreateDescribeDisksRequest()
+	req.RegionId = GlobalConfigVar.Region
+	req.Tag = &[]ecs.DescribeDisksTag{{Key: StripeVolumeTag, Value: diskTag(disk, StripeVolumeTag)}}
+	req.MaxResults = requests.NewInteger(maxStripeCount)
+	resp, err := cs.ecs.DescribeDisks(req)
+	if err != nil {
+		return "", 0, err
+	}
+	diskIDs := make([]string, n)
+	var capacity int64
+	for _, member := range resp.Disks.Disk {
+		m, j, count, err := parseStripeMemberTag(diskTag(&member, StripeMemberTag))
+		if err != nil || m != mode || count != n || diskIDs[j] != "" {
+			continue // a member of another volume with the same name
+		}
+		diskIDs[j] = member.DiskId
+		capacity += utils.Gi2Bytes(int64(member.Size))
+	}
+	if diskIDs[0] != disk.DiskId || slices.Contains(diskIDs, "") {
+		klog.FromContext(ctx).V(2).Info("striped volume is incomplete, not listed", "volumeName", diskTag(disk, StripeVolumeTag), "members", diskIDs)
+		return "", 0, nil
+	}
+	return stripeVolumeID(mode, diskIDs), capacity, nil
+}
+
+// isSnapshotGroupID reports whether snapshotID refers to the snapshot group of a striped volume.
+func isSnapshotGroupID(snapshotID string) bool {
+	return strings.HasPrefix(snapshotID, "ssg-")
+}
+
+// The snapshot group of a striped volume records the stripe in its description, so the volume can be restored:
+// stripe-<mode>:<rank>,<rank>,... lists the members in stripe order, by the rank of their disk ID among the sorted
+// source disks of the group. The disk IDs themselves would not fit.
+func stripeSnapshotLayout(mode stripe.Mode, diskIDs []string) string {
+	sorted := slices.Sorted(slices.Values(diskIDs))
+	ranks := make([]string, len(diskIDs))
+	for i, diskID := range diskIDs {
+		ranks[i] = strconv.Itoa(slices.Index(sorted, diskID))
+	}
+	return stripeVolumeIDPrefix + string(mode) + ":" + strings.Join(ranks, ",")
+}
+
+// parseStripeSnapshotLayout returns the stripe mode recorded in group, and its snapshots in stripe order.
+func parseStripeSnapshotLayout(group *ecs.SnapshotGroup) (stripe.Mode, []string, error) {
+	rest, ok := strings.CutPrefix(group.Description, stripeVolumeIDPrefix)
+	if !ok {
+		return "", nil, fmt.Errorf("snapshot group %s is not a snapshot of a striped volume", group.SnapshotGroupId)
+	}
+	m, list, _ := strings.Cut(rest, ":")
+	mode, err := stripe.ParseMode(m)
+	if err != nil {
+		return "", nil, fmt.Errorf("snapshot group %s: %w", group.SnapshotGroupId, err)
+	}
+	snapshots := slices.SortedFunc(slices.Values(group.Snapshots.Snapshot), func(a, b ecs.Snapshot) int {
+		return strings.Compare(a.SourceDiskId, b.SourceDiskId)
+	})
+	ranks := strings.Split(list, ",")
+	if len(ranks) != len(snapshots) {
+		return "", nil, fmt.Errorf("snapshot group %s has %d snapshots, but %d members are recorded", group.SnapshotGroupId, len(snapshots), len(ranks))
+	}
+	snapshotIDs := make([]string, len(ranks))
+	seen := sets.New[int]()
+	for i, r := range ranks {
+		rank, err := strconv.Atoi(r)
+		if err != nil || rank < 0 || rank >= len(snapshots) || seen.Has(rank) {
+			return "", nil, fmt.Errorf("snapshot group %s has an invalid stripe layout %q", group.SnapshotGroupId, group.Description)
+		}
+		seen.Insert(rank)
+		snapshotIDs[i] = snapshots[rank].SnapshotId
+	}
+	return mode, snapshotIDs, nil
+}
+
+// stripedSnapshotMembers returns the snapshots to restore the members of a striped volume from, in stripe order.
+// The requested stripe must match the snapshotted one, which also ensures the StorageClass was validated for striping.
+func (cs *controllerServer) stripedSnapshotMembers(groupID string, requested stripeParams) ([]string, error) {
+	group, err := describeSnapshotGroup(cs.ecs, "", groupID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "describe snapshot group %s failed: %v", groupID, err)
+	}
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "snapshot %s not found", groupID)
+	}
+	if group.Status != SnapshotStatusAccomplished {
+		return nil, status.Errorf(codes.Unavailable, "snapshot group %s is %s", groupID, group.Status)
+	}
+	mode, snapshotIDs, err := parseStripeSnapshotLayout(group)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if requested.Count != len(snapshotIDs) || requested.Mode != mode {
+		return nil, status.Errorf(codes.InvalidArgument, "snapshot %s is of a volume striped over %d disks in %s mode, set %s=%d and %s=%s to restore it",
+			groupID, len(snapshotIDs), mode, StripeCountKey, len(snapshotIDs), StripeModeKey, mode)
+	}
+	return snapshotIDs, nil
+}
+
+// createStripedDisks creates the member disks of a striped volume, each sized to hold its share of the volume,
+// and restored from the snapshots in memberSnapshots, if any, in stripe order.
+// It returns the ID of the striped volume and its capacity.
+func (cs *controllerServer) createStripedDisks(ctx context.Context, volumeName string, diskVol *diskVolumeArgs, mutable ModifyParameters,
+	supportedTypes sets.Set[Category], selectedInstance string, isVirtualNode bool, memberSnapshots []string,
+) (string, int64, createAttempt, error) {
+	n := int64(diskVol.Stripe.Count)
+	member := *diskVol
+	member.RequestGB = (diskVol.RequestGB + n - 1) / n
+
+	var attempt createAttempt
+	diskIDs := make([]string, n)
+	for i := range diskIDs {
+		member.DiskTags = maps.Clone(diskVol.DiskTags)
+		member.DiskTags[StripeVolumeTag] = volumeName
+		member.DiskTags[StripeMemberTag] = stripeMemberTag(diskVol.Stripe.Mode, i, len(diskIDs))
+		var snapshotID string
+		if memberSnapshots != nil {
+			snapshotID = memberSnapshots[i]
+		}
+		diskID, a, err := cs.cd.createDisk(ctx, stripeMemberName(volumeName, i), snapshotID, &member, supportedTypes, selectedInstance, isVirtualNode)
+		if err != nil {
+			return "", 0, attempt, mapCreateDiskError(err, volumeName, snapshotID)
+		}
+		if i == 0 {
+			// Stripes perform as the slowest member, make the rest identical to the first one.
+			attempt = a
+			member.Type = []Category{a.Category}
+			member.PerformanceLevel = nil
+			if a.PerformanceLevel != "" {
+				member.PerformanceLevel = []PerformanceLevel{a.PerformanceLevel}
+			}
+		}
+		if err := cs.setupCreatedDisk(ctx, diskID, diskVol, mutable); err != nil {
+			return "", 0, attempt, err
+		}
+		diskIDs[i] = diskID
+	}
+	return stripeVolumeID(diskVol.Stripe.Mode, diskIDs), utils.Gi2Bytes(member.RequestGB * n), attempt, nil
+}
+
+// publishStripedVolume attaches all the members to the node.
+// The serial numbers are passed to the node in stripe order, empty for those attached by the node itself.
+func (cs *controllerServer) publishStripedVolume(ctx context.Context, diskIDs []string, nodeID string) (*csi.ControllerPublishVolumeResponse, error) {
+	serials := make([]string, len(diskIDs))
+	errs := make([]error, len(diskIDs))
+	var wg sync.WaitGroup
+	for i, diskID := range diskIDs {
+		// AttachDetachSlots limit the concurrency on the node
+		wg.Go(func() {
+			r, err := cs.ad.attachDisk(klog.NewContext(ctx, klog.FromContext(ctx).WithValues("diskID", diskID)), diskID, nodeID)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			serials[i] = r.disk.SerialNumber
+		})
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			// keep the status code of the first failure, others are likely the same
+			return nil, err
+		}
+	}
+	klog.Infof("ControllerPublishVolume: successfully attached disks: %v to node: %s", diskIDs, nodeID)
+	return &csi.ControllerPublishVolumeResponse{
+		PublishContext: map[string]string{
+			PUBLISH_CONTEXT_SERIAL: strings.Join(serials, ","),
+		},
+	}, nil
+}
+
+// expandStripedVolume expands every member to its share of the new capacity.
+// The node grows the stripe afterwards.
+func (cs *controllerServer) expandStripedVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest, mode stripe.Mode, diskIDs []string) (*csi.ControllerExpandVolumeResponse, error) {
+	if mode != stripe.LVM {
+		return nil, status.Errorf(codes.InvalidArgument, "striped volume %s in %s mode can not be expanded", req.VolumeId, mode)
+	}
+	n := int64(len(diskIDs))
+	requestGB := (req.GetCapacityRange().GetRequiredBytes() + utils.Gi2Bytes(1) - 1) / utils.Gi2Bytes(1)
+	memberBytes := utils.Gi2Bytes((requestGB + n - 1) / n)
+	for _, diskID := range diskIDs {
+		_, err := cs.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{
+			VolumeId:         diskID,
+			CapacityRange:    &csi.CapacityRange{RequiredBytes: memberBytes},
+			Secrets:          req.Secrets,
+			VolumeCapability: req.VolumeCapability,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &csi.ControllerExpandVolumeResponse{CapacityBytes: memberBytes * n, NodeExpansionRequired: true}, nil
+}
+
+// getStripedVolume reports the first abnormal member as the condition of the striped volume.
+func (cs *controllerServer) getStripedVolume(ctx context.Context, volumeID string, diskIDs []string) (*csi.ControllerGetVolumeResponse, error) {
+	resp := &csi.ControllerGetVolumeResponse{
+		Volume: &csi.Volume{VolumeId: volumeID},
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{},
+	}
+	for i, diskID := range diskIDs {
+		disk, err := cs.cd.batcher.Describe(ctx, diskID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "describe disk %s failed: %v", diskID, err)
+		}
+		if disk == nil {
+			return nil, status.Errorf(codes.NotFound, "disk %s of striped volume %s not found", diskID, volumeID)
+		}
+		nodeIDs := attachedInstances(disk)
+		if i == 0 {
+			resp.Status.PublishedNodeIds = nodeIDs
+		}
+		resp.Volume.CapacityBytes += utils.Gi2Bytes(int64(disk.Size))
+		cond := cs.diskCondition(ctx, disk, nodeIDs)
+		if resp.Status.VolumeCondition == nil || (!resp.Status.VolumeCondition.Abnormal && cond.Abnormal) {
+			resp.Status.VolumeCondition = cond
+		}
+	}
+	return resp, nil
+}
+
+// describeSnapshotGroup finds the snapshot group by name or ID, in any status.
+func describeSnapshotGroup(ecsClient cloud.ECSInterface, name, id string) (*ecs.SnapshotGroup, error) {
+	req := ecs.CreateDescribeSnapshotGroupsRequest()
+	req.RegionId = GlobalConfigVar.Region
+	req.Name = name
+	if id != "" {
+		req.SnapshotGroupId = &[]string{id}
+	}
+	resp, err := ecsClient.DescribeSnapshotGroups(req)
+	if err != nil {
+		return nil, err
+	}
+	groups := resp.SnapshotGroups.SnapshotGroup
+	switch len(groups) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &groups[0], nil
+	default:
+		return nil, fmt.Errorf("find more than one snapshot group with name %q id %q", name, id)
+	}
+}
+
+// waitSnapshotGroupCut waits until all the n snapshots in the group are cut,
+// after which the source disks can be thawed.
+func waitSnapshotGroupCut(ctx context.Context, ecsClient cloud.ECSInterface, waiter waitstatus.StatusWaiter[ecs.Snapshot], groupID string, n int) error {
+	var snapshotIDs []string
+	err := wait.PollUntilContextCancel(ctx, time.Second, true, func(ctx context.Context) (bool, error) {
+		group, err := describeSnapshotGroup(ecsClient, "", groupID)
+		if err != nil {
+			klog.FromContext(ctx).Error(err, "DescribeSnapshotGroups failed", "groupSnapshotID", groupID)
+			return false, nil
+		}
+		snapshotIDs = snapshotIDs[:0]
+		if group != nil {
+			for _, snapshot := range group.Snapshots.Snapshot {
+				snapshotIDs = append(snapshotIDs, snapshot.SnapshotId)
+			}
+		}
+		return len(snapshotIDs) == n, nil
+	})
+	if err != nil {
+		return fmt.Errorf("snapshots of group %s are not created: %w", groupID, err)
+	}
+	for _, id := range snapshotIDs {
+		if _, err := waiter.WaitFor(ctx, id, waitstatus.SnapshotCut); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatStripedSnapshot reports the snapshot group of a striped volume as one CSI snapshot.
+func formatStripedSnapshot(group *ecs.SnapshotGroup, sourceVolumeID string) (*csi.Snapshot, error) {
+	g, err := formatGroupSnapshot(group)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "format snapshot group %s failed: %v", group.SnapshotGroupId, err)
+	}
+	s := &csi.Snapshot{
+		SnapshotId:     group.SnapshotGroupId,
+		SourceVolumeId: sourceVolumeID,
+		CreationTime:   g.CreationTime,
+		ReadyToUse:     g.ReadyToUse,
+	}
+	for _, member := range g.Snapshots {
+		s.SizeBytes += member.SizeBytes
+	}
+	return s, nil
+}
+
+// createStripedSnapshot snapshots all the members of a striped volume together in a snapshot group,
+// so that the stripe is consistent. The ID of the group is the ID of the CSI snapshot.
+func (cs *controllerServer) createStripedSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest, params *createSnapshotParams, mode stripe.Mode, diskIDs []string) (*csi.CreateSnapshotResponse, error) {
+	logger := klog.FromContext(ctx)
+	group, err := describeSnapshotGroup(cs.ecs, req.Name, "")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "find existing snapshot group %s failed: %v", req.Name, err)
+	}
+
+	waitCtx := ctx
+	var groupID string
+	if group != nil {
+		if !groupSourceDisks(group).Equal(sets.New(diskIDs...)) {
+			return nil, status.Errorf(codes.AlreadyExists, "snapshot group %s already exists with different source disks", req.Name)
+		}
+		groupID = group.SnapshotGroupId
+		logger.V(2).Info("snapshot group already created", "snapshotGroupID", groupID)
+	} else {
+		if params.FsFreeze {
+			disks := getDisks(diskIDs, cs.ecs)
+			if len(disks) != len(diskIDs) {
+				return nil, status.Errorf(codes.Internal, "CreateSnapshot:: failed to get disks %v", diskIDs)
+			}
+			var cancel context.CancelFunc
+			waitCtx, cancel = context.WithTimeout(ctx, cs.fsFreeze.timeout)
+			defer cancel()
+			// the node knows the filesystem by the striped volume, not its members
+			thaw, err := cs.fsFreeze.freezeVolumes(waitCtx, map[string][]ecs.Disk{req.SourceVolumeId: disks})
+			if err != nil {
+				return nil, status.Errorf(codes.Unavailable, "CreateSnapshot:: failed to freeze filesystem: %v", err)
+			}
+			defer thaw()
+		}
+		if params.RetentionDays > 0 {
+			logger.Info("retention days is not supported by snapshot groups, ignored", "retentionDays", params.RetentionDays)
+		}
+
+		createReq := ecs.CreateCreateSnapshotGroupRequest()
+		createReq.RegionId = GlobalConfigVar.Region
+		createReq.DiskId = &diskIDs
+		createReq.Name = req.Name
+		createReq.Description = stripeSnapshotLayout(mode, diskIDs)
+		createReq.ResourceGroupId = params.ResourceGroupID
+		tags := []ecs.CreateSnapshotGroupTag{
+			{Key: DISKTAGKEY2, Value: DISKTAGVALUE2},
+			{Key: SNAPSHOTTAGKEY1, Value: "true"},
+		}
+		if GlobalConfigVar.ClusterID != "" {
+			tags = append(tags, ecs.CreateSnapshotGroupTag{Key: DISKTAGKEY3, Value: GlobalConfigVar.ClusterID})
+		}
+		for _, t := range params.SnapshotTags {
+			tags = append(tags, ecs.CreateSnapshotGroupTag{Key: t.Key, Value: t.Value})
+		}
+		createReq.Tag = &tags
+		resp, err := cs.ecs.CreateSnapshotGroup(createReq)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "create snapshot group %s failed: %v", req.Name, err)
+		}
+		groupID = resp.SnapshotGroupId
+		cs.snapshotGroups.invalidate()
+		logger.V(2).Info("snapshot group created", "snapshotGroupID", groupID, "disks", diskIDs)
+	}
+
+	if err := waitSnapshotGroupCut(waitCtx, cs.ecs, cs.snapshotWaiter, groupID, len(diskIDs)); err != nil {
+		if params.FsFreeze && ctx.Err() == nil {
+			// A retry would find this group by name and return it as if it were consistent.
+			if _, delErr := cs.deleteStripedSnapshot(ctx, groupID); delErr != nil {
+				return nil, status.Errorf(codes.Internal, "snapshot group %s is not cut within the filesystem freeze timeout %v, and failed to delete it: %v",
+					groupID, cs.fsFreeze.timeout, delErr)
+			}
+			cs.snapshotGroups.invalidate()
+			return nil, status.Errorf(codes.DeadlineExceeded, "snapshot group %s is not cut within the filesystem freeze timeout %v, deleted it as it may not be application-consistent: %v",
+				groupID, cs.fsFreeze.timeout, err)
+		}
+		return nil, status.Errorf(codes.Internal, "failed while waiting for snapshot group cut: %v", err)
+	}
+
+	group, err = describeSnapshotGroup(cs.ecs, "", groupID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "describe snapshot group %s failed: %v", groupID, err)
+	}
+	if group == nil {
+		return nil, status.Errorf(codes.Internal, "snapshot group %s disappeared", groupID)
+	}
+	snapshot, err := formatStripedSnapshot(group, req.SourceVolumeId)
+	if err != nil {
+		return nil, err
+	}
+	return &csi.CreateSnapshotResponse{Snapshot: snapshot}, nil
+}
+
+func (cs *controllerServer) deleteStripedSnapshot(ctx context.Context, groupID string) (*csi.DeleteSnapshotResponse, error) {
+	req := ecs.CreateDeleteSnapshotGroupRequest()
+	req.RegionId = GlobalConfigVar.Region
+	req.SnapshotGroupId = groupID
+	_, err := cs.ecs.DeleteSnapshotGroup(req)
+	if err != nil {
+		if aliErr, ok := errors.AsType[*alicloudErr.ServerError](err); !ok || aliErr.ErrorCode() != SnapshotNotFound {
+			return nil, status.Errorf(codes.Internal, "DeleteSnapshot: failed to delete snapshot group %s: %v", groupID, err)
+		}
+	}
+	klog.FromContext(ctx).V(2).Info("deleted snapshot group", "snapshotGroupID", groupID)
+	return &csi.DeleteSnapshotResponse{}, nil
+}
+
+func (cs *controllerServer) listStripedSnapshot(groupID string) (*csi.ListSnapshotsResponse, error) {
+	group, err := describeSnapshotGroup(cs.ecs, "", groupID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to find snapshot group %s: %v", groupID, err)
+	}
+	resp := &csi.ListSnapshotsResponse{}
+	if group == nil {
+		return resp, nil
+	}
+	// The source volume ID can not be recovered from the group.
+	snapshot, err := formatStripedSnapshot(group, "")
+	if err != nil {
+		return nil, err
+	}
+	resp.Entries = append(resp.Entries, &csi.ListSnapshotsResponse_Entry{Snapshot: snapshot})
+	return resp, nil
+}
@@ -0,0 +1,332 @@
+//go:build !windows
+
+package disk
+
+import (
+	"fmt"
+	"testing"
+
+	alicloudErr "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/stripe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func TestParseStripeParams(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     map[string]string
+		expected stripeParams
+		err      bool
+	}{
+		{name: "not striped"},
+		{name: "single disk", opts: map[string]string{StripeCountKey: "1"}},
+		{name: "default mode", opts: map[string]string{StripeCountKey: "4"}, expected: stripeParams{Count: 4, Mode: stripe.LVM}},
+		{name: "md", opts: map[string]string{StripeCountKey: "2", StripeModeKey: "md"}, expected: stripeParams{Count: 2, Mode: stripe.MD}},
+		{name: "too many", opts: map[string]string{StripeCountKey: "17"}, err: true},
+		{name: "invalid count", opts: map[string]string{StripeCountKey: "two"}, err: true},
+		{name: "invalid mode", opts: map[string]string{StripeCountKey: "2", StripeModeKey: "raid5"}, err: true},
+		{name: "mode without count", opts: map[string]string{StripeModeKey: "md"}, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := parseStripeParams(c.opts)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, p)
+		})
+	}
+}
+
+func TestParseStripeVolumeID(t *testing.T) {
+	id := stripeVolumeID(stripe.MD, []string{"d-1", "d-2"})
+	assert.Equal(t, "stripe-md:d-1,d-2", id)
+
+	mode, diskIDs, ok := parseStripeVolumeID(id)
+	assert.True(t, ok)
+	assert.Equal(t, stripe.MD, mode)
+	assert.Equal(t, []string{"d-1", "d-2"}, diskIDs)
+	assert.Equal(t, diskIDs, volumeDisks(id))
+
+	_, _, ok = parseStripeVolumeID("d-1")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"d-1"}, volumeDisks("d-1"))
+
+	path, ok := stripeDevicePath(id)
+	assert.True(t, ok)
+	assert.Equal(t, "/dev/md/csi-stripe-d-1", path)
+}
+
+func TestCreateStripedDisks(t *testing.T) {
+	c, cd := testCreateDelete(t)
+	_, ctx := ktesting.NewTestContext(t)
+	cs := &controllerServer{ecs: c, cd: *cd}
+
+	var created []*ecs.CreateDiskRequest
+	c.EXPECT().CreateDisk(gomock.Any()).DoAndReturn(func(req *ecs.CreateDiskRequest) (*ecs.CreateDiskResponse, error) {
+		created = append(created, req)
+		return &ecs.CreateDiskResponse{DiskId: "d-" + req.DiskName}, nil
+	}).Times(3)
+
+	args := &diskVolumeArgs{
+		Type:      []Category{DiskESSD, DiskESSDAuto},
+		RequestGB: 100,
+		DiskTags:  map[string]string{},
+		Stripe:    stripeParams{Count: 3, Mode: stripe.LVM},
+	}
+	volumeID, capacity, attempt, err := cs.createStripedDisks(ctx, "pvc-1", args, ModifyParameters{}, nil, "", false, []string{"s-0", "s-1", "s-2"})
+	require.NoError(t, err)
+	assert.Equal(t, "stripe-lvm:d-pvc-1-0,d-pvc-1-1,d-pvc-1-2", volumeID)
+	assert.Equal(t, int64(102<<30), capacity)
+	assert.Equal(t, DiskESSD, attempt.Category)
+	for i, req := range created {
+		assert.Equal(t, "34", string(req.Size))
+		assert.Equal(t, string(DiskESSD), req.DiskCategory)
+		assert.Equal(t, fmt.Sprintf("s-%d", i), req.SnapshotId)
+		assert.Contains(t, *req.Tag, ecs.CreateDiskTag{Key: StripeVolumeTag, Value: "pvc-1"})
+		assert.Contains(t, *req.Tag, ecs.CreateDiskTag{Key: StripeMemberTag, Value: fmt.Sprintf("lvm:%d/3", i)})
+	}
+	assert.Empty(t, args.DiskTags, "should not modify the original args")
+}
+
+func TestExpandStripedVolumeMD(t *testing.T) {
+	_, _, cs := testCloneServer(t)
+	_, ctx := ktesting.NewTestContext(t)
+
+	_, err := cs.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{
+		VolumeId:      "stripe-md:d-1,d-2",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 100 << 30},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func snapshotGroupsResp(groups ...ecs.SnapshotGroup) *ecs.DescribeSnapshotGroupsResponse {
+	return &ecs.DescribeSnapshotGroupsResponse{
+		SnapshotGroups: ecs.SnapshotGroups{SnapshotGroup: groups},
+	}
+}
+
+func TestCreateStripedSnapshot(t *testing.T) {
+	group := ecs.SnapshotGroup{
+		SnapshotGroupId: "ssg-1",
+		Status:          SnapshotStatusAccomplished,
+		CreationTime:    "2026-01-01T00:00:00Z",
+		Snapshots: ecs.SnapshotsInDescribeSnapshotGroups{Snapshot: []ecs.Snapshot{
+			{SnapshotId: "s-1", SourceDiskId: "d-1", SourceDiskSize: "20", Available: true},
+			{SnapshotId: "s-2", SourceDiskId: "d-2", SourceDiskSize: "20", Available: true},
+		}},
+	}
+	req := &csi.CreateSnapshotRequest{Name: "snap-1", SourceVolumeId: "stripe-lvm:d-1,d-2"}
+
+	t.Run("create", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		w.snapshot.Status = SnapshotStatusAccomplished
+		c.EXPECT().DescribeSnapshotGroups(gomock.Any()).DoAndReturn(func(r *ecs.DescribeSnapshotGroupsRequest) (*ecs.DescribeSnapshotGroupsResponse, error) {
+			assert.Equal(t, "snap-1", r.Name)
+			return snapshotGroupsResp(), nil
+		})
+		c.EXPECT().CreateSnapshotGroup(gomock.Any()).DoAndReturn(func(r *ecs.CreateSnapshotGroupRequest) (*ecs.CreateSnapshotGroupResponse, error) {
+			assert.Equal(t, []string{"d-1", "d-2"}, *r.DiskId)
+			assert.Equal(t, "snap-1", r.Name)
+			return &ecs.CreateSnapshotGroupResponse{SnapshotGroupId: "ssg-1"}, nil
+		})
+		c.EXPECT().DescribeSnapshotGroups(gomock.Any()).Return(snapshotGroupsResp(group), nil).Times(2)
+
+		resp, err := cs.CreateSnapshot(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, "ssg-1", resp.Snapshot.SnapshotId)
+		assert.Equal(t, "stripe-lvm:d-1,d-2", resp.Snapshot.SourceVolumeId)
+		assert.Equal(t, int64(40<<30), resp.Snapshot.SizeBytes)
+		assert.True(t, resp.Snapshot.ReadyToUse)
+		assert.Equal(t, []string{"s-1", "s-2"}, w.waited)
+	})
+
+	t.Run("freeze timeout", func(t *testing.T) {
+		c, w, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		cs.fsFreeze = &fsFreezeClient{timeout: fsFreezeTimeout}
+		w.snapshot.CreationTime = ""
+		c.EXPECT().DescribeSnapshotGroups(gomock.Any()).Return(snapshotGroupsResp(), nil)
+		// not attached, nothing to freeze
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(&ecs.DescribeDisksResponse{
+			Disks: ecs.DisksInDescribeDisks{Disk: []ecs.Disk{{DiskId: "d-1"}, {DiskId: "d-2"}}},
+		}, nil)
+		c.EXPECT().CreateSnapshotGroup(gomock.Any()).Return(&ecs.CreateSnapshotGroupResponse{SnapshotGroupId: "ssg-1"}, nil)
+		// the snapshots in the group are never cut
+		c.EXPECT().DescribeSnapshotGroups(gomock.Any()).Return(snapshotGroupsResp(group), nil)
+		c.EXPECT().DeleteSnapshotGroup(gomock.Any()).DoAndReturn(func(r *ecs.DeleteSnapshotGroupRequest) (*ecs.DeleteSnapshotGroupResponse, error) {
+			assert.Equal(t, "ssg-1", r.SnapshotGroupId)
+			return &ecs.DeleteSnapshotGroupResponse{}, nil
+		})
+
+		freezeReq := &csi.CreateSnapshotRequest{Name: "snap-1", SourceVolumeId: "stripe-lvm:d-1,d-2", Parameters: map[string]string{FSFREEZE: "true"}}
+		_, err := cs.CreateSnapshot(ctx, freezeReq)
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	})
+
+	t.Run("exists with other disks", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		other := group
+		other.Snapshots.Snapshot = other.Snapshots.Snapshot[:1]
+		c.EXPECT().DescribeSnapshotGroups(gomock.Any()).Return(snapshotGroupsResp(other), nil)
+
+		_, err := cs.CreateSnapshot(ctx, req)
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+}
+
+func TestDeleteStripedSnapshot(t *testing.T) {
+	c, _, cs := testCloneServer(t)
+	_, ctx := ktesting.NewTestContext(t)
+	c.EXPECT().DeleteSnapshotGroup(gomock.Any()).DoAndReturn(func(r *ecs.DeleteSnapshotGroupRequest) (*ecs.DeleteSnapshotGroupResponse, error) {
+		assert.Equal(t, "ssg-1", r.SnapshotGroupId)
+		return &ecs.DeleteSnapshotGroupResponse{}, nil
+	})
+	_, err := cs.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{SnapshotId: "ssg-1"})
+	assert.NoError(t, err)
+
+	c.EXPECT().DeleteSnapshotGroup(gomock.Any()).Return(nil, alicloudErr.NewServerError(404, `{"Code": "InvalidSnapshotId.NotFound"}`, ""))
+	_, err = cs.DeleteSnapshot(ctx, &csi.DeleteSnapshotRequest{SnapshotId: "ssg-1"})
+	assert.NoError(t, err)
+}
+
+func TestStripeSnapshotLayout(t *testing.T) {
+	diskIDs := []string{"d-b", "d-c", "d-a"}
+	layout := stripeSnapshotLayout(stripe.MD, diskIDs)
+	assert.Equal(t, "stripe-md:1,2,0", layout)
+
+	group := &ecs.SnapshotGroup{
+		SnapshotGroupId: "ssg-1",
+		Description:     layout,
+		Snapshots: ecs.SnapshotsInDescribeSnapshotGroups{Snapshot: []ecs.Snapshot{
+			{SnapshotId: "s-a", SourceDiskId: "d-a"},
+			{SnapshotId: "s-c", SourceDiskId: "d-c"},
+			{SnapshotId: "s-b", SourceDiskId: "d-b"},
+		}},
+	}
+	mode, snapshotIDs, err := parseStripeSnapshotLayout(group)
+	require.NoError(t, err)
+	assert.Equal(t, stripe.MD, mode)
+	assert.Equal(t, []string{"s-b", "s-c", "s-a"}, snapshotIDs)
+
+	for _, desc := range []string{"", "stripe-md:0,1", "stripe-md:0,0,1", "stripe-md:0,1,3", "stripe-raid5:0,1,2"} {
+		group.Description = desc
+		_, _, err := parseStripeSnapshotLayout(group)
+		assert.Error(t, err, desc)
+	}
+}
+
+func TestParseStripeMemberTag(t *testing.T) {
+	mode, i, n, err := parseStripeMemberTag(stripeMemberTag(stripe.LVM, 2, 4))
+	require.NoError(t, err)
+	assert.Equal(t, stripe.LVM, mode)
+	assert.Equal(t, 2, i)
+	assert.Equal(t, 4, n)
+
+	for _, v := range []string{"", "lvm", "lvm:4/4", "lvm:-1/4", "lvm:a/b"} {
+		_, _, _, err := parseStripeMemberTag(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestRestoreStripedSnapshot(t *testing.T) {
+	group := ecs.SnapshotGroup{
+		SnapshotGroupId: "ssg-1",
+		Status:          SnapshotStatusAccomplished,
+		Description:     "stripe-lvm:1,0",
+		Snapshots: ecs.SnapshotsInDescribeSnapshotGroups{Snapshot: []ecs.Snapshot{
+			{SnapshotId: "s-1", SourceDiskId: "d-1"},
+			{SnapshotId: "s-2", SourceDiskId: "d-2"},
+		}},
+	}
+	req := func(params map[string]string) *csi.CreateVolumeRequest {
+		return &csi.CreateVolumeRequest{
+			Name:          "pvc-1",
+			CapacityRange: &csi.CapacityRange{RequiredBytes: 40 << 30},
+			Parameters:    params,
+			VolumeContentSource: &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Snapshot{
+				Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "ssg-1"},
+			}},
+		}
+	}
+
+	t.Run("members", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		c.EXPECT().DescribeSnapshotGroups(gomock.Any()).Return(snapshotGroupsResp(group), nil)
+		snapshotIDs, err := cs.stripedSnapshotMembers("ssg-1", stripeParams{Count: 2, Mode: stripe.LVM})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"s-2", "s-1"}, snapshotIDs)
+	})
+
+	t.Run("not accomplished", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		progressing := group
+		progressing.Status = "progressing"
+		c.EXPECT().DescribeSnapshotGroups(gomock.Any()).Return(snapshotGroupsResp(progressing), nil)
+		_, err := cs.stripedSnapshotMembers("ssg-1", stripeParams{Count: 2, Mode: stripe.LVM})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	for _, c := range []struct {
+		name   string
+		params map[string]string
+	}{
+		{name: "not striped", params: map[string]string{"type": "cloud_essd", "zoneId": "cn-hangzhou-a"}},
+		{name: "other count", params: map[string]string{"type": "cloud_essd", "zoneId": "cn-hangzhou-a", StripeCountKey: "3"}},
+		{name: "other mode", params: map[string]string{"type": "cloud_essd", "zoneId": "cn-hangzhou-a", StripeCountKey: "2", StripeModeKey: "md"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			e, _, cs := testCloneServer(t)
+			_, ctx := ktesting.NewTestContext(t)
+			e.EXPECT().DescribeSnapshotGroups(gomock.Any()).Return(snapshotGroupsResp(group), nil)
+			_, err := cs.CreateVolume(ctx, req(c.params))
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestStripedVolumeRejectsLuks(t *testing.T) {
+	_, _, cs := testCloneServer(t)
+	_, ctx := ktesting.NewTestContext(t)
+	_, err := cs.CreateVolume(ctx, &csi.CreateVolumeRequest{
+		Name:          "pvc-1",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 40 << 30},
+		Parameters:    map[string]string{"type": "cloud_essd", "zoneId": "cn-hangzhou-a", StripeCountKey: "2", LuksEncrypted: "true"},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestStripedVolumeRejectsOtherSources(t *testing.T) {
+	for name, source := range map[string]*csi.VolumeContentSource{
+		"snapshot": {Type: &csi.VolumeContentSource_Snapshot{
+			Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "s-1"},
+		}},
+		"volume": {Type: &csi.VolumeContentSource_Volume{
+			Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "d-1"},
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, cs := testCloneServer(t)
+			_, ctx := ktesting.NewTestContext(t)
+			_, err := cs.CreateVolume(ctx, &csi.CreateVolumeRequest{
+				Name:                "pvc-1",
+				CapacityRange:       &csi.CapacityRange{RequiredBytes: 40 << 30},
+				Parameters:          map[string]string{"type": "cloud_essd", "zoneId": "cn-hangzhou-a", StripeCountKey: "2"},
+				VolumeContentSource: source,
+			})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			assert.ErrorContains(t, err, "snapshot group")
+		})
+	}
+}
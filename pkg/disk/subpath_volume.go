@@ -0,0 +1,525 @@
+package disk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// A subpath volume is a directory on a disk shared by all the subpath volumes on one node,
+// limited by a project quota. The shared disk is created for, and only attached to, the node owning it.
+// Its ID names the shared disk and the directory: subpath:<diskID>:<name>
+const subpathVolumeIDPrefix = "subpath:"
+
+type subpathParams struct {
+	// SharedDiskGB is the size of the shared disk created for a node, 0 if not a subpath volume
+	SharedDiskGB int64
+}
+
+func parseSubpathParams(opts map[string]string) (subpathParams, error) {
+	var p subpathParams
+	switch opts[VolumeAsKey] {
+	case "", "disk":
+		if opts[SharedDiskSizeKey] != "" {
+			return p, fmt.Errorf("%s requires %s=%s", SharedDiskSizeKey, VolumeAsKey, VolumeAsSubpath)
+		}
+		return p, nil
+	case VolumeAsSubpath:
+	default:
+		return p, fmt.Errorf("invalid %s %q, must be disk or %s", VolumeAsKey, opts[VolumeAsKey], VolumeAsSubpath)
+	}
+	value := opts[SharedDiskSizeKey]
+	if value == "" {
+		return p, fmt.Errorf("%s is required for %s=%s", SharedDiskSizeKey, VolumeAsKey, VolumeAsSubpath)
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size <= 0 {
+		return p, fmt.Errorf("invalid %s %q, must be a positive number of GiB", SharedDiskSizeKey, value)
+	}
+	p.SharedDiskGB = size
+	return p, nil
+}
+
+func subpathVolumeID(sharedDiskID, name string) string {
+	return subpathVolumeIDPrefix + sharedDiskID + ":" + name
+}
+
+// parseSubpathVolumeID returns the shared disk and the directory name of a subpath volume.
+// ok is false if volumeID is not a subpath volume.
+func parseSubpathVolumeID(volumeID string) (sharedDiskID, name string, ok bool) {
+	rest, ok := strings.CutPrefix(volumeID, subpathVolumeIDPrefix)
+	if !ok {
+		return "", "", false
+	}
+	sharedDiskID, name, ok = strings.Cut(rest, ":")
+	if !ok || sharedDiskID == "" || name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		return "", "", false
+	}
+	return sharedDiskID, name, true
+}
+
+// sharedDiskName names the shared disk of instanceID, so that it can be found again.
+func sharedDiskName(instanceID string) string {
+	return "csi-shared-" + instanceID
+}
+
+func diskTag(disk *ecs.Disk, key string) string {
+	for _, tag := range disk.Tags.Tag {
+		if tag.TagKey == key {
+			return tag.TagValue
+		}
+	}
+	return ""
+}
+
+// subpathLedger records the volumes on a shared disk. It is kept in a ConfigMap so that it survives controller restarts,
+// and the node owning the shared disk can read it.
+type subpathLedger struct {
+	// Quotas granted to the volumes on the shared disk, by volume ID, from CreateVolume until DeleteVolume
+	Quotas map[string]int64 `json:"quotas"`
+	// Deleted are the directory names of the volumes deleted by DeleteVolume, until the node removes them.
+	// Only DeleteVolume decides that the data of a volume can be removed.
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+const subpathLedgerKey = "ledger"
+
+func subpathLedgerName(sharedDiskID string) string {
+	return "csi-subpath-" + strings.ToLower(sharedDiskID)
+}
+
+// getSubpathLedger returns nil if the shared disk has no ledger.
+func getSubpathLedger(ctx context.Context, clientSet kubernetes.Interface, sharedDiskID string) (*subpathLedger, error) {
+	cm, err := clientSet.CoreV1().ConfigMaps(pluginNamespace()).Get(ctx, subpathLedgerName(sharedDiskID), metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseSubpathLedger(cm)
+}
+
+func parseSubpathLedger(cm *corev1.ConfigMap) (*subpathLedger, error) {
+	l := &subpathLedger{}
+	if err := json.Unmarshal([]byte(cm.Data[subpathLedgerKey]), l); err != nil {
+		return nil, fmt.Errorf("corrupted ledger %s: %w", cm.Name, err)
+	}
+	if l.Quotas == nil {
+		l.Quotas = map[string]int64{}
+	}
+	return l, nil
+}
+
+// modifySubpathLedger applies mutate to the ledger of the shared disk and saves it if changed, retrying on conflicts.
+// A missing ledger is built by seed, or left missing if seed is nil.
+func modifySubpathLedger(ctx context.Context, clientSet kubernetes.Interface, sharedDiskID string,
+	seed func(ctx context.Context) (*subpathLedger, error), mutate func(l *subpathLedger) (bool, error),
+) error {
+	configMaps := clientSet.CoreV1().ConfigMaps(pluginNamespace())
+	name := subpathLedgerName(sharedDiskID)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		var l *subpathLedger
+		switch {
+		case err == nil:
+			l, err = parseSubpathLedger(cm)
+			if err != nil {
+				return err
+			}
+		case apierrors.IsNotFound(err):
+			if seed == nil {
+				return nil
+			}
+			l, err = seed(ctx)
+			if err != nil {
+				return err
+			}
+			cm = nil
+		default:
+			return err
+		}
+		changed, err := mutate(l)
+		if err != nil || (!changed && cm != nil) {
+			return err
+		}
+		data, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		if cm == nil {
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{SharedDiskLabel: sharedDiskID}},
+				Data:       map[string]string{subpathLedgerKey: string(data)},
+			}, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// created concurrently, start over from it
+				return apierrors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+			return err
+		}
+		cm.Data = map[string]string{subpathLedgerKey: string(data)}
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// subpathAllocator makes sure the quotas of the subpath volumes on a shared disk do not exceed its size,
+// by granting them in the ledger of the shared disk.
+type subpathAllocator struct {
+	clientSet kubernetes.Interface
+
+	// The PVs are only used to build the ledger of the shared disks allocated before ledgers existed.
+	pvInformerOnce sync.Once
+	pvs            corelisters.PersistentVolumeLister
+	pvsSynced      cache.InformerSynced
+}
+
+func newSubpathAllocator(clientSet kubernetes.Interface) *subpathAllocator {
+	return &subpathAllocator{clientSet: clientSet}
+}
+
+// seed builds the ledger of a shared disk from the existing PVs.
+func (a *subpathAllocator) seed(sharedDiskID string) func(ctx context.Context) (*subpathLedger, error) {
+	return func(ctx context.Context) (*subpathLedger, error) {
+		a.pvInformerOnce.Do(func() {
+			factory := informers.NewSharedInformerFactory(a.clientSet, 0)
+			informer := factory.Core().V1().PersistentVolumes()
+			a.pvs = informer.Lister()
+			a.pvsSynced = informer.Informer().HasSynced
+			factory.Start(wait.NeverStop)
+		})
+		if !cache.WaitForCacheSync(ctx.Done(), a.pvsSynced) {
+			return nil, fmt.Errorf("PV informer not synced: %w", ctx.Err())
+		}
+		pvs, err := a.pvs.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		prefix := subpathVolumeIDPrefix + sharedDiskID + ":"
+		l := &subpathLedger{Quotas: map[string]int64{}}
+		for _, pv := range pvs {
+			if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != DriverName || !strings.HasPrefix(pv.Spec.CSI.VolumeHandle, prefix) {
+				continue
+			}
+			l.Quotas[pv.Spec.CSI.VolumeHandle] = pv.Spec.Capacity.Storage().Value()
+		}
+		klog.FromContext(ctx).Info("building ledger of shared disk from PVs", "diskID", sharedDiskID, "volumes", len(l.Quotas))
+		return l, nil
+	}
+}
+
+// volumes returns the quotas of the volumes on the shared disk, by volume ID.
+func (a *subpathAllocator) volumes(ctx context.Context, sharedDiskID string) (map[string]int64, error) {
+	l, err := getSubpathLedger(ctx, a.clientSet, sharedDiskID)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		l, err = a.seed(sharedDiskID)(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l.Quotas, nil
+}
+
+// reserve sets the quota of volumeID to bytes, if it fits in the shared disk of capacity bytes.
+// The quota is never lowered.
+func (a *subpathAllocator) reserve(ctx context.Context, sharedDiskID string, capacity int64, volumeID string, bytes int64) error {
+	_, name, _ := parseSubpathVolumeID(volumeID)
+	err := modifySubpathLedger(ctx, a.clientSet, sharedDiskID, a.seed(sharedDiskID), func(l *subpathLedger) (bool, error) {
+		if slices.Contains(l.Deleted, name) {
+			return false, status.Errorf(codes.Aborted, "a deleted volume named %s is not yet removed from shared disk %s", name, sharedDiskID)
+		}
+		if bytes <= l.Quotas[volumeID] {
+			return false, nil
+		}
+		total := bytes
+		for id, b := range l.Quotas {
+			if id != volumeID {
+				total += b
+			}
+		}
+		if total > capacity {
+			return false, status.Errorf(codes.ResourceExhausted, "shared disk %s of %s can not hold %s more, %s allocated to %d volumes",
+				sharedDiskID, resource.NewQuantity(capacity, resource.BinarySI), resource.NewQuantity(bytes, resource.BinarySI),
+				resource.NewQuantity(total-bytes, resource.BinarySI), len(l.Quotas))
+		}
+		l.Quotas[volumeID] = bytes
+		return true, nil
+	})
+	return subpathLedgerError(err, sharedDiskID)
+}
+
+// release records that volumeID is deleted, so that the node removes its directory.
+func (a *subpathAllocator) release(ctx context.Context, volumeID string) error {
+	sharedDiskID, name, _ := parseSubpathVolumeID(volumeID)
+	err := modifySubpathLedger(ctx, a.clientSet, sharedDiskID, a.seed(sharedDiskID), func(l *subpathLedger) (bool, error) {
+		_, granted := l.Quotas[volumeID]
+		if !granted && slices.Contains(l.Deleted, name) {
+			return false, nil
+		}
+		delete(l.Quotas, volumeID)
+		if !slices.Contains(l.Deleted, name) {
+			l.Deleted = append(l.Deleted, name)
+		}
+		return true, nil
+	})
+	return subpathLedgerError(err, sharedDiskID)
+}
+
+func subpathLedgerError(err error, sharedDiskID string) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "update ledger of shared disk %s: %v", sharedDiskID, err)
+}
+
+// createSubpathVolume allocates a subpath volume on the shared disk of the selected node,
+// creating the shared disk first if the node has none. The volume can only be used on that node.
+// The directory and its quota are set up by the node on NodeStageVolume.
+func (cs *controllerServer) createSubpathVolume(ctx context.Context, req *csi.CreateVolumeRequest, diskVol *diskVolumeArgs, mutable ModifyParameters,
+	supportedTypes sets.Set[Category], selectedInstance string,
+) (*csi.CreateVolumeResponse, error) {
+	if req.VolumeContentSource != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s=%s is not supported when restoring from a snapshot or cloning a volume", VolumeAsKey, VolumeAsSubpath)
+	}
+	if selectedInstance == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "%s=%s requires a selected node, use volumeBindingMode WaitForFirstConsumer", VolumeAsKey, VolumeAsSubpath)
+	}
+	disk, err := cs.ensureSharedDisk(ctx, diskVol, mutable, supportedTypes, selectedInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeID := subpathVolumeID(disk.DiskId, req.Name)
+	quota := utils.Gi2Bytes(diskVol.RequestGB)
+	if err := cs.subpaths.reserve(ctx, disk.DiskId, utils.Gi2Bytes(int64(disk.Size)), volumeID, quota); err != nil {
+		return nil, err
+	}
+
+	attempt := createAttempt{
+		Category:         Category(disk.Category),
+		PerformanceLevel: PerformanceLevel(disk.PerformanceLevel),
+		Instance:         selectedInstance, // pin to the node owning the shared disk
+	}
+	volumeContext := updateVolumeContext(createdVolumeContext(req, mutable, attempt))
+	volumeContext[SubpathQuotaKey] = strconv.FormatInt(quota, 10)
+	klog.FromContext(ctx).Info("allocated subpath volume", "volumeID", volumeID, "quota", DiskSize{quota}, "instance", selectedInstance)
+	return &csi.CreateVolumeResponse{Volume: volumeCreate(attempt, volumeID, quota, volumeContext, disk.ZoneId, diskVol.ExtraTopology, nil)}, nil
+}
+
+// ensureSharedDisk finds or creates the shared disk of instanceID.
+// An existing shared disk is used as is, even if the StorageClass asks for another size or type.
+func (cs *controllerServer) ensureSharedDisk(ctx context.Context, diskVol *diskVolumeArgs, mutable ModifyParameters,
+	supportedTypes sets.Set[Category], instanceID string,
+) (*ecs.Disk, error) {
+	name := sharedDiskName(instanceID)
+	disk, err := findDiskByName(name, cs.ecs)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "find shared disk %s failed: %v", name, err)
+	}
+	if disk == nil {
+		shared := *diskVol
+		shared.RequestGB = diskVol.Subpath.SharedDiskGB
+		shared.DiskTags = maps.Clone(diskVol.DiskTags)
+		shared.DiskTags[SharedDiskOwnerTag] = instanceID
+		diskID, _, err := cs.cd.createDisk(ctx, name, "", &shared, supportedTypes, instanceID, false)
+		if err != nil {
+			return nil, mapCreateDiskError(err, name, "")
+		}
+		if err := cs.setupCreatedDisk(ctx, diskID, &shared, mutable); err != nil {
+			return nil, err
+		}
+		klog.FromContext(ctx).Info("created shared disk for subpath volumes", "diskID", diskID, "instance", instanceID)
+
+		disk, err = cs.cd.batcher.Describe(ctx, diskID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "describe shared disk %s failed: %v", diskID, err)
+		}
+		if disk == nil {
+			return nil, status.Errorf(codes.Unavailable, "shared disk %s created but not found yet", diskID)
+		}
+	}
+	if owner := diskTag(disk, SharedDiskOwnerTag); owner != instanceID {
+		return nil, status.Errorf(codes.FailedPrecondition, "disk %s named %s is owned by %q, not %s", disk.DiskId, name, owner, instanceID)
+	}
+	return disk, nil
+}
+
+// publishSubpathVolume attaches the shared disk to the node owning it.
+// It is never detached by ControllerUnpublishVolume, other subpath volumes may still use it.
+func (cs *controllerServer) publishSubpathVolume(ctx context.Context, sharedDiskID, nodeID string) (*csi.ControllerPublishVolumeResponse, error) {
+	disk, err := cs.cd.batcher.Describe(ctx, sharedDiskID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "describe shared disk %s failed: %v", sharedDiskID, err)
+	}
+	if disk == nil {
+		return nil, status.Errorf(codes.NotFound, "shared disk %s not found", sharedDiskID)
+	}
+	if owner := diskTag(disk, SharedDiskOwnerTag); owner != nodeID {
+		return nil, status.Errorf(codes.FailedPrecondition, "shared disk %s is owned by %q, can not be used on %s", sharedDiskID, owner, nodeID)
+	}
+	r, err := cs.ad.attachDisk(ctx, sharedDiskID, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return &csi.ControllerPublishVolumeResponse{
+		PublishContext: map[string]string{
+			PUBLISH_CONTEXT_SERIAL: r.disk.SerialNumber,
+		},
+	}, nil
+}
+
+// expandSubpathVolume grows the quota if it still fits in the shared disk. The node applies it.
+func (cs *controllerServer) expandSubpathVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest, sharedDiskID string) (*csi.ControllerExpandVolumeResponse, error) {
+	disk, err := cs.cd.batcher.Describe(ctx, sharedDiskID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "describe shared disk %s failed: %v", sharedDiskID, err)
+	}
+	if disk == nil {
+		return nil, status.Errorf(codes.NotFound, "shared disk %s not found", sharedDiskID)
+	}
+	requestGB := (req.GetCapacityRange().GetRequiredBytes() + utils.Gi2Bytes(1) - 1) / utils.Gi2Bytes(1)
+	quota := utils.Gi2Bytes(requestGB)
+	if err := cs.subpaths.reserve(ctx, sharedDiskID, utils.Gi2Bytes(int64(disk.Size)), req.VolumeId, quota); err != nil {
+		return nil, err
+	}
+	return &csi.ControllerExpandVolumeResponse{CapacityBytes: quota, NodeExpansionRequired: true}, nil
+}
+
+// getSubpathVolume reports the condition of the shared disk. The quota is only known to the node.
+func (cs *controllerServer) getSubpathVolume(ctx context.Context, volumeID, sharedDiskID string) (*csi.ControllerGetVolumeResponse, error) {
+	resp, err := cs.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: sharedDiskID})
+	if err != nil {
+		return nil, err
+	}
+	resp.Volume.VolumeId = volumeID
+	resp.Volume.CapacityBytes = 0
+	return resp, nil
+}
+
+// sharedDiskGCInterval is how often the shared disks left by released instances are looked for.
+const sharedDiskGCInterval = time.Hour
+
+// runSharedDiskGC garbage-collects the shared disks periodically until ctx is done.
+// It is run by the leader replica only, see runAsLeader.
+func (cs *controllerServer) runSharedDiskGC(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	if GlobalConfigVar.ClusterID == "" {
+		// The shared disks of other clusters in the same account can not be told apart
+		logger.Info("CLUSTER_ID not set, shared disks of subpath volumes will not be garbage-collected")
+		return
+	}
+	ticker := time.NewTicker(sharedDiskGCInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cs.gcSharedDisks(ctx); err != nil {
+				logger.Error(err, "failed to garbage-collect shared disks")
+			}
+		}
+	}
+}
+
+// gcSharedDisks deletes the detached shared disks of this cluster whose owner instance is released,
+// once all their volumes are deleted. The volumes whose PV is retained keep their shared disk.
+func (cs *controllerServer) gcSharedDisks(ctx context.Context) error {
+	logger := klog.FromContext(ctx)
+	req := ecs.CreateDescribeDisksRequest()
+	req.RegionId = GlobalConfigVar.Region
+	req.Status = DiskStatusAvailable
+	req.Tag = &[]ecs.DescribeDisksTag{{Key: SharedDiskOwnerTag}, {Key: DISKTAGKEY3, Value: GlobalConfigVar.ClusterID}}
+	req.MaxResults = requests.NewInteger(100)
+	for {
+		resp, err := cs.ecs.DescribeDisks(req)
+		if err != nil {
+			return fmt.Errorf("describe shared disks: %w", err)
+		}
+		for i := range resp.Disks.Disk {
+			disk := &resp.Disks.Disk[i]
+			if err := cs.gcSharedDisk(ctx, disk); err != nil {
+				logger.Error(err, "failed to garbage-collect shared disk", "diskID", disk.DiskId)
+			}
+		}
+		if resp.NextToken == "" {
+			return nil
+		}
+		req.NextToken = resp.NextToken
+	}
+}
+
+func (cs *controllerServer) gcSharedDisk(ctx context.Context, disk *ecs.Disk) error {
+	logger := klog.FromContext(ctx).WithValues("diskID", disk.DiskId)
+	owner := diskTag(disk, SharedDiskOwnerTag)
+	if owner == "" || disk.Status != DiskStatusAvailable || diskTag(disk, DISKTAGKEY3) != GlobalConfigVar.ClusterID {
+		return nil
+	}
+	instanceReq := ecs.CreateDescribeInstancesRequest()
+	instanceReq.RegionId = GlobalConfigVar.Region
+	instanceReq.InstanceIds = fmt.Sprintf("[%q]", owner)
+	instanceResp, err := cs.ecs.DescribeInstances(instanceReq)
+	if err != nil {
+		return fmt.Errorf("describe owner instance %s: %w", owner, err)
+	}
+	if len(instanceResp.Instances.Instance) > 0 {
+		return nil // the shared disk is reused when the instance comes back
+	}
+
+	l, err := getSubpathLedger(ctx, cs.clientSet, disk.DiskId)
+	if err != nil {
+		return fmt.Errorf("get ledger: %w", err)
+	}
+	if l == nil {
+		l, err = cs.subpaths.seed(disk.DiskId)(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if len(l.Quotas) > 0 {
+		logger.V(2).Info("kept shared disk of released instance for the volumes not deleted", "instance", owner, "volumes", len(l.Quotas))
+		return nil
+	}
+	if _, err := cs.cd.deleteDisk(ctx, cs.ecs, disk.DiskId); err != nil {
+		return fmt.Errorf("delete disk: %w", err)
+	}
+	logger.Info("deleted shared disk of released instance", "instance", owner)
+	err = cs.clientSet.CoreV1().ConfigMaps(pluginNamespace()).Delete(ctx, subpathLedgerName(disk.DiskId), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("delete ledger: %w", err)
+	}
+	return nil
+}
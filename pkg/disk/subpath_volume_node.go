@@ -0,0 +1,280 @@
+//go:build !windows
+
+package disk
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/projquota"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	k8smount "k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+)
+
+// sharedDiskMountPath is where the shared disk is mounted on the node, once for all its subpath volumes.
+func sharedDiskMountPath(diskID string) string {
+	return filepath.Join(utils.KubeletRootDir, "plugins/kubernetes.io/csi", DriverName, "shared", diskID)
+}
+
+// subpathAllocationsFile at the root of the shared disk records its directories.
+// A hidden name never clashes with a volume directory.
+const subpathAllocationsFile = ".csi-subpath.json"
+
+type subpathAllocation struct {
+	ProjectID  uint32 `json:"projectID"`
+	QuotaBytes int64  `json:"quotaBytes"`
+}
+
+// subpathAllocations are the directories on a shared disk, by name.
+type subpathAllocations map[string]subpathAllocation
+
+func loadSubpathAllocations(mountPath string) (subpathAllocations, error) {
+	data, err := os.ReadFile(filepath.Join(mountPath, subpathAllocationsFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return subpathAllocations{}, nil
+		}
+		return nil, err
+	}
+	allocs := subpathAllocations{}
+	if err := json.Unmarshal(data, &allocs); err != nil {
+		return nil, fmt.Errorf("corrupted %s: %w", subpathAllocationsFile, err)
+	}
+	return allocs, nil
+}
+
+func (a subpathAllocations) save(mountPath string) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(mountPath, subpathAllocationsFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// nextProjectID never reuses the ID of a removed directory, in case some of its files are left.
+func (a subpathAllocations) nextProjectID() uint32 {
+	var id uint32
+	for _, alloc := range a {
+		id = max(id, alloc.ProjectID)
+	}
+	return id + 1
+}
+
+type subpathRequest interface {
+	setupRequest
+	GetPublishContext() map[string]string
+}
+
+// stageSubpathVolume mounts the shared disk if needed, creates the directory of the volume with its quota,
+// then bind mounts the directory to targetPath.
+func (ns *nodeServer) stageSubpathVolume(ctx context.Context, req subpathRequest, sharedDiskID, name, targetPath string) error {
+	logger := klog.FromContext(ctx)
+	if req.GetVolumeCapability().GetBlock() != nil {
+		return status.Error(codes.InvalidArgument, "subpath volume can not be used as block device")
+	}
+	notMounted, err := ns.k8smounter.IsLikelyNotMountPoint(targetPath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "check %s mounted: %v", targetPath, err)
+	}
+	if !notMounted {
+		logger.V(2).Info("subpath volume already staged", "targetPath", targetPath)
+		return nil
+	}
+
+	ns.subpathLock.Lock()
+	defer ns.subpathLock.Unlock()
+
+	mountPath, fsType, err := ns.mountSharedDisk(ctx, sharedDiskID, req)
+	if err != nil {
+		return err
+	}
+	allocs, err := loadSubpathAllocations(mountPath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "load allocations of shared disk %s: %v", sharedDiskID, err)
+	}
+	if ns.clientSet != nil {
+		if err := reclaimDeletedSubpaths(ctx, ns.clientSet, mountPath, fsType, sharedDiskID, name, allocs); err != nil {
+			logger.Error(err, "failed to remove deleted subpath volumes, will retry on next stage")
+		}
+	}
+
+	dir := filepath.Join(mountPath, name)
+	if _, ok := allocs[name]; !ok {
+		quota, err := strconv.ParseInt(req.GetVolumeContext()[SubpathQuotaKey], 10, 64)
+		if err != nil || quota <= 0 {
+			return status.Errorf(codes.InvalidArgument, "invalid %s in volume context: %q", SubpathQuotaKey, req.GetVolumeContext()[SubpathQuotaKey])
+		}
+		alloc := subpathAllocation{ProjectID: allocs.nextProjectID(), QuotaBytes: quota}
+		// Record it after the quota is set, a retry starts over with a new project.
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return status.Errorf(codes.Internal, "create subpath directory: %v", err)
+		}
+		if err := projquota.Assign(ctx, fsType, mountPath, dir, alloc.ProjectID); err != nil {
+			return status.Errorf(codes.Internal, "assign project quota: %v", err)
+		}
+		if err := projquota.SetLimit(ctx, fsType, mountPath, alloc.ProjectID, alloc.QuotaBytes); err != nil {
+			return status.Errorf(codes.Internal, "set project quota: %v", err)
+		}
+		allocs[name] = alloc
+		logger.Info("created subpath directory", "dir", dir, "projectID", alloc.ProjectID, "quota", DiskSize{alloc.QuotaBytes})
+	}
+	if err := allocs.save(mountPath); err != nil {
+		return status.Errorf(codes.Internal, "save allocations of shared disk %s: %v", sharedDiskID, err)
+	}
+
+	if err := ns.k8smounter.Mount(dir, targetPath, "", []string{"bind"}); err != nil {
+		return status.Errorf(codes.Internal, "bind mount %s to %s: %v", dir, targetPath, err)
+	}
+	logger.V(2).Info("subpath volume staged", "dir", dir, "targetPath", targetPath)
+	return nil
+}
+
+// mountSharedDisk attaches and mounts the shared disk with project quotas enforced, if not done yet.
+// A blank disk is formatted with the fsType of the volume. It returns the mount path and the actual fsType.
+func (ns *nodeServer) mountSharedDisk(ctx context.Context, sharedDiskID string, req subpathRequest) (string, string, error) {
+	mountPath := sharedDiskMountPath(sharedDiskID)
+	fsType, err := ns.sharedDiskFsType(mountPath)
+	if err != nil {
+		return "", "", status.Errorf(codes.Internal, "check shared disk mount: %v", err)
+	}
+	if fsType == "" {
+		device, _, err := ns.findOrAttachDevice(ctx, sharedDiskID, req.GetPublishContext()[PUBLISH_CONTEXT_SERIAL], codes.Internal)
+		if err != nil {
+			return "", "", err
+		}
+		device, err = DefaultDeviceManager.adaptDevicePartition(device)
+		if err != nil {
+			return "", "", status.Errorf(codes.Internal, "failed to adapt partition %s: %v", device, err)
+		}
+		diskMounter := &k8smount.SafeFormatAndMount{Interface: ns.k8smounter, Exec: utilexec.New()}
+		fsType, err = diskMounter.GetDiskFormat(device)
+		if err != nil {
+			return "", "", status.Errorf(codes.Internal, "get format of %s: %v", device, err)
+		}
+		if fsType == "" {
+			fsType = cmp.Or(req.GetVolumeCapability().GetMount().GetFsType(), EXT4_FSTYPE)
+		}
+		if !projquota.Supported(fsType) {
+			return "", "", status.Errorf(codes.InvalidArgument, "shared disk %s is %s, project quota requires %s or %s", sharedDiskID, fsType, XFS_FSTYPE, EXT4_FSTYPE)
+		}
+		if err := os.MkdirAll(mountPath, 0o755); err != nil {
+			return "", "", status.Errorf(codes.Internal, "create shared disk mount path: %v", err)
+		}
+		err = utils.FormatAndMount(diskMounter, device, mountPath, fsType, projquota.FormatOptions(fsType), projquota.MountOptions(), false)
+		if err != nil {
+			return "", "", status.Errorf(codes.Internal, "mount shared disk %s: %v", sharedDiskID, err)
+		}
+		klog.FromContext(ctx).Info("mounted shared disk", "diskID", sharedDiskID, "device", device, "fsType", fsType, "path", mountPath)
+	}
+	if !projquota.Supported(fsType) {
+		return "", "", status.Errorf(codes.FailedPrecondition, "shared disk %s is mounted as %s, project quota is not supported", sharedDiskID, fsType)
+	}
+	return mountPath, fsType, nil
+}
+
+// sharedDiskFsType returns the fsType of the shared disk mounted at mountPath, or "" if not mounted.
+func (ns *nodeServer) sharedDiskFsType(mountPath string) (string, error) {
+	mnts, err := ns.k8smounter.List()
+	if err != nil {
+		return "", err
+	}
+	for _, mnt := range mnts {
+		if mnt.Path == mountPath {
+			return mnt.Type, nil
+		}
+	}
+	return "", nil
+}
+
+// reclaimDeletedSubpaths removes the directories of the volumes recorded as deleted in the ledger of the shared disk.
+// Only DeleteVolume records a volume as deleted, a volume whose PV is missing or retained is never removed.
+// DeleteVolume can not reach the node, so the node cleans up when it stages another volume.
+func reclaimDeletedSubpaths(ctx context.Context, clientSet kubernetes.Interface, mountPath, fsType, sharedDiskID, stagingName string, allocs subpathAllocations) error {
+	logger := klog.FromContext(ctx)
+	l, err := getSubpathLedger(ctx, clientSet, sharedDiskID)
+	if err != nil || l == nil || len(l.Deleted) == 0 {
+		return err
+	}
+	reclaimed := sets.New[string]()
+	for _, name := range l.Deleted {
+		if name == stagingName {
+			continue // should never happen, DeleteVolume is only called on unused volumes
+		}
+		if alloc, ok := allocs[name]; ok {
+			if err := projquota.Remove(ctx, fsType, mountPath, filepath.Join(mountPath, name), alloc.ProjectID); err != nil {
+				logger.Error(err, "failed to remove deleted subpath volume", "name", name)
+				continue
+			}
+			delete(allocs, name)
+			logger.Info("removed deleted subpath volume", "name", name)
+		}
+		reclaimed.Insert(name)
+	}
+	if reclaimed.Len() == 0 {
+		return nil
+	}
+	// Forget them in the ledger only once forgotten on the disk, a retry would remove the directories again
+	if err := allocs.save(mountPath); err != nil {
+		return fmt.Errorf("save allocations: %w", err)
+	}
+	return modifySubpathLedger(ctx, clientSet, sharedDiskID, nil, func(l *subpathLedger) (bool, error) {
+		n := len(l.Deleted)
+		l.Deleted = slices.DeleteFunc(l.Deleted, reclaimed.Has)
+		return len(l.Deleted) != n, nil
+	})
+}
+
+// expandSubpathVolume raises the quota of the volume. The controller has checked it fits in the shared disk.
+func (ns *nodeServer) expandSubpathVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest, sharedDiskID, name string) (*csi.NodeExpandVolumeResponse, error) {
+	ns.subpathLock.Lock()
+	defer ns.subpathLock.Unlock()
+
+	mountPath := sharedDiskMountPath(sharedDiskID)
+	fsType, err := ns.sharedDiskFsType(mountPath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "check shared disk mount: %v", err)
+	}
+	if fsType == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "shared disk %s is not mounted", sharedDiskID)
+	}
+	allocs, err := loadSubpathAllocations(mountPath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "load allocations of shared disk %s: %v", sharedDiskID, err)
+	}
+	alloc, ok := allocs[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "subpath volume %s not found on shared disk %s", name, sharedDiskID)
+	}
+	requestBytes := req.GetCapacityRange().GetRequiredBytes()
+	if requestBytes > alloc.QuotaBytes {
+		if err := projquota.SetLimit(ctx, fsType, mountPath, alloc.ProjectID, requestBytes); err != nil {
+			return nil, status.Errorf(codes.Internal, "set project quota: %v", err)
+		}
+		alloc.QuotaBytes = requestBytes
+		allocs[name] = alloc
+		if err := allocs.save(mountPath); err != nil {
+			return nil, status.Errorf(codes.Internal, "save allocations of shared disk %s: %v", sharedDiskID, err)
+		}
+		klog.FromContext(ctx).V(2).Info("expanded subpath quota", "name", name, "quota", DiskSize{requestBytes})
+	}
+	return &csi.NodeExpandVolumeResponse{CapacityBytes: alloc.QuotaBytes}, nil
+}
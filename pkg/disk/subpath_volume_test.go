@@ -0,0 +1,317 @@
+//go:build !windows
+
+package disk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func TestParseSubpathParams(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     map[string]string
+		expected subpathParams
+		err      bool
+	}{
+		{name: "disk"},
+		{name: "explicit disk", opts: map[string]string{VolumeAsKey: "disk"}},
+		{name: "subpath", opts: map[string]string{VolumeAsKey: "subpath", SharedDiskSizeKey: "500"}, expected: subpathParams{SharedDiskGB: 500}},
+		{name: "no size", opts: map[string]string{VolumeAsKey: "subpath"}, err: true},
+		{name: "invalid size", opts: map[string]string{VolumeAsKey: "subpath", SharedDiskSizeKey: "500Gi"}, err: true},
+		{name: "size without subpath", opts: map[string]string{SharedDiskSizeKey: "500"}, err: true},
+		{name: "unknown", opts: map[string]string{VolumeAsKey: "filesystem"}, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := parseSubpathParams(c.opts)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, p)
+		})
+	}
+}
+
+func TestParseSubpathVolumeID(t *testing.T) {
+	id := subpathVolumeID("d-shared", "pvc-1")
+	assert.Equal(t, "subpath:d-shared:pvc-1", id)
+
+	diskID, name, ok := parseSubpathVolumeID(id)
+	assert.True(t, ok)
+	assert.Equal(t, "d-shared", diskID)
+	assert.Equal(t, "pvc-1", name)
+
+	for _, invalid := range []string{"d-1", "subpath:d-shared", "subpath::pvc-1", "subpath:d-shared:../etc", "subpath:d-shared:.csi-subpath.json"} {
+		_, _, ok = parseSubpathVolumeID(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func subpathPV(name, handle string, gi int64) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: *resource.NewQuantity(gi<<30, resource.BinarySI)},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: DriverName, VolumeHandle: handle},
+			},
+		},
+	}
+}
+
+func TestSubpathAllocator(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	client := fake.NewClientset(
+		subpathPV("pvc-1", "subpath:d-shared:pvc-1", 60),
+		subpathPV("pvc-other", "subpath:d-other:pvc-other", 100),
+	)
+	a := newSubpathAllocator(client)
+
+	// the ledger is built from the PVs allocated before it existed
+	require.NoError(t, a.reserve(ctx, "d-shared", 100<<30, "subpath:d-shared:pvc-2", 30<<30))
+	// pvc-2 is granted, not yet a PV
+	err := a.reserve(ctx, "d-shared", 100<<30, "subpath:d-shared:pvc-3", 20<<30)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// retry of the same volume
+	require.NoError(t, a.reserve(ctx, "d-shared", 100<<30, "subpath:d-shared:pvc-2", 30<<30))
+	// expand pvc-1 into the rest
+	require.NoError(t, a.reserve(ctx, "d-shared", 100<<30, "subpath:d-shared:pvc-1", 70<<30))
+
+	// the grants survive a restart of the controller
+	a = newSubpathAllocator(client)
+	err = a.reserve(ctx, "d-shared", 100<<30, "subpath:d-shared:pvc-3", 1<<30)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	require.NoError(t, a.release(ctx, "subpath:d-shared:pvc-2"))
+	require.NoError(t, a.release(ctx, "subpath:d-shared:pvc-1"))
+	require.NoError(t, a.release(ctx, "subpath:d-shared:pvc-1"))
+	// the directory of the deleted pvc-2 is not removed by the node yet
+	err = a.reserve(ctx, "d-shared", 100<<30, "subpath:d-shared:pvc-2", 1<<30)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	require.NoError(t, a.reserve(ctx, "d-shared", 100<<30, "subpath:d-shared:pvc-3", 40<<30))
+
+	l, err := getSubpathLedger(ctx, client, "d-shared")
+	require.NoError(t, err)
+	assert.Equal(t, &subpathLedger{
+		Quotas:  map[string]int64{"subpath:d-shared:pvc-3": 40 << 30},
+		Deleted: []string{"pvc-2", "pvc-1"},
+	}, l)
+}
+
+func TestCreateSubpathVolume(t *testing.T) {
+	shared := ecs.Disk{
+		DiskId:   "d-shared",
+		Size:     100,
+		Category: string(DiskESSD),
+		ZoneId:   "cn-hangzhou-a",
+		Tags:     ecs.TagsInDescribeDisks{Tag: []ecs.Tag{{TagKey: SharedDiskOwnerTag, TagValue: "i-1"}}},
+	}
+	req := &csi.CreateVolumeRequest{Name: "pvc-2", Parameters: map[string]string{VolumeAsKey: VolumeAsSubpath, SharedDiskSizeKey: "100"}}
+	args := &diskVolumeArgs{RequestGB: 20, Subpath: subpathParams{SharedDiskGB: 100}, DiskTags: map[string]string{}}
+
+	t.Run("existing shared disk", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		cs.subpaths = newSubpathAllocator(fake.NewClientset(subpathPV("pvc-1", "subpath:d-shared:pvc-1", 80)))
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(shared), nil).Times(2)
+
+		resp, err := cs.createSubpathVolume(ctx, req, args, ModifyParameters{}, nil, "i-1")
+		require.NoError(t, err)
+		assert.Equal(t, "subpath:d-shared:pvc-2", resp.Volume.VolumeId)
+		assert.Equal(t, int64(20<<30), resp.Volume.CapacityBytes)
+		assert.Equal(t, "21474836480", resp.Volume.VolumeContext[SubpathQuotaKey])
+		assert.Equal(t, "i-1", resp.Volume.AccessibleTopology[0].Segments[common.ECSInstanceIDTopologyKey])
+
+		req := &csi.CreateVolumeRequest{Name: "pvc-3"}
+		_, err = cs.createSubpathVolume(ctx, req, args, ModifyParameters{}, nil, "i-1")
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("owned by another node", func(t *testing.T) {
+		c, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		c.EXPECT().DescribeDisks(gomock.Any()).Return(diskResp(shared), nil)
+
+		_, err := cs.createSubpathVolume(ctx, req, args, ModifyParameters{}, nil, "i-2")
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("content source", func(t *testing.T) {
+		_, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		req := &csi.CreateVolumeRequest{Name: "pvc-2", VolumeContentSource: &csi.VolumeContentSource{Type: &csi.VolumeContentSource_Snapshot{
+			Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "s-1"},
+		}}}
+		_, err := cs.createSubpathVolume(ctx, req, args, ModifyParameters{}, nil, "i-1")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("immediate binding", func(t *testing.T) {
+		_, _, cs := testCloneServer(t)
+		_, ctx := ktesting.NewTestContext(t)
+		_, err := cs.createSubpathVolume(ctx, req, args, ModifyParameters{}, nil, "")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestPublishSubpathVolumeOtherNode(t *testing.T) {
+	_, _, cs := testCloneServer(t)
+	_, ctx := ktesting.NewTestContext(t)
+	cs.cd.batcher = &fakeBatcher{disk: &ecs.Disk{
+		DiskId: "d-shared",
+		Tags:   ecs.TagsInDescribeDisks{Tag: []ecs.Tag{{TagKey: SharedDiskOwnerTag, TagValue: "i-1"}}},
+	}}
+	_, err := cs.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{VolumeId: "subpath:d-shared:pvc-1", NodeId: "i-2"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestDeleteSubpathVolume(t *testing.T) {
+	_, _, cs := testCloneServer(t)
+	_, ctx := ktesting.NewTestContext(t)
+	client := fake.NewClientset(subpathPV("pvc-1", "subpath:d-shared:pvc-1", 10))
+	cs.subpaths = newSubpathAllocator(client)
+
+	// no ECS calls, the node removes the directory
+	_, err := cs.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: "subpath:d-shared:pvc-1"})
+	require.NoError(t, err)
+	l, err := getSubpathLedger(ctx, client, "d-shared")
+	require.NoError(t, err)
+	assert.Equal(t, &subpathLedger{Quotas: map[string]int64{}, Deleted: []string{"pvc-1"}}, l)
+}
+
+func TestSubpathAllocations(t *testing.T) {
+	dir := t.TempDir()
+	allocs, err := loadSubpathAllocations(dir)
+	require.NoError(t, err)
+	assert.Empty(t, allocs)
+	assert.Equal(t, uint32(1), allocs.nextProjectID())
+
+	allocs["pvc-1"] = subpathAllocation{ProjectID: 1, QuotaBytes: 10 << 30}
+	allocs["pvc-3"] = subpathAllocation{ProjectID: 3, QuotaBytes: 20 << 30}
+	require.NoError(t, allocs.save(dir))
+
+	loaded, err := loadSubpathAllocations(dir)
+	require.NoError(t, err)
+	assert.Equal(t, allocs, loaded)
+	assert.Equal(t, uint32(4), loaded.nextProjectID())
+}
+
+func TestReclaimDeletedSubpaths(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	mountPath := t.TempDir()
+	allocs := subpathAllocations{"pvc-1": {ProjectID: 1}, "pvc-2": {ProjectID: 2}}
+
+	// No ledger: no PV is ever taken as a sign of deletion
+	clientSet := fake.NewClientset()
+	require.NoError(t, reclaimDeletedSubpaths(ctx, clientSet, mountPath, XFS_FSTYPE, "d-shared", "pvc-3", allocs))
+	assert.Len(t, allocs, 2)
+
+	// pvc-4 was deleted before it was ever staged, pvc-3 is being staged
+	require.NoError(t, modifySubpathLedger(ctx, clientSet, "d-shared", func(context.Context) (*subpathLedger, error) {
+		return &subpathLedger{Quotas: map[string]int64{}}, nil
+	}, func(l *subpathLedger) (bool, error) {
+		l.Deleted = []string{"pvc-4", "pvc-3"}
+		return true, nil
+	}))
+	require.NoError(t, reclaimDeletedSubpaths(ctx, clientSet, mountPath, XFS_FSTYPE, "d-shared", "pvc-3", allocs))
+	assert.Len(t, allocs, 2)
+	l, err := getSubpathLedger(ctx, clientSet, "d-shared")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pvc-3"}, l.Deleted)
+}
+
+func TestGCSharedDisk(t *testing.T) {
+	withClusterID(t, "c-test")
+	shared := func(id string) ecs.Disk {
+		return ecs.Disk{
+			DiskId: id,
+			Status: DiskStatusAvailable,
+			Tags: ecs.TagsInDescribeDisks{Tag: []ecs.Tag{
+				{TagKey: SharedDiskOwnerTag, TagValue: "i-released"},
+				{TagKey: DISKTAGKEY3, TagValue: "c-test"},
+			}},
+		}
+	}
+	c, _, cs := testCloneServer(t)
+	_, ctx := ktesting.NewTestContext(t)
+	client := fake.NewClientset(subpathPV("pvc-retained", "subpath:d-kept:pvc-retained", 10))
+	cs.clientSet = client
+	cs.subpaths = newSubpathAllocator(client)
+	cs.cd.ecs = c
+	cs.cd.deleteThrottler = defaultThrottler()
+
+	c.EXPECT().DescribeInstances(gomock.Any()).Return(&ecs.DescribeInstancesResponse{}, nil).Times(2)
+	c.EXPECT().DeleteDisk(gomock.Any()).DoAndReturn(func(req *ecs.DeleteDiskRequest) (*ecs.DeleteDiskResponse, error) {
+		assert.Equal(t, "d-empty", req.DiskId)
+		return &ecs.DeleteDiskResponse{}, nil
+	})
+
+	// a volume whose PV is retained keeps the shared disk
+	disk := shared("d-kept")
+	require.NoError(t, cs.gcSharedDisk(ctx, &disk))
+	// all its volumes are deleted
+	disk = shared("d-empty")
+	require.NoError(t, cs.gcSharedDisk(ctx, &disk))
+	// another cluster
+	disk = shared("d-other")
+	disk.Tags.Tag[1].TagValue = "c-other"
+	require.NoError(t, cs.gcSharedDisk(ctx, &disk))
+}
+
+func TestListSubpathVolumes(t *testing.T) {
+	c, _, cs := testCloneServer(t)
+	_, ctx := ktesting.NewTestContext(t)
+	cs.subpaths = newSubpathAllocator(fake.NewClientset(
+		subpathPV("pvc-1", "subpath:d-shared:pvc-1", 10),
+		subpathPV("pvc-2", "subpath:d-shared:pvc-2", 10),
+		subpathPV("pvc-3", "subpath:d-shared:pvc-3", 10),
+	))
+	page := []ecs.Disk{
+		{DiskId: "d-shared", Size: 100, Tags: ecs.TagsInDescribeDisks{Tag: []ecs.Tag{{TagKey: SharedDiskOwnerTag, TagValue: "i-1"}}}},
+		{DiskId: "d-1", Size: 20},
+	}
+	c.EXPECT().DescribeDisks(gomock.Any()).DoAndReturn(func(req *ecs.DescribeDisksRequest) (*ecs.DescribeDisksResponse, error) {
+		assert.Empty(t, req.NextToken)
+		return &ecs.DescribeDisksResponse{Disks: ecs.DisksInDescribeDisks{Disk: page}}, nil
+	}).Times(2)
+
+	// the shared disk has more volumes than max_entries
+	resp, err := cs.ListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: 2})
+	require.NoError(t, err)
+	var volumeIDs []string
+	for _, e := range resp.Entries {
+		volumeIDs = append(volumeIDs, e.Volume.VolumeId)
+	}
+	assert.Equal(t, []string{"subpath:d-shared:pvc-1", "subpath:d-shared:pvc-2"}, volumeIDs)
+	require.NotEmpty(t, resp.NextToken)
+
+	resp, err = cs.ListVolumes(ctx, &csi.ListVolumesRequest{MaxEntries: 2, StartingToken: resp.NextToken})
+	require.NoError(t, err)
+	volumeIDs = nil
+	for _, e := range resp.Entries {
+		volumeIDs = append(volumeIDs, e.Volume.VolumeId)
+	}
+	assert.Empty(t, resp.NextToken)
+	assert.Equal(t, []string{"subpath:d-shared:pvc-3", "d-1"}, volumeIDs)
+
+	_, err = cs.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: "x+0@"})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+}
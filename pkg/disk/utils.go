@@ -416,7 +416,7 @@ func getDiskVolumeOptions(
 	req *csi.CreateVolumeRequest,
 	m metadata.MetadataProvider,
 	recorder record.EventRecorder,
-	snapshotId string,
+	dataSourceID string,
 ) (*diskVolumeArgs, error) {
 	var ok bool
 	diskVolArgs := &diskVolumeArgs{
@@ -434,8 +434,8 @@ func getDiskVolumeOptions(
 	for _, cap := range req.GetVolumeCapabilities() {
 		mnt := cap.GetMount()
 		if mnt != nil && mnt.FsType != "" {
-			// Note: skip filesystem type validation when CreateDisk from a snapshot.
-			if snapshotId != "" {
+			// Note: skip filesystem type validation when CreateDisk from a snapshot or another disk.
+			if dataSourceID != "" {
 				continue
 			}
 			if SupportedFilesystemTypes.Has(mnt.FsType) {
@@ -508,7 +508,7 @@ func getDiskVolumeOptions(
 			return nil, err
 		}
 		diskVolArgs.MultiAttach = false
-		if v, ok := volOptions["multiAttach"]; ok {
+		if v, ok := volOptions[MULTI_ATTACH_KEY]; ok {
 			switch strings.ToLower(v) {
 			case "true", "enabled":
 				diskVolArgs.MultiAttach = true
@@ -650,6 +650,11 @@ func getDiskVolumeOptions(
 		}
 	}
 
+	switch strings.ToLower(volOptions[StrictGroupRestoreKey]) {
+	case "yes", "true", "1":
+		diskVolArgs.StrictGroupRestore = true
+	}
+
 	if req.GetCapacityRange() == nil {
 		return nil, fmt.Errorf("capacity range is required")
 	}
@@ -669,6 +674,48 @@ func getDiskVolumeOptions(
 		return nil, err
 	}
 
+	if _, err := parseAutoExpandPolicy(volOptions, nil); err != nil {
+		return nil, err
+	}
+
+	diskVolArgs.AutoSnapshotPolicy, err = parseAutoSnapshotPolicyParams(volOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	diskVolArgs.Stripe, err = parseStripeParams(volOptions)
+	if err != nil {
+		return nil, err
+	}
+	if diskVolArgs.Stripe.Count > 0 {
+		luks, _ := strconv.ParseBool(volOptions[LuksEncrypted])
+		switch {
+		case luks:
+			return nil, fmt.Errorf("%s is not supported with %s", StripeCountKey, LuksEncrypted)
+		case diskVolArgs.MultiAttach:
+			return nil, fmt.Errorf("%s is not supported with multi-attach", StripeCountKey)
+		case diskVolArgs.DataCache.Enabled():
+			return nil, fmt.Errorf("%s is not supported with data cache", StripeCountKey)
+		}
+	}
+
+	diskVolArgs.Subpath, err = parseSubpathParams(volOptions)
+	if err != nil {
+		return nil, err
+	}
+	if diskVolArgs.Subpath.SharedDiskGB > 0 {
+		switch {
+		case diskVolArgs.Stripe.Count > 0:
+			return nil, fmt.Errorf("%s=%s is not supported with %s", VolumeAsKey, VolumeAsSubpath, StripeCountKey)
+		case diskVolArgs.MultiAttach:
+			return nil, fmt.Errorf("%s=%s is not supported with multi-attach", VolumeAsKey, VolumeAsSubpath)
+		case diskVolArgs.DataCache.Enabled():
+			return nil, fmt.Errorf("%s=%s is not supported with data cache", VolumeAsKey, VolumeAsSubpath)
+		case slices.ContainsFunc(req.GetVolumeCapabilities(), func(c *csi.VolumeCapability) bool { return c.GetBlock() != nil }):
+			return nil, fmt.Errorf("%s=%s is not supported for block volumes", VolumeAsKey, VolumeAsSubpath)
+		}
+	}
+
 	return diskVolArgs, nil
 }
 
@@ -761,6 +808,35 @@ func parseMutableParameters(mutableParameters map[string]string) (ModifyParamete
 				return params, fmt.Errorf("invalid %s: %w", BURSTING_ENABLED_KEY, err)
 			}
 			params.BurstingEnabled = &en
+		case MULTI_ATTACH_KEY:
+			switch strings.ToLower(v) {
+			case "true", "enabled":
+				params.MultiAttach = new(true)
+			case "false", "disabled":
+				params.MultiAttach = new(false)
+			default:
+				return params, fmt.Errorf("invalid %s: %q", MULTI_ATTACH_KEY, v)
+			}
+		case DELETE_AUTO_SNAPSHOT:
+			en, err := strconv.ParseBool(v)
+			if err != nil {
+				return params, fmt.Errorf("invalid %s: %w", DELETE_AUTO_SNAPSHOT, err)
+			}
+			params.DeleteAutoSnapshot = &en
+		case DELETE_WITH_INSTANCE:
+			en, err := strconv.ParseBool(v)
+			if err != nil {
+				return params, fmt.Errorf("invalid %s: %w", DELETE_WITH_INSTANCE, err)
+			}
+			params.DeleteWithInstance = &en
+		case utils.ReadIOPSKey, utils.WriteIOPSKey, utils.ReadBPSKey, utils.WriteBPSKey:
+			if err := utils.ValidateIOLimit(k, v); err != nil {
+				return params, err
+			}
+			if params.IOLimits == nil {
+				params.IOLimits = map[string]string{}
+			}
+			params.IOLimits[k] = v
 		default:
 			switch {
 			case strings.HasPrefix(k, DISK_TAG_PREFIX):
@@ -795,6 +871,9 @@ func importMutableParameters(params *diskVolumeArgs, mutable *ModifyParameters)
 	if mutable.BurstingEnabled != nil {
 		params.BurstingEnabled = *mutable.BurstingEnabled
 	}
+	if mutable.MultiAttach != nil {
+		params.MultiAttach = *mutable.MultiAttach
+	}
 	for _, tag := range mutable.RemoveTags {
 		delete(params.DiskTags, *tag)
 	}
@@ -803,32 +882,45 @@ func importMutableParameters(params *diskVolumeArgs, mutable *ModifyParameters)
 	}
 }
 
-func appendDiskTypes(resp *ecs20140526.DescribeAvailableResourceResponse, zoneID string, types []string) []string {
-	if resp == nil || resp.Body == nil || resp.Body.AvailableZones == nil {
-		return types
-	}
-	for _, zone := range resp.Body.AvailableZones.AvailableZone {
-		if zone == nil || zone.AvailableResources == nil {
-			continue
-		}
-		if ptr.Deref(zone.ZoneId, "") != zoneID {
-			continue
+type supportedDiskResource = ecs20140526.DescribeAvailableResourceResponseBodyAvailableZonesAvailableZoneAvailableResourcesAvailableResourceSupportedResourcesSupportedResource
+
+// supportedDiskResources yields the disk categories in resp supported in zoneID.
+func supportedDiskResources(resp *ecs20140526.DescribeAvailableResourceResponse, zoneID string) iter.Seq[*supportedDiskResource] {
+	return func(yield func(*supportedDiskResource) bool) {
+		if resp == nil || resp.Body == nil || resp.Body.AvailableZones == nil {
+			return
 		}
-		for _, resource := range zone.AvailableResources.AvailableResource {
-			if resource == nil || resource.SupportedResources == nil {
+		for _, zone := range resp.Body.AvailableZones.AvailableZone {
+			if zone == nil || zone.AvailableResources == nil {
 				continue
 			}
-			if ptr.Deref(resource.Type, "") != describeResourceType {
+			if ptr.Deref(zone.ZoneId, "") != zoneID {
 				continue
 			}
-			for _, supported := range resource.SupportedResources.SupportedResource {
-				if supported == nil || supported.Value == nil {
+			for _, resource := range zone.AvailableResources.AvailableResource {
+				if resource == nil || resource.SupportedResources == nil {
 					continue
 				}
-				types = append(types, *supported.Value)
+				if ptr.Deref(resource.Type, "") != describeResourceType {
+					continue
+				}
+				for _, supported := range resource.SupportedResources.SupportedResource {
+					if supported == nil || supported.Value == nil {
+						continue
+					}
+					if !yield(supported) {
+						return
+					}
+				}
 			}
 		}
 	}
+}
+
+func appendDiskTypes(resp *ecs20140526.DescribeAvailableResourceResponse, zoneID string, types []string) []string {
+	for supported := range supportedDiskResources(resp, zoneID) {
+		types = append(types, *supported.Value)
+	}
 	return types
 }
 
@@ -979,29 +1071,44 @@ func diskTypeMetadata(category Category, performanceLevel PerformanceLevel) (lab
 	return label, string(diskTypeTopoBytes)
 }
 
-func parseSnapshotID(req *csi.CreateVolumeRequest) (string, error) {
+// parseVolumeSource returns the snapshot or the source disk the new disk should be created from.
+// At most one of them is non-empty.
+func parseVolumeSource(req *csi.CreateVolumeRequest) (snapshotID, sourceVolumeID string, err error) {
 	volumeSource := req.GetVolumeContentSource()
 	if volumeSource != nil {
-		sourceSnapshot := volumeSource.GetSnapshot()
-		if sourceSnapshot == nil {
-			return "", fmt.Errorf("CreateVolume: unsupported volumeContentSource type: %T", volumeSource.Type)
+		switch source := volumeSource.Type.(type) {
+		case *csi.VolumeContentSource_Snapshot:
+			return source.Snapshot.GetSnapshotId(), "", nil
+		case *csi.VolumeContentSource_Volume:
+			return "", source.Volume.GetVolumeId(), nil
+		default:
+			return "", "", fmt.Errorf("CreateVolume: unsupported volumeContentSource type: %T", volumeSource.Type)
 		}
-		return sourceSnapshot.GetSnapshotId(), nil
 	}
 	// set snapshotID if pvc labels/annotation set it.
-	return req.Parameters[DiskSnapshotID], nil
+	return req.Parameters[DiskSnapshotID], "", nil
 }
 
-func volumeContentSource(snapshotID string) *csi.VolumeContentSource {
-	if snapshotID == "" {
-		return nil
-	}
-	return &csi.VolumeContentSource{
-		Type: &csi.VolumeContentSource_Snapshot{
-			Snapshot: &csi.VolumeContentSource_SnapshotSource{
-				SnapshotId: snapshotID,
+func volumeContentSource(snapshotID, sourceVolumeID string) *csi.VolumeContentSource {
+	switch {
+	case sourceVolumeID != "":
+		return &csi.VolumeContentSource{
+			Type: &csi.VolumeContentSource_Volume{
+				Volume: &csi.VolumeContentSource_VolumeSource{
+					VolumeId: sourceVolumeID,
+				},
 			},
-		},
+		}
+	case snapshotID != "":
+		return &csi.VolumeContentSource{
+			Type: &csi.VolumeContentSource_Snapshot{
+				Snapshot: &csi.VolumeContentSource_SnapshotSource{
+					SnapshotId: snapshotID,
+				},
+			},
+		}
+	default:
+		return nil
 	}
 }
 
@@ -1033,7 +1140,7 @@ func staticVolumeCreate(req *csi.CreateVolumeRequest) (*csi.Volume, error) {
 	}
 
 	// Set VolumeContentSource
-	snapshotID, err := parseSnapshotID(req)
+	snapshotID, sourceVolumeID, err := parseVolumeSource(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1048,7 +1155,7 @@ func staticVolumeCreate(req *csi.CreateVolumeRequest) (*csi.Volume, error) {
 		Category(disk.Category), PerformanceLevel(disk.PerformanceLevel),
 		"", // no instanceID for virtual-kubelet.
 	}
-	return volumeCreate(attempt, diskID, volSizeBytes, volumeContext, disk.ZoneId, nil, volumeContentSource(snapshotID)), nil
+	return volumeCreate(attempt, diskID, volSizeBytes, volumeContext, disk.ZoneId, nil, volumeContentSource(snapshotID, sourceVolumeID)), nil
 }
 
 // updateVolumeContext remove unnecessary volume context
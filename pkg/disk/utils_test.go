@@ -1019,3 +1019,42 @@ func TestDiskTypeMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestParseVolumeSource(t *testing.T) {
+	cases := []struct {
+		name           string
+		req            *csi.CreateVolumeRequest
+		snapshotID     string
+		sourceVolumeID string
+	}{
+		{
+			name: "empty",
+			req:  &csi.CreateVolumeRequest{},
+		},
+		{
+			name:       "snapshot",
+			req:        &csi.CreateVolumeRequest{VolumeContentSource: volumeContentSource("s-123", "")},
+			snapshotID: "s-123",
+		},
+		{
+			name:           "volume",
+			req:            &csi.CreateVolumeRequest{VolumeContentSource: volumeContentSource("", "d-123")},
+			sourceVolumeID: "d-123",
+		},
+		{
+			name: "snapshot from parameters",
+			req: &csi.CreateVolumeRequest{Parameters: map[string]string{
+				DiskSnapshotID: "s-456",
+			}},
+			snapshotID: "s-456",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			snapshotID, sourceVolumeID, err := parseVolumeSource(c.req)
+			assert.NoError(t, err)
+			assert.Equal(t, c.snapshotID, snapshotID)
+			assert.Equal(t, c.sourceVolumeID, sourceVolumeID)
+		})
+	}
+}
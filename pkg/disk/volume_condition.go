@@ -0,0 +1,202 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	storagev1 "k8s.io/api/storage/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Disk status returned in ecs.DescribeDisks that should be reported as abnormal volume condition
+var abnormalDiskStatus = sets.New("ReIniting", "Expiring")
+
+// instanceEventsCacheTTL is the default monitor interval of external-health-monitor,
+// so that the events of an instance are described once per poll for all the disks attached to it.
+const instanceEventsCacheTTL = time.Minute
+
+// ControllerGetVolume reports the disk status, used by external-health-monitor
+func (cs *controllerServer) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
+	if _, diskIDs, ok := parseStripeVolumeID(req.VolumeId); ok {
+		return cs.getStripedVolume(ctx, req.VolumeId, diskIDs)
+	}
+	if sharedDiskID, _, ok := parseSubpathVolumeID(req.VolumeId); ok {
+		return cs.getSubpathVolume(ctx, req.VolumeId, sharedDiskID)
+	}
+	disk, err := cs.cd.batcher.Describe(ctx, req.VolumeId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "describe disk %s failed: %v", req.VolumeId, err)
+	}
+	if disk == nil {
+		return nil, status.Errorf(codes.NotFound, "disk %s not found", req.VolumeId)
+	}
+
+	nodeIDs := attachedInstances(disk)
+	return &csi.ControllerGetVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      disk.DiskId,
+			CapacityBytes: utils.Gi2Bytes(int64(disk.Size)),
+		},
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			PublishedNodeIds: nodeIDs,
+			VolumeCondition:  cs.diskCondition(ctx, disk, nodeIDs),
+		},
+	}, nil
+}
+
+func (cs *controllerServer) diskCondition(ctx context.Context, disk *ecs.Disk, attached []string) *csi.VolumeCondition {
+	logger := klog.FromContext(ctx)
+
+	problems := diskStatusProblems(disk)
+	if disk.Status != DiskStatusAttaching && disk.Status != DiskStatusDetaching {
+		expected, err := cs.expectedInstances(ctx, disk)
+		if err != nil {
+			logger.Error(err, "failed to get expected attachments")
+		} else {
+			problems = append(problems, attachmentProblems(attached, expected)...)
+		}
+	}
+	for _, instanceID := range attached {
+		eventMaps, err := cs.instanceEvents.Get(ctx, instanceID, func() (map[string]string, error) {
+			return DescribeDiskInstanceEvents(instanceID, cs.ecs)
+		})
+		if err != nil {
+			continue
+		}
+		if events := fatalInstanceEvents(eventMaps); len(events) > 0 {
+			problems = append(problems, fmt.Sprintf("instance %s has events %v", instanceID, events))
+		}
+	}
+
+	if len(problems) == 0 {
+		return &csi.VolumeCondition{Abnormal: false, Message: "disk is healthy"}
+	}
+	return &csi.VolumeCondition{Abnormal: true, Message: strings.Join(problems, "; ")}
+}
+
+func diskStatusProblems(disk *ecs.Disk) []string {
+	var problems []string
+	if abnormalDiskStatus.Has(disk.Status) {
+		problems = append(problems, fmt.Sprintf("disk is %s", disk.Status))
+	}
+	for _, lock := range disk.OperationLocks.OperationLock {
+		problems = append(problems, fmt.Sprintf("disk is locked for %s", lock.LockReason))
+	}
+	return problems
+}
+
+// attachmentProblems compares the instances the disk is attached to with the expected ones.
+// expected == nil means we don't know which instances the disk should be attached to.
+func attachmentProblems(attached []string, expected sets.Set[string]) []string {
+	if expected == nil {
+		return nil
+	}
+	var problems []string
+	for _, instanceID := range sets.List(expected) {
+		if !slices.Contains(attached, instanceID) {
+			problems = append(problems, fmt.Sprintf("disk is detached unexpectedly from instance %s", instanceID))
+		}
+	}
+	if expected.Len() > 0 {
+		for _, instanceID := range attached {
+			if !expected.Has(instanceID) {
+				problems = append(problems, fmt.Sprintf("disk is attached to unexpected instance %s", instanceID))
+			}
+		}
+	}
+	return problems
+}
+
+// expectedInstances returns the ECS instances the disk should be attached to, according to VolumeAttachments.
+// Returns nil if the PV of this disk is unknown.
+func (cs *controllerServer) expectedInstances(ctx context.Context, disk *ecs.Disk) (sets.Set[string], error) {
+	_, pvName := utils.HasSpecificTagKey(common.VolumeNameTag, disk)
+	if pvName == "" || cs.attachments == nil {
+		return nil, nil
+	}
+	return cs.attachments.expectedInstances(ctx, pvName)
+}
+
+// vaPVIndex indexes the VolumeAttachments by the name of their PV
+const vaPVIndex = "pv"
+
+// volumeAttachments looks up the VolumeAttachments of PVs and the ECS instances of the nodes,
+// from informers started on the first lookup.
+type volumeAttachments struct {
+	clientSet kubernetes.Interface
+
+	informerOnce sync.Once
+	vas          cache.Indexer
+	csiNodes     storagelisters.CSINodeLister
+	synced       []cache.InformerSynced
+}
+
+func newVolumeAttachments(clientSet kubernetes.Interface) *volumeAttachments {
+	if clientSet == nil {
+		return nil
+	}
+	return &volumeAttachments{clientSet: clientSet}
+}
+
+func (a *volumeAttachments) start() {
+	factory := informers.NewSharedInformerFactory(a.clientSet, 0)
+	vaInformer := factory.Storage().V1().VolumeAttachments().Informer()
+	// only fails once the informer is started
+	utilruntime.Must(vaInformer.AddIndexers(cache.Indexers{vaPVIndex: func(obj any) ([]string, error) {
+		va := obj.(*storagev1.VolumeAttachment)
+		if va.Spec.Attacher != DriverName || va.Spec.Source.PersistentVolumeName == nil {
+			return nil, nil
+		}
+		return []string{*va.Spec.Source.PersistentVolumeName}, nil
+	}}))
+	csiNodes := factory.Storage().V1().CSINodes()
+	a.vas = vaInformer.GetIndexer()
+	a.csiNodes = csiNodes.Lister()
+	a.synced = []cache.InformerSynced{vaInformer.HasSynced, csiNodes.Informer().HasSynced}
+	factory.Start(wait.NeverStop)
+}
+
+func (a *volumeAttachments) expectedInstances(ctx context.Context, pvName string) (sets.Set[string], error) {
+	a.informerOnce.Do(a.start)
+	if !cache.WaitForCacheSync(ctx.Done(), a.synced...) {
+		return nil, fmt.Errorf("VolumeAttachment informer not synced: %w", ctx.Err())
+	}
+	objs, err := a.vas.ByIndex(vaPVIndex, pvName)
+	if err != nil {
+		return nil, err
+	}
+	expected := sets.New[string]()
+	for _, obj := range objs {
+		va := obj.(*storagev1.VolumeAttachment)
+		if va.DeletionTimestamp != nil || !va.Status.Attached {
+			continue
+		}
+		csiNode, err := a.csiNodes.Get(va.Spec.NodeName)
+		if err != nil {
+			return nil, fmt.Errorf("get CSINode %s: %w", va.Spec.NodeName, err)
+		}
+		for _, d := range csiNode.Spec.Drivers {
+			if d.Name == DriverName {
+				expected.Insert(d.NodeID)
+			}
+		}
+	}
+	return expected, nil
+}
@@ -0,0 +1,160 @@
+//go:build !windows
+
+package disk
+
+import (
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/ecs"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/ttlcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2/ktesting"
+	"k8s.io/utils/ptr"
+)
+
+func TestAttachmentProblems(t *testing.T) {
+	cases := []struct {
+		name     string
+		attached []string
+		expected sets.Set[string]
+		problems []string
+	}{
+		{
+			name:     "unknown",
+			attached: []string{"i-1"},
+		},
+		{
+			name:     "match",
+			attached: []string{"i-1"},
+			expected: sets.New("i-1"),
+		},
+		{
+			name:     "not published",
+			attached: []string{"i-1"},
+			expected: sets.New[string](),
+		},
+		{
+			name:     "detached",
+			expected: sets.New("i-1"),
+			problems: []string{"disk is detached unexpectedly from instance i-1"},
+		},
+		{
+			name:     "wrong instance",
+			attached: []string{"i-2"},
+			expected: sets.New("i-1"),
+			problems: []string{
+				"disk is detached unexpectedly from instance i-1",
+				"disk is attached to unexpected instance i-2",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.problems, attachmentProblems(c.attached, c.expected))
+		})
+	}
+}
+
+func TestDiskStatusProblems(t *testing.T) {
+	assert.Empty(t, diskStatusProblems(&ecs.Disk{Status: DiskStatusInuse}))
+	assert.Equal(t, []string{"disk is ReIniting"}, diskStatusProblems(&ecs.Disk{Status: "ReIniting"}))
+
+	locked := &ecs.Disk{Status: DiskStatusInuse}
+	locked.OperationLocks.OperationLock = []ecs.OperationLock{{LockReason: "financial"}}
+	assert.Equal(t, []string{"disk is locked for financial"}, diskStatusProblems(locked))
+}
+
+func TestControllerGetVolume(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	ctrl := gomock.NewController(t)
+	c := cloud.NewMockECSInterface(ctrl)
+	c.EXPECT().DescribeInstanceHistoryEvents(gomock.Any()).Return(&ecs.DescribeInstanceHistoryEventsResponse{}, nil)
+
+	d := &ecs.Disk{DiskId: "d-1", Size: 20, Status: DiskStatusInuse}
+	d.Attachments.Attachment = []ecs.Attachment{{InstanceId: "i-2"}}
+	d.Tags.Tag = []ecs.Tag{{TagKey: common.VolumeNameTag, TagValue: "pv-1"}}
+
+	client := fake.NewSimpleClientset(
+		&storagev1.VolumeAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: "va-1"},
+			Spec: storagev1.VolumeAttachmentSpec{
+				Attacher: DriverName,
+				NodeName: "node-1",
+				Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: ptr.To("pv-1")},
+			},
+			Status: storagev1.VolumeAttachmentStatus{Attached: true},
+		},
+		&storagev1.CSINode{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Spec: storagev1.CSINodeSpec{
+				Drivers: []storagev1.CSINodeDriver{{Name: DriverName, NodeID: "i-1"}},
+			},
+		},
+	)
+	cs := &controllerServer{
+		ecs:            c,
+		clientSet:      client,
+		cd:             DiskCreateDelete{batcher: &fakeBatcher{disk: d}},
+		attachments:    newVolumeAttachments(client),
+		instanceEvents: ttlcache.NewTTLCache[string, map[string]string](instanceEventsCacheTTL),
+	}
+
+	// the instance events are described once per poll interval
+	for range 2 {
+		resp, err := cs.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: "d-1"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(20*GBSIZE), resp.Volume.CapacityBytes)
+		assert.Equal(t, []string{"i-2"}, resp.Status.PublishedNodeIds)
+		assert.True(t, resp.Status.VolumeCondition.Abnormal)
+		assert.Equal(t, "disk is detached unexpectedly from instance i-1; disk is attached to unexpected instance i-2", resp.Status.VolumeCondition.Message)
+	}
+}
+
+func TestVolumeAttachmentsExpectedInstances(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	va := func(name, pv, node, attacher string, attached bool) *storagev1.VolumeAttachment {
+		return &storagev1.VolumeAttachment{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: storagev1.VolumeAttachmentSpec{
+				Attacher: attacher,
+				NodeName: node,
+				Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: ptr.To(pv)},
+			},
+			Status: storagev1.VolumeAttachmentStatus{Attached: attached},
+		}
+	}
+	csiNode := func(name, instanceID string) *storagev1.CSINode {
+		return &storagev1.CSINode{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: storagev1.CSINodeSpec{
+				Drivers: []storagev1.CSINodeDriver{{Name: DriverName, NodeID: instanceID}},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		va("va-1", "pv-1", "node-1", DriverName, true),
+		va("va-2", "pv-1", "node-2", DriverName, false),
+		va("va-3", "pv-1", "node-3", "other.csi.example.com", true),
+		va("va-4", "pv-2", "node-2", DriverName, true),
+		csiNode("node-1", "i-1"),
+		csiNode("node-2", "i-2"),
+	)
+	a := newVolumeAttachments(client)
+
+	expected, err := a.expectedInstances(ctx, "pv-1")
+	require.NoError(t, err)
+	assert.Equal(t, sets.New("i-1"), expected)
+	expected, err = a.expectedInstances(ctx, "pv-3")
+	require.NoError(t, err)
+	assert.Empty(t, expected)
+	assert.NotNil(t, expected)
+}
@@ -72,6 +72,18 @@ const (
 	// instance type does not support high density incur no extra call. When disabled,
 	// the behavior is byte-identical to before (no extra API call).
 	DiskHighDensityMode featuregate.Feature = "DiskHighDensityMode"
+
+	// Expand disk PVCs automatically when the usage crosses the threshold of their auto expansion policy.
+	// The policy is opt-in per StorageClass or PVC, see docs/disk-resizer.md.
+	//
+	// Enable this at node. The node plugin needs to get PVs and patch PVCs.
+	DiskAutoExpand featuregate.Feature = "DiskAutoExpand"
+
+	// Remount OSS volumes whose fuse pod crashed or was evicted, see docs/oss.md.
+	// The node plugin recreates the fuse pod, so it needs to create pods in the fuse pod namespace.
+	//
+	// Enable this at node. Only the volumes published after the node plugin started are recovered.
+	OSSFuseRecovery featuregate.Feature = "OSSFuseRecovery"
 )
 
 var (
@@ -84,11 +96,13 @@ var (
 		EnableVolumeGroupSnapshots: {Default: false, PreRelease: featuregate.Alpha},
 		EnableDeleteAutoSnapshots:  {Default: false, PreRelease: featuregate.Alpha},
 		DiskHighDensityMode:        {Default: false, PreRelease: featuregate.Alpha},
+		DiskAutoExpand:             {Default: false, PreRelease: featuregate.Alpha},
 	}
 
 	defaultOSSFeatureGate = map[featuregate.Feature]featuregate.FeatureSpec{
 		UpdatedOssfsVersion:      {Default: true, PreRelease: featuregate.Beta},
 		ConstrainFusePodDeleteRV: {Default: true, PreRelease: featuregate.Beta},
+		OSSFuseRecovery:          {Default: false, PreRelease: featuregate.Alpha},
 	}
 
 	defaultNasFeatureGate = map[featuregate.Feature]featuregate.FeatureSpec{
@@ -0,0 +1,153 @@
+//go:build !windows
+
+package metric
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/datacache"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/options"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+var datacacheLabelNames = []string{"namespace", "pvc", "pv"}
+
+func datacacheMetricDesc(name, help string, extraLabels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName(nodeNamespace, volumeSubsystem, "datacache_"+name),
+		help,
+		slices.Concat(datacacheLabelNames, extraLabels), diskStatConstLabels,
+	)
+}
+
+// stats from the dm-cache status of volumes staged with dataCacheSize
+var (
+	datacacheReadHitsDesc    = datacacheMetricDesc("read_hits_total", "The total number of reads served by the cache.")
+	datacacheReadMissesDesc  = datacacheMetricDesc("read_misses_total", "The total number of reads served by the origin disk.")
+	datacacheWriteHitsDesc   = datacacheMetricDesc("write_hits_total", "The total number of writes to blocks in the cache.")
+	datacacheWriteMissesDesc = datacacheMetricDesc("write_misses_total", "The total number of writes to blocks not in the cache.")
+	datacachePromotionsDesc  = datacacheMetricDesc("promotions_total", "The total number of blocks promoted to the cache.")
+	datacacheDemotionsDesc   = datacacheMetricDesc("demotions_total", "The total number of blocks demoted from the cache.")
+	datacacheDirtyDesc       = datacacheMetricDesc("dirty_blocks", "The number of blocks in the cache not yet written back to the origin disk.")
+	datacacheUsedDesc        = datacacheMetricDesc("used_blocks", "The number of blocks in use in the cache.")
+	datacacheTotalDesc       = datacacheMetricDesc("total_blocks", "The number of blocks of the cache.")
+	datacacheBlockSizeDesc   = datacacheMetricDesc("block_size_bytes", "The size of a cache block in bytes.")
+	datacacheModeDesc        = datacacheMetricDesc("mode", "The current mode of the cache, writeback, writethrough or cleaner (flushing).", "mode")
+)
+
+type datacacheVolume struct {
+	DiskID string
+	PVCRef *v1.ObjectReference
+}
+
+type diskDatacacheCollector struct {
+	clientSet kubernetes.Interface
+	getStats  func(volumeID string) (*datacache.Stats, error)
+
+	mu sync.Mutex
+	// cache-backed volumes, by PV name
+	volumes map[string]datacacheVolume
+}
+
+func init() {
+	registerCollector("disk_datacache", NewDiskDatacacheCollector, diskDriverName)
+}
+
+// NewDiskDatacacheCollector returns a new Collector exposing dm-cache stats of disk volumes.
+func NewDiskDatacacheCollector() (Collector, error) {
+	config, err := options.GetRestConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	ctrl, err := datacache.OpenDmControl()
+	if err != nil {
+		return nil, err
+	}
+	return &diskDatacacheCollector{
+		clientSet: clientset,
+		getStats: func(volumeID string) (*datacache.Stats, error) {
+			return datacache.GetStats(klog.Background(), ctrl, volumeID)
+		},
+		volumes: map[string]datacacheVolume{},
+	}, nil
+}
+
+func (p *diskDatacacheCollector) Update(ctx context.Context, pvcs sets.Set[string], ch chan<- prometheus.Metric) error {
+	volJSONPaths, err := findVolJSON(podsRootPath)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	seen := sets.New[string]()
+	for _, path := range volJSONPaths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		pvName, diskID, err := getVolumeInfoByJSON(path, diskDriverName)
+		if err != nil {
+			continue
+		}
+		if seen.Has(pvName) {
+			continue // used by multiple pods
+		}
+		seen.Insert(pvName)
+
+		stats, err := p.getStats(diskID)
+		if err != nil {
+			klog.ErrorS(err, "Get datacache stats failed", "disk", diskID)
+			continue
+		}
+		if stats == nil {
+			continue // not cache-backed
+		}
+		vol, ok := p.volumes[pvName]
+		if !ok || vol.DiskID != diskID {
+			pvcRef, err := getDiskPvcByPvName(ctx, p.clientSet, pvName)
+			if err != nil {
+				klog.ErrorS(err, "Get PVC of PV failed", "pv", pvName)
+				continue
+			}
+			vol = datacacheVolume{DiskID: diskID, PVCRef: pvcRef}
+			p.volumes[pvName] = vol
+		}
+		if pvcs != nil && !pvcs.Has(vol.PVCRef.Name) {
+			continue
+		}
+		sendDatacacheStats(stats, []string{vol.PVCRef.Namespace, vol.PVCRef.Name, pvName}, ch)
+	}
+	for pvName := range p.volumes {
+		if !seen.Has(pvName) {
+			delete(p.volumes, pvName)
+		}
+	}
+	if len(p.volumes) == 0 {
+		return ErrNoData
+	}
+	return nil
+}
+
+func sendDatacacheStats(stats *datacache.Stats, labels []string, ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(datacacheReadHitsDesc, prometheus.CounterValue, float64(stats.ReadHits), labels...)
+	ch <- prometheus.MustNewConstMetric(datacacheReadMissesDesc, prometheus.CounterValue, float64(stats.ReadMisses), labels...)
+	ch <- prometheus.MustNewConstMetric(datacacheWriteHitsDesc, prometheus.CounterValue, float64(stats.WriteHits), labels...)
+	ch <- prometheus.MustNewConstMetric(datacacheWriteMissesDesc, prometheus.CounterValue, float64(stats.WriteMisses), labels...)
+	ch <- prometheus.MustNewConstMetric(datacachePromotionsDesc, prometheus.CounterValue, float64(stats.Promotions), labels...)
+	ch <- prometheus.MustNewConstMetric(datacacheDemotionsDesc, prometheus.CounterValue, float64(stats.Demotions), labels...)
+	ch <- prometheus.MustNewConstMetric(datacacheDirtyDesc, prometheus.GaugeValue, float64(stats.Dirty), labels...)
+	ch <- prometheus.MustNewConstMetric(datacacheUsedDesc, prometheus.GaugeValue, float64(stats.UsedBlocks), labels...)
+	ch <- prometheus.MustNewConstMetric(datacacheTotalDesc, prometheus.GaugeValue, float64(stats.TotalBlocks), labels...)
+	ch <- prometheus.MustNewConstMetric(datacacheBlockSizeDesc, prometheus.GaugeValue, float64(stats.BlockSize), labels...)
+	ch <- prometheus.MustNewConstMetric(datacacheModeDesc, prometheus.GaugeValue, 1, append(labels, stats.Mode)...)
+}
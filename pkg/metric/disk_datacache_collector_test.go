@@ -0,0 +1,105 @@
+//go:build !windows
+
+package metric
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/disk/datacache"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func writeVolData(t *testing.T, podUID, pvName, driverName, volumeHandle string) {
+	t.Helper()
+	dir := filepath.Join(podsRootPath, podUID, csiMountKeyWords, pvName)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	data := `{"driverName":"` + driverName + `","specVolID":"` + pvName + `","volumeHandle":"` + volumeHandle + `"}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, volDataFile), []byte(data), 0o644))
+}
+
+func boundPV(name, namespace, pvc string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			ClaimRef: &v1.ObjectReference{Namespace: namespace, Name: pvc},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: v1.VolumeBound},
+	}
+}
+
+func collectDatacache(t *testing.T, c *diskDatacacheCollector) (map[*prometheus.Desc]*dto.Metric, error) {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 100)
+	err := c.Update(context.Background(), nil, ch)
+	close(ch)
+	metrics := map[*prometheus.Desc]*dto.Metric{}
+	for m := range ch {
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		metrics[m.Desc()] = &out
+	}
+	return metrics, err
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestDiskDatacacheCollector(t *testing.T) {
+	podsRootPath = t.TempDir()
+	writeVolData(t, "pod-1", "pv-cached", diskDriverName, "d-cached")
+	writeVolData(t, "pod-2", "pv-cached", diskDriverName, "d-cached")
+	writeVolData(t, "pod-2", "pv-plain", diskDriverName, "d-plain")
+	writeVolData(t, "pod-3", "pv-nas", nasDriverName, "nas-1")
+
+	c := &diskDatacacheCollector{
+		clientSet: fake.NewClientset(boundPV("pv-cached", "default", "data-0"), boundPV("pv-plain", "default", "data-1")),
+		getStats: func(volumeID string) (*datacache.Stats, error) {
+			if volumeID != "d-cached" {
+				return nil, nil
+			}
+			return &datacache.Stats{
+				BlockSize:   256 << 10,
+				UsedBlocks:  9,
+				TotalBlocks: 256,
+				Dirty:       3,
+				ReadHits:    14,
+				Mode:        "writeback",
+			}, nil
+		},
+		volumes: map[string]datacacheVolume{},
+	}
+
+	metrics, err := collectDatacache(t, c)
+	require.NoError(t, err)
+	assert.Len(t, metrics, 11)
+	dirty := metrics[datacacheDirtyDesc]
+	assert.Equal(t, 3.0, dirty.GetGauge().GetValue())
+	assert.Equal(t, "data-0", labelValue(dirty, "pvc"))
+	assert.Equal(t, "pv-cached", labelValue(dirty, "pv"))
+	assert.Equal(t, "writeback", labelValue(metrics[datacacheModeDesc], "mode"))
+	assert.Equal(t, 14.0, metrics[datacacheReadHitsDesc].GetCounter().GetValue())
+	assert.Len(t, c.volumes, 1)
+	assert.Equal(t, "data-0", c.volumes["pv-cached"].PVCRef.Name)
+
+	// volume unstaged
+	require.NoError(t, os.RemoveAll(podsRootPath))
+	metrics, err = collectDatacache(t, c)
+	assert.ErrorIs(t, err, ErrNoData)
+	assert.Empty(t, metrics)
+	assert.Empty(t, c.volumes)
+}
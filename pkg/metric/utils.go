@@ -56,7 +56,7 @@ func readAllContent(path string) (string, error) {
 	return result, nil
 }
 
-func getDiskPvcByPvName(ctx context.Context, clientSet *kubernetes.Clientset, pvName string) (*apicorev1.ObjectReference, error) {
+func getDiskPvcByPvName(ctx context.Context, clientSet kubernetes.Interface, pvName string) (*apicorev1.ObjectReference, error) {
 	pv, err := clientSet.CoreV1().PersistentVolumes().Get(ctx, pvName, apismetav1.GetOptions{})
 	if err != nil {
 		return nil, err
@@ -207,6 +207,7 @@ type FusePodManager struct {
 	client                   kubernetes.Interface
 	constrainResourceVersion bool
 	FuseMounterType
+	shared *sharedRefs
 }
 
 func NewFusePodManager(fuseType FuseMounterType, client kubernetes.Interface, constrainResourceVersion bool) *FusePodManager {
@@ -214,6 +215,7 @@ func NewFusePodManager(fuseType FuseMounterType, client kubernetes.Interface, co
 		client:                   client,
 		constrainResourceVersion: constrainResourceVersion,
 		FuseMounterType:          fuseType,
+		shared:                   &sharedRefs{},
 	}
 }
 
@@ -237,6 +239,11 @@ func (fpm *FusePodManager) labelsAndListOptionsFor(c *FusePodContext, target str
 }
 
 func (fpm *FusePodManager) Create(c *FusePodContext, target string) (*corev1.Pod, error) {
+	return fpm.create(c, target, nil)
+}
+
+// create is Create with extraLabels set on the pod created, they do not select the existing pods.
+func (fpm *FusePodManager) create(c *FusePodContext, target string, extraLabels map[string]string) (*corev1.Pod, error) {
 	ctx, cancel := context.WithTimeout(c, fusePodManagerTimeout)
 	defer cancel()
 
@@ -291,6 +298,7 @@ func (fpm *FusePodManager) Create(c *FusePodContext, target string) (*corev1.Pod
 		} else {
 			maps.Copy(rawPod.Labels, labels)
 		}
+		maps.Copy(rawPod.Labels, extraLabels)
 		// make ack drain skip fuse pods
 		rawPod.Labels[ACKDrainLabelKey] = "skip"
 
@@ -432,6 +440,24 @@ func (fpm *FusePodManager) Delete(c *FusePodContext) error {
 	}
 }
 
+// List returns the fuse pods of the volume on the node.
+func (fpm *FusePodManager) List(c *FusePodContext) ([]corev1.Pod, error) {
+	_, listOptions := fpm.labelsAndListOptionsFor(c, "")
+	if fpm.constrainResourceVersion {
+		listOptions.ResourceVersion = "0"
+	}
+	pods, err := fpm.client.CoreV1().Pods(c.Namespace).List(c, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// IsFusePodReady reports whether the fuse pod is running and ready.
+func IsFusePodReady(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodRunning && isFusePodReady(pod)
+}
+
 func isFusePodReady(pod *corev1.Pod) bool {
 	for _, cond := range pod.Status.Conditions {
 		if cond.Type == corev1.PodReady {
@@ -114,9 +114,13 @@ type Options struct {
 	AuthType      string `json:"authType"`
 	FuseType      string `json:"fuseType"`
 	ReadOnly      bool   `json:"readOnly"`
+	// SharedFusePod makes RunC volumes of the same SharedFusePodHash share a fuse pod on a node
+	SharedFusePod bool `json:"sharedFusePod"`
 
 	// overlay options
 	Overlay bool `json:"overlay"`
+	// FdPassing lets mount-proxy-server keep the FUSE connection, so ossfs can be restarted without breaking the mount
+	FdPassing bool `json:"fdPassing"`
 
 	// pod template
 	DnsPolicy corev1.DNSPolicy `json:"dnsPolicy"`
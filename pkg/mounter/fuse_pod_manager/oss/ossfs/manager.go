@@ -343,7 +343,7 @@ func (f *fuseOssfs) getAuthOptions(o *ossfpm.Options, region string) (mountOptio
 			}
 		}
 	case ossfpm.AuthTypeCSS:
-		mountOptions = append(mountOptions, "secret_store_dir=/etc/ossfs/secrets-store")
+		mountOptions = append(mountOptions, mounterutils.OptSecretStoreDir+"=/etc/ossfs/secrets-store")
 	case ossfpm.AuthTypeSTS:
 		if o.RoleName != "" {
 			mountOptions = append(mountOptions, "ram_role="+o.RoleName)
@@ -351,7 +351,7 @@ func (f *fuseOssfs) getAuthOptions(o *ossfpm.Options, region string) (mountOptio
 	case ossfpm.AuthTypeAgentIdentity:
 		// Env vars are validated in PrecheckAuthConfig.
 		mountOptions = append(mountOptions, fmt.Sprintf("agent_identity_endpoint=%s", agentidentity.GetEndpoint()))
-		mountOptions = append(mountOptions, fmt.Sprintf("agent_identity_token_file=%s", agentidentity.GetTokenFilePath(o.SandboxId)))
+		mountOptions = append(mountOptions, fmt.Sprintf("%s=%s", mounterutils.OptAgentIdentityTokenFile, agentidentity.GetTokenFilePath(o.SandboxId)))
 		mountOptions = append(mountOptions, fmt.Sprintf("agent_identity_cred_provider=%s", o.SandboxCredProviderName))
 		// agent_identity_ca_file is not added here — it is optional and only appended
 		// by ApplyOptionDefaults if the file is readable. See AgentIdentityConfig for details.
@@ -364,7 +364,7 @@ func (f *fuseOssfs) getAuthOptions(o *ossfpm.Options, region string) (mountOptio
 
 		// secret volume for STS.Token
 		if o.SecretRef != "" {
-			mountOptions = append(mountOptions, fmt.Sprintf("passwd_file=%s", filepath.Join(mounterutils.GetConfigDir(o.FuseType), mounterutils.GetPasswdFileName(o.FuseType))))
+			mountOptions = append(mountOptions, fmt.Sprintf("%s=%s", mounterutils.OptPasswdFile, filepath.Join(mounterutils.GetConfigDir(o.FuseType), mounterutils.GetPasswdFileName(o.FuseType))))
 		}
 
 		// republish token retoate for STS.Token
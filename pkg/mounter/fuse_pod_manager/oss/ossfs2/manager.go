@@ -114,7 +114,7 @@ func (f *fuseOssfs) MakeAuthConfig(o *ossfpm.Options, m metadata.MetadataProvide
 		// fixed credentials
 		if o.AkID != "" && o.AkSecret != "" {
 			authCfg.Secrets = map[string]string{
-				mounterutils.GetPasswdFileName(f.Name()): fmt.Sprintf("--oss_access_key_id=%s\n--oss_access_key_secret=%s", o.AkID, o.AkSecret),
+				mounterutils.GetPasswdFileName(f.Name()): fmt.Sprintf("--%s=%s\n--%s=%s", mounterutils.OptOssAccessKeyID, o.AkID, mounterutils.OptOssAccessKeySecret, o.AkSecret),
 			}
 			return authCfg, nil
 		}
@@ -215,9 +215,9 @@ func (f *fuseOssfs) getAuthOptions(o *ossfpm.Options, region string) (mountOptio
 		// secret volume for STS.Token
 		if o.SecretRef != "" {
 			mountOptions = append(mountOptions,
-				fmt.Sprintf("oss_sts_multi_conf_ak_file=%s", filepath.Join(mounterutils.GetConfigDir(o.FuseType), mounterutils.GetPasswdFileName(o.FuseType), mounterutils.KeyAccessKeyId)),
-				fmt.Sprintf("oss_sts_multi_conf_sk_file=%s", filepath.Join(mounterutils.GetConfigDir(o.FuseType), mounterutils.GetPasswdFileName(o.FuseType), mounterutils.KeyAccessKeySecret)),
-				fmt.Sprintf("oss_sts_multi_conf_token_file=%s", filepath.Join(mounterutils.GetConfigDir(o.FuseType), mounterutils.GetPasswdFileName(o.FuseType), mounterutils.KeySecurityToken)),
+				fmt.Sprintf("%s=%s", mounterutils.OptOssStsAkFile, filepath.Join(mounterutils.GetConfigDir(o.FuseType), mounterutils.GetPasswdFileName(o.FuseType), mounterutils.KeyAccessKeyId)),
+				fmt.Sprintf("%s=%s", mounterutils.OptOssStsSkFile, filepath.Join(mounterutils.GetConfigDir(o.FuseType), mounterutils.GetPasswdFileName(o.FuseType), mounterutils.KeyAccessKeySecret)),
+				fmt.Sprintf("%s=%s", mounterutils.OptOssStsTokenFile, filepath.Join(mounterutils.GetConfigDir(o.FuseType), mounterutils.GetPasswdFileName(o.FuseType), mounterutils.KeySecurityToken)),
 			)
 		}
 
@@ -1,9 +1,13 @@
 package oss
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
+	"slices"
 	"strings"
 
 	"github.com/alibabacloud-go/tea/tea"
@@ -233,7 +237,34 @@ func AppendRRSAAuthOptions(m metadata.MetadataProvider, options []string, volume
 		options = append(options, fmt.Sprintf("rrsa_oidc_provider_arn=%s", authCfg.RrsaConfig.OidcProviderArn))
 		options = append(options, fmt.Sprintf("rrsa_role_arn=%s", authCfg.RrsaConfig.RoleArn))
 		options = append(options, fmt.Sprintf("rrsa_role_session_name=%s", sessionName))
-		options = append(options, fmt.Sprintf("rrsa_token_file=%s", tokenFile))
+		options = append(options, fmt.Sprintf("%s=%s", mounterutils.OptRrsaTokenFile, tokenFile))
 	}
 	return options, nil
 }
+
+// SharedFusePodHash returns the content hash of what a fuse pod serves: volumes of the same bucket, url, path,
+// credentials and mount options get the same hash, and can share a fuse pod on a node.
+func SharedFusePodHash(o *Options, authCfg *fpm.AuthConfig, ptCfg *fpm.PodTemplateConfig, mountOptions []string) string {
+	data, _ := json.Marshal(struct {
+		FuseType          string
+		URL               string
+		Bucket            string
+		Path              string
+		AuthConfig        *fpm.AuthConfig
+		PodTemplateConfig *fpm.PodTemplateConfig
+		MountOptions      []string
+		FdPassing         bool
+	}{
+		FuseType:          o.FuseType,
+		URL:               o.URL,
+		Bucket:            o.MountBucket(),
+		Path:              path.Clean("/" + o.Path),
+		AuthConfig:        authCfg,
+		PodTemplateConfig: ptCfg,
+		MountOptions:      slices.Sorted(slices.Values(mountOptions)),
+		FdPassing:         o.FdPassing,
+	})
+	sum := sha256.Sum256(data)
+	// short enough for a label value
+	return hex.EncodeToString(sum[:16])
+}
@@ -472,3 +472,18 @@ func TestResolveRegion(t *testing.T) {
 		})
 	}
 }
+
+func TestSharedFusePodHash(t *testing.T) {
+	opts := &Options{FuseType: "ossfs", Bucket: "bucket", URL: "oss-cn-beijing-internal.aliyuncs.com", Path: "/data"}
+	authCfg := &fpm.AuthConfig{Secrets: map[string]string{"akId": "ak", "akSecret": "sk"}}
+	ptCfg := &fpm.PodTemplateConfig{}
+	hash := SharedFusePodHash(opts, authCfg, ptCfg, []string{"allow_other", "ro"})
+	assert.Len(t, hash, 32)
+	assert.Equal(t, hash, SharedFusePodHash(&Options{FuseType: "ossfs", Bucket: "bucket", URL: "oss-cn-beijing-internal.aliyuncs.com", Path: "data/"},
+		authCfg, ptCfg, []string{"ro", "allow_other"}), "same path and options")
+	assert.NotEqual(t, hash, SharedFusePodHash(opts,
+		&fpm.AuthConfig{Secrets: map[string]string{"akId": "ak", "akSecret": "other"}}, ptCfg, []string{"allow_other", "ro"}), "different secrets")
+	assert.NotEqual(t, hash, SharedFusePodHash(opts, authCfg, ptCfg, []string{"allow_other"}), "different options")
+	assert.NotEqual(t, hash, SharedFusePodHash(&Options{FuseType: "ossfs", Bucket: "bucket", URL: "oss-cn-beijing-internal.aliyuncs.com", Path: "/data", FdPassing: true},
+		authCfg, ptCfg, []string{"allow_other", "ro"}), "different fd passing")
+}
@@ -0,0 +1,193 @@
+package fuse_pod_manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// FuseSharedHashLabelKey is set on fuse pods shared by all volumes of the same content hash on a node.
+	FuseSharedHashLabelKey = "csi.alibabacloud.com/shared-hash"
+	// FuseSharedRefLabelPrefix is followed by the volume-id label value of each volume using a shared fuse pod.
+	FuseSharedRefLabelPrefix = "ref.csi.alibabacloud.com/"
+)
+
+// SharedVolumeId returns the volume ID the shared fuse pod of the hash is created for,
+// which also decides its mount proxy socket and attach path.
+func SharedVolumeId(hash string) string {
+	return "shared-" + hash
+}
+
+func sharedRefLabelKey(volumeId string) string {
+	return FuseSharedRefLabelPrefix + mounterutils.ComputeVolumeIdLabelVal(volumeId)
+}
+
+// sharedRefs caches the references to the shared fuse pods on the node.
+// The labels of the pods are the source of truth, they are loaded again after csi-plugin restarts.
+type sharedRefs struct {
+	mu     sync.Mutex
+	loaded bool
+	// shared volume ID -> volume-id label values of the volumes using it
+	refs map[string]sets.Set[string]
+}
+
+// loadSharedRefs rebuilds the references from the labels of the shared fuse pods on the node.
+// The caller must hold fpm.shared.mu.
+func (fpm *FusePodManager) loadSharedRefs(c *FusePodContext) error {
+	if fpm.shared.loaded {
+		return nil
+	}
+	pods, err := fpm.client.CoreV1().Pods(c.Namespace).List(c, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", c.NodeName).String(),
+		// every fuse type has its own FusePodManager
+		LabelSelector: fmt.Sprintf("%s,%s=%s", FuseSharedHashLabelKey, FuseTypeLabelKey, fpm.Name()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list shared fuse pods: %w", err)
+	}
+	refs := map[string]sets.Set[string]{}
+	for _, pod := range pods.Items {
+		volumeId := SharedVolumeId(pod.Labels[FuseSharedHashLabelKey])
+		for key := range pod.Labels {
+			if ref, ok := strings.CutPrefix(key, FuseSharedRefLabelPrefix); ok {
+				if refs[volumeId] == nil {
+					refs[volumeId] = sets.New[string]()
+				}
+				refs[volumeId].Insert(ref)
+			}
+		}
+	}
+	klog.FromContext(c).V(2).Info("loaded references to shared fuse pods", "count", len(refs))
+	fpm.shared.refs = refs
+	fpm.shared.loaded = true
+	return nil
+}
+
+// AcquireShared waits for the shared fuse pod of the hash on the node to be ready, creating it if needed,
+// and records on the pod that c.VolumeId uses it.
+// The pod is created for SharedVolumeId(hash) with the other fields of c, and mounts target.
+func (fpm *FusePodManager) AcquireShared(c *FusePodContext, hash, target string) (*corev1.Pod, error) {
+	fpm.shared.mu.Lock()
+	defer fpm.shared.mu.Unlock()
+	if err := fpm.loadSharedRefs(c); err != nil {
+		return nil, err
+	}
+
+	sc := *c
+	sc.VolumeId = SharedVolumeId(hash)
+	pod, err := fpm.create(&sc, target, map[string]string{FuseSharedHashLabelKey: hash})
+	if err != nil {
+		return nil, err
+	}
+	ref := sharedRefLabelKey(c.VolumeId)
+	if _, ok := pod.Labels[ref]; !ok {
+		pod, err = fpm.patchLabels(c, pod.Name, map[string]*string{ref: new("true")})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add reference to fuse pod: %w", err)
+		}
+	}
+	if fpm.shared.refs[sc.VolumeId] == nil {
+		fpm.shared.refs[sc.VolumeId] = sets.New[string]()
+	}
+	fpm.shared.refs[sc.VolumeId].Insert(mounterutils.ComputeVolumeIdLabelVal(c.VolumeId))
+	klog.FromContext(c).V(2).Info("referenced shared fuse pod", "volumeId", c.VolumeId, "pod", pod.Name,
+		"references", fpm.shared.refs[sc.VolumeId].Len())
+	return pod, nil
+}
+
+// SharedVolumeIdOf returns the shared volume ID whose fuse pod c.VolumeId uses on the node, or "" if none.
+func (fpm *FusePodManager) SharedVolumeIdOf(c *FusePodContext) (string, error) {
+	fpm.shared.mu.Lock()
+	defer fpm.shared.mu.Unlock()
+	if err := fpm.loadSharedRefs(c); err != nil {
+		return "", err
+	}
+	ref := mounterutils.ComputeVolumeIdLabelVal(c.VolumeId)
+	for volumeId, refs := range fpm.shared.refs {
+		if refs.Has(ref) {
+			return volumeId, nil
+		}
+	}
+	return "", nil
+}
+
+// ReleaseShared removes the reference of c.VolumeId from the fuse pods of the shared volume on the node,
+// and returns the number of other volumes still using them.
+// The last reference is kept: when 0 is returned, the caller should unmount the shared volume and DeleteShared,
+// so that a failure in between is retried by releasing again.
+func (fpm *FusePodManager) ReleaseShared(c *FusePodContext, sharedVolumeId string) (int, error) {
+	fpm.shared.mu.Lock()
+	defer fpm.shared.mu.Unlock()
+	if err := fpm.loadSharedRefs(c); err != nil {
+		return 0, err
+	}
+
+	sc := *c
+	sc.VolumeId = sharedVolumeId
+	sc.FuseType = ""
+	_, listOptions := fpm.labelsAndListOptionsFor(&sc, "")
+	// always read the latest labels
+	pods, err := fpm.client.CoreV1().Pods(c.Namespace).List(c, listOptions)
+	if err != nil {
+		return 0, err
+	}
+	ref := sharedRefLabelKey(c.VolumeId)
+	others := sets.New[string]()
+	for _, pod := range pods.Items {
+		for key := range pod.Labels {
+			if r, ok := strings.CutPrefix(key, FuseSharedRefLabelPrefix); ok && key != ref {
+				others.Insert(r)
+			}
+		}
+	}
+	if others.Len() == 0 {
+		klog.FromContext(c).V(2).Info("last reference to shared fuse pod", "volumeId", c.VolumeId, "sharedVolumeId", sharedVolumeId)
+		return 0, nil
+	}
+	for _, pod := range pods.Items {
+		if _, ok := pod.Labels[ref]; ok {
+			if _, err := fpm.patchLabels(c, pod.Name, map[string]*string{ref: nil}); err != nil {
+				return 0, fmt.Errorf("failed to remove reference from fuse pod %s: %w", pod.Name, err)
+			}
+		}
+	}
+	fpm.shared.refs[sharedVolumeId] = others
+	klog.FromContext(c).V(2).Info("released shared fuse pod", "volumeId", c.VolumeId, "sharedVolumeId", sharedVolumeId,
+		"references", others.Len())
+	return others.Len(), nil
+}
+
+// DeleteShared deletes the fuse pods of the shared volume on the node, and the last reference with them.
+func (fpm *FusePodManager) DeleteShared(c *FusePodContext, sharedVolumeId string) error {
+	sc := *c
+	sc.VolumeId = sharedVolumeId
+	sc.FuseType = ""
+	if err := fpm.Delete(&sc); err != nil {
+		return err
+	}
+	fpm.shared.mu.Lock()
+	defer fpm.shared.mu.Unlock()
+	delete(fpm.shared.refs, sharedVolumeId)
+	return nil
+}
+
+// patchLabels sets the labels of the pod, or removes those whose value is nil.
+func (fpm *FusePodManager) patchLabels(c *FusePodContext, name string, labels map[string]*string) (*corev1.Pod, error) {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"labels": labels},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fpm.client.CoreV1().Pods(c.Namespace).Patch(c, name, types.MergePatchType, patch, metav1.PatchOptions{})
+}
@@ -0,0 +1,85 @@
+package fuse_pod_manager
+
+import (
+	"testing"
+
+	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2/ktesting"
+)
+
+func TestSharedFusePod(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	target := "/run/test-fuse/shared"
+	// the shared fuse pod is ready, so that Create does not wait for it
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test-fuse-pod",
+			Namespace:       "test-fuse",
+			ResourceVersion: "1",
+			Labels: map[string]string{
+				FuseVolumeIdLabelKey:      SharedVolumeId("hash"),
+				FuseTypeLabelKey:          "test",
+				FuseMountPathHashLabelKey: mounterutils.ComputeMountPathHash(target),
+				FuseSharedHashLabelKey:    "hash",
+			},
+			Annotations: map[string]string{FuseMountPathAnnoKey: target},
+		},
+		Spec: corev1.PodSpec{NodeName: "test-node"},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	})
+	podContext := func(volumeId string) *FusePodContext {
+		return &FusePodContext{
+			Context:   ctx,
+			Namespace: "test-fuse",
+			NodeName:  "test-node",
+			VolumeId:  volumeId,
+			FuseType:  "test",
+		}
+	}
+
+	fpm := NewFusePodManager(testFuse{}, client, false)
+	for _, volumeId := range []string{"pv-1", "pv-2"} {
+		pod, err := fpm.AcquireShared(podContext(volumeId), "hash", target)
+		require.NoError(t, err)
+		assert.Equal(t, "test-fuse-pod", pod.Name)
+	}
+	pod, err := client.CoreV1().Pods("test-fuse").Get(ctx, "test-fuse-pod", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", pod.Labels[FuseSharedRefLabelPrefix+"pv-1"])
+	assert.Equal(t, "true", pod.Labels[FuseSharedRefLabelPrefix+"pv-2"])
+
+	// csi-plugin restarted
+	fpm = NewFusePodManager(testFuse{}, client, false)
+	sharedVolumeId, err := fpm.SharedVolumeIdOf(podContext("pv-1"))
+	require.NoError(t, err)
+	assert.Equal(t, SharedVolumeId("hash"), sharedVolumeId)
+	sharedVolumeId, err = fpm.SharedVolumeIdOf(podContext("pv-3"))
+	require.NoError(t, err)
+	assert.Empty(t, sharedVolumeId)
+
+	remaining, err := fpm.ReleaseShared(podContext("pv-1"), SharedVolumeId("hash"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, remaining)
+	sharedVolumeId, err = fpm.SharedVolumeIdOf(podContext("pv-1"))
+	require.NoError(t, err)
+	assert.Empty(t, sharedVolumeId)
+
+	// the last reference is kept until the pod is deleted
+	remaining, err = fpm.ReleaseShared(podContext("pv-2"), SharedVolumeId("hash"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, remaining)
+	pod, err = client.CoreV1().Pods("test-fuse").Get(ctx, "test-fuse-pod", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, pod.Labels, FuseSharedRefLabelPrefix+"pv-2")
+	sharedVolumeId, err = fpm.SharedVolumeIdOf(podContext("pv-2"))
+	require.NoError(t, err)
+	assert.Equal(t, SharedVolumeId("hash"), sharedVolumeId)
+}
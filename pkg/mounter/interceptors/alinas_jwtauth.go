@@ -17,9 +17,9 @@ const (
 	// alinas mount options that carry the STS credential. The alinas client
 	// accepts the STS triple directly as mount options:
 	//   mount -t alinas -o tls,vers=3,ram,access_key_id=<AK>,access_key_secret=<SK>,security_token=<TOKEN> ...
-	optAlinasAccessKeyID     = "access_key_id"
-	optAlinasAccessKeySecret = "access_key_secret"
-	optAlinasSecurityToken   = "security_token"
+	optAlinasAccessKeyID     = mounterutils.OptAccessKeyID
+	optAlinasAccessKeySecret = mounterutils.OptAccessKeySecret
+	optAlinasSecurityToken   = mounterutils.OptSecurityToken
 	optAlinasTLS             = "tls"
 	optAlinasRAM             = "ram"
 )
@@ -141,3 +141,26 @@ func splitAlinasSTSOptions(flatOptions []string, cred *jwtauth.STSToken) (option
 	}
 	return options, sensitiveOptions
 }
+
+// RestartAlinasJWTAuthRefresher restarts the credential refresher of an alinas
+// mount that outlived mount-proxy-server, from the options it was mounted with.
+// Unlike the interceptor, the first credential is pushed through the sink right
+// away, since the mounted one may have expired in the meantime.
+//
+// For any other authType it is a no-op.
+func RestartAlinasJWTAuthRefresher(ctx context.Context, target string, options []string) error {
+	idx := mounterutils.IndexMountOptions(flattenMountOptions(options))
+	if !jwtauth.IsAgentIdentity(idx[jwtauth.OptAuthType]) {
+		return nil
+	}
+	opts := jwtauth.ResolveOpts(idx)
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("jwtauth config error: %w", err)
+	}
+	refresher := jwtauth.NewRefresher(opts, newAlinasCertRefreshSink(target))
+	if err := refresher.Start(ctx); err != nil {
+		return fmt.Errorf("jwtauth start credential refresher: %w", err)
+	}
+	jwtauth.DefaultManager.Add(target, refresher)
+	return nil
+}
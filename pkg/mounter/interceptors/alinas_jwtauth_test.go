@@ -344,3 +344,13 @@ func TestFlattenMountOptions(t *testing.T) {
 	out := flattenMountOptions(in)
 	assert.Equal(t, []string{"tls", "vers=3", "authType=agent-identity", "ro", "nolock"}, out)
 }
+
+func TestRestartAlinasJWTAuthRefresher(t *testing.T) {
+	const target = "/mnt/nas-restored"
+	require.NoError(t, RestartAlinasJWTAuthRefresher(context.Background(), target, []string{"tls,vers=3"}))
+	assert.False(t, jwtauth.DefaultManager.HasTarget(target), "no refresher for mounts without agent identity")
+
+	err := RestartAlinasJWTAuthRefresher(context.Background(), target, []string{"vers=3,authType=agent-identity"})
+	assert.ErrorContains(t, err, "jwtauth config error")
+	assert.False(t, jwtauth.DefaultManager.HasTarget(target))
+}
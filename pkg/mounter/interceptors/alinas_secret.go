@@ -7,6 +7,7 @@ import (
 	"path"
 
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter"
+	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
 	"k8s.io/klog/v2"
 )
 
@@ -42,7 +43,7 @@ func AlinasSecretInterceptor(ctx context.Context, op *mounter.MountOperation, ha
 	}
 
 	klog.V(4).InfoS("Created alinas credential file", "path", credFilePath)
-	op.Options = append(op.Options, "ram_config_file="+credFilePath)
+	op.Options = append(op.Options, mounterutils.OptRamConfigFile+"="+credFilePath)
 
 	return handler(ctx, op)
 }
@@ -3,9 +3,11 @@ package interceptors
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy/server"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 	"k8s.io/mount-utils"
 )
@@ -17,8 +19,16 @@ var (
 	monitorManager = server.NewMountMonitorManager()
 )
 
+// OssfsMountMonitors returns the monitors of the targets mounted through OssfsMonitorInterceptor.
+func OssfsMountMonitors() *server.MountMonitorManager {
+	return monitorManager
+}
+
 func OssfsMonitorInterceptor(ctx context.Context, op *mounter.MountOperation, handler mounter.MountHandler) error {
 	if op == nil || op.MetricsPath == "" {
+		if op != nil && op.FdPassing {
+			return superviseOnly(ctx, op, handler)
+		}
 		return handler(ctx, op)
 	}
 
@@ -26,6 +36,9 @@ func OssfsMonitorInterceptor(ctx context.Context, op *mounter.MountOperation, ha
 	monitor, found := monitorManager.GetMountMonitor(op.Target, op.MetricsPath, raw, true)
 	if monitor == nil {
 		klog.ErrorS(errors.New("failed to get mount monitor"), "stop monitoring mountpoint status", "mountpoint", op.Target)
+		if op.FdPassing {
+			return superviseOnly(ctx, op, handler)
+		}
 		return handler(ctx, op)
 	}
 	if found {
@@ -50,21 +63,91 @@ func OssfsMonitorInterceptor(ctx context.Context, op *mounter.MountOperation, ha
 		return err
 	}
 
-	go func() {
-		exitErr := <-res.ExitChan
-		// Assume the process exits with no error upon receiving SIGTERM,
-		// and exits with an error in case of unexpected failures.
-		if err == nil && exitErr != nil {
-			monitor.HandleMountFailureOrExit(exitErr)
-		}
-	}()
-
 	if err != nil {
 		return err
 	}
 
+	go supervise(op.Target, res, monitor)
+
 	monitor.HandleMountSuccess(res.PID)
 	// Start monitoring goroutine (ticker based only)
 	monitorManager.StartMonitoring(op.Target)
 	return nil
 }
+
+// superviseOnly restarts the fuse clients of a fd-passing mount that is not monitored.
+func superviseOnly(ctx context.Context, op *mounter.MountOperation, handler mounter.MountHandler) error {
+	err := handler(ctx, op)
+	if err != nil || op.MountResult == nil {
+		return err
+	}
+	res, ok := op.MountResult.(server.OssfsMountResult)
+	if !ok {
+		klog.ErrorS(errors.New("failed to assert ossfs mount result type"), "skipping supervision of mountpoint", "mountpoint", op.Target)
+		return nil
+	}
+	go supervise(op.Target, res, nil)
+	return nil
+}
+
+// restartBackoff spaces the restarts of a crashing fuse client, supervise gives up once its steps are used.
+// A client that served for restartResetPeriod is considered recovered, the backoff starts over.
+// They are variables so tests can shorten them.
+var (
+	restartBackoff     = wait.Backoff{Duration: time.Second, Factor: 2, Steps: 6, Cap: time.Minute}
+	restartResetPeriod = 10 * time.Minute
+)
+
+// supervise waits for the fuse client to exit, and reports unexpected exits to monitor if not nil.
+// The fuse client of a fd-passing mount is then restarted on the same FUSE connection with backoff,
+// so the mountpoint only stalls in the meantime, until it crashes too often and the mount is aborted.
+func supervise(target string, res server.OssfsMountResult, monitor *server.MountMonitor) {
+	backoff := restartBackoff
+	started := time.Now()
+	for {
+		// Assume the process exits with no error upon receiving SIGTERM,
+		// and exits with an error in case of unexpected failures.
+		exitErr := <-res.ExitChan
+		if exitErr == nil {
+			return
+		}
+		if res.Restart == nil {
+			if monitor != nil {
+				monitor.HandleMountFailureOrExit(exitErr)
+			}
+			return
+		}
+		if time.Since(started) >= restartResetPeriod {
+			backoff = restartBackoff
+		}
+		if backoff.Steps < 1 {
+			klog.ErrorS(exitErr, "Fuse client keeps crashing, aborting the FUSE connection", "mountpoint", target)
+			res.Abort()
+			if monitor != nil {
+				monitor.HandleMountFailureOrExit(exitErr)
+			}
+			return
+		}
+		delay := backoff.Step()
+		// Restart before reporting: the status check of the monitor holds its lock
+		// while it waits for the stalled mountpoint.
+		klog.InfoS("Restarting fuse client on the kept FUSE connection", "mountpoint", target, "exitError", exitErr, "delay", delay)
+		time.Sleep(delay)
+		next, err := res.Restart()
+		if monitor != nil {
+			monitor.HandleMountFailureOrExit(exitErr)
+		}
+		if err != nil {
+			klog.ErrorS(err, "Failed to restart fuse client", "mountpoint", target)
+			if monitor != nil {
+				monitor.HandleMountFailureOrExit(err)
+			}
+			return
+		}
+		if monitor != nil {
+			monitor.HandleFailover(next.PID)
+		}
+		res = next
+		started = time.Now()
+	}
+}
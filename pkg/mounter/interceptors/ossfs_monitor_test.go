@@ -2,14 +2,17 @@ package interceptors
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy/server"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 func TestOssfsMonitorInterceptor(t *testing.T) {
@@ -105,6 +108,80 @@ func TestOssfsMonitorInterceptor(t *testing.T) {
 	assertMountMetricValue(t, op.MetricsPath, utils.MetricsMountRetryCount, "1")
 }
 
+func fastRestarts(t *testing.T, steps int) {
+	orig := restartBackoff
+	t.Cleanup(func() { restartBackoff = orig })
+	restartBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: steps}
+}
+
+func TestOssfsMonitorInterceptor_RestartsFdPassingClient(t *testing.T) {
+	fastRestarts(t, 6)
+	metricsDir := t.TempDir()
+	monitorManager = server.NewMountMonitorManager()
+	defer func() {
+		monitorManager.StopAllMonitoring()
+		monitorManager.WaitForAllMonitoring()
+	}()
+
+	firstExit, secondExit := make(chan error, 1), make(chan error, 1)
+	restarted := make(chan struct{})
+	op := &mounter.MountOperation{
+		Target:      "volume-fd-passing",
+		MetricsPath: metricsDir,
+		FdPassing:   true,
+		MountResult: server.OssfsMountResult{
+			PID:      123,
+			ExitChan: firstExit,
+			Restart: func() (server.OssfsMountResult, error) {
+				defer close(restarted)
+				return server.OssfsMountResult{PID: 456, ExitChan: secondExit}, nil
+			},
+		},
+	}
+	err := OssfsMonitorInterceptor(context.Background(), op, successMountHandler)
+	assert.NoError(t, err)
+
+	firstExit <- errors.New("ossfs crashed")
+	<-restarted
+	// The exit is reported after the restart, then the failover marks the mountpoint healthy again
+	assert.Eventually(t, func() bool {
+		failover, _ := os.ReadFile(filepath.Join(metricsDir, utils.MetricsMountPointFailoverCount))
+		status, _ := os.ReadFile(filepath.Join(metricsDir, utils.MetricsMountPointStatus))
+		return string(failover) == "1" && string(status) == "0"
+	}, time.Second, 10*time.Millisecond)
+	reason, err := os.ReadFile(filepath.Join(metricsDir, utils.MetricsLastFuseClientExitReason))
+	assert.NoError(t, err)
+	assert.Contains(t, string(reason), "ossfs crashed")
+	secondExit <- nil
+}
+
+func TestSupervise_GivesUpOnCrashLoop(t *testing.T) {
+	fastRestarts(t, 2)
+	restarts := 0
+	aborted := make(chan struct{})
+	var crashed func() server.OssfsMountResult
+	crashed = func() server.OssfsMountResult {
+		exit := make(chan error, 1)
+		exit <- errors.New("ossfs2 crashed")
+		return server.OssfsMountResult{
+			ExitChan: exit,
+			Restart: func() (server.OssfsMountResult, error) {
+				restarts++
+				return crashed(), nil
+			},
+			Abort: func() { close(aborted) },
+		}
+	}
+
+	supervise("volume-crash-loop", crashed(), nil)
+	assert.Equal(t, 2, restarts)
+	select {
+	case <-aborted:
+	default:
+		t.Fatal("the FUSE connection should be aborted")
+	}
+}
+
 func assertMountMetricValue(t *testing.T, metricsDir, metricsFile string, expected string) {
 	actual, err := os.ReadFile(filepath.Join(metricsDir, metricsFile))
 	assert.NoError(t, err)
@@ -72,7 +72,7 @@ func ossfsSecretInterceptorWithMounter(ctx context.Context, op *mounter.MountOpe
 	if passwdFile != "" {
 		klog.V(4).InfoS("created ossfs passwd file", "path", passwdFile)
 		if fuseType == mounterutils.OssFsType {
-			op.Options = append(op.Options, "passwd_file="+passwdFile)
+			op.Options = append(op.Options, mounterutils.OptPasswdFile+"="+passwdFile)
 		} else {
 			// ossfs2
 			op.Args = append(op.Args, []string{"-c", passwdFile}...)
@@ -81,14 +81,14 @@ func ossfsSecretInterceptorWithMounter(ctx context.Context, op *mounter.MountOpe
 	if tokenDir != "" {
 		klog.V(4).InfoS("created ossfs token directory", "dir", tokenDir)
 		if fuseType == mounterutils.OssFsType {
-			op.Options = append(op.Options, "passwd_file="+tokenDir)
+			op.Options = append(op.Options, mounterutils.OptPasswdFile+"="+tokenDir)
 		} else {
 			// ossfs2
 			// For ossfs2, file-path is a common option configuration after -o, so append to op.Options
 			op.Options = append(op.Options,
-				fmt.Sprintf("oss_sts_multi_conf_ak_file=%s", getTokenFilePath(tokenDir, mounterutils.KeyAccessKeyId)),
-				fmt.Sprintf("oss_sts_multi_conf_sk_file=%s", getTokenFilePath(tokenDir, mounterutils.KeyAccessKeySecret)),
-				fmt.Sprintf("oss_sts_multi_conf_token_file=%s", getTokenFilePath(tokenDir, mounterutils.KeySecurityToken)),
+				fmt.Sprintf("%s=%s", mounterutils.OptOssStsAkFile, getTokenFilePath(tokenDir, mounterutils.KeyAccessKeyId)),
+				fmt.Sprintf("%s=%s", mounterutils.OptOssStsSkFile, getTokenFilePath(tokenDir, mounterutils.KeyAccessKeySecret)),
+				fmt.Sprintf("%s=%s", mounterutils.OptOssStsTokenFile, getTokenFilePath(tokenDir, mounterutils.KeySecurityToken)),
 			)
 		}
 	}
@@ -110,7 +110,12 @@ func ossfsSecretInterceptorWithMounter(ctx context.Context, op *mounter.MountOpe
 	}
 
 	go func() {
-		<-result.ExitChan
+		// The fuse client of a fd-passing mount is restarted with the same credential files
+		if result.Done != nil {
+			<-result.Done
+		} else {
+			<-result.ExitChan
+		}
 		// Clean up passwd file and all temporary files
 		cleanupPasswdFile(passwdFile)
 		// Clean up token directory (symlink, actual directory, and any temporary files)
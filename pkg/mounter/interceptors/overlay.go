@@ -15,7 +15,7 @@ import (
 // This decouples the interceptor from the concrete *server.OverlayManager type,
 // keeping the interceptors package generic and free of reverse dependencies.
 type overlayMounter interface {
-	MountOverlay(mergedDir string) error
+	MountOverlay(ctx context.Context, mergedDir string) error
 }
 
 // NewOverlayInterceptor turns a plain FUSE/NFS mount into an overlay: it rewrites
@@ -39,13 +39,14 @@ func NewOverlayInterceptor(manager overlayMounter) mounter.MountInterceptor {
 		// overlay whose lower FUSE died. Presence of the lower proves nothing — see
 		// IsNotLiveMountPoint for what actually decides liveness.
 		//
-		// TODO(fd-passing): with fd-passing the FUSE connection outlives the daemon, so
-		// probing a dead lower hangs in D state (see SafeIsNotMountPoint on the
-		// ossfs2-failover branch). One early return here is the whole fix:
-		//   if op.FdPassing { op.Target = lowerDir; return handler(ctx, op) }
-		// mount-proxy holds the fd, so the lower superblock — and therefore merged — stays
-		// valid while ossfs is restarted, leaving nothing for this interceptor to do.
+		// With fd-passing, mount-proxy holds the FUSE fd, so the lower superblock — and
+		// therefore merged — stays valid while the fuse client is restarted. Probing a
+		// lower whose client is down would hang in D state, and there is nothing to recover.
 		notMnt, err := raw.IsLikelyNotMountPoint(mergedDir)
+		if err == nil && !notMnt && op.FdPassing {
+			op.Target = lowerDir
+			return handler(ctx, op)
+		}
 		if err == nil && !notMnt {
 			notLive, lerr := mounterutils.IsNotLiveMountPoint(raw, lowerDir)
 			switch {
@@ -86,7 +87,7 @@ func NewOverlayInterceptor(manager overlayMounter) mounter.MountInterceptor {
 			return err
 		}
 
-		if err := manager.MountOverlay(mergedDir); err != nil {
+		if err := manager.MountOverlay(ctx, mergedDir); err != nil {
 			klog.ErrorS(err, "Overlay mount failed, cleaning up underlying mount", "lower", lowerDir, "merged", mergedDir)
 			if uerr := mountutils.CleanupMountPoint(lowerDir, raw, false); uerr != nil {
 				klog.ErrorS(uerr, "Failed to cleanup underlying mount after overlay failure", "target", lowerDir)
@@ -247,6 +247,41 @@ func TestOverlayInterceptor_LowerProbeFails_SkipsRecovery(t *testing.T) {
 	assert.Contains(t, paths, lowerDir, "lower must not be unmounted on an unclear probe result")
 }
 
+func TestOverlayInterceptor_FdPassing_SkipsLowerProbe(t *testing.T) {
+	mounterutils.OverlayBaseDir = t.TempDir()
+	merged := t.TempDir()
+	lowerDir := mounterutils.OverlayLowerDir(merged)
+	require.NoError(t, os.MkdirAll(lowerDir, 0755))
+
+	fake := k8smount.NewFakeMounter([]k8smount.MountPoint{
+		{Path: merged, Device: "overlay", Type: "overlay"},
+		{Path: lowerDir, Device: "ossfs", Type: "fuse.ossfs"},
+	})
+	origRaw := raw
+	// The client of the lower is being restarted: a real probe would block, this one fails loudly
+	raw = &probeMounter{
+		FakeMounter: fake,
+		failPath:    lowerDir,
+		failErr:     &os.PathError{Op: "stat", Path: lowerDir, Err: syscall.ENOTCONN},
+	}
+	defer func() { raw = origRaw }()
+
+	interceptor := NewOverlayInterceptor(server.NewOverlayManager(fake))
+
+	var mountedTarget string
+	handler := func(ctx context.Context, op *mounter.MountOperation) error {
+		mountedTarget = op.Target
+		return nil
+	}
+
+	op := &mounter.MountOperation{Overlay: true, FdPassing: true, Target: merged}
+	require.NoError(t, interceptor(context.Background(), op, handler))
+
+	assert.Equal(t, lowerDir, mountedTarget, "should pass through to the lower dir")
+	mountPoints, _ := fake.List()
+	assert.Len(t, mountPoints, 2, "nothing may be torn down or re-mounted while the FUSE connection is kept")
+}
+
 func TestOverlayInterceptor_FirstMount_Success(t *testing.T) {
 	mounterutils.OverlayBaseDir = t.TempDir()
 	fakeMounter := k8smount.NewFakeMounter(nil)
@@ -47,6 +47,9 @@ type MountOperation struct {
 	MetricsPath string
 	VolumeID    string
 	Overlay     bool
+	// FdPassing keeps the FUSE connection open in mount-proxy-server across restarts
+	// of the fuse daemon, see proxy.MountRequest.
+	FdPassing bool
 	// SensitiveOptions are mount options that carry secrets (e.g. STS
 	// credentials). They are passed to the mount via MountSensitive so they
 	// are masked in logs and error messages, and must never be logged.
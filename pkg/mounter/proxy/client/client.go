@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
 	"time"
 
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
@@ -73,25 +74,31 @@ func (c *client) doRequest(ctx context.Context, req *proxy.Request) (*proxy.Resp
 	// We need to maintain capability.
 	n, err := conn.Write(append(data, proxy.MessageEnd))
 	if err != nil {
-		if ctx.Err() != nil {
-			err = ctx.Err()
-		}
-		return nil, fmt.Errorf("send request: %w", err)
+		return nil, fmt.Errorf("send request: %w", contextError(ctx, err))
 	}
 	logger.V(4).Info("sendmsg successfully for request", "socket", c.raddr, "n", n)
 
 	var response proxy.Response
 	err = proxy.ReadMsg(conn, &response)
 	if err != nil {
-		if ctx.Err() != nil {
-			err = ctx.Err()
-		}
-		return nil, fmt.Errorf("read response: %w", err)
+		return nil, fmt.Errorf("read response: %w", contextError(ctx, err))
 	}
 	logger.V(2).Info("response from mount-proxy", "seq", response.Seq)
 	return &response, nil
 }
 
+// contextError returns the error of ctx if the request failed because of it.
+// The deadline of the connection is the one of ctx, so the connection may time out slightly before ctx is done.
+func contextError(ctx context.Context, err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		<-ctx.Done()
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
 func (c *client) Mount(ctx context.Context, req *proxy.MountRequest) (*proxy.Response, error) {
 	return c.doRequest(ctx, &proxy.Request{
 		Header: proxy.Header{
@@ -117,3 +124,190 @@ func (c *client) Ping(ctx context.Context) (*proxy.Response, error) {
 		},
 	})
 }
+
+// List returns the targets managed by mount-proxy-server.
+func (c *client) List(ctx context.Context) (*proxy.ListResponse, error) {
+	resp, err := c.doRequest(ctx, &proxy.Request{
+		Header: proxy.Header{
+			Method: proxy.List,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var list proxy.ListResponse
+	if err := decodeResult(resp, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Status returns the state of a target managed by mount-proxy-server.
+func (c *client) Status(ctx context.Context, req *proxy.StatusRequest) (*proxy.MountStatus, error) {
+	resp, err := c.doRequest(ctx, &proxy.Request{
+		Header: proxy.Header{
+			Method: proxy.Status,
+		},
+		Body: req,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var status proxy.MountStatus
+	if err := decodeResult(resp, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func decodeResult(resp *proxy.Response, result any) error {
+	if err := resp.ToError(); err != nil {
+		return err
+	}
+	if len(resp.Result) == 0 {
+		return errors.New("empty result, mount-proxy-server may be too old")
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("decode result: %w", err)
+	}
+	return nil
+}
+
+// Hello returns what mount-proxy-server supports.
+// Servers predating the handshake are described by proxy.LegacyHello.
+func (c *client) Hello(ctx context.Context) (*proxy.HelloResponse, error) {
+	resp, err := c.doRequest(ctx, &proxy.Request{
+		Header: proxy.Header{
+			Method: proxy.Hello,
+		},
+		Body: &proxy.HelloRequest{ProtocolVersion: proxy.ProtocolVersion},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error == "invalid method" {
+		hello := proxy.LegacyHello
+		return &hello, nil
+	}
+	var hello proxy.HelloResponse
+	if err := decodeResult(resp, &hello); err != nil {
+		return nil, err
+	}
+	return &hello, nil
+}
+
+// Wait waits for an async mount to finish, at most until the deadline of the request.
+func (c *client) Wait(ctx context.Context, operationID string) (*proxy.OperationStatus, error) {
+	return c.operationRequest(ctx, proxy.Wait, operationID)
+}
+
+// Cancel cancels an async mount and waits for it to stop.
+func (c *client) Cancel(ctx context.Context, operationID string) (*proxy.OperationStatus, error) {
+	return c.operationRequest(ctx, proxy.Cancel, operationID)
+}
+
+func (c *client) operationRequest(ctx context.Context, method proxy.Method, operationID string) (*proxy.OperationStatus, error) {
+	resp, err := c.doRequest(ctx, &proxy.Request{
+		Header: proxy.Header{
+			Method: method,
+		},
+		Body: &proxy.MountOperation{OperationID: operationID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var status proxy.OperationStatus
+	if err := decodeResult(resp, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ErrCapabilityMissing is returned when mount-proxy-server cannot honor a field of a MountRequest.
+var ErrCapabilityMissing = errors.New("not supported by mount-proxy-server")
+
+// cancelTimeout bounds the cancellation of an async mount whose context expired.
+const cancelTimeout = 5 * time.Second
+
+// Negotiate adapts req to what the server described by hello supports.
+// Overlay mounts are refused, as a plain mount has different semantics,
+// while fd-passing is dropped, as it only makes the mount more resilient.
+func Negotiate(ctx context.Context, hello *proxy.HelloResponse, req *proxy.MountRequest) error {
+	if req.Overlay && !hello.HasCapability(req.Fstype, proxy.CapabilityOverlay) {
+		return fmt.Errorf("overlay for fstype %q: %w (protocol version %d)", req.Fstype, ErrCapabilityMissing, hello.ProtocolVersion)
+	}
+	if req.FdPassing && !hello.HasCapability(req.Fstype, proxy.CapabilityFdPassing) {
+		klog.FromContext(ctx).Info("fd-passing not supported by mount-proxy-server, mounting without it",
+			"fstype", req.Fstype, "protocolVersion", hello.ProtocolVersion)
+		req.FdPassing = false
+	}
+	return nil
+}
+
+// MountAndWait negotiates req with the server and mounts it.
+// With servers supporting async mounts, it returns when ctx expires, after cancelling the mount,
+// instead of being bounded by the timeout of the server.
+func (c *client) MountAndWait(ctx context.Context, req *proxy.MountRequest) error {
+	logger := klog.FromContext(ctx)
+	hello, err := c.Hello(ctx)
+	if err != nil {
+		return fmt.Errorf("hello: %w", err)
+	}
+	negotiated := *req
+	if err := Negotiate(ctx, hello, &negotiated); err != nil {
+		return err
+	}
+
+	if !hello.HasMethod(proxy.Wait) || !hello.HasMethod(proxy.Cancel) {
+		logger.V(2).Info("mount-proxy-server does not support async mounts, mounting synchronously", "protocolVersion", hello.ProtocolVersion)
+		resp, err := c.Mount(ctx, &negotiated)
+		if err != nil {
+			return err
+		}
+		return resp.ToError()
+	}
+
+	negotiated.Async = true
+	resp, err := c.Mount(ctx, &negotiated)
+	if err != nil {
+		return err
+	}
+	var op proxy.MountOperation
+	if err := decodeResult(resp, &op); err != nil {
+		return err
+	}
+	logger.V(2).Info("Started async mount", "operationID", op.OperationID, "target", req.Target)
+	for {
+		status, err := c.Wait(ctx, op.OperationID)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.cancel(ctx, op.OperationID)
+				return fmt.Errorf("wait for mount: %w", ctx.Err())
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				// Only this wait request timed out, the server timeout is longer than the client one
+				continue
+			}
+			return fmt.Errorf("wait for mount: %w", err)
+		}
+		if status.Done {
+			if status.Error != "" {
+				return errors.New(status.Error)
+			}
+			return nil
+		}
+	}
+}
+
+// cancel cancels an async mount whose ctx expired, the mount would otherwise go on in the background.
+func (c *client) cancel(ctx context.Context, operationID string) {
+	logger := klog.FromContext(ctx)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+	defer cancel()
+	status, err := c.Cancel(ctx, operationID)
+	if err != nil {
+		logger.Error(err, "Failed to cancel mount", "operationID", operationID)
+		return
+	}
+	logger.Info("Cancelled mount", "operationID", operationID, "done", status.Done, "err", status.Error)
+}
@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"encoding/json"
 	"net"
 	"path/filepath"
 	"testing"
@@ -96,3 +97,180 @@ func TestContextCancellation(t *testing.T) {
 	err := <-mountDone
 	assert.ErrorIs(t, err, context.Canceled)
 }
+
+// listDriver reports a fixed set of targets.
+type listDriver struct {
+	slowDriver
+	mounts []proxy.MountStatus
+}
+
+func (d *listDriver) Name() string              { return "list" }
+func (d *listDriver) List() []proxy.MountStatus { return d.mounts }
+func (d *listDriver) Fstypes() []string         { return []string{"list"} }
+
+func TestListAndStatus(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+
+	mounted := proxy.MountStatus{
+		Target:    "/mnt/list",
+		Driver:    "list",
+		PID:       42,
+		MountTime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Alive:     true,
+	}
+	server.RegisterDriver(&listDriver{mounts: []proxy.MountStatus{mounted}})
+
+	socketPath := newTestServer(t)
+	c := client.NewClient(socketPath)
+
+	list, err := c.List(ctx)
+	require.NoError(t, err, "List")
+	assert.Contains(t, list.Mounts, mounted)
+
+	status, err := c.Status(ctx, &proxy.StatusRequest{Target: "/mnt/list"})
+	require.NoError(t, err, "Status")
+	assert.Equal(t, &mounted, status)
+
+	_, err = c.Status(ctx, &proxy.StatusRequest{Target: "/mnt/other"})
+	assert.ErrorContains(t, err, proxy.ErrTargetNotManaged)
+}
+
+// asyncDriver mounts after delay, unless its context is done first.
+type asyncDriver struct {
+	name      string
+	delay     time.Duration
+	cancelled chan struct{}
+	requests  chan *proxy.MountRequest
+}
+
+func (d *asyncDriver) Name() string                                  { return d.name }
+func (d *asyncDriver) Fstypes() []string                             { return []string{d.name} }
+func (d *asyncDriver) Init()                                         {}
+func (d *asyncDriver) Terminate()                                    {}
+func (d *asyncDriver) ApplyOptionDefaults(options []string) []string { return options }
+func (d *asyncDriver) Capabilities() []proxy.Capability {
+	return []proxy.Capability{proxy.CapabilityFdPassing}
+}
+
+func (d *asyncDriver) Mount(ctx context.Context, req *proxy.MountRequest) error {
+	d.requests <- req
+	select {
+	case <-time.After(d.delay):
+		return nil
+	case <-ctx.Done():
+		close(d.cancelled)
+		return ctx.Err()
+	}
+}
+
+func newAsyncDriver(name string, delay time.Duration) *asyncDriver {
+	d := &asyncDriver{
+		name:      name,
+		delay:     delay,
+		cancelled: make(chan struct{}),
+		requests:  make(chan *proxy.MountRequest, 1),
+	}
+	server.RegisterDriver(d)
+	server.Init([]string{name})
+	return d
+}
+
+func TestMountAndWait(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	d := newAsyncDriver("async-ok", 100*time.Millisecond)
+	c := client.NewClient(newTestServer(t))
+
+	hello, err := c.Hello(ctx)
+	require.NoError(t, err, "Hello")
+	assert.Equal(t, proxy.ProtocolVersion, hello.ProtocolVersion)
+	assert.True(t, hello.HasCapability("async-ok", proxy.CapabilityFdPassing))
+
+	err = c.MountAndWait(ctx, &proxy.MountRequest{Fstype: "async-ok", Target: "/mnt/async-ok", FdPassing: true})
+	require.NoError(t, err)
+	req := <-d.requests
+	assert.True(t, req.Async)
+	assert.True(t, req.FdPassing)
+}
+
+func TestMountAndWait_CancelledAtDeadline(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	d := newAsyncDriver("async-slow", time.Hour)
+	c := client.NewClient(newTestServer(t))
+
+	ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := c.MountAndWait(ctx, &proxy.MountRequest{Fstype: "async-slow", Target: "/mnt/async-slow"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// Bounded by the deadline of ctx, not the timeout of the server
+	assert.Less(t, time.Since(start), testTimeout)
+
+	select {
+	case <-d.cancelled:
+	case <-time.After(testTimeout):
+		t.Fatal("mount was not cancelled")
+	}
+}
+
+func TestMountAndWait_MissingCapability(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	newAsyncDriver("async-nooverlay", 0)
+	c := client.NewClient(newTestServer(t))
+
+	err := c.MountAndWait(ctx, &proxy.MountRequest{Fstype: "async-nooverlay", Target: "/mnt/overlay", Overlay: true})
+	assert.ErrorIs(t, err, client.ErrCapabilityMissing)
+}
+
+// newLegacyServer serves the requests like a server predating the hello handshake.
+func newLegacyServer(t *testing.T, requests chan<- proxy.Request) string {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "legacy.sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	require.NoError(t, err, "listen")
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.AcceptUnix()
+			if err != nil {
+				return
+			}
+			var req proxy.Request
+			if err := proxy.ReadMsg(conn, &req); err == nil {
+				resp := proxy.Response{Seq: 1}
+				if req.Header.Method != proxy.Mount && req.Header.Method != proxy.Ping {
+					resp.Error = "invalid method"
+				} else {
+					requests <- req
+				}
+				data, _ := json.Marshal(resp)
+				_, _ = conn.Write(append(data, proxy.MessageEnd))
+			}
+			conn.Close()
+		}
+	}()
+	return socketPath
+}
+
+func TestMountAndWait_LegacyServer(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	requests := make(chan proxy.Request, 1)
+	c := client.NewClient(newLegacyServer(t, requests))
+
+	hello, err := c.Hello(ctx)
+	require.NoError(t, err, "Hello")
+	assert.Equal(t, proxy.LegacyHello, *hello)
+
+	// fd-passing is dropped, and the mount is synchronous
+	err = c.MountAndWait(ctx, &proxy.MountRequest{Fstype: "ossfs", Target: "/mnt/legacy", FdPassing: true})
+	require.NoError(t, err)
+	req := <-requests
+	body, err := json.Marshal(req.Body)
+	require.NoError(t, err)
+	var mountReq proxy.MountRequest
+	require.NoError(t, json.Unmarshal(body, &mountReq))
+	assert.Equal(t, proxy.MountRequest{Fstype: "ossfs", Target: "/mnt/legacy"}, mountReq)
+
+	// Overlay is refused
+	err = c.MountAndWait(ctx, &proxy.MountRequest{Fstype: "ossfs", Target: "/mnt/legacy", Overlay: true})
+	assert.ErrorIs(t, err, client.ErrCapabilityMissing)
+}
@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
+	"time"
 
+	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
 	"k8s.io/klog/v2"
 )
 
@@ -14,12 +18,33 @@ const (
 	MessageEnd = '\n'
 )
 
+// ProtocolVersion is the revision of the protocol implemented by this package,
+// exchanged by the Hello method. Servers predating Hello implement version 1.
+const ProtocolVersion = 2
+
 type Method string
 
 const (
 	Mount   Method = "mount"
 	Unmount Method = "unmount"
 	Ping    Method = "ping"
+	List    Method = "list"
+	Status  Method = "status"
+	Hello   Method = "hello"
+	Wait    Method = "wait"
+	Cancel  Method = "cancel"
+)
+
+// Capability is an optional feature of a driver of mount-proxy-server, reported by Hello.
+type Capability string
+
+const (
+	// CapabilityOverlay means the driver honors MountRequest.Overlay.
+	CapabilityOverlay Capability = "overlay"
+	// CapabilityFdPassing means the driver honors MountRequest.FdPassing.
+	CapabilityFdPassing Capability = "fdPassing"
+	// CapabilityUnmount means the driver handles Unmount requests for the targets it mounted.
+	CapabilityUnmount Capability = "unmount"
 )
 
 // ErrTargetNotManaged is the error string returned by mount-proxy-server when an
@@ -41,6 +66,8 @@ type Request struct {
 type Response struct {
 	Seq   int64  `json:"seq,omitempty"`
 	Error string `json:"error,omitempty"`
+	// Result is the method specific payload, e.g. a ListResponse for List.
+	Result json.RawMessage `json:"result,omitempty"`
 }
 
 func (r *Response) ToError() error {
@@ -65,6 +92,17 @@ type MountRequest struct {
 	// exposes the merged view at Target.
 	// Forward-compatible: old mount-proxy-server versions ignore unknown JSON fields.
 	Overlay bool `json:"overlay,omitempty"`
+	// FdPassing instructs mount-proxy-server to open /dev/fuse and mount the FUSE
+	// filesystem itself, then hand the fd to the fuse daemon while keeping a duplicate.
+	// When the daemon crashes or is upgraded, it is restarted against the same FUSE
+	// connection, so the mountpoint stalls for a while instead of failing with ENOTCONN.
+	// Forward-compatible: old mount-proxy-server versions ignore unknown JSON fields.
+	FdPassing bool `json:"fdPassing,omitempty"`
+	// Async makes mount-proxy-server reply with a MountOperation as soon as the mount
+	// is started, to be followed with Wait and Cancel requests.
+	// Only sent to servers listing the Wait method in their HelloResponse, as old ones
+	// would ignore it and mount synchronously.
+	Async bool `json:"async,omitempty"`
 }
 
 // UnmountRequest asks mount-proxy-server to unmount a mount point that was
@@ -83,6 +121,118 @@ type UnmountRequest struct {
 	Target string `json:"target,omitempty"`
 }
 
+// StatusRequest asks mount-proxy-server for the state of a single managed target.
+// If no driver manages it, the broker returns ErrTargetNotManaged.
+type StatusRequest struct {
+	Target string `json:"target,omitempty"`
+}
+
+// MountStatus describes a target managed by mount-proxy-server.
+type MountStatus struct {
+	Target string `json:"target"`
+	Driver string `json:"driver"`
+	Source string `json:"source,omitempty"`
+	Fstype string `json:"fstype,omitempty"`
+	// Options are the requested mount options, with credentials redacted.
+	Options   []string  `json:"options,omitempty"`
+	PID       int       `json:"pid,omitempty"`
+	MountTime time.Time `json:"mountTime"`
+	// RetryCount and FailoverCount are only known for monitored targets,
+	// i.e. those mounted with a MetricsPath.
+	RetryCount    int  `json:"retryCount"`
+	FailoverCount int  `json:"failoverCount"`
+	Monitored     bool `json:"monitored"`
+	// Alive is the health reported by the monitor of the target,
+	// or whether the target is still mounted when it is not monitored.
+	Alive bool `json:"alive"`
+}
+
+// HelloRequest starts the handshake, with the protocol version of the client.
+type HelloRequest struct {
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+// HelloResponse describes what mount-proxy-server supports.
+type HelloResponse struct {
+	ProtocolVersion int          `json:"protocolVersion"`
+	Methods         []Method     `json:"methods"`
+	Drivers         []DriverInfo `json:"drivers"`
+}
+
+// DriverInfo describes an enabled driver of mount-proxy-server.
+type DriverInfo struct {
+	Name         string       `json:"name"`
+	Fstypes      []string     `json:"fstypes"`
+	Capabilities []Capability `json:"capabilities,omitempty"`
+}
+
+// LegacyHello is what a server answering "invalid method" to Hello supports.
+// Its drivers are unknown.
+var LegacyHello = HelloResponse{
+	ProtocolVersion: 1,
+	Methods:         []Method{Mount, Unmount, Ping},
+}
+
+// HasMethod reports whether the server supports method.
+func (h *HelloResponse) HasMethod(method Method) bool {
+	return slices.Contains(h.Methods, method)
+}
+
+// HasCapability reports whether the driver serving fstype has capability.
+func (h *HelloResponse) HasCapability(fstype string, capability Capability) bool {
+	for _, d := range h.Drivers {
+		if slices.Contains(d.Fstypes, fstype) {
+			return slices.Contains(d.Capabilities, capability)
+		}
+	}
+	return false
+}
+
+// MountOperation is the Result of an Async mount request, and the body of Wait and Cancel requests.
+type MountOperation struct {
+	OperationID string `json:"operationID"`
+}
+
+// OperationStatus is the Result of Wait and Cancel requests.
+type OperationStatus struct {
+	OperationID string `json:"operationID"`
+	// Done is false when the operation is still running at the deadline of the Wait request.
+	Done bool `json:"done"`
+	// Error is the error of the finished operation.
+	Error string `json:"error,omitempty"`
+}
+
+// ListResponse is the Result of a List request.
+type ListResponse struct {
+	Mounts []MountStatus `json:"mounts"`
+}
+
+// Redacted is the value that replaces credentials in reported mount options.
+const Redacted = "<redacted>"
+
+// RedactOptions returns a copy of options with the values of credential options replaced by Redacted,
+// see mounterutils.IsCredentialOption. Paths to credential files (e.g. passwd_file) are redacted as well.
+func RedactOptions(options []string) []string {
+	if options == nil {
+		return nil
+	}
+	redacted := make([]string, 0, len(options))
+	for _, opts := range options {
+		parts := strings.Split(opts, ",")
+		for i, opt := range parts {
+			key, _, ok := strings.Cut(opt, "=")
+			if !ok {
+				continue
+			}
+			if mounterutils.IsCredentialOption(key) {
+				parts[i] = key + "=" + Redacted
+			}
+		}
+		redacted = append(redacted, strings.Join(parts, ","))
+	}
+	return redacted
+}
+
 func ReadMsg(r io.Reader, msg any) error {
 	lr := io.LimitedReader{R: r, N: MaxMsgSize}
 	dec := json.NewDecoder(&lr)
@@ -81,3 +81,41 @@ func TestReadMsgExtraBytesAfterDelimiter(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, Header{Method: "test"}, parsed.Header)
 }
+
+func TestRedactOptions(t *testing.T) {
+	options := []string{
+		"allow_other,passwd_file=/tmp/passwd",
+		"access_key_secret=sk",
+		"ro",
+		"security_token=abc,url=http://oss",
+		"ak=AK,sk=SK,ram_role=role",
+	}
+	assert.Equal(t, []string{
+		"allow_other,passwd_file=<redacted>",
+		"access_key_secret=<redacted>",
+		"ro",
+		"security_token=<redacted>,url=http://oss",
+		"ak=<redacted>,sk=<redacted>,ram_role=role",
+	}, RedactOptions(options))
+	assert.Equal(t, "access_key_secret=sk", options[1], "input must not be modified")
+	assert.Nil(t, RedactOptions(nil))
+}
+
+func TestHelloResponse(t *testing.T) {
+	hello := HelloResponse{
+		ProtocolVersion: ProtocolVersion,
+		Methods:         []Method{Mount, Hello, Wait},
+		Drivers: []DriverInfo{
+			{Name: "ossfs", Fstypes: []string{"ossfs"}, Capabilities: []Capability{CapabilityOverlay}},
+			{Name: "alinas", Fstypes: []string{"alinas", "cpfs-nfs"}, Capabilities: []Capability{CapabilityUnmount}},
+		},
+	}
+	assert.True(t, hello.HasMethod(Wait))
+	assert.False(t, hello.HasMethod(Cancel))
+	assert.True(t, hello.HasCapability("ossfs", CapabilityOverlay))
+	assert.False(t, hello.HasCapability("ossfs", CapabilityFdPassing))
+	assert.True(t, hello.HasCapability("cpfs-nfs", CapabilityUnmount))
+	assert.False(t, hello.HasCapability("nfs", CapabilityUnmount))
+	assert.False(t, LegacyHello.HasMethod(Wait))
+	assert.False(t, LegacyHello.HasCapability("ossfs", CapabilityOverlay))
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -33,7 +34,10 @@ func init() {
 }
 
 func NewDriver() *Driver {
-	driver := &Driver{}
+	driver := &Driver{
+		monitorManager: server.NewMountMonitorManager(),
+		mounts:         server.NewMountTable("alinas"),
+	}
 	driver.Mounter = mounter.NewForMounter(
 		&extendedMounter{driver: driver, Interface: mount.New("")},
 		interceptors.AlinasSecretInterceptor,
@@ -46,6 +50,11 @@ type Driver struct {
 	mounter.Mounter
 	targets       sync.Map
 	ResetFlagPath string
+	// journal persists the mount requests, so ownership of the NFS mounts,
+	// which outlive the process, is restored by Init after a restart.
+	journal        *server.MountJournal
+	monitorManager *server.MountMonitorManager
+	mounts         *server.MountTable
 }
 
 func (h *Driver) Name() string {
@@ -80,23 +89,74 @@ func (h *Driver) Unmount(target string) (owned bool, err error) {
 }
 
 func (h *Driver) Mount(ctx context.Context, req *proxy.MountRequest) error {
-	return h.ExtendedMount(ctx, &mounter.MountOperation{
+	err := h.ExtendedMount(ctx, &mounter.MountOperation{
 		Source:      req.Source,
 		Target:      req.Target,
 		FsType:      req.Fstype,
-		Options:     req.Options,
+		Options:     slices.Clone(req.Options),
 		Secrets:     req.Secrets,
 		MetricsPath: req.MetricsPath,
 		VolumeID:    req.VolumeID,
 	})
+	if err != nil {
+		return err
+	}
+	// The original options are journaled: the interceptors rewrite them,
+	// and the jwtauth ones are needed to restart the credential refresher.
+	h.journal.Record(req)
+	h.mounts.Add(req, req.Target, 0, time.Now())
+	return nil
+}
+
+var _ server.Lister = (*Driver)(nil)
+
+// List implements server.Lister. NAS mounts have no daemon, so no pid is reported.
+func (h *Driver) List() []proxy.MountStatus {
+	return h.mounts.List(h.monitorManager)
 }
 
 func (h *Driver) Init() {
 	setupDefaultConfigs()
+	h.restoreMounts(context.Background())
 	go runCommandForever("aliyun-alinas-mount-watchdog")
 	go runCommandForever("aliyun-cpfs-mount-watchdog")
 }
 
+// restoreMounts replays the mount journal: the mounts that are still alive are owned
+// by this driver again, so their unmounts are routed through the broker, and their
+// credential refreshers and monitors are restarted.
+func (h *Driver) restoreMounts(ctx context.Context) {
+	journal, err := server.OpenMountJournal(h.Name())
+	if err != nil {
+		klog.ErrorS(err, "Failed to open mount journal, existing mounts will not be restored")
+		return
+	}
+	h.journal = journal
+	for _, entry := range journal.LiveEntries() {
+		h.targets.Store(entry.Target, struct{}{})
+		if err := interceptors.RestartAlinasJWTAuthRefresher(ctx, entry.Target, entry.Options); err != nil {
+			klog.ErrorS(err, "Failed to restart credential refresher, mounted credential will expire without refresh", "target", entry.Target)
+		}
+		h.startMonitoring(entry.Target, entry.MetricsPath)
+		h.mounts.Add(&entry.MountRequest, entry.Target, 0, entry.MountTime)
+		klog.InfoS("Restored mount from journal", "target", entry.Target, "source", entry.Source, "mountTime", entry.MountTime)
+	}
+}
+
+// startMonitoring reports the status of target to metricsPath, if set.
+// NAS mounts have no daemon of their own, so no pid is reported.
+func (h *Driver) startMonitoring(target, metricsPath string) {
+	if metricsPath == "" || h.monitorManager == nil {
+		return
+	}
+	monitor, _ := h.monitorManager.GetMountMonitor(target, metricsPath, mount.New(""), true)
+	if monitor == nil {
+		return
+	}
+	monitor.HandleMountSuccess(0)
+	h.monitorManager.StartMonitoring(target)
+}
+
 // defaultResetFlagPath is the path to the reset flag file written by envd.
 // When this file exists at termination time, the driver will unmount all
 // tracked NAS mount points before exiting.
@@ -113,6 +173,10 @@ func (h *Driver) Terminate() {
 	// Stop all jwtauth credential refreshers regardless of mount cleanup
 	// policy, so no refresh goroutine outlives the driver.
 	jwtauth.StopAll()
+	if h.monitorManager != nil {
+		h.monitorManager.StopAllMonitoring()
+		h.monitorManager.WaitForAllMonitoring()
+	}
 
 	if !server.CleanupNASMountsOnExit() {
 		return
@@ -266,9 +330,10 @@ func (m *extendedMounter) ExtendedMount(ctx context.Context, op *mounter.MountOp
 	}
 	// SensitiveOptions (e.g. jwtauth STS credentials) are passed separately so
 	// mount-utils masks them in logs and error messages.
-	err := m.MountSensitive(op.Source, op.Target, op.FsType, op.Options, op.SensitiveOptions)
+	err := m.MountSensitive(op.Source, server.MountTarget(ctx, op.Target), op.FsType, op.Options, op.SensitiveOptions)
 	if err == nil {
 		m.driver.targets.Store(op.Target, struct{}{})
+		m.driver.startMonitoring(op.Target, op.MetricsPath)
 	}
 	return err
 }
@@ -277,6 +342,11 @@ func (m *extendedMounter) Unmount(target string) error {
 	err := m.Interface.Unmount(target)
 	if err == nil {
 		m.driver.targets.Delete(target)
+		m.driver.journal.Remove(target)
+		m.driver.mounts.Remove(target)
+		if m.driver.monitorManager != nil {
+			m.driver.monitorManager.StopMonitoring(target)
+		}
 		// The mount is gone; stop any jwtauth credential refresher serving it.
 		jwtauth.DefaultManager.StopByTarget(target)
 	}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/jwtauth"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy/server"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -183,6 +184,46 @@ func TestExtendedMounterUnmountStopsJWTAuthRefresher(t *testing.T) {
 		"jwtauth refresher should be stopped when the mount is unmounted")
 }
 
+func TestDriverRestoresJournaledMounts(t *testing.T) {
+	stateDir := t.TempDir()
+	server.SetStateDir(stateDir)
+	defer server.SetStateDir("")
+
+	fakeMounter := mount.NewFakeMounter(nil)
+	driver := &Driver{}
+	driver.Mounter = mounter.NewForMounter(&extendedMounter{driver: driver, Interface: fakeMounter})
+	driver.restoreMounts(context.Background())
+
+	require.NoError(t, driver.Mount(context.Background(), &proxy.MountRequest{
+		Source:  "192.168.1.1:/share",
+		Target:  "/mnt/nas1",
+		Fstype:  "alinas",
+		Options: []string{"vers=3"},
+	}))
+
+	// mount-proxy-server restarted: the journaled target is owned again.
+	// /mnt/nas1 is not in the real mount table, so it is dropped instead.
+	restarted := &Driver{}
+	restarted.restoreMounts(context.Background())
+	_, loaded := restarted.targets.Load("/mnt/nas1")
+	assert.False(t, loaded, "a journaled target that is no longer mounted must not be restored")
+
+	// "/" is always in the real mount table
+	require.NoError(t, driver.Mount(context.Background(), &proxy.MountRequest{
+		Source: "192.168.1.1:/share",
+		Target: "/",
+		Fstype: "alinas",
+	}))
+	restarted = &Driver{}
+	restarted.restoreMounts(context.Background())
+	_, loaded = restarted.targets.Load("/")
+	assert.True(t, loaded, "a journaled target that is still mounted must be owned again")
+
+	owned, err := restarted.Unmount("/mnt/nas1")
+	assert.NoError(t, err)
+	assert.False(t, owned)
+}
+
 type noopCredentialSink struct{}
 
 func (noopCredentialSink) Apply(*jwtauth.STSToken) error { return nil }
@@ -0,0 +1,309 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+)
+
+// procRoot is where the exe and cgroup of peers are read, replaced in tests.
+var procRoot = "/proc"
+
+// PeerCred identifies the process at the other end of a connection, as reported by SO_PEERCRED.
+type PeerCred struct {
+	// PID is 0 when the peer is not visible in the pid namespace of mount-proxy-server,
+	// Exe and Cgroups are then unknown.
+	PID     int32
+	UID     uint32
+	GID     uint32
+	Exe     string
+	Cgroups []string
+}
+
+// ReadPeerCred reads the credentials of the peer of conn.
+// Exe and Cgroups are best effort: the peer may have exited since it connected.
+func ReadPeerCred(conn *net.UnixConn) (*PeerCred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, fmt.Errorf("get raw conn: %w", err)
+	}
+	var ucred *unix.Ucred
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		ucred, serr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err == nil {
+		err = serr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get SO_PEERCRED: %w", err)
+	}
+
+	cred := &PeerCred{PID: ucred.Pid, UID: ucred.Uid, GID: ucred.Gid}
+	if cred.PID == 0 {
+		return cred, nil
+	}
+	pidDir := filepath.Join(procRoot, strconv.Itoa(int(cred.PID)))
+	if exe, err := os.Readlink(filepath.Join(pidDir, "exe")); err == nil {
+		cred.Exe = exe
+	} else {
+		klog.V(2).InfoS("Failed to read peer executable", "pid", cred.PID, "err", err)
+	}
+	if cgroups, err := readCgroups(filepath.Join(pidDir, "cgroup")); err == nil {
+		cred.Cgroups = cgroups
+	} else {
+		klog.V(2).InfoS("Failed to read peer cgroups", "pid", cred.PID, "err", err)
+	}
+	return cred, nil
+}
+
+// readCgroups returns the cgroup paths of all hierarchies in a /proc/<pid>/cgroup file.
+func readCgroups(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cgroups []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) == 3 {
+			cgroups = append(cgroups, parts[2])
+		}
+	}
+	return cgroups, scanner.Err()
+}
+
+// AuthPolicy decides which peers may send which requests.
+// A request is allowed if any rule matches its peer and permits it.
+type AuthPolicy struct {
+	Rules []AuthRule `json:"rules"`
+}
+
+// AuthRule grants permissions to the peers it selects.
+// An empty selector matches any peer, and an empty permission list allows everything.
+type AuthRule struct {
+	// Name identifies the rule in the audit log.
+	Name string `json:"name"`
+
+	// Peer selectors, all the non-empty ones must match.
+	UIDs []uint32 `json:"uids,omitempty"`
+	GIDs []uint32 `json:"gids,omitempty"`
+	// CgroupPrefixes match any of the cgroup paths of the peer, e.g. "/kubepods".
+	CgroupPrefixes []string `json:"cgroupPrefixes,omitempty"`
+	// Executables are the paths of the peer executable as shown by /proc/<pid>/exe.
+	// Like CgroupPrefixes, they never match a peer in another pid namespace, whose pid is 0.
+	Executables []string `json:"executables,omitempty"`
+
+	// Permissions, all the non-empty ones must allow the request.
+	Methods []proxy.Method `json:"methods,omitempty"`
+	// Fstypes are checked for requests carrying a fstype, i.e. mount.
+	Fstypes []string `json:"fstypes,omitempty"`
+	// TargetPrefixes are checked for requests carrying a target, i.e. mount, unmount and status,
+	// e.g. "/var/lib/kubelet/pods". Prefixes match whole path components, after resolving symlinks.
+	TargetPrefixes []string `json:"targetPrefixes,omitempty"`
+}
+
+// LoadAuthPolicy reads a JSON AuthPolicy from path.
+func LoadAuthPolicy(path string) (*AuthPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read auth policy: %w", err)
+	}
+	var policy AuthPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parse auth policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid auth policy %s: %w", path, err)
+	}
+	return &policy, nil
+}
+
+// Validate rejects policies that would silently never match.
+func (p *AuthPolicy) Validate() error {
+	if len(p.Rules) == 0 {
+		return errors.New("no rules, every request would be denied")
+	}
+	for i, r := range p.Rules {
+		for _, m := range r.Methods {
+			if !slices.Contains(supportedMethods, m) {
+				return fmt.Errorf("rule %d (%s): unknown method %q", i, r.Name, m)
+			}
+		}
+		for _, prefixes := range [][]string{r.CgroupPrefixes, r.Executables, r.TargetPrefixes} {
+			for _, prefix := range prefixes {
+				if !filepath.IsAbs(prefix) {
+					return fmt.Errorf("rule %d (%s): %q is not an absolute path", i, r.Name, prefix)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// targetMethods are the methods whose requests must carry a target.
+var targetMethods = []proxy.Method{proxy.Mount, proxy.Unmount, proxy.Status}
+
+// authRequest holds the fields of a request body that the policy checks.
+type authRequest struct {
+	Target string `json:"target,omitempty"`
+	Fstype string `json:"fstype,omitempty"`
+}
+
+// Authorize checks a request of peer against the policy, and audits the decision.
+// It returns the body to handle, whose target is replaced by the resolved path that was checked.
+func (p *AuthPolicy) Authorize(seq int64, peer *PeerCred, method proxy.Method, body json.RawMessage) (json.RawMessage, error) {
+	var req authRequest
+	rule, err := p.authorize(peer, method, body, &req)
+	if err == nil && req.Target != "" {
+		body, err = replaceTarget(body, req.Target)
+	}
+	kv := []any{"seq", seq, "method", method, "target", req.Target, "fstype", req.Fstype}
+	if peer != nil {
+		kv = append(kv, "pid", peer.PID, "uid", peer.UID, "gid", peer.GID, "exe", peer.Exe, "cgroups", peer.Cgroups)
+	}
+	if err != nil {
+		auditLogger.Info("Denied request", append(kv, "reason", err.Error())...)
+		return nil, fmt.Errorf("permission denied: %w", err)
+	}
+	auditLogger.V(2).Info("Allowed request", append(kv, "rule", rule)...)
+	return body, nil
+}
+
+// replaceTarget sets the target of a request body, keeping the other fields as they are.
+func replaceTarget(body json.RawMessage, target string) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	var err error
+	fields["target"], err = json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// authorize returns the name of the first rule allowing the request, parsed into req.
+func (p *AuthPolicy) authorize(peer *PeerCred, method proxy.Method, body json.RawMessage, req *authRequest) (string, error) {
+	if peer == nil {
+		return "", errors.New("unknown peer")
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, req); err != nil {
+			return "", fmt.Errorf("invalid request body: %w", err)
+		}
+	}
+	if req.Target != "" {
+		if !filepath.IsAbs(req.Target) {
+			return "", fmt.Errorf("target %q is not an absolute path", req.Target)
+		}
+		// Do not let "/allowed/../other" or a symlink below "/allowed" pass the prefix check,
+		// the resolved target is handled instead of the requested one.
+		target, err := resolvePath(req.Target)
+		if err != nil {
+			return "", fmt.Errorf("resolve target %q: %w", req.Target, err)
+		}
+		req.Target = target
+	}
+	for _, r := range p.Rules {
+		if r.matchesPeer(peer) && r.allows(method, req) {
+			return r.Name, nil
+		}
+	}
+	return "", errors.New("no rule allows the request")
+}
+
+func (r *AuthRule) matchesPeer(peer *PeerCred) bool {
+	if len(r.UIDs) > 0 && !slices.Contains(r.UIDs, peer.UID) {
+		return false
+	}
+	if len(r.GIDs) > 0 && !slices.Contains(r.GIDs, peer.GID) {
+		return false
+	}
+	if len(r.Executables) > 0 && (peer.Exe == "" || !slices.Contains(r.Executables, peer.Exe)) {
+		return false
+	}
+	if len(r.CgroupPrefixes) > 0 && !slices.ContainsFunc(peer.Cgroups, func(cgroup string) bool {
+		return hasPathPrefix(cgroup, r.CgroupPrefixes)
+	}) {
+		return false
+	}
+	return true
+}
+
+func (r *AuthRule) allows(method proxy.Method, req *authRequest) bool {
+	if len(r.Methods) > 0 && !slices.Contains(r.Methods, method) {
+		return false
+	}
+	// A mount without fstype or target would fail anyway, but never let it bypass the rule
+	if len(r.Fstypes) > 0 && (req.Fstype != "" || method == proxy.Mount) && !slices.Contains(r.Fstypes, req.Fstype) {
+		return false
+	}
+	if len(r.TargetPrefixes) > 0 && (req.Target != "" || slices.Contains(targetMethods, method)) &&
+		!hasPathPrefix(req.Target, resolvePrefixes(r.TargetPrefixes)) {
+		return false
+	}
+	return true
+}
+
+// resolvePath evaluates the symlinks of path. The components that do not exist yet are appended as they are,
+// as a mount target may be created by the driver.
+func resolvePath(path string) (string, error) {
+	path = filepath.Clean(path)
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			slices.Reverse(missing)
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) || path == "/" {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(path))
+		path = filepath.Dir(path)
+	}
+}
+
+// resolvePrefixes evaluates the symlinks of the target prefixes, e.g. a kubelet root dir on another disk,
+// so that they match the resolved targets. A prefix that cannot be resolved is kept as it is.
+func resolvePrefixes(prefixes []string) []string {
+	resolved := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		if path, err := resolvePath(prefix); err == nil {
+			prefix = path
+		}
+		resolved = append(resolved, prefix)
+	}
+	return resolved
+}
+
+// hasPathPrefix reports whether path is one of prefixes or below one of them.
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = filepath.Clean(prefix)
+		if path == prefix || prefix == "/" || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// auditLogger logs the authorization decisions. Denials are always logged, allowed requests only at V(2).
+var auditLogger = klog.Background().WithName("audit")
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPeerCred(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "peer.sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	client, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socketPath, Net: "unix"})
+	require.NoError(t, err)
+	defer client.Close()
+	conn, err := listener.AcceptUnix()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	peer, err := ReadPeerCred(conn)
+	require.NoError(t, err)
+	assert.Equal(t, int32(os.Getpid()), peer.PID)
+	assert.Equal(t, uint32(os.Getuid()), peer.UID)
+	assert.Equal(t, uint32(os.Getgid()), peer.GID)
+	exe, err := os.Readlink("/proc/self/exe")
+	require.NoError(t, err)
+	assert.Equal(t, exe, peer.Exe)
+}
+
+func TestReadCgroups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cgroup")
+	require.NoError(t, os.WriteFile(path, []byte("12:memory:/kubepods/pod1/abc\n0::/kubepods/pod1/abc\n"), 0o644))
+	cgroups, err := readCgroups(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/kubepods/pod1/abc", "/kubepods/pod1/abc"}, cgroups)
+}
+
+func TestAuthPolicy_Authorize(t *testing.T) {
+	policy := &AuthPolicy{Rules: []AuthRule{
+		{
+			Name:           "csi-plugin",
+			UIDs:           []uint32{0},
+			Executables:    []string{"/bin/plugin.csi.alibabacloud.com"},
+			Fstypes:        []string{"ossfs", "ossfs2"},
+			TargetPrefixes: []string{"/var/lib/kubelet/pods"},
+		},
+		{
+			Name:           "debug",
+			CgroupPrefixes: []string{"/system.slice/sshd.service"},
+			Methods:        []proxy.Method{proxy.Ping, proxy.List, proxy.Status},
+		},
+	}}
+	require.NoError(t, policy.Validate())
+
+	plugin := &PeerCred{PID: 10, UID: 0, Exe: "/bin/plugin.csi.alibabacloud.com", Cgroups: []string{"/kubepods/besteffort/pod1"}}
+	sshd := &PeerCred{PID: 11, UID: 1000, Exe: "/usr/bin/bash", Cgroups: []string{"/system.slice/sshd.service"}}
+	sidecar := &PeerCred{PID: 12, UID: 0, Exe: "/usr/bin/sidecar", Cgroups: []string{"/kubepods/besteffort/pod2"}}
+
+	tests := []struct {
+		name    string
+		peer    *PeerCred
+		method  proxy.Method
+		body    string
+		allowed bool
+	}{
+		{"plugin mounts into pods", plugin, proxy.Mount, `{"fstype":"ossfs","target":"/var/lib/kubelet/pods/uid/volumes/v/mount"}`, true},
+		{"plugin unmounts from pods", plugin, proxy.Unmount, `{"target":"/var/lib/kubelet/pods/uid/volumes/v/mount"}`, true},
+		{"plugin mounts outside pods", plugin, proxy.Mount, `{"fstype":"ossfs","target":"/etc"}`, false},
+		{"plugin escapes prefix", plugin, proxy.Mount, `{"fstype":"ossfs","target":"/var/lib/kubelet/pods/../../../etc"}`, false},
+		{"plugin prefix is a path component", plugin, proxy.Mount, `{"fstype":"ossfs","target":"/var/lib/kubelet/pods2/x"}`, false},
+		{"plugin relative target", plugin, proxy.Mount, `{"fstype":"ossfs","target":"var/lib/kubelet/pods/x"}`, false},
+		{"plugin other fstype", plugin, proxy.Mount, `{"fstype":"alinas","target":"/var/lib/kubelet/pods/x"}`, false},
+		{"plugin mount without fstype", plugin, proxy.Mount, `{"target":"/var/lib/kubelet/pods/x"}`, false},
+		{"plugin unmount without target", plugin, proxy.Unmount, `{}`, false},
+		{"plugin lists", plugin, proxy.List, ``, true},
+		{"plugin hello", plugin, proxy.Hello, `{"protocolVersion":2}`, true},
+		{"plugin waits", plugin, proxy.Wait, `{"operationID":"abc"}`, true},
+		{"debug lists", sshd, proxy.List, ``, true},
+		{"debug status", sshd, proxy.Status, `{"target":"/etc"}`, true},
+		{"debug cannot mount", sshd, proxy.Mount, `{"fstype":"ossfs","target":"/var/lib/kubelet/pods/x"}`, false},
+		{"privileged sidecar", sidecar, proxy.Mount, `{"fstype":"ossfs","target":"/var/lib/kubelet/pods/x"}`, false},
+		{"unknown peer", nil, proxy.Ping, ``, false},
+		{"bad body", plugin, proxy.Mount, `{bad`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := policy.Authorize(1, tt.peer, tt.method, json.RawMessage(tt.body))
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, "permission denied")
+			}
+		})
+	}
+}
+
+func TestAuthPolicy_AuthorizeSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	pods := filepath.Join(dir, "pods")
+	require.NoError(t, os.MkdirAll(filepath.Join(pods, "uid", "volumes"), 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "etc"), 0o755))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "etc"), filepath.Join(pods, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(pods, "uid"), filepath.Join(pods, "inside")))
+	// the kubelet root dir may be a symlink itself
+	require.NoError(t, os.Symlink(pods, filepath.Join(dir, "kubelet-pods")))
+
+	policy := &AuthPolicy{Rules: []AuthRule{{
+		Name:           "csi-plugin",
+		UIDs:           []uint32{0},
+		TargetPrefixes: []string{filepath.Join(dir, "kubelet-pods")},
+	}}}
+	plugin := &PeerCred{PID: 10, UID: 0}
+	authorize := func(target string) (string, error) {
+		body, err := json.Marshal(proxy.MountRequest{Fstype: "ossfs", Target: target, Options: []string{"ro"}})
+		require.NoError(t, err)
+		body, err = policy.Authorize(1, plugin, proxy.Mount, body)
+		if err != nil {
+			return "", err
+		}
+		var req proxy.MountRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+		assert.Equal(t, []string{"ro"}, req.Options)
+		return req.Target, nil
+	}
+
+	_, err := authorize(filepath.Join(pods, "escape", "mount"))
+	assert.ErrorContains(t, err, "permission denied")
+	_, err = authorize(filepath.Join(pods, "escape"))
+	assert.ErrorContains(t, err, "permission denied")
+
+	// the resolved target is handled, the new components are kept
+	target, err := authorize(filepath.Join(pods, "inside", "volumes", "v", "mount"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(pods, "uid", "volumes", "v", "mount"), target)
+	target, err = authorize(filepath.Join(dir, "kubelet-pods", "uid", "..", "uid", "volumes"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(pods, "uid", "volumes"), target)
+}
+
+func TestLoadAuthPolicy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "policy.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	policy, err := LoadAuthPolicy(write(`{"rules":[{"name":"root","uids":[0],"methods":["mount","unmount"],"targetPrefixes":["/var/lib/kubelet"]}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, &AuthPolicy{Rules: []AuthRule{{
+		Name:           "root",
+		UIDs:           []uint32{0},
+		Methods:        []proxy.Method{proxy.Mount, proxy.Unmount},
+		TargetPrefixes: []string{"/var/lib/kubelet"},
+	}}}, policy)
+
+	_, err = LoadAuthPolicy(write(`{"rules":[]}`))
+	assert.ErrorContains(t, err, "no rules")
+	_, err = LoadAuthPolicy(write(`{"rules":[{"methods":["mnt"]}]}`))
+	assert.ErrorContains(t, err, "unknown method")
+	_, err = LoadAuthPolicy(write(`{"rules":[{"targetPrefixes":["var/lib"]}]}`))
+	assert.ErrorContains(t, err, "not an absolute path")
+	_, err = LoadAuthPolicy(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestServerDeniesUnauthorizedPeer(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "mounter.sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	require.NoError(t, err)
+	srv := NewServer(listener, time.Second)
+	srv.SetAuthPolicy(&AuthPolicy{Rules: []AuthRule{{
+		Name:    "ping-only",
+		UIDs:    []uint32{uint32(os.Getuid())},
+		Methods: []proxy.Method{proxy.Ping},
+	}}})
+	go srv.Serve()
+	t.Cleanup(func() {
+		assert.NoError(t, srv.Close())
+	})
+
+	send := func(req *proxy.Request) proxy.Response {
+		conn := dialTestServer(t, socketPath)
+		data, err := json.Marshal(req)
+		require.NoError(t, err)
+		_, err = conn.Write(append(data, proxy.MessageEnd))
+		require.NoError(t, err)
+		return readResponse(t, conn)
+	}
+
+	resp := send(&proxy.Request{Header: proxy.Header{Method: proxy.Ping}})
+	assert.Empty(t, resp.Error)
+	resp = send(&proxy.Request{Header: proxy.Header{Method: proxy.List}})
+	assert.Contains(t, resp.Error, "permission denied")
+}
@@ -3,6 +3,8 @@ package server
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
 )
@@ -34,6 +36,13 @@ type Unmounter interface {
 	Unmount(target string) (owned bool, err error)
 }
 
+// CapabilityReporter is an optional interface a Driver may implement to report
+// the optional MountRequest fields it honors, e.g. proxy.CapabilityOverlay.
+// proxy.CapabilityUnmount is reported for drivers implementing Unmounter.
+type CapabilityReporter interface {
+	Capabilities() []proxy.Capability
+}
+
 var (
 	fstypeToDriver = map[string]Driver{}
 	nameToDriver   = map[string]Driver{}
@@ -48,6 +57,14 @@ func handleMountRequest(ctx context.Context, req *proxy.MountRequest) error {
 	if h == nil {
 		return fmt.Errorf("fstype %q not supported", req.Fstype)
 	}
+	if pinningTargets(ctx) {
+		pinned, err := pinTarget(req.Target)
+		if err != nil {
+			return err
+		}
+		defer pinned.Close()
+		ctx = context.WithValue(ctx, pinnedTargetKey{}, pinned)
+	}
 	return h.Mount(ctx, req)
 }
 
@@ -74,3 +91,50 @@ func handleUnmountRequest(_ context.Context, req *proxy.UnmountRequest) error {
 	}
 	return fmt.Errorf("%s: %s", proxy.ErrTargetNotManaged, req.Target)
 }
+
+// handleStatusRequest reports the target from whichever driver manages it.
+// Like unmount, it returns proxy.ErrTargetNotManaged if no driver does.
+func handleStatusRequest(req *proxy.StatusRequest) (*proxy.MountStatus, error) {
+	if req.Target == "" {
+		return nil, fmt.Errorf("empty status target")
+	}
+	for _, status := range listMounts() {
+		if status.Target == req.Target {
+			return &status, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %s", proxy.ErrTargetNotManaged, req.Target)
+}
+
+// hello reports the protocol version, the methods and the enabled drivers with their capabilities.
+func hello() *proxy.HelloResponse {
+	resp := &proxy.HelloResponse{
+		ProtocolVersion: proxy.ProtocolVersion,
+		Methods:         supportedMethods,
+		Drivers:         []proxy.DriverInfo{},
+	}
+	for _, d := range nameToDriver {
+		var fstypes []string
+		for _, fstype := range d.Fstypes() {
+			if fstypeToDriver[fstype] == d {
+				fstypes = append(fstypes, fstype)
+			}
+		}
+		if len(fstypes) == 0 {
+			// Not enabled by Init
+			continue
+		}
+		info := proxy.DriverInfo{Name: d.Name(), Fstypes: fstypes}
+		if r, ok := d.(CapabilityReporter); ok {
+			info.Capabilities = append(info.Capabilities, r.Capabilities()...)
+		}
+		if _, ok := d.(Unmounter); ok {
+			info.Capabilities = append(info.Capabilities, proxy.CapabilityUnmount)
+		}
+		resp.Drivers = append(resp.Drivers, info)
+	}
+	slices.SortFunc(resp.Drivers, func(a, b proxy.DriverInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return resp
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+)
+
+// FuseDaemonMountpoint is the mountpoint passed to fuse daemons of a FuseSession,
+// libfuse takes the FUSE connection from the fd instead of mounting.
+const FuseDaemonMountpoint = "/dev/fd/3"
+
+// FuseSession is a FUSE connection mounted by mount-proxy-server itself.
+// The fd of /dev/fuse is kept open, so the daemon serving it can be restarted:
+// requests to the mountpoint stall until the new daemon serves them instead of failing with ENOTCONN.
+// The kernel only sends FUSE_INIT once per connection, so a restarted daemon must be told to take over
+// the initialized connection; stock libfuse fails every request of such a restart with EIO.
+type FuseSession struct {
+	Target string
+
+	mu   sync.Mutex
+	dev  *os.File
+	done chan struct{}
+}
+
+// fuseDevice and mountFuse are variables so tests can fake them.
+var (
+	fuseDevice = "/dev/fuse"
+	mountFuse  = unix.Mount
+)
+
+// daemonRestartTimeout bounds how long a restarted daemon may take to serve the connection.
+const daemonRestartTimeout = 30 * time.Second
+
+// NewFuseSession opens /dev/fuse and mounts it on target as fuse.<fsType>, see MountTarget.
+// Only the options known by the kernel are used, the rest is for the daemon.
+func NewFuseSession(ctx context.Context, target, fsType string, options []string) (*FuseSession, error) {
+	dev, err := os.OpenFile(fuseDevice, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", fuseDevice, err)
+	}
+	flags := uintptr(unix.MS_NOSUID | unix.MS_NODEV)
+	if slices.Contains(options, "ro") {
+		flags |= unix.MS_RDONLY
+	}
+	data := []string{
+		fmt.Sprintf("fd=%d", dev.Fd()),
+		"rootmode=40000",
+		fmt.Sprintf("user_id=%d", os.Geteuid()),
+		fmt.Sprintf("group_id=%d", os.Getegid()),
+	}
+	for _, o := range options {
+		if o == "allow_other" || o == "default_permissions" {
+			data = append(data, o)
+		}
+	}
+	err = mountFuse(fsType, MountTarget(ctx, target), "fuse."+fsType, flags, strings.Join(data, ","))
+	if err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("mount fuse on %s: %w", target, err)
+	}
+	klog.InfoS("Mounted FUSE connection", "target", target, "fstype", fsType)
+	return &FuseSession{Target: target, dev: dev, done: make(chan struct{})}, nil
+}
+
+// DaemonFile returns a duplicate of the FUSE fd, to be passed to a daemon as fd 3.
+// The caller closes it once the daemon is started.
+func (s *FuseSession) DaemonFile() (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dev == nil {
+		return nil, fmt.Errorf("FUSE connection of %s is closed", s.Target)
+	}
+	fd, err := unix.Dup(int(s.dev.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("dup FUSE fd: %w", err)
+	}
+	return os.NewFile(uintptr(fd), fuseDevice), nil
+}
+
+// WaitReady waits for a daemon to answer the FUSE requests, or to exit.
+// The pending request is only interrupted by Abort.
+func (s *FuseSession) WaitReady(ctx context.Context, exited <-chan error) error {
+	ready := make(chan error, 1)
+	go func() {
+		var st unix.Statfs_t
+		ready <- unix.Statfs(s.Target, &st)
+	}()
+	select {
+	case err := <-ready:
+		return err
+	case err := <-exited:
+		if err != nil {
+			return fmt.Errorf("fuse daemon exited: %w", err)
+		}
+		return fmt.Errorf("fuse daemon exited")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done is closed once the kept fd is closed.
+func (s *FuseSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close closes the kept fd. The connection is gone with the last daemon holding it.
+func (s *FuseSession) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dev != nil {
+		s.dev.Close()
+		s.dev = nil
+		close(s.done)
+	}
+}
+
+// Abort detaches the mountpoint and closes the fd, failing the pending requests.
+func (s *FuseSession) Abort() {
+	if err := unix.Unmount(s.Target, unix.MNT_DETACH); err != nil {
+		klog.ErrorS(err, "Failed to detach FUSE mountpoint", "target", s.Target)
+	}
+	s.Close()
+}
+
+// DaemonRunner starts a fuse daemon serving a FuseSession and waits for it to be ready.
+// takeover is set for the restarted daemons, which must not wait for FUSE_INIT.
+type DaemonRunner func(ctx context.Context, takeover bool) (OssfsMountResult, error)
+
+// RunFuseSession runs the first daemon of session, and makes its result restartable.
+// The session is closed when a daemon exits cleanly (e.g. after the target was unmounted),
+// and aborted when the first daemon or a restart fails.
+func RunFuseSession(ctx context.Context, session *FuseSession, run DaemonRunner) (OssfsMountResult, error) {
+	var start func(ctx context.Context, takeover bool) (OssfsMountResult, error)
+	start = func(ctx context.Context, takeover bool) (OssfsMountResult, error) {
+		res, err := run(ctx, takeover)
+		if err != nil {
+			session.Abort()
+			return res, err
+		}
+		daemonExited, exited := res.ExitChan, make(chan error, 1)
+		go func() {
+			err := <-daemonExited
+			if err == nil {
+				session.Close()
+			}
+			exited <- err
+			close(exited)
+		}()
+		res.ExitChan = exited
+		res.Done = session.Done()
+		res.Abort = session.Abort
+		res.Restart = func() (OssfsMountResult, error) {
+			mounted, err := mountedTargets()
+			if err != nil {
+				session.Abort()
+				return OssfsMountResult{}, fmt.Errorf("check mountpoint %s: %w", session.Target, err)
+			}
+			if !mounted.Has(session.Target) {
+				session.Close()
+				return OssfsMountResult{}, fmt.Errorf("%s is no longer mounted", session.Target)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), daemonRestartTimeout)
+			defer cancel()
+			return start(ctx, true)
+		}
+		return res, nil
+	}
+	return start(ctx, false)
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeFuseSession(t *testing.T) (s *FuseSession, data *string) {
+	t.Helper()
+	origDevice, origMount := fuseDevice, mountFuse
+	t.Cleanup(func() { fuseDevice, mountFuse = origDevice, origMount })
+
+	fuseDevice = filepath.Join(t.TempDir(), "fuse")
+	require.NoError(t, os.WriteFile(fuseDevice, nil, 0o600))
+	data = new(string)
+	mountFuse = func(source, target, fstype string, flags uintptr, d string) error {
+		assert.Equal(t, "fuse.ossfs", fstype)
+		*data = d
+		return nil
+	}
+
+	s, err := NewFuseSession(context.Background(), t.TempDir(), "ossfs", []string{"allow_other", "max_stat_cache_size=0"})
+	require.NoError(t, err)
+	t.Cleanup(s.Close)
+	return s, data
+}
+
+func TestNewFuseSession(t *testing.T) {
+	s, data := fakeFuseSession(t)
+
+	assert.Contains(t, *data, fmt.Sprintf("fd=%d", s.dev.Fd()))
+	assert.Contains(t, *data, "allow_other")
+	assert.NotContains(t, *data, "max_stat_cache_size", "daemon options must not reach the kernel")
+
+	f, err := s.DaemonFile()
+	require.NoError(t, err)
+	assert.NotEqual(t, s.dev.Fd(), f.Fd())
+	f.Close()
+
+	s.Close()
+	_, err = s.DaemonFile()
+	assert.Error(t, err)
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("Done should be closed with the session")
+	}
+}
+
+func TestNewFuseSession_MountFails(t *testing.T) {
+	origDevice, origMount := fuseDevice, mountFuse
+	defer func() { fuseDevice, mountFuse = origDevice, origMount }()
+
+	fuseDevice = filepath.Join(t.TempDir(), "fuse")
+	require.NoError(t, os.WriteFile(fuseDevice, nil, 0o600))
+	mountFuse = func(string, string, string, uintptr, string) error {
+		return errors.New("EPERM")
+	}
+
+	_, err := NewFuseSession(context.Background(), t.TempDir(), "ossfs", nil)
+	assert.ErrorContains(t, err, "EPERM")
+}
+
+func exitedDaemon(pid int, exitErr error) OssfsMountResult {
+	exit := make(chan error, 1)
+	exit <- exitErr
+	close(exit)
+	return OssfsMountResult{PID: pid, ExitChan: exit}
+}
+
+func TestRunFuseSession_Restart(t *testing.T) {
+	s, _ := fakeFuseSession(t)
+	origPath := mountInfoPath
+	defer func() { mountInfoPath = origPath }()
+	mountInfoPath = writeFixture(t, fmt.Sprintf("310 331 0:200 / %s rw,relatime - fuse.ossfs ossfs rw,user_id=0\n", s.Target))
+
+	runs := 0
+	res, err := RunFuseSession(context.Background(), s, func(ctx context.Context, takeover bool) (OssfsMountResult, error) {
+		runs++
+		assert.Equal(t, runs > 1, takeover, "only restarts take over the connection")
+		if runs == 1 {
+			return exitedDaemon(runs, errors.New("crashed")), nil
+		}
+		return exitedDaemon(runs, nil), nil
+	})
+	require.NoError(t, err)
+	assert.Error(t, <-res.ExitChan)
+	require.NotNil(t, res.Restart)
+
+	res, err = res.Restart()
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.PID)
+	assert.NoError(t, <-res.ExitChan)
+	<-res.Done
+}
+
+func TestRunFuseSession_RestartAfterUnmount(t *testing.T) {
+	s, _ := fakeFuseSession(t)
+	origPath := mountInfoPath
+	defer func() { mountInfoPath = origPath }()
+	mountInfoPath = writeFixture(t, "")
+
+	res, err := RunFuseSession(context.Background(), s, func(ctx context.Context, takeover bool) (OssfsMountResult, error) {
+		return exitedDaemon(1, errors.New("crashed")), nil
+	})
+	require.NoError(t, err)
+	<-res.ExitChan
+
+	_, err = res.Restart()
+	assert.ErrorContains(t, err, "no longer mounted")
+	<-res.Done
+}
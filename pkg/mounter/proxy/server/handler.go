@@ -16,7 +16,11 @@ import (
 	"k8s.io/klog/v2"
 )
 
-func Handle(conn *net.UnixConn, timeout time.Duration, seq int64) error {
+// Authorizer decides whether a request may be handled, and returns the body to handle.
+// nil allows all requests.
+type Authorizer func(method proxy.Method, body json.RawMessage) (json.RawMessage, error)
+
+func Handle(conn *net.UnixConn, timeout time.Duration, seq int64, authorize Authorizer) error {
 	logger := klog.Background().WithValues("seq", seq)
 	ctx := klog.NewContext(context.Background(), logger)
 	deadline := time.Now().Add(timeout)
@@ -57,6 +61,16 @@ func Handle(conn *net.UnixConn, timeout time.Duration, seq int64) error {
 		resp = proxy.Response{
 			Error: fmt.Sprintf("read request: %v", err),
 		}
+	} else if authorize != nil {
+		// The target checked by authorize must be the one mounted
+		ctx = withTargetPinning(ctx)
+		if req.Body, err = authorize(req.Header.Method, req.Body); err != nil {
+			resp = proxy.Response{
+				Error: err.Error(),
+			}
+		} else {
+			resp = handle(ctx, &req)
+		}
 	} else {
 		resp = handle(ctx, &req)
 	}
@@ -79,6 +93,12 @@ func Handle(conn *net.UnixConn, timeout time.Duration, seq int64) error {
 	return nil
 }
 
+// supportedMethods are the methods reported by Hello.
+var supportedMethods = []proxy.Method{
+	proxy.Mount, proxy.Unmount, proxy.Ping, proxy.List, proxy.Status,
+	proxy.Hello, proxy.Wait, proxy.Cancel,
+}
+
 type rawRequest struct {
 	Header proxy.Header    `json:"header"`
 	Body   json.RawMessage `json:"body,omitempty"`
@@ -94,6 +114,15 @@ func handle(ctx context.Context, req *rawRequest) proxy.Response {
 				Error: err.Error(),
 			}
 		}
+		if mountReq.Async {
+			id, err := operations.start(ctx, &mountReq)
+			if err != nil {
+				return proxy.Response{
+					Error: err.Error(),
+				}
+			}
+			return resultResponse(&proxy.MountOperation{OperationID: id})
+		}
 		err = handleMountRequest(ctx, &mountReq)
 		if err != nil {
 			return proxy.Response{
@@ -116,6 +145,45 @@ func handle(ctx context.Context, req *rawRequest) proxy.Response {
 		}
 	case proxy.Ping:
 		return proxy.Response{}
+	case proxy.List:
+		return resultResponse(&proxy.ListResponse{Mounts: listMounts()})
+	case proxy.Status:
+		var statusReq proxy.StatusRequest
+		err := json.Unmarshal(req.Body, &statusReq)
+		if err != nil {
+			return proxy.Response{
+				Error: err.Error(),
+			}
+		}
+		status, err := handleStatusRequest(&statusReq)
+		if err != nil {
+			return proxy.Response{
+				Error: err.Error(),
+			}
+		}
+		return resultResponse(status)
+	case proxy.Hello:
+		return resultResponse(hello())
+	case proxy.Wait, proxy.Cancel:
+		var op proxy.MountOperation
+		err := json.Unmarshal(req.Body, &op)
+		if err != nil {
+			return proxy.Response{
+				Error: err.Error(),
+			}
+		}
+		var status *proxy.OperationStatus
+		if req.Header.Method == proxy.Wait {
+			status, err = operations.wait(ctx, op.OperationID)
+		} else {
+			status, err = operations.cancel(ctx, op.OperationID)
+		}
+		if err != nil {
+			return proxy.Response{
+				Error: err.Error(),
+			}
+		}
+		return resultResponse(status)
 	default:
 		return proxy.Response{
 			Error: "invalid method",
@@ -124,6 +192,16 @@ func handle(ctx context.Context, req *rawRequest) proxy.Response {
 	return proxy.Response{}
 }
 
+func resultResponse(result any) proxy.Response {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return proxy.Response{
+			Error: fmt.Sprintf("encode result: %v", err),
+		}
+	}
+	return proxy.Response{Result: data}
+}
+
 var cleanupNASMountsOnExit atomic.Bool
 
 // SetCleanupNASMountsOnExit controls whether the alinas driver should
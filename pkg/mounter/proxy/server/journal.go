@@ -0,0 +1,159 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	"k8s.io/klog/v2"
+)
+
+var (
+	stateDirMu sync.RWMutex
+	stateDir   string
+)
+
+// SetStateDir sets the directory where drivers persist their mount journals.
+// Journals are disabled when dir is empty.
+func SetStateDir(dir string) {
+	stateDirMu.Lock()
+	defer stateDirMu.Unlock()
+	stateDir = dir
+}
+
+// StateDir returns the directory set by SetStateDir.
+func StateDir() string {
+	stateDirMu.RLock()
+	defer stateDirMu.RUnlock()
+	return stateDir
+}
+
+// JournalEntry is a mount request served by a driver, without its secrets.
+type JournalEntry struct {
+	proxy.MountRequest
+	MountTime time.Time `json:"mountTime"`
+}
+
+// MountJournal persists the mount requests a driver served, keyed by target,
+// so that the ownership of mounts outliving the process (e.g. NFS) survives a
+// restart of mount-proxy-server.
+// A nil MountJournal is valid and records nothing.
+type MountJournal struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]JournalEntry
+}
+
+// OpenMountJournal loads the journal of driver from the state directory.
+// It returns nil when no state directory is set.
+func OpenMountJournal(driver string) (*MountJournal, error) {
+	dir := StateDir()
+	if dir == "" {
+		return nil, nil
+	}
+	j := &MountJournal{
+		path:    filepath.Join(dir, driver+"-mounts.json"),
+		entries: map[string]JournalEntry{},
+	}
+	data, err := os.ReadFile(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return j, nil
+		}
+		return nil, fmt.Errorf("read mount journal: %w", err)
+	}
+	var entries []JournalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// A corrupted journal only loses the ownership of existing mounts, do not block new ones
+		klog.ErrorS(err, "Ignoring corrupted mount journal", "path", j.path)
+		return j, nil
+	}
+	for _, e := range entries {
+		j.entries[e.Target] = e
+	}
+	return j, nil
+}
+
+// Record adds req to the journal. Secrets are never written.
+func (j *MountJournal) Record(req *proxy.MountRequest) {
+	if j == nil {
+		return
+	}
+	entry := JournalEntry{MountRequest: *req, MountTime: time.Now()}
+	entry.Secrets = nil
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries[req.Target] = entry
+	j.save()
+}
+
+// Remove drops target from the journal.
+func (j *MountJournal) Remove(target string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.entries[target]; !ok {
+		return
+	}
+	delete(j.entries, target)
+	j.save()
+}
+
+// LiveEntries returns the entries whose target is still mounted,
+// and drops the others from the journal.
+func (j *MountJournal) LiveEntries() []JournalEntry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	mounted, err := mountedTargets()
+	if err != nil {
+		// Keep the entries, the next restart will check them again
+		klog.ErrorS(err, "Failed to check journaled mountpoints")
+		return nil
+	}
+	var live []JournalEntry
+	changed := false
+	for target, e := range j.entries {
+		if !mounted.Has(target) {
+			klog.InfoS("Dropping journaled mount that is gone", "target", target)
+			delete(j.entries, target)
+			changed = true
+			continue
+		}
+		live = append(live, e)
+	}
+	if changed {
+		j.save()
+	}
+	return live
+}
+
+// save writes the journal atomically. Failures are only logged:
+// the mount itself succeeded, and the journal is rewritten on the next change.
+func (j *MountJournal) save() {
+	entries := make([]JournalEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		entries = append(entries, e)
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		klog.ErrorS(err, "Failed to marshal mount journal", "path", j.path)
+		return
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		klog.ErrorS(err, "Failed to write mount journal", "path", tmp)
+		return
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		klog.ErrorS(err, "Failed to write mount journal", "path", j.path)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountJournal_Disabled(t *testing.T) {
+	SetStateDir("")
+	j, err := OpenMountJournal("alinas")
+	require.NoError(t, err)
+	assert.Nil(t, j)
+	// A nil journal records nothing
+	j.Record(&proxy.MountRequest{Target: "/mnt/nas"})
+	j.Remove("/mnt/nas")
+	assert.Empty(t, j.LiveEntries())
+}
+
+func TestMountJournal_RecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	SetStateDir(dir)
+	defer SetStateDir("")
+	origPath := mountInfoPath
+	defer func() { mountInfoPath = origPath }()
+	mountInfoPath = writeFixture(t, "310 331 0:200 / /mnt/live rw,relatime - nfs 1.2.3.4:/ rw,vers=3\n")
+
+	j, err := OpenMountJournal("alinas")
+	require.NoError(t, err)
+	j.Record(&proxy.MountRequest{
+		Source:  "1.2.3.4:/",
+		Target:  "/mnt/live",
+		Fstype:  "alinas",
+		Options: []string{"vers=3"},
+		Secrets: map[string]string{"akId": "ak", "akSecret": "sk"},
+	})
+	j.Record(&proxy.MountRequest{Target: "/mnt/gone"})
+	j.Record(&proxy.MountRequest{Target: "/mnt/removed"})
+	j.Remove("/mnt/removed")
+
+	data, err := os.ReadFile(filepath.Join(dir, "alinas-mounts.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "akSecret", "secrets must never be written")
+
+	// A restarted process reads the journal back
+	j, err = OpenMountJournal("alinas")
+	require.NoError(t, err)
+	live := j.LiveEntries()
+	require.Len(t, live, 1)
+	assert.Equal(t, "/mnt/live", live[0].Target)
+	assert.Equal(t, []string{"vers=3"}, live[0].Options)
+	assert.Nil(t, live[0].Secrets)
+	assert.False(t, live[0].MountTime.IsZero())
+
+	// Entries that are gone are dropped for good
+	mountInfoPath = writeFixture(t, fmt.Sprintf("310 331 0:200 / %s rw,relatime - nfs 1.2.3.4:/ rw\n", "/mnt/gone"))
+	j, err = OpenMountJournal("alinas")
+	require.NoError(t, err)
+	assert.Empty(t, j.LiveEntries())
+}
+
+func TestMountJournal_Corrupted(t *testing.T) {
+	dir := t.TempDir()
+	SetStateDir(dir)
+	defer SetStateDir("")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "alinas-mounts.json"), []byte("{"), 0o600))
+
+	j, err := OpenMountJournal("alinas")
+	require.NoError(t, err)
+	assert.Empty(t, j.LiveEntries())
+}
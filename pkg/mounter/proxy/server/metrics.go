@@ -143,6 +143,23 @@ func (m *MountMonitor) HandleMountSuccess(pid int) {
 	klog.InfoS("Mount succeeded", "target", m.Target, "pid", m.Pid)
 }
 
+// HandleFailover handles the case when a new fuse client took over
+// the FUSE connection of an exited one
+func (m *MountMonitor) HandleFailover(pid int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.failoverCount < maxCountRecord {
+		m.failoverCount++
+	}
+	m.updateMountPointMetrics(nil, &m.failoverCount, nil)
+
+	m.Pid = pid
+	m.State = MonitorStateMonitoring
+
+	klog.InfoS("Fuse client restarted", "target", m.Target, "pid", m.Pid, "failover_count", m.failoverCount)
+}
+
 // Stop stops the monitoring and cleans up metrics files
 func (m *MountMonitor) Stop() {
 	// Close stopCh first to signal monitoring goroutine to stop
@@ -304,6 +321,32 @@ func (m *MountMonitor) readMetricsFiles(metrics string) string {
 	}
 }
 
+// MonitorStatus is a snapshot of what a MountMonitor knows about its target.
+type MonitorStatus struct {
+	Pid           int
+	RetryCount    int
+	FailoverCount int
+	// Healthy is false when the client exited or the last check of the mountpoint failed
+	Healthy bool
+}
+
+// Status returns a snapshot of the monitored target.
+// The monitor holds its lock while checking the mountpoint, which may hang on a dead
+// server, so ok is false instead of blocking when a check is in progress.
+func (m *MountMonitor) Status() (status MonitorStatus, ok bool) {
+	if !m.mu.TryRLock() {
+		return MonitorStatus{}, false
+	}
+	defer m.mu.RUnlock()
+	return MonitorStatus{
+		Pid:           m.Pid,
+		RetryCount:    m.retryCount,
+		FailoverCount: m.failoverCount,
+		// metrics file stores notHealthy as "1", healthy as "0"
+		Healthy: m.State == MonitorStateMonitoring && m.readMetricsFiles(utils.MetricsMountPointStatus) != "1",
+	}, true
+}
+
 // StartMonitoring starts the monitoring goroutine for this monitor
 func (manager *MountMonitorManager) StartMonitoring(target string) {
 	monitor, _ := manager.GetMountMonitor(target, "", nil, false)
@@ -332,6 +375,16 @@ func (manager *MountMonitorManager) StartMonitoring(target string) {
 	}()
 }
 
+// StopMonitoring stops the monitoring of a target that was unmounted
+func (manager *MountMonitorManager) StopMonitoring(target string) {
+	value, loaded := manager.monitors.LoadAndDelete(target)
+	if !loaded {
+		return
+	}
+	klog.InfoS("Stopping mount monitoring", "target", target)
+	value.(*MountMonitor).Stop()
+}
+
 // StopAllMonitoring stops all mount monitoring
 func (manager *MountMonitorManager) StopAllMonitoring() {
 	manager.monitors.Range(func(key, value any) bool {
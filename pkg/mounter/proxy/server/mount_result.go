@@ -3,4 +3,12 @@ package server
 type OssfsMountResult struct {
 	PID      int
 	ExitChan chan error
+	// Restart starts a new daemon on the FUSE connection of a fd-passing mount after
+	// the previous one failed, nil otherwise.
+	Restart func() (OssfsMountResult, error)
+	// Done is closed when the FUSE connection of a fd-passing mount is gone for good.
+	// It is nil otherwise, and the mount ends with the exit of the daemon.
+	Done <-chan struct{}
+	// Abort fails the pending and future requests of a fd-passing mount whose daemon is not restarted, nil otherwise.
+	Abort func()
 }
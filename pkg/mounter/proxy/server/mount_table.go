@@ -0,0 +1,141 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	mountutils "k8s.io/mount-utils"
+)
+
+// Lister is an optional interface a Driver may implement to report the targets
+// it manages to the list and status methods.
+type Lister interface {
+	List() []proxy.MountStatus
+}
+
+// MountTable records the mounts served by a driver, for Lister.
+// A nil MountTable is valid and records nothing.
+type MountTable struct {
+	driver string
+	// map[string]*mountTableEntry - key is target path
+	mounts sync.Map
+}
+
+type mountTableEntry struct {
+	status proxy.MountStatus
+	// monitorTarget is where the daemon serves the mount and its monitor watches it,
+	// i.e. the lower dir of an overlay.
+	monitorTarget string
+}
+
+func NewMountTable(driver string) *MountTable {
+	return &MountTable{driver: driver}
+}
+
+// Add records a mount of req served at mountTime, monitored at monitorTarget.
+// Secrets are never recorded, and credentials in the options are redacted.
+func (t *MountTable) Add(req *proxy.MountRequest, monitorTarget string, pid int, mountTime time.Time) {
+	if t == nil {
+		return
+	}
+	t.mounts.Store(req.Target, &mountTableEntry{
+		status: proxy.MountStatus{
+			Target:    req.Target,
+			Driver:    t.driver,
+			Source:    req.Source,
+			Fstype:    req.Fstype,
+			Options:   proxy.RedactOptions(req.Options),
+			PID:       pid,
+			MountTime: mountTime,
+		},
+		monitorTarget: monitorTarget,
+	})
+}
+
+// Remove drops target from the table.
+func (t *MountTable) Remove(target string) {
+	if t == nil {
+		return
+	}
+	t.mounts.Delete(target)
+}
+
+// List returns the recorded mounts sorted by target, with the pid, retry count and
+// liveness seen by their monitor in monitors, if any.
+// Targets that are no longer mounted are dropped from the table.
+func (t *MountTable) List(monitors *MountMonitorManager) []proxy.MountStatus {
+	if t == nil {
+		return nil
+	}
+	mounted, err := mountedTargets()
+	if err != nil {
+		klog.ErrorS(err, "Failed to read mount table, reporting all targets as not alive")
+	}
+	var list []proxy.MountStatus
+	t.mounts.Range(func(key, value any) bool {
+		entry := value.(*mountTableEntry)
+		status := entry.status
+		if err == nil && !mounted.Has(status.Target) {
+			// Keep a record replaced by a concurrent mount of the same target
+			t.mounts.CompareAndDelete(key, value)
+			return true
+		}
+		status.Alive = err == nil
+		if monitors != nil {
+			if monitor, found := monitors.GetMountMonitor(entry.monitorTarget, "", nil, false); found {
+				status.Monitored = true
+				ms, ok := monitor.Status()
+				if !ok {
+					klog.V(2).InfoS("Mountpoint is being checked, reporting it as not alive", "target", status.Target)
+					status.Alive = false
+				} else {
+					if ms.Pid != 0 {
+						status.PID = ms.Pid
+					}
+					status.RetryCount = ms.RetryCount
+					status.FailoverCount = ms.FailoverCount
+					status.Alive = ms.Healthy
+				}
+			}
+		}
+		list = append(list, status)
+		return true
+	})
+	slices.SortFunc(list, func(a, b proxy.MountStatus) int {
+		return strings.Compare(a.Target, b.Target)
+	})
+	return list
+}
+
+// mountedTargets returns the mountpoints in the mount table.
+// Unlike stat, this never waits for the daemon of a FUSE mountpoint.
+func mountedTargets() (sets.Set[string], error) {
+	mis, err := mountutils.ParseMountInfo(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	targets := sets.New[string]()
+	for _, mi := range mis {
+		targets.Insert(mi.MountPoint)
+	}
+	return targets, nil
+}
+
+// listMounts collects the targets of all drivers implementing Lister.
+func listMounts() []proxy.MountStatus {
+	list := []proxy.MountStatus{}
+	for _, d := range nameToDriver {
+		if l, ok := d.(Lister); ok {
+			list = append(list, l.List()...)
+		}
+	}
+	slices.SortFunc(list, func(a, b proxy.MountStatus) int {
+		return strings.Compare(a.Target, b.Target)
+	})
+	return list
+}
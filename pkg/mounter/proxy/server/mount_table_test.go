@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountTable_List(t *testing.T) {
+	origPath := mountInfoPath
+	defer func() { mountInfoPath = origPath }()
+	mountInfoPath = writeFixture(t, "310 331 0:200 / /mnt/monitored rw - fuse.ossfs ossfs rw\n"+
+		"311 331 0:201 / /mnt/plain rw - fuse.ossfs ossfs rw\n")
+
+	mountTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	table := NewMountTable("ossfs")
+	table.Add(&proxy.MountRequest{
+		Source:  "bucket:/",
+		Target:  "/mnt/plain",
+		Fstype:  "ossfs",
+		Options: []string{"passwd_file=/tmp/passwd,allow_other"},
+		Secrets: map[string]string{"akSecret": "sk"},
+	}, "/mnt/plain", 100, mountTime)
+	table.Add(&proxy.MountRequest{Target: "/mnt/monitored", Fstype: "ossfs"}, "/mnt/monitored", 101, mountTime)
+	table.Add(&proxy.MountRequest{Target: "/mnt/gone", Fstype: "ossfs"}, "/mnt/gone", 102, mountTime)
+
+	monitors := NewMountMonitorManager()
+	monitor, _ := monitors.GetMountMonitor("/mnt/monitored", t.TempDir(), nil, true)
+	require.NotNil(t, monitor)
+	monitor.IncreaseMountRetryCount()
+	monitor.HandleMountSuccess(200)
+
+	assert.Equal(t, []proxy.MountStatus{
+		{
+			Target:        "/mnt/monitored",
+			Driver:        "ossfs",
+			Fstype:        "ossfs",
+			PID:           200,
+			MountTime:     mountTime,
+			RetryCount:    1,
+			FailoverCount: 0,
+			Monitored:     true,
+			Alive:         true,
+		},
+		{
+			Target:    "/mnt/plain",
+			Driver:    "ossfs",
+			Source:    "bucket:/",
+			Fstype:    "ossfs",
+			Options:   []string{"passwd_file=<redacted>,allow_other"},
+			PID:       100,
+			MountTime: mountTime,
+			Alive:     true,
+		},
+	}, table.List(monitors))
+
+	// The unmounted target was dropped
+	_, ok := table.mounts.Load("/mnt/gone")
+	assert.False(t, ok)
+
+	monitor.HandleMountFailureOrExit(errors.New("ossfs crashed"))
+	list := table.List(monitors)
+	require.Len(t, list, 2)
+	assert.False(t, list[0].Alive)
+}
+
+func TestMountTable_Nil(t *testing.T) {
+	var table *MountTable
+	table.Add(&proxy.MountRequest{Target: "/mnt/a"}, "/mnt/a", 0, time.Now())
+	table.Remove("/mnt/a")
+	assert.Nil(t, table.List(nil))
+}
+
+// fakeListDriver is a minimal Driver implementing Lister.
+type fakeListDriver struct {
+	fakeUnmountDriver
+	mounts []proxy.MountStatus
+}
+
+func (d *fakeListDriver) List() []proxy.MountStatus { return d.mounts }
+
+func TestHandleList(t *testing.T) {
+	withRegisteredDriver(t, &fakeListDriver{
+		fakeUnmountDriver: fakeUnmountDriver{name: "fake-b"},
+		mounts:            []proxy.MountStatus{{Target: "/mnt/b", Driver: "fake-b"}},
+	})
+	withRegisteredDriver(t, &fakeListDriver{
+		fakeUnmountDriver: fakeUnmountDriver{name: "fake-a"},
+		mounts:            []proxy.MountStatus{{Target: "/mnt/a", Driver: "fake-a"}},
+	})
+	// Drivers without Lister are skipped
+	withRegisteredDriver(t, &fakeUnmountDriver{name: "fake-c"})
+
+	resp := handle(context.Background(), &rawRequest{
+		Header: proxy.Header{Method: proxy.List},
+	})
+	require.Empty(t, resp.Error)
+	var list proxy.ListResponse
+	require.NoError(t, json.Unmarshal(resp.Result, &list))
+	assert.Equal(t, []proxy.MountStatus{
+		{Target: "/mnt/a", Driver: "fake-a"},
+		{Target: "/mnt/b", Driver: "fake-b"},
+	}, list.Mounts)
+}
+
+func TestHandleStatus(t *testing.T) {
+	withRegisteredDriver(t, &fakeListDriver{
+		fakeUnmountDriver: fakeUnmountDriver{name: "fake-a"},
+		mounts:            []proxy.MountStatus{{Target: "/mnt/a", Driver: "fake-a", PID: 10}},
+	})
+
+	resp := handle(context.Background(), &rawRequest{
+		Header: proxy.Header{Method: proxy.Status},
+		Body:   json.RawMessage(`{"target":"/mnt/a"}`),
+	})
+	require.Empty(t, resp.Error)
+	var status proxy.MountStatus
+	require.NoError(t, json.Unmarshal(resp.Result, &status))
+	assert.Equal(t, proxy.MountStatus{Target: "/mnt/a", Driver: "fake-a", PID: 10}, status)
+
+	resp = handle(context.Background(), &rawRequest{
+		Header: proxy.Header{Method: proxy.Status},
+		Body:   json.RawMessage(`{"target":"/mnt/other"}`),
+	})
+	assert.Contains(t, resp.Error, proxy.ErrTargetNotManaged)
+
+	resp = handle(context.Background(), &rawRequest{
+		Header: proxy.Header{Method: proxy.Status},
+		Body:   json.RawMessage(`{}`),
+	})
+	assert.Contains(t, resp.Error, "empty status target")
+}
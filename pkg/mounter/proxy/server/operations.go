@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	"k8s.io/klog/v2"
+)
+
+var (
+	// asyncMountTimeout bounds an async mount whose client went away without cancelling it.
+	asyncMountTimeout = 10 * time.Minute
+	// operationRetention is how long the result of a finished operation can be waited for.
+	operationRetention = 5 * time.Minute
+)
+
+var errCancelledByClient = errors.New("mount cancelled by client")
+
+// asyncOperation is a mount running independently of the connection that started it.
+type asyncOperation struct {
+	id     string
+	target string
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+	// err is set before done is closed
+	err error
+}
+
+func (op *asyncOperation) status() *proxy.OperationStatus {
+	status := &proxy.OperationStatus{OperationID: op.id}
+	select {
+	case <-op.done:
+		status.Done = true
+		if op.err != nil {
+			status.Error = op.err.Error()
+		}
+	default:
+	}
+	return status
+}
+
+// operationManager tracks the async mounts, so the clients can wait for them with
+// requests bounded by their own deadline, and cancel them.
+type operationManager struct {
+	mu  sync.Mutex
+	ops map[string]*asyncOperation
+}
+
+var operations = &operationManager{ops: map[string]*asyncOperation{}}
+
+// start starts mounting req in the background and returns the operation ID.
+func (m *operationManager) start(ctx context.Context, req *proxy.MountRequest) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, op := range m.ops {
+		if op.target == req.Target && !op.status().Done {
+			return "", fmt.Errorf("mount of %s already in progress as operation %s", req.Target, op.id)
+		}
+	}
+
+	id, err := newOperationID()
+	if err != nil {
+		return "", err
+	}
+	logger := klog.FromContext(ctx).WithValues("operationID", id)
+	opCtx, cancelTimeout := context.WithTimeout(klog.NewContext(context.Background(), logger), asyncMountTimeout)
+	opCtx, cancel := context.WithCancelCause(opCtx)
+	if pinningTargets(ctx) {
+		opCtx = withTargetPinning(opCtx)
+	}
+	op := &asyncOperation{
+		id:     id,
+		target: req.Target,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	m.ops[id] = op
+
+	logger.Info("Started async mount", "target", req.Target)
+	go func() {
+		defer cancelTimeout()
+		op.err = handleMountRequest(opCtx, req)
+		close(op.done)
+		if op.err != nil {
+			logger.Error(op.err, "Async mount failed", "target", req.Target)
+		} else {
+			logger.Info("Async mount succeeded", "target", req.Target)
+		}
+		time.AfterFunc(operationRetention, func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			delete(m.ops, id)
+		})
+	}()
+	return id, nil
+}
+
+func (m *operationManager) get(id string) (*asyncOperation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	op, ok := m.ops[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown operation %q", id)
+	}
+	return op, nil
+}
+
+// wait waits for the operation to finish, or for ctx to be done.
+// The status is not done in the latter case, and the client should wait again.
+func (m *operationManager) wait(ctx context.Context, id string) (*proxy.OperationStatus, error) {
+	op, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-op.done:
+	case <-ctx.Done():
+	}
+	return op.status(), nil
+}
+
+// cancel cancels the operation and waits for it to stop, like wait.
+func (m *operationManager) cancel(ctx context.Context, id string) (*proxy.OperationStatus, error) {
+	op, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+	klog.FromContext(ctx).Info("Cancelling async mount", "operationID", id, "target", op.target)
+	op.cancel(errCancelledByClient)
+	return m.wait(ctx, id)
+}
+
+func newOperationID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate operation ID: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
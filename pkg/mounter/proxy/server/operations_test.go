@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingMountDriver mounts when release is closed, or fails when its context is done.
+type blockingMountDriver struct {
+	fakeUnmountDriver
+	release chan struct{}
+	err     error
+}
+
+func (d *blockingMountDriver) Fstypes() []string { return []string{d.name} }
+
+func (d *blockingMountDriver) Mount(ctx context.Context, _ *proxy.MountRequest) error {
+	select {
+	case <-d.release:
+		return d.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// withEnabledDriver registers and enables d for its fstypes, and restores on cleanup.
+func withEnabledDriver(t *testing.T, d Driver) {
+	t.Helper()
+	withRegisteredDriver(t, d)
+	for _, fstype := range d.Fstypes() {
+		prev, existed := fstypeToDriver[fstype]
+		fstypeToDriver[fstype] = d
+		t.Cleanup(func() {
+			if existed {
+				fstypeToDriver[fstype] = prev
+			} else {
+				delete(fstypeToDriver, fstype)
+			}
+		})
+	}
+}
+
+func shortContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestAsyncMount_Wait(t *testing.T) {
+	d := &blockingMountDriver{
+		fakeUnmountDriver: fakeUnmountDriver{name: "blocking-wait"},
+		release:           make(chan struct{}),
+		err:               errors.New("mount failed"),
+	}
+	withEnabledDriver(t, d)
+
+	id, err := operations.start(context.Background(), &proxy.MountRequest{Fstype: "blocking-wait", Target: "/mnt/wait"})
+	require.NoError(t, err)
+
+	// The deadline of the wait request is reached first
+	status, err := operations.wait(shortContext(t), id)
+	require.NoError(t, err)
+	assert.Equal(t, &proxy.OperationStatus{OperationID: id}, status)
+
+	// Only one mount of a target at a time
+	_, err = operations.start(context.Background(), &proxy.MountRequest{Fstype: "blocking-wait", Target: "/mnt/wait"})
+	assert.ErrorContains(t, err, "already in progress")
+
+	close(d.release)
+	status, err = operations.wait(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, &proxy.OperationStatus{OperationID: id, Done: true, Error: "mount failed"}, status)
+}
+
+func TestAsyncMount_Cancel(t *testing.T) {
+	withEnabledDriver(t, &blockingMountDriver{
+		fakeUnmountDriver: fakeUnmountDriver{name: "blocking-cancel"},
+		release:           make(chan struct{}),
+	})
+
+	id, err := operations.start(context.Background(), &proxy.MountRequest{Fstype: "blocking-cancel", Target: "/mnt/cancel"})
+	require.NoError(t, err)
+
+	status, err := operations.cancel(context.Background(), id)
+	require.NoError(t, err)
+	assert.True(t, status.Done)
+	assert.Equal(t, context.Canceled.Error(), status.Error)
+}
+
+func TestAsyncMount_UnknownOperation(t *testing.T) {
+	_, err := operations.wait(context.Background(), "nonexistent")
+	assert.ErrorContains(t, err, "unknown operation")
+	_, err = operations.cancel(context.Background(), "nonexistent")
+	assert.ErrorContains(t, err, "unknown operation")
+}
+
+func TestHandleAsyncMount(t *testing.T) {
+	d := &blockingMountDriver{
+		fakeUnmountDriver: fakeUnmountDriver{name: "blocking-handle"},
+		release:           make(chan struct{}),
+	}
+	withEnabledDriver(t, d)
+	close(d.release)
+
+	resp := handle(context.Background(), &rawRequest{
+		Header: proxy.Header{Method: proxy.Mount},
+		Body:   json.RawMessage(`{"fstype":"blocking-handle","target":"/mnt/handle","async":true}`),
+	})
+	require.Empty(t, resp.Error)
+	var op proxy.MountOperation
+	require.NoError(t, json.Unmarshal(resp.Result, &op))
+	require.NotEmpty(t, op.OperationID)
+
+	body, err := json.Marshal(&op)
+	require.NoError(t, err)
+	resp = handle(context.Background(), &rawRequest{
+		Header: proxy.Header{Method: proxy.Wait},
+		Body:   body,
+	})
+	require.Empty(t, resp.Error)
+	var status proxy.OperationStatus
+	require.NoError(t, json.Unmarshal(resp.Result, &status))
+	assert.Equal(t, proxy.OperationStatus{OperationID: op.OperationID, Done: true}, status)
+}
+
+func TestHandleHello(t *testing.T) {
+	withEnabledDriver(t, &blockingMountDriver{fakeUnmountDriver: fakeUnmountDriver{name: "hello-enabled"}})
+	// Registered but not enabled by Init
+	withRegisteredDriver(t, &blockingMountDriver{fakeUnmountDriver: fakeUnmountDriver{name: "hello-disabled"}})
+
+	resp := handle(context.Background(), &rawRequest{
+		Header: proxy.Header{Method: proxy.Hello},
+		Body:   json.RawMessage(`{"protocolVersion":2}`),
+	})
+	require.Empty(t, resp.Error)
+	var hello proxy.HelloResponse
+	require.NoError(t, json.Unmarshal(resp.Result, &hello))
+	assert.Equal(t, proxy.ProtocolVersion, hello.ProtocolVersion)
+	assert.True(t, hello.HasMethod(proxy.Wait))
+	assert.Contains(t, hello.Drivers, proxy.DriverInfo{
+		Name:         "hello-enabled",
+		Fstypes:      []string{"hello-enabled"},
+		Capabilities: []proxy.Capability{proxy.CapabilityUnmount},
+	})
+	for _, d := range hello.Drivers {
+		assert.NotEqual(t, "hello-disabled", d.Name)
+	}
+}
@@ -36,6 +36,7 @@ type Driver struct {
 	monitorManager *server.MountMonitorManager
 	wg             sync.WaitGroup
 	overlay        *server.OverlayManager
+	mounts         *server.MountTable
 }
 
 func NewDriver() *Driver {
@@ -43,6 +44,7 @@ func NewDriver() *Driver {
 	driver := &Driver{
 		monitorManager: server.NewMountMonitorManager(),
 		overlay:        server.NewOverlayManager(rawMounter),
+		mounts:         server.NewMountTable("ossfs"),
 	}
 	m := &extendedMounter{
 		driver:    driver,
@@ -66,7 +68,11 @@ func (h *Driver) Fstypes() []string {
 }
 
 func (h *Driver) Mount(ctx context.Context, req *proxy.MountRequest) error {
-	return h.ExtendedMount(ctx, &mounter.MountOperation{
+	// ossfs can not take over an initialized FUSE connection, see Capabilities
+	if req.FdPassing {
+		klog.FromContext(ctx).Info("fdPassing is not supported by ossfs, ignored", "mountpoint", req.Target)
+	}
+	op := &mounter.MountOperation{
 		Source:      req.Source,
 		Target:      req.Target,
 		FsType:      req.Fstype,
@@ -75,7 +81,29 @@ func (h *Driver) Mount(ctx context.Context, req *proxy.MountRequest) error {
 		MetricsPath: req.MetricsPath,
 		VolumeID:    req.VolumeID,
 		Overlay:     req.Overlay,
-	})
+	}
+	if err := h.ExtendedMount(ctx, op); err != nil {
+		return err
+	}
+	// No result when the target was already mounted and only its credentials were rotated
+	if res, ok := op.MountResult.(server.OssfsMountResult); ok {
+		h.mounts.Add(req, op.Target, res.PID, time.Now())
+	}
+	return nil
+}
+
+var _ server.CapabilityReporter = (*Driver)(nil)
+
+// Capabilities implements server.CapabilityReporter.
+func (h *Driver) Capabilities() []proxy.Capability {
+	return []proxy.Capability{proxy.CapabilityOverlay}
+}
+
+var _ server.Lister = (*Driver)(nil)
+
+// List implements server.Lister.
+func (h *Driver) List() []proxy.MountStatus {
+	return h.mounts.List(interceptors.OssfsMountMonitors())
 }
 
 func (h *Driver) Init() {}
@@ -136,13 +164,22 @@ type extendedMounter struct {
 var _ mounter.Mounter = &extendedMounter{}
 
 func (m *extendedMounter) ExtendedMount(ctx context.Context, op *mounter.MountOperation) error {
-	logger := klog.FromContext(ctx)
 	options := m.driver.ApplyOptionDefaults(op.Options)
-	target := op.Target
 
-	args := mount.MakeMountArgs(op.Source, op.Target, "", options)
+	args := mount.MakeMountArgs(op.Source, server.MountTarget(ctx, op.Target), "", options)
 	args = append(args, op.Args...)
 	args = append(args, "-f")
+	res, err := m.runOssfs(ctx, op.Target, args)
+	if err != nil {
+		return err
+	}
+	op.MountResult = res
+	return nil
+}
+
+// runOssfs starts ossfs and waits for it to mount target.
+func (m *extendedMounter) runOssfs(ctx context.Context, target string, args []string) (server.OssfsMountResult, error) {
+	logger := klog.FromContext(ctx)
 
 	var stderrBuf bytes.Buffer
 	multiWriter := io.MultiWriter(os.Stderr, &stderrBuf)
@@ -156,7 +193,7 @@ func (m *extendedMounter) ExtendedMount(ctx context.Context, op *mounter.MountOp
 
 	err := cmd.Start()
 	if err != nil {
-		return fmt.Errorf("start ossfs failed: %w", err)
+		return server.OssfsMountResult{}, fmt.Errorf("start ossfs failed: %w", err)
 	}
 
 	pid := cmd.Process.Pid
@@ -194,33 +231,13 @@ func (m *extendedMounter) ExtendedMount(ctx context.Context, op *mounter.MountOp
 		close(ossfsExited)
 	}()
 
-	err = wait.PollUntilContextCancel(ctx, 100*time.Millisecond, true, func(ctx context.Context) (done bool, err error) {
-		select {
-		case err := <-ossfsExited:
-			if err != nil {
-				return false, fmt.Errorf("ossfs exited: %w", err)
-			}
-			return false, fmt.Errorf("ossfs exited")
-		default:
-			notMnt, err := m.IsLikelyNotMountPoint(target)
-			if err != nil {
-				logger.Error(err, "check mountpoint", "mountpoint", target)
-				return false, nil
-			}
-			if !notMnt {
-				logger.Info("Successfully mounted", "mountpoint", target)
-				return true, nil
-			}
-			return false, nil
-		}
-	})
+	err = m.waitMounted(ctx, target, ossfsExited)
 
 	if err == nil {
-		op.MountResult = server.OssfsMountResult{
+		return server.OssfsMountResult{
 			PID:      pid,
 			ExitChan: ossfsExited,
-		}
-		return nil
+		}, nil
 	}
 
 	if wait.Interrupted(err) {
@@ -240,5 +257,30 @@ func (m *extendedMounter) ExtendedMount(ctx context.Context, op *mounter.MountOp
 	}
 	// Process exit handling (including metrics) is done in the Wait goroutine.
 	// Just return the error to caller to avoid double counting.
-	return err
+	return server.OssfsMountResult{}, err
+}
+
+// waitMounted waits for ossfs to mount target, or to exit.
+func (m *extendedMounter) waitMounted(ctx context.Context, target string, ossfsExited <-chan error) error {
+	logger := klog.FromContext(ctx)
+	return wait.PollUntilContextCancel(ctx, 100*time.Millisecond, true, func(ctx context.Context) (done bool, err error) {
+		select {
+		case err := <-ossfsExited:
+			if err != nil {
+				return false, fmt.Errorf("ossfs exited: %w", err)
+			}
+			return false, fmt.Errorf("ossfs exited")
+		default:
+			notMnt, err := m.IsLikelyNotMountPoint(target)
+			if err != nil {
+				logger.Error(err, "check mountpoint", "mountpoint", target)
+				return false, nil
+			}
+			if !notMnt {
+				logger.Info("Successfully mounted", "mountpoint", target)
+				return true, nil
+			}
+			return false, nil
+		}
+	})
 }
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -57,3 +58,8 @@ func TestApplyOptionDefaults(t *testing.T) {
 		assert.Equal(t, []string{"url=oss.aliyuncs.com"}, result)
 	})
 }
+
+func TestCapabilities(t *testing.T) {
+	d := &Driver{}
+	assert.Equal(t, []proxy.Capability{proxy.CapabilityOverlay}, d.Capabilities(), "ossfs can not take over a FUSE connection")
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
@@ -33,6 +34,26 @@ type Driver struct {
 	monitorManager *server.MountMonitorManager
 	wg             sync.WaitGroup
 	overlay        *server.OverlayManager
+	mounts         *server.MountTable
+	// takeover is set by Init if ossfs2 can serve an initialized FUSE connection
+	takeover bool
+}
+
+// takeoverOption tells ossfs2 that the FUSE connection it gets is already initialized by a previous daemon,
+// so it serves the requests without waiting for FUSE_INIT.
+const takeoverOption = "fuse_takeover"
+
+// probeTakeover reports whether the installed ossfs2 declares takeoverOption in its help.
+// It is a variable so tests can fake it.
+var probeTakeover = func() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ossfs2", "--help").CombinedOutput()
+	if err != nil && len(out) == 0 {
+		klog.ErrorS(err, "Failed to run ossfs2 --help")
+		return false
+	}
+	return bytes.Contains(out, []byte("--"+takeoverOption))
 }
 
 func NewDriver() *Driver {
@@ -41,6 +62,7 @@ func NewDriver() *Driver {
 		pids:           new(sync.Map),
 		monitorManager: server.NewMountMonitorManager(),
 		overlay:        server.NewOverlayManager(rawMounter),
+		mounts:         server.NewMountTable("ossfs2"),
 	}
 	m := &extendedMounter{
 		driver:    driver,
@@ -64,7 +86,11 @@ func (h *Driver) Fstypes() []string {
 }
 
 func (h *Driver) Mount(ctx context.Context, req *proxy.MountRequest) error {
-	return h.ExtendedMount(ctx, &mounter.MountOperation{
+	// Clients without the hello handshake may ask for fdPassing anyway
+	if req.FdPassing && !h.takeover {
+		klog.FromContext(ctx).Info("fdPassing is not supported by the installed ossfs2, ignored", "mountpoint", req.Target)
+	}
+	op := &mounter.MountOperation{
 		Source:      req.Source,
 		Target:      req.Target,
 		FsType:      req.Fstype,
@@ -73,10 +99,41 @@ func (h *Driver) Mount(ctx context.Context, req *proxy.MountRequest) error {
 		MetricsPath: req.MetricsPath,
 		VolumeID:    req.VolumeID,
 		Overlay:     req.Overlay,
-	})
+		FdPassing:   req.FdPassing && h.takeover,
+	}
+	if err := h.ExtendedMount(ctx, op); err != nil {
+		return err
+	}
+	// No result when the target was already mounted and only its credentials were rotated
+	if res, ok := op.MountResult.(server.OssfsMountResult); ok {
+		h.mounts.Add(req, op.Target, res.PID, time.Now())
+	}
+	return nil
 }
 
-func (h *Driver) Init() {}
+var _ server.CapabilityReporter = (*Driver)(nil)
+
+// Capabilities implements server.CapabilityReporter.
+// CapabilityFdPassing is only reported if ossfs2 can be restarted on the kept FUSE connection.
+func (h *Driver) Capabilities() []proxy.Capability {
+	caps := []proxy.Capability{proxy.CapabilityOverlay}
+	if h.takeover {
+		caps = append(caps, proxy.CapabilityFdPassing)
+	}
+	return caps
+}
+
+var _ server.Lister = (*Driver)(nil)
+
+// List implements server.Lister.
+func (h *Driver) List() []proxy.MountStatus {
+	return h.mounts.List(interceptors.OssfsMountMonitors())
+}
+
+func (h *Driver) Init() {
+	h.takeover = probeTakeover()
+	klog.InfoS("Probed ossfs2", "fdPassing", h.takeover)
+}
 
 // ApplyOptionDefaults applies driver-specific option defaults.
 // ossfs2 does not support agent identity auth, so no defaults are applied.
@@ -115,18 +172,52 @@ type extendedMounter struct {
 var _ mounter.Mounter = &extendedMounter{}
 
 func (m *extendedMounter) ExtendedMount(ctx context.Context, op *mounter.MountOperation) error {
-	logger := klog.FromContext(ctx)
 	options := m.driver.ApplyOptionDefaults(op.Options)
-	target := op.Target
 
-	args := []string{"mount", op.Target}
+	if !op.FdPassing {
+		res, err := m.runOssfs2(ctx, op.Target, makeMountArgs(server.MountTarget(ctx, op.Target), op.Args, options), nil)
+		if err != nil {
+			return err
+		}
+		op.MountResult = res
+		return nil
+	}
+
+	// mount-proxy-server mounts the FUSE connection itself and keeps its fd,
+	// ossfs2 only serves it and can be restarted.
+	session, err := server.NewFuseSession(ctx, op.Target, "ossfs2", options)
+	if err != nil {
+		return err
+	}
+	res, err := server.RunFuseSession(ctx, session, func(ctx context.Context, takeover bool) (server.OssfsMountResult, error) {
+		daemonOptions := options
+		if takeover {
+			daemonOptions = append(slices.Clip(options), takeoverOption)
+		}
+		return m.runOssfs2(ctx, op.Target, makeMountArgs(server.FuseDaemonMountpoint, op.Args, daemonOptions), session)
+	})
+	if err != nil {
+		return err
+	}
+	op.MountResult = res
+	return nil
+}
+
+func makeMountArgs(mountpoint string, extraArgs, options []string) []string {
+	args := []string{"mount", mountpoint}
 	// ossfs2.0 forbid to use FUSE args
 	// args = append(args, req.MountFlags...)
-	args = append(args, op.Args...)
+	args = append(args, extraArgs...)
 	for _, o := range options {
 		args = append(args, fmt.Sprintf("--%s", o))
 	}
-	args = append(args, "-f")
+	return append(args, "-f")
+}
+
+// runOssfs2 starts ossfs2 and waits for it to serve target.
+// With a session, ossfs2 gets the FUSE fd instead of mounting target by itself.
+func (m *extendedMounter) runOssfs2(ctx context.Context, target string, args []string, session *server.FuseSession) (server.OssfsMountResult, error) {
+	logger := klog.FromContext(ctx)
 
 	var stderrBuf bytes.Buffer
 	multiWriter := io.MultiWriter(os.Stderr, &stderrBuf)
@@ -138,9 +229,18 @@ func (m *extendedMounter) ExtendedMount(ctx context.Context, op *mounter.MountOp
 		sw.SwitchTarget(os.Stderr)
 	}()
 
+	if session != nil {
+		dev, err := session.DaemonFile()
+		if err != nil {
+			return server.OssfsMountResult{}, err
+		}
+		defer dev.Close()
+		cmd.ExtraFiles = []*os.File{dev}
+	}
+
 	err := cmd.Start()
 	if err != nil {
-		return fmt.Errorf("start ossfs2 failed: %w", err)
+		return server.OssfsMountResult{}, fmt.Errorf("start ossfs2 failed: %w", err)
 	}
 
 	pid := cmd.Process.Pid
@@ -178,33 +278,20 @@ func (m *extendedMounter) ExtendedMount(ctx context.Context, op *mounter.MountOp
 		close(ossfsExited)
 	}()
 
-	err = wait.PollUntilContextCancel(ctx, 100*time.Millisecond, true, func(ctx context.Context) (done bool, err error) {
-		select {
-		case err := <-ossfsExited:
-			if err != nil {
-				return false, fmt.Errorf("ossfs2 exited: %w", err)
-			}
-			return false, fmt.Errorf("ossfs2 exited")
-		default:
-			notMnt, err := m.IsLikelyNotMountPoint(target)
-			if err != nil {
-				logger.Error(err, "check mountpoint", "mountpoint", target)
-				return false, nil
-			}
-			if !notMnt {
-				logger.Info("Successfully mounted", "mountpoint", target)
-				return true, nil
-			}
-			return false, nil
+	if session != nil {
+		err = session.WaitReady(ctx, ossfsExited)
+		if err == nil {
+			logger.Info("Successfully served FUSE connection", "mountpoint", target)
 		}
-	})
+	} else {
+		err = m.waitMounted(ctx, target, ossfsExited)
+	}
 
 	if err == nil {
-		op.MountResult = server.OssfsMountResult{
+		return server.OssfsMountResult{
 			PID:      pid,
 			ExitChan: ossfsExited,
-		}
-		return nil
+		}, nil
 	}
 
 	if wait.Interrupted(err) {
@@ -224,5 +311,30 @@ func (m *extendedMounter) ExtendedMount(ctx context.Context, op *mounter.MountOp
 	}
 	// Process exit handling (including metrics) is done in the Wait goroutine.
 	// Just return the error to caller to avoid double counting.
-	return err
+	return server.OssfsMountResult{}, err
+}
+
+// waitMounted waits for ossfs2 to mount target, or to exit.
+func (m *extendedMounter) waitMounted(ctx context.Context, target string, ossfsExited <-chan error) error {
+	logger := klog.FromContext(ctx)
+	return wait.PollUntilContextCancel(ctx, 100*time.Millisecond, true, func(ctx context.Context) (done bool, err error) {
+		select {
+		case err := <-ossfsExited:
+			if err != nil {
+				return false, fmt.Errorf("ossfs2 exited: %w", err)
+			}
+			return false, fmt.Errorf("ossfs2 exited")
+		default:
+			notMnt, err := m.IsLikelyNotMountPoint(target)
+			if err != nil {
+				logger.Error(err, "check mountpoint", "mountpoint", target)
+				return false, nil
+			}
+			if !notMnt {
+				logger.Info("Successfully mounted", "mountpoint", target)
+				return true, nil
+			}
+			return false, nil
+		}
+	})
 }
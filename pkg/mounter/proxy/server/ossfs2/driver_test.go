@@ -0,0 +1,27 @@
+package ossfs2
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	orig := probeTakeover
+	t.Cleanup(func() { probeTakeover = orig })
+
+	for _, takeover := range []bool{false, true} {
+		probeTakeover = func() bool { return takeover }
+		d := &Driver{}
+		d.Init()
+		assert.Equal(t, takeover, slices.Contains(d.Capabilities(), proxy.CapabilityFdPassing))
+		assert.Contains(t, d.Capabilities(), proxy.CapabilityOverlay)
+	}
+}
+
+func TestMakeMountArgs(t *testing.T) {
+	args := makeMountArgs("/dev/fd/3", nil, []string{"allow_other", takeoverOption})
+	assert.Equal(t, []string{"mount", "/dev/fd/3", "--allow_other", "--fuse_takeover", "-f"}, args)
+}
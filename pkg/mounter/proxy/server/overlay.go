@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -25,9 +26,9 @@ func NewOverlayManager(mounter mountutils.Interface) *OverlayManager {
 }
 
 // MountOverlay performs an overlay mount with the given merged dir as both the
-// overlay key (for dir path computation) and the mount target.
+// overlay key (for dir path computation) and the mount target, see MountTarget.
 // It creates lower, upper, and work dirs if they don't exist.
-func (m *OverlayManager) MountOverlay(mergedDir string) error {
+func (m *OverlayManager) MountOverlay(ctx context.Context, mergedDir string) error {
 	lowerDir, upperDir, workDir := mounterutils.OverlayDirs(mergedDir)
 
 	for _, dir := range []string{lowerDir, upperDir, workDir} {
@@ -41,7 +42,7 @@ func (m *OverlayManager) MountOverlay(mergedDir string) error {
 		fmt.Sprintf("upperdir=%s", upperDir),
 		fmt.Sprintf("workdir=%s", workDir),
 	}
-	if err := m.mounter.Mount("overlay", mergedDir, "overlay", options); err != nil {
+	if err := m.mounter.Mount("overlay", MountTarget(ctx, mergedDir), "overlay", options); err != nil {
 		return fmt.Errorf("overlay mount failed: %w", err)
 	}
 
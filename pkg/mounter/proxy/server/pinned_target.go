@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// pinnedTarget is a mount target opened without following symlinks.
+// The target checked by the auth policy is a path, and a peer able to write under an allowed prefix could
+// replace one of its components by a symlink before the mount. Mounting at the fd of the opened directory
+// instead of the path mounts on the directory that was checked.
+type pinnedTarget struct {
+	target string
+	file   *os.File
+}
+
+type pinTargetsKey struct{}
+type pinnedTargetKey struct{}
+
+// withTargetPinning makes handleMountRequest pin the targets of the mount requests handled with ctx.
+func withTargetPinning(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pinTargetsKey{}, true)
+}
+
+func pinningTargets(ctx context.Context) bool {
+	pin, _ := ctx.Value(pinTargetsKey{}).(bool)
+	return pin
+}
+
+// pinTarget opens the directory target one component at a time with O_NOFOLLOW,
+// failing with ELOOP if any component is a symlink. openat2 with RESOLVE_NO_SYMLINKS would do the same,
+// but needs Linux 5.6.
+func pinTarget(target string) (*pinnedTarget, error) {
+	if !filepath.IsAbs(target) {
+		return nil, fmt.Errorf("target %q is not an absolute path", target)
+	}
+	fd, err := unix.Open("/", unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open /: %w", err)
+	}
+	for _, name := range strings.Split(strings.TrimPrefix(filepath.Clean(target), "/"), "/") {
+		if name == "" {
+			continue
+		}
+		next, err := unix.Openat(fd, name, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+		unix.Close(fd)
+		if err != nil {
+			return nil, fmt.Errorf("open %s of target %s: %w", name, target, err)
+		}
+		fd = next
+		// O_PATH|O_NOFOLLOW opens a symlink itself instead of failing
+		var st unix.Stat_t
+		if err := unix.Fstat(fd, &st); err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("stat %s of target %s: %w", name, target, err)
+		}
+		switch st.Mode & unix.S_IFMT {
+		case unix.S_IFDIR:
+		case unix.S_IFLNK:
+			unix.Close(fd)
+			return nil, fmt.Errorf("open %s of target %s: %w", name, target, unix.ELOOP)
+		default:
+			unix.Close(fd)
+			return nil, fmt.Errorf("open %s of target %s: %w", name, target, unix.ENOTDIR)
+		}
+	}
+	return &pinnedTarget{target: target, file: os.NewFile(uintptr(fd), target)}, nil
+}
+
+// path returns the path of the opened directory. It is resolved by the kernel to the directory itself,
+// whatever happens to the path of target. The pid is used instead of "self", so that the fuse clients
+// started by mount-proxy-server can mount at it as well.
+func (p *pinnedTarget) path() string {
+	return fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), p.file.Fd())
+}
+
+func (p *pinnedTarget) Close() error {
+	return p.file.Close()
+}
+
+// MountTarget returns the path to pass to mount(2) or a fuse client to mount target.
+// It is the pinned directory if target is the pinned target of the request in ctx, and target itself otherwise,
+// e.g. for the lower dir of an overlay, which is private to mount-proxy-server.
+func MountTarget(ctx context.Context, target string) string {
+	if p, ok := ctx.Value(pinnedTargetKey{}).(*pinnedTarget); ok && p.target == target {
+		return p.path()
+	}
+	return target
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestPinTarget(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "pods", "mount")
+	require.NoError(t, os.MkdirAll(target, 0o755))
+
+	pinned, err := pinTarget(target)
+	require.NoError(t, err)
+	defer pinned.Close()
+
+	// Replace a component of the target by a symlink after it is pinned
+	other := filepath.Join(dir, "other")
+	require.NoError(t, os.MkdirAll(filepath.Join(other, "mount"), 0o755))
+	require.NoError(t, os.Rename(filepath.Join(dir, "pods"), filepath.Join(dir, "moved")))
+	require.NoError(t, os.Symlink(other, filepath.Join(dir, "pods")))
+
+	ctx := context.WithValue(context.Background(), pinnedTargetKey{}, pinned)
+	resolved, err := filepath.EvalSymlinks(MountTarget(ctx, target))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "moved", "mount"), resolved)
+	assert.Equal(t, "/run/lower", MountTarget(ctx, "/run/lower"))
+	assert.Equal(t, target, MountTarget(context.Background(), target))
+
+	_, err = pinTarget(target)
+	assert.ErrorIs(t, err, unix.ELOOP)
+	_, err = pinTarget(filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, unix.ENOENT)
+}
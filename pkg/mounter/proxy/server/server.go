@@ -1,12 +1,14 @@
 package server
 
 import (
+	"encoding/json"
 	"errors"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
 	"k8s.io/klog/v2"
 )
 
@@ -15,6 +17,8 @@ type Server struct {
 	listener *net.UnixListener
 	timeout  time.Duration
 	connSeq  atomic.Int64
+	// policy authorizes the requests by the credentials of their peer, nil allows all.
+	policy *AuthPolicy
 
 	wg   sync.WaitGroup
 	done chan struct{}
@@ -29,6 +33,12 @@ func NewServer(listener *net.UnixListener, timeout time.Duration) *Server {
 	}
 }
 
+// SetAuthPolicy makes the server check every request against policy.
+// It must be called before Serve.
+func (s *Server) SetAuthPolicy(policy *AuthPolicy) {
+	s.policy = policy
+}
+
 // Serve accepts connections in a loop. Each connection is handled in a
 // separate goroutine. Serve blocks until the listener is closed.
 func (s *Server) Serve() {
@@ -55,7 +65,18 @@ func (s *Server) handle(conn *net.UnixConn) {
 			klog.ErrorS(err, "Failed to close", "seq", seq)
 		}
 	}()
-	if err := Handle(conn, s.timeout, seq); err != nil {
+	var authorize Authorizer
+	if s.policy != nil {
+		// Read the credentials first, the peer may exit before its request is read
+		peer, err := ReadPeerCred(conn)
+		if err != nil {
+			klog.ErrorS(err, "Failed to read peer credentials, requests will be denied", "seq", seq)
+		}
+		authorize = func(method proxy.Method, body json.RawMessage) (json.RawMessage, error) {
+			return s.policy.Authorize(seq, peer, method, body)
+		}
+	}
+	if err := Handle(conn, s.timeout, seq, authorize); err != nil {
 		klog.ErrorS(err, "Failed to handle", "seq", seq)
 	}
 }
@@ -28,12 +28,16 @@ func NewProxyMounter(socketPath string, inner mountutils.Interface) Mounter {
 	}
 }
 
+// ExtendedMount mounts through the mount broker (mount-proxy-server).
+// Brokers supporting async mounts are waited for until ctx expires, e.g. the gRPC
+// deadline, and the mount is cancelled then. Overlay mounts are refused by brokers
+// not reporting the capability, and fd-passing is dropped.
 func (m *ProxyMounter) ExtendedMount(ctx context.Context, op *MountOperation) error {
 	if op == nil {
 		return nil
 	}
 	dclient := client.NewClient(m.socketPath)
-	resp, err := dclient.Mount(ctx, &proxy.MountRequest{
+	err := dclient.MountAndWait(ctx, &proxy.MountRequest{
 		Source:      op.Source,
 		Target:      op.Target,
 		Fstype:      op.FsType,
@@ -42,11 +46,8 @@ func (m *ProxyMounter) ExtendedMount(ctx context.Context, op *MountOperation) er
 		MetricsPath: op.MetricsPath,
 		VolumeID:    op.VolumeID,
 		Overlay:     op.Overlay,
+		FdPassing:   op.FdPassing,
 	})
-	if err != nil {
-		return fmt.Errorf("call mounter daemon: %w", err)
-	}
-	err = resp.ToError()
 	if err != nil {
 		return fmt.Errorf("failed to mount: %w", err)
 	}
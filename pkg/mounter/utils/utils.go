@@ -40,6 +40,51 @@ const (
 	KeySecurityToken   = "SecurityToken"
 )
 
+// Mount options passing credentials, or the files holding them, to the fuse clients.
+// Their values are redacted when the mount options are reported, see IsCredentialOption.
+const (
+	// ossfs
+	OptPasswdFile             = "passwd_file"
+	OptSecretStoreDir         = "secret_store_dir"
+	OptAgentIdentityTokenFile = "agent_identity_token_file"
+	OptRrsaTokenFile          = "rrsa_token_file"
+	// ossfs 2.0
+	OptOssAccessKeyID     = "oss_access_key_id"
+	OptOssAccessKeySecret = "oss_access_key_secret"
+	OptOssStsAkFile       = "oss_sts_multi_conf_ak_file"
+	OptOssStsSkFile       = "oss_sts_multi_conf_sk_file"
+	OptOssStsTokenFile    = "oss_sts_multi_conf_token_file"
+	// alinas
+	OptRamConfigFile   = "ram_config_file"
+	OptAccessKeyID     = "access_key_id"
+	OptAccessKeySecret = "access_key_secret"
+	OptSecurityToken   = "security_token"
+)
+
+var credentialOptions = map[string]bool{
+	OptPasswdFile:             true,
+	OptSecretStoreDir:         true,
+	OptAgentIdentityTokenFile: true,
+	OptRrsaTokenFile:          true,
+	OptOssAccessKeyID:         true,
+	OptOssAccessKeySecret:     true,
+	OptOssStsAkFile:           true,
+	OptOssStsSkFile:           true,
+	OptOssStsTokenFile:        true,
+	OptRamConfigFile:          true,
+	OptAccessKeyID:            true,
+	OptAccessKeySecret:        true,
+	OptSecurityToken:          true,
+	// not set by the option builders, but accepted from users
+	"ak": true,
+	"sk": true,
+}
+
+// IsCredentialOption reports whether the value of the mount option key is a credential, or the file holding it.
+func IsCredentialOption(key string) bool {
+	return credentialOptions[key]
+}
+
 const LegacyFusePodNamespace = "kube-system" // deprecated
 
 // fuseAttachBaseDir is the base directory for fuse attach paths.
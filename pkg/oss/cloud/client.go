@@ -0,0 +1,263 @@
+// Package cloud is a client of the OSS API on top of the OSS SDK, covering the bucket and object operations
+// needed to provision and reclaim a prefix of a bucket.
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss"
+	osscred "github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss/credentials"
+	alicred "github.com/aliyun/credentials-go/credentials/providers"
+	"k8s.io/utils/ptr"
+)
+
+// Interface is the operations on a bucket.
+type Interface interface {
+	// GetBucketInfo returns a *ServiceError with code NoSuchBucket if the bucket does not exist.
+	GetBucketInfo(ctx context.Context) error
+	PutBucket(ctx context.Context, config BucketConfiguration) error
+	PutBucketEncryption(ctx context.Context, algorithm, kmsKeyID string) error
+	PutObject(ctx context.Context, key string, body []byte) error
+	// ListObjects returns at most maxKeys objects, starting from the first key with the prefix.
+	ListObjects(ctx context.Context, prefix string, maxKeys int) ([]Object, error)
+	DeleteObjects(ctx context.Context, keys []string) error
+	// CopyObject copies an object in the bucket. Objects larger than 1GiB are copied in parts.
+	CopyObject(ctx context.Context, src, dst string, size int64) error
+	// GetBucketLifecycle returns no rules if the bucket has no lifecycle configuration.
+	GetBucketLifecycle(ctx context.Context) ([]LifecycleRule, error)
+	PutBucketLifecycle(ctx context.Context, rules []LifecycleRule) error
+	// GetBucketStat returns the storage and object count of the whole bucket,
+	// which the server updates about once an hour.
+	GetBucketStat(ctx context.Context) (*BucketStat, error)
+}
+
+const (
+	ErrNoSuchBucket    = "NoSuchBucket"
+	ErrNoSuchLifecycle = "NoSuchLifecycle"
+)
+
+// ServiceError is the error returned by the OSS server.
+type ServiceError = oss.ServiceError
+
+// IsErrorCode reports whether err is a ServiceError with the code.
+func IsErrorCode(err error, code string) bool {
+	svcErr, ok := errors.AsType[*ServiceError](err)
+	return ok && svcErr.Code == code
+}
+
+// BucketConfiguration is the attributes of a new bucket, empty ones are left to the server default.
+type BucketConfiguration struct {
+	StorageClass       string
+	DataRedundancyType string
+}
+
+type Object struct {
+	Key  string
+	Size int64
+}
+
+// BucketStat is the usage of a bucket.
+type BucketStat struct {
+	Storage     int64
+	ObjectCount int64
+}
+
+// LifecycleRule is a rule of the bucket lifecycle configuration.
+// The whole rule is kept, so that the rules set by others are preserved when the configuration is rewritten.
+type LifecycleRule struct {
+	ID     string
+	Prefix string
+	rule   oss.LifecycleRule
+}
+
+func newLifecycleRule(rule oss.LifecycleRule) LifecycleRule {
+	return LifecycleRule{ID: ptr.Deref(rule.ID, ""), Prefix: ptr.Deref(rule.Prefix, ""), rule: rule}
+}
+
+// NewArchiveRule makes a rule transitioning the objects with the prefix to the Archive storage class.
+func NewArchiveRule(id, prefix string, days int) LifecycleRule {
+	return newLifecycleRule(oss.LifecycleRule{
+		ID:     &id,
+		Prefix: &prefix,
+		Status: new("Enabled"),
+		Transitions: []oss.LifecycleRuleTransition{{
+			Days:         new(int32(days)),
+			StorageClass: oss.StorageClassArchive,
+		}},
+	})
+}
+
+// maxCopySize is the largest object CopyObject can copy in one request.
+const maxCopySize = 1 << 30
+
+// Client calls the OSS API of one bucket.
+type Client struct {
+	client *oss.Client
+	copier *oss.Copier
+	bucket string
+}
+
+// NewClient creates a client of the bucket. The endpoint is a host, optionally prefixed with a scheme,
+// https is used if not specified.
+// Requests are signed with the signature V4 if the region is known, and V1 otherwise.
+func NewClient(endpoint, region, bucket string, provider alicred.CredentialsProvider) *Client {
+	return newClient(newConfig(endpoint, region, provider), bucket)
+}
+
+func newConfig(endpoint, region string, provider alicred.CredentialsProvider) *oss.Config {
+	cfg := oss.LoadDefaultConfig().
+		WithEndpoint(endpoint).
+		WithCredentialsProvider(osscred.CredentialsProviderFunc(func(ctx context.Context) (osscred.Credentials, error) {
+			cred, err := provider.GetCredentials()
+			if err != nil {
+				return osscred.Credentials{}, err
+			}
+			return osscred.Credentials{
+				AccessKeyID:     cred.AccessKeyId,
+				AccessKeySecret: cred.AccessKeySecret,
+				SecurityToken:   cred.SecurityToken,
+			}, nil
+		}))
+	if region != "" {
+		return cfg.WithRegion(region)
+	}
+	return cfg.WithSignatureVersion(oss.SignatureVersionV1)
+}
+
+func newClient(cfg *oss.Config, bucket string) *Client {
+	client := oss.NewClient(cfg)
+	return &Client{
+		client: client,
+		copier: client.NewCopier(func(o *oss.CopierOptions) {
+			o.MultipartCopyThreshold = maxCopySize
+			o.PartSize = maxCopySize
+			o.DisableShallowCopy = true
+		}),
+		bucket: bucket,
+	}
+}
+
+func (c *Client) GetBucketInfo(ctx context.Context) error {
+	_, err := c.client.GetBucketInfo(ctx, &oss.GetBucketInfoRequest{Bucket: &c.bucket})
+	return err
+}
+
+func (c *Client) PutBucket(ctx context.Context, config BucketConfiguration) error {
+	_, err := c.client.PutBucket(ctx, &oss.PutBucketRequest{
+		Bucket: &c.bucket,
+		CreateBucketConfiguration: &oss.CreateBucketConfiguration{
+			StorageClass:       oss.StorageClassType(config.StorageClass),
+			DataRedundancyType: oss.DataRedundancyType(config.DataRedundancyType),
+		},
+	})
+	return err
+}
+
+func (c *Client) PutBucketEncryption(ctx context.Context, algorithm, kmsKeyID string) error {
+	rule := &oss.ApplyServerSideEncryptionByDefault{SSEAlgorithm: &algorithm}
+	if kmsKeyID != "" {
+		rule.KMSMasterKeyID = &kmsKeyID
+	}
+	_, err := c.client.PutBucketEncryption(ctx, &oss.PutBucketEncryptionRequest{
+		Bucket:                   &c.bucket,
+		ServerSideEncryptionRule: &oss.ServerSideEncryptionRule{ApplyServerSideEncryptionByDefault: rule},
+	})
+	return err
+}
+
+func (c *Client) PutObject(ctx context.Context, key string, body []byte) error {
+	_, err := c.client.PutObject(ctx, &oss.PutObjectRequest{
+		Bucket: &c.bucket,
+		Key:    &key,
+		Body:   bytes.NewReader(body),
+	})
+	return err
+}
+
+func (c *Client) ListObjects(ctx context.Context, prefix string, maxKeys int) ([]Object, error) {
+	result, err := c.client.ListObjectsV2(ctx, &oss.ListObjectsV2Request{
+		Bucket:  &c.bucket,
+		Prefix:  &prefix,
+		MaxKeys: int32(maxKeys),
+	})
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]Object, 0, len(result.Contents))
+	for _, o := range result.Contents {
+		objects = append(objects, Object{Key: ptr.Deref(o.Key, ""), Size: o.Size})
+	}
+	return objects, nil
+}
+
+func (c *Client) DeleteObjects(ctx context.Context, keys []string) error {
+	objects := make([]oss.DeleteObject, 0, len(keys))
+	for _, k := range keys {
+		objects = append(objects, oss.DeleteObject{Key: &k})
+	}
+	_, err := c.client.DeleteMultipleObjects(ctx, &oss.DeleteMultipleObjectsRequest{
+		Bucket:  &c.bucket,
+		Objects: objects,
+		Quiet:   true,
+	})
+	return err
+}
+
+func (c *Client) CopyObject(ctx context.Context, src, dst string, size int64) error {
+	req := &oss.CopyObjectRequest{
+		Bucket:       &c.bucket,
+		Key:          &dst,
+		SourceBucket: &c.bucket,
+		SourceKey:    &src,
+	}
+	if size <= maxCopySize {
+		_, err := c.client.CopyObject(ctx, req)
+		return err
+	}
+	_, err := c.copier.Copy(ctx, req)
+	return err
+}
+
+func (c *Client) GetBucketLifecycle(ctx context.Context) ([]LifecycleRule, error) {
+	result, err := c.client.GetBucketLifecycle(ctx, &oss.GetBucketLifecycleRequest{Bucket: &c.bucket})
+	if err != nil {
+		if IsErrorCode(err, ErrNoSuchLifecycle) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if result.LifecycleConfiguration == nil {
+		return nil, nil
+	}
+	rules := make([]LifecycleRule, 0, len(result.LifecycleConfiguration.Rules))
+	for _, r := range result.LifecycleConfiguration.Rules {
+		rules = append(rules, newLifecycleRule(r))
+	}
+	return rules, nil
+}
+
+func (c *Client) PutBucketLifecycle(ctx context.Context, rules []LifecycleRule) error {
+	config := &oss.LifecycleConfiguration{}
+	for _, r := range rules {
+		rule := r.rule
+		if rule.ID == nil {
+			rule.ID, rule.Prefix = &r.ID, &r.Prefix
+		}
+		config.Rules = append(config.Rules, rule)
+	}
+	_, err := c.client.PutBucketLifecycle(ctx, &oss.PutBucketLifecycleRequest{
+		Bucket:                 &c.bucket,
+		LifecycleConfiguration: config,
+	})
+	return err
+}
+
+func (c *Client) GetBucketStat(ctx context.Context) (*BucketStat, error) {
+	result, err := c.client.GetBucketStat(ctx, &oss.GetBucketStatRequest{Bucket: &c.bucket})
+	if err != nil {
+		return nil, err
+	}
+	return &BucketStat{Storage: result.Storage, ObjectCount: result.ObjectCount}, nil
+}
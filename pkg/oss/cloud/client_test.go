@@ -0,0 +1,151 @@
+package cloud
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	alicred "github.com/aliyun/credentials-go/credentials/providers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedRequest struct {
+	method, host, uri, body string
+	header                  http.Header
+}
+
+func testClient(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) (*Client, *[]recordedRequest) {
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, recordedRequest{r.Method, r.Host, r.RequestURI, string(body), r.Header})
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := alicred.NewStaticSTSCredentialsProviderBuilder().
+		WithAccessKeyId("ak").WithAccessKeySecret("sk").WithSecurityToken("token").Build()
+	require.NoError(t, err)
+	cfg := newConfig("http://oss-cn-hangzhou-internal.aliyuncs.com", "cn-hangzhou", provider).
+		WithHttpClient(&http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			},
+		}})
+	return newClient(cfg, "bucket"), &requests
+}
+
+func (r recordedRequest) query() url.Values {
+	u, _ := url.ParseRequestURI(r.uri)
+	return u.Query()
+}
+
+func TestListObjects(t *testing.T) {
+	c, requests := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `<ListBucketResult><EncodingType>url</EncodingType><Contents><Key>data/pvc-1/a%20b</Key><Size>3</Size></Contents><Contents><Key>data/pvc-1/</Key><Size>0</Size></Contents></ListBucketResult>`)
+	})
+	objects, err := c.ListObjects(context.Background(), "data/pvc-1/", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, []Object{{Key: "data/pvc-1/a b", Size: 3}, {Key: "data/pvc-1/", Size: 0}}, objects)
+
+	req := (*requests)[0]
+	assert.Equal(t, "bucket.oss-cn-hangzhou-internal.aliyuncs.com", req.host)
+	query := req.query()
+	assert.Equal(t, "2", query.Get("list-type"))
+	assert.Equal(t, "1000", query.Get("max-keys"))
+	assert.Equal(t, "data/pvc-1/", query.Get("prefix"))
+	assert.Equal(t, "token", req.header.Get("X-Oss-Security-Token"))
+	assert.True(t, strings.HasPrefix(req.header.Get("Authorization"), "OSS4-HMAC-SHA256 Credential=ak/"))
+}
+
+func TestServiceError(t *testing.T) {
+	c, _ := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist.</Message><RequestId>req-1</RequestId></Error>`)
+	})
+	err := c.GetBucketInfo(context.Background())
+	assert.True(t, IsErrorCode(err, ErrNoSuchBucket))
+	assert.ErrorContains(t, err, "req-1")
+}
+
+func TestDeleteObjects(t *testing.T) {
+	c, requests := testClient(t, func(w http.ResponseWriter, r *http.Request) {})
+	require.NoError(t, c.DeleteObjects(context.Background(), []string{"a", "b&c"}))
+
+	req := (*requests)[0]
+	assert.Equal(t, http.MethodPost, req.method)
+	assert.True(t, req.query().Has("delete"))
+	assert.Contains(t, req.body, `<Quiet>true</Quiet>`)
+	assert.Contains(t, req.body, `<Object><Key>a</Key></Object>`)
+	assert.Contains(t, req.body, `<Object><Key>b&amp;c</Key></Object>`)
+	assert.NotEmpty(t, req.header.Get("Content-MD5"))
+}
+
+func TestCopyLargeObject(t *testing.T) {
+	c, requests := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead:
+			w.Header().Set("Content-Length", strconv.Itoa(maxCopySize+1))
+			w.Header().Set("ETag", `"etag-src"`)
+			w.Header().Set("Last-Modified", "Thu, 17 Nov 2005 18:49:58 GMT")
+		case r.URL.Query().Has("uploads"):
+			_, _ = io.WriteString(w, `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>archive/src</Key><UploadId>up-1</UploadId></InitiateMultipartUploadResult>`)
+		case r.URL.Query().Has("partNumber"):
+			_, _ = io.WriteString(w, `<CopyPartResult><ETag>"etag-`+r.URL.Query().Get("partNumber")+`"</ETag></CopyPartResult>`)
+		case r.URL.Query().Has("uploadId"):
+			_, _ = io.WriteString(w, `<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>archive/src</Key><ETag>"etag-dst"</ETag></CompleteMultipartUploadResult>`)
+		}
+	})
+	require.NoError(t, c.CopyObject(context.Background(), "src", "archive/src", maxCopySize+1))
+
+	var ranges []string
+	for _, req := range *requests {
+		if req.query().Has("partNumber") {
+			assert.Equal(t, "up-1", req.query().Get("uploadId"))
+			assert.Equal(t, "/bucket/src", req.header.Get("X-Oss-Copy-Source"))
+			ranges = append(ranges, req.header.Get("X-Oss-Copy-Source-Range"))
+		}
+	}
+	assert.ElementsMatch(t, []string{"bytes=0-1073741823", "bytes=1073741824-1073741824"}, ranges)
+	last := (*requests)[len(*requests)-1]
+	assert.Equal(t, http.MethodPost, last.method)
+	assert.Equal(t, "up-1", last.query().Get("uploadId"))
+}
+
+func TestLifecycle(t *testing.T) {
+	existing := `<Rule><ID>other</ID><Prefix>logs/</Prefix><Status>Enabled</Status><Expiration><Days>7</Days></Expiration></Rule>`
+	c, requests := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = io.WriteString(w, `<LifecycleConfiguration>`+existing+`</LifecycleConfiguration>`)
+		}
+	})
+	rules, err := c.GetBucketLifecycle(context.Background())
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, "other", rules[0].ID)
+
+	rules = append(rules, NewArchiveRule("csi-archive-pvc-1", "data/pvc-1/", 1))
+	require.NoError(t, c.PutBucketLifecycle(context.Background(), rules))
+	body := (*requests)[1].body
+	assert.Contains(t, body, `<ID>other</ID>`)
+	assert.Contains(t, body, `<Days>7</Days>`)
+	assert.Contains(t, body, `<ID>csi-archive-pvc-1</ID>`)
+	assert.Contains(t, body, `<StorageClass>Archive</StorageClass>`)
+}
+
+func TestGetBucketStat(t *testing.T) {
+	c, requests := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `<BucketStat><Storage>1600</Storage><ObjectCount>230</ObjectCount><MultipartUploadCount>40</MultipartUploadCount></BucketStat>`)
+	})
+	stat, err := c.GetBucketStat(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &BucketStat{Storage: 1600, ObjectCount: 230}, stat)
+	assert.True(t, (*requests)[0].query().Has("stat"))
+}
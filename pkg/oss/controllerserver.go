@@ -31,9 +31,11 @@ import (
 	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
 	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
+	osscloud "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/oss/cloud"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
@@ -57,6 +59,8 @@ type controllerServer struct {
 	fusePodManagers map[string]*ossfpm.OSSFusePodManager
 	legacyPods      sets.Set[podLoc]
 	legacyPodsMu    sync.Mutex
+	ossClient       func(*ossfpm.Options) (osscloud.Interface, error)
+	prefixes        *prefixCleaner
 	common.GenericControllerServer
 }
 
@@ -72,15 +76,36 @@ func (*controllerServer) ControllerGetCapabilities(context.Context, *csi.Control
 
 // provisioner: create/delete oss volume
 func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	prefix, err := parsePrefixParams(req.Parameters)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
 	reclaimPolicy, ok := req.Parameters[common.CsiAlibabaCloudPrefix+"/"+"reclaimPolicy"]
-	if ok && reclaimPolicy != string(corev1.PersistentVolumeReclaimRetain) {
-		return nil, status.Errorf(codes.InvalidArgument, "ReclaimPolicy must be Retain. The current reclaimPolicy is %q", reclaimPolicy)
+	if ok && reclaimPolicy != string(corev1.PersistentVolumeReclaimRetain) &&
+		(prefix == nil || reclaimPolicy != string(corev1.PersistentVolumeReclaimDelete)) {
+		return nil, status.Errorf(codes.InvalidArgument, "ReclaimPolicy must be Retain unless %s is set. The current reclaimPolicy is %q", volKeyCreatePrefix, reclaimPolicy)
 	}
 
 	volumeContext, err := buildVolumeContext(req.Parameters, req.GetName())
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
 	}
+	if prefix != nil {
+		opts, err := parseOptions(ctx, cs.cnfsGetter, req.Parameters, req.Secrets, req.VolumeCapabilities, false, req.Name, false, cs.metadata)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+		if opts.URL == "" || opts.Bucket == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "url and bucket are required by %s", volKeyCreatePrefix)
+		}
+		client, err := cs.ossClient(opts)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "%v", err)
+		}
+		if err := createPrefix(ctx, client, opts, prefix); err != nil {
+			return nil, status.Errorf(codes.Internal, "%v", err)
+		}
+	}
 	volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
 	csiTargetVolume := &csi.Volume{
 		VolumeId:      req.Name,
@@ -95,7 +120,22 @@ func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVol
 
 func (cs *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
 	logger := klog.FromContext(ctx)
-	logger.Info("skipped")
+	pv, err := cs.client.CoreV1().PersistentVolumes().Get(ctx, req.VolumeId, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("PV not found, skipped")
+			return &csi.DeleteVolumeResponse{}, nil
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.VolumeAttributes[volKeyCreatePrefix] == "" {
+		logger.Info("skipped")
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+	if err := cs.deletePrefixVolume(ctx, req, pv); err != nil {
+		return nil, err
+	}
+	logger.Info("volume deleted")
 	return &csi.DeleteVolumeResponse{}, nil
 }
 
@@ -197,6 +237,16 @@ func (cs *controllerServer) ControllerPublishVolume(ctx context.Context, req *cs
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
+	// the fuse pod shared with other volumes is created by NodeStageVolume,
+	// the socket path only tells NodePublishVolume the runtime is RunC
+	if opts.SharedFusePod {
+		klog.Infof("ControllerPublishVolume: volume %s shares fuse pods on node %s", req.VolumeId, req.NodeId)
+		return &csi.ControllerPublishVolumeResponse{
+			PublishContext: map[string]string{
+				mountProxySocket: mounterutils.GetMountProxySocketPath(req.VolumeId),
+			},
+		}, nil
+	}
 	// make pod template config
 	ptCfg := makePodTemplateConfig(opts)
 	// make mount options
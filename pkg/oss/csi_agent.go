@@ -59,3 +59,7 @@ func (a *CSIAgent) NodePublishVolume(ctx context.Context, req *csi.NodePublishVo
 func (a *CSIAgent) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
 	return a.ns.NodeUnpublishVolume(ctx, req)
 }
+
+func (a *CSIAgent) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	return a.ns.NodeGetVolumeStats(ctx, req)
+}
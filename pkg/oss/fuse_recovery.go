@@ -0,0 +1,447 @@
+//go:build !windows
+
+package oss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter"
+	fpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager"
+	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy/client"
+	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"golang.org/x/sys/unix"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	mountutils "k8s.io/mount-utils"
+)
+
+const (
+	fuseRecoveryInterval = 10 * time.Second
+	fuseRecoveryTimeout  = 5 * time.Minute
+	// statfsTimeout bounds how long a mountpoint may take to answer statfs,
+	// a fuse client that is alive but stuck never answers.
+	statfsTimeout = 5 * time.Second
+)
+
+// mountInfoPath is a variable so tests can fake it.
+var mountInfoPath = "/proc/self/mountinfo"
+
+// fuseRecovery remounts the attach paths of RunC volumes whose fuse pod crashed or was evicted,
+// and the targets bind mounted from them.
+//
+// The application pods only see the new mounts if their volumeMounts have mountPropagation: HostToContainer,
+// otherwise they still have to be restarted.
+type fuseRecovery struct {
+	locks           *utils.VolumeLocks
+	rawMounter      mountutils.Interface
+	fusePodManagers map[string]*ossfpm.OSSFusePodManager
+	recorder        record.EventRecorder
+	newMounter      func(socketPath string) mounter.Mounter
+	// isDisconnected reports whether the mountpoint lost its fuse daemon.
+	isDisconnected func(path string) bool
+	// listMounts asks the mount-proxy-server listening on socketPath for its mounts.
+	listMounts func(ctx context.Context, socketPath string) ([]proxy.MountStatus, error)
+
+	mu      sync.Mutex
+	volumes map[string]*recoverableVolume
+}
+
+// recoverableVolume is what NodePublishVolume did for a volume,
+// the secrets are only kept in memory.
+type recoverableVolume struct {
+	podContext fpm.FusePodContext
+	socketPath string
+	mount      mounter.MountOperation
+	// target path -> the pod using it
+	targets map[string]*corev1.ObjectReference
+}
+
+func newFuseRecovery(locks *utils.VolumeLocks, rawMounter mountutils.Interface,
+	fusePodManagers map[string]*ossfpm.OSSFusePodManager, recorder record.EventRecorder,
+) *fuseRecovery {
+	return &fuseRecovery{
+		locks:           locks,
+		rawMounter:      rawMounter,
+		fusePodManagers: fusePodManagers,
+		recorder:        recorder,
+		newMounter: func(socketPath string) mounter.Mounter {
+			return mounter.NewForMounter(mounter.NewProxyMounter(socketPath, rawMounter))
+		},
+		isDisconnected: isDisconnected,
+		listMounts: func(ctx context.Context, socketPath string) ([]proxy.MountStatus, error) {
+			list, err := client.NewClient(socketPath).List(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return list.Mounts, nil
+		},
+		volumes: map[string]*recoverableVolume{},
+	}
+}
+
+var errStatfsTimeout = errors.New("statfs timed out")
+
+// pendingStatfs holds the paths whose statfs has not returned yet,
+// they are not checked again until it does, so stuck goroutines do not pile up.
+var pendingStatfs sync.Map
+
+// statfs is unix.Statfs bounded by statfsTimeout.
+func statfs(path string) (*unix.Statfs_t, error) {
+	if _, loaded := pendingStatfs.LoadOrStore(path, struct{}{}); loaded {
+		return nil, errStatfsTimeout
+	}
+	st := &unix.Statfs_t{}
+	done := make(chan error, 1)
+	go func() {
+		err := unix.Statfs(path, st)
+		pendingStatfs.Delete(path)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return st, nil
+	case <-time.After(statfsTimeout):
+		return nil, errStatfsTimeout
+	}
+}
+
+// isDisconnected does not report a mountpoint whose fuse client is stuck, remounting would not help it.
+func isDisconnected(path string) bool {
+	_, err := statfs(path)
+	if errors.Is(err, errStatfsTimeout) {
+		klog.InfoS("mountpoint does not answer statfs, the fuse client may be stuck", "path", path)
+		return false
+	}
+	return err != nil && mountutils.IsCorruptedMnt(err)
+}
+
+// podOfTarget returns the pod publishing the target, from the pod info passed by kubelet.
+func podOfTarget(req *csi.NodePublishVolumeRequest) *corev1.ObjectReference {
+	name := req.VolumeContext["csi.storage.k8s.io/pod.name"]
+	if name == "" {
+		return nil
+	}
+	return &corev1.ObjectReference{
+		Kind:      "Pod",
+		Namespace: req.VolumeContext["csi.storage.k8s.io/pod.namespace"],
+		Name:      name,
+		UID:       types.UID(req.VolumeContext["csi.storage.k8s.io/pod.uid"]),
+	}
+}
+
+// track remembers how the volume is mounted on the attach path (op.Target) and bind mounted to the target.
+func (r *fuseRecovery) track(req *csi.NodePublishVolumeRequest, podContext *fpm.FusePodContext, socketPath string, op *mounter.MountOperation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	targets := map[string]*corev1.ObjectReference{}
+	if v := r.volumes[req.VolumeId]; v != nil {
+		targets = v.targets
+	}
+	targets[req.TargetPath] = podOfTarget(req)
+	v := &recoverableVolume{
+		podContext: *podContext,
+		socketPath: socketPath,
+		mount:      *op,
+		targets:    targets,
+	}
+	// never retain the context of the publish request
+	v.podContext.Context = nil
+	r.volumes[req.VolumeId] = v
+}
+
+// rebuild tracks again the volumes published before the node plugin restarted,
+// from the fuse pods on the node and the targets bind mounted from their attach paths.
+// The mount options are asked from the mount-proxy-server of the fuse pod, without the credentials in them.
+// The secrets are lost until the next token rotation, and no events are emitted on the application pods.
+func (r *fuseRecovery) rebuild(ctx context.Context, client kubernetes.Interface, nodeName string) error {
+	logger := klog.FromContext(ctx)
+	pods, err := client.CoreV1().Pods(fusePodNamespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+		// the shared fuse pods are not recovered
+		LabelSelector: fmt.Sprintf("%s,!%s", fpm.FuseVolumeIdLabelKey, fpm.FuseSharedHashLabelKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list fuse pods: %w", err)
+	}
+	mis, err := mountutils.ParseMountInfo(mountInfoPath)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		volumeID := pod.Labels[fpm.FuseVolumeIdLabelKey]
+		fuseType := pod.Labels[fpm.FuseTypeLabelKey]
+		attachPath := pod.Annotations[fpm.FuseMountPathAnnoKey]
+		// the label is hashed if the volume ID is not a valid label value
+		if r.fusePodManagers[fuseType] == nil || attachPath != mounterutils.GetAttachPath(volumeID) {
+			continue
+		}
+		targets := bindMounts(mis, attachPath)
+		if len(targets) == 0 {
+			continue
+		}
+		podContext := fpm.FusePodContext{
+			Namespace: fusePodNamespace,
+			NodeName:  nodeName,
+			VolumeId:  volumeID,
+			FuseType:  fuseType,
+		}
+		if r.rebuildVolume(ctx, podContext, attachPath, targets) {
+			logger.Info("tracking volume published before restart", "volumeID", volumeID, "targets", targets)
+		}
+	}
+	return nil
+}
+
+func (r *fuseRecovery) rebuildVolume(ctx context.Context, podContext fpm.FusePodContext, attachPath string, targets []string) bool {
+	volumeID := podContext.VolumeId
+	if !r.locks.TryAcquire(volumeID) {
+		// being published or unpublished, which tracks it again if needed
+		return false
+	}
+	defer r.locks.Release(volumeID)
+	r.mu.Lock()
+	_, tracked := r.volumes[volumeID]
+	r.mu.Unlock()
+	if tracked {
+		return false
+	}
+
+	socketPath := mounterutils.GetMountProxySocketPath(volumeID)
+	op := mounter.MountOperation{Target: attachPath, FsType: podContext.FuseType}
+	mounts, err := r.listMounts(ctx, socketPath)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "failed to list mounts of mount-proxy-server, the volume cannot be remounted",
+			"volumeID", volumeID)
+	}
+	for _, m := range mounts {
+		if m.Target == attachPath {
+			op.Source = m.Source
+			op.Options = unredactedOptions(m.Options)
+		}
+	}
+	v := &recoverableVolume{
+		podContext: podContext,
+		socketPath: socketPath,
+		mount:      op,
+		targets:    map[string]*corev1.ObjectReference{},
+	}
+	for _, target := range targets {
+		v.targets[target] = nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.volumes[volumeID] = v
+	return true
+}
+
+// bindMounts returns the other mountpoints of the mount on path in the mount table.
+// Unlike GetMountRefs, path is never stat'ed, which hangs on a stuck fuse mountpoint.
+func bindMounts(mis []mountutils.MountInfo, path string) []string {
+	i := slices.IndexFunc(mis, func(mi mountutils.MountInfo) bool { return mi.MountPoint == path })
+	if i < 0 {
+		return nil
+	}
+	var refs []string
+	for _, mi := range mis {
+		if mi.MountPoint != path && mi.Major == mis[i].Major && mi.Minor == mis[i].Minor && mi.Root == mis[i].Root {
+			refs = append(refs, mi.MountPoint)
+		}
+	}
+	return refs
+}
+
+// unredactedOptions drops the options redacted by mount-proxy-server.
+func unredactedOptions(options []string) []string {
+	var kept []string
+	for _, o := range options {
+		parts := slices.DeleteFunc(strings.Split(o, ","), func(opt string) bool {
+			return strings.HasSuffix(opt, "="+proxy.Redacted)
+		})
+		if len(parts) > 0 {
+			kept = append(kept, strings.Join(parts, ","))
+		}
+	}
+	return kept
+}
+
+// rotateSecrets updates the secrets used to remount the volume.
+func (r *fuseRecovery) rotateSecrets(volumeID string, secrets map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v := r.volumes[volumeID]; v != nil {
+		v.mount.Secrets = secrets
+	}
+}
+
+func (r *fuseRecovery) untrackTarget(volumeID, targetPath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v := r.volumes[volumeID]; v != nil {
+		delete(v.targets, targetPath)
+	}
+}
+
+func (r *fuseRecovery) untrack(volumeID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.volumes, volumeID)
+}
+
+// recoverAll checks all tracked volumes, and recovers the broken ones one by one.
+func (r *fuseRecovery) recoverAll() {
+	r.mu.Lock()
+	volumeIDs := slices.Sorted(maps.Keys(r.volumes))
+	r.mu.Unlock()
+	for _, volumeID := range volumeIDs {
+		r.recover(volumeID)
+	}
+}
+
+func (r *fuseRecovery) recover(volumeID string) {
+	if !r.locks.TryAcquire(volumeID) {
+		// being published or unpublished, check it next time
+		return
+	}
+	defer r.locks.Release(volumeID)
+
+	r.mu.Lock()
+	v := r.volumes[volumeID]
+	var (
+		podContext fpm.FusePodContext
+		socketPath string
+		op         mounter.MountOperation
+		targets    map[string]*corev1.ObjectReference
+	)
+	if v != nil {
+		podContext, socketPath, op, targets = v.podContext, v.socketPath, v.mount, maps.Clone(v.targets)
+	}
+	r.mu.Unlock()
+	if v == nil {
+		return
+	}
+
+	attachBroken := r.isDisconnected(op.Target)
+	var broken []string
+	for target := range targets {
+		if r.isDisconnected(target) {
+			broken = append(broken, target)
+		}
+	}
+	if !attachBroken && len(broken) == 0 {
+		return
+	}
+	slices.Sort(broken)
+
+	ctx, cancel := context.WithTimeout(context.Background(), fuseRecoveryTimeout)
+	defer cancel()
+	logger := klog.FromContext(ctx).WithValues("volumeID", volumeID)
+	logger.Info("fuse mount disconnected, recovering", "attachPath", op.Target, "attachBroken", attachBroken, "targets", broken)
+	for _, target := range broken {
+		r.event(targets[target], corev1.EventTypeWarning, "FuseMountDisconnected",
+			"Volume %s is disconnected from its %s client, recovering", volumeID, op.FsType)
+	}
+
+	err := r.remount(ctx, &podContext, socketPath, &op, attachBroken, broken)
+	if err != nil {
+		logger.Error(err, "failed to recover fuse mount, will retry")
+	} else {
+		logger.Info("fuse mount recovered")
+	}
+	for _, target := range broken {
+		if err != nil {
+			r.event(targets[target], corev1.EventTypeWarning, "FuseMountRecoveryFailed",
+				"Failed to recover volume %s, will retry: %v", volumeID, err)
+		} else {
+			r.event(targets[target], corev1.EventTypeNormal, "FuseMountRecovered",
+				"Volume %s is remounted, restart the pod if it still fails to access the volume", volumeID)
+		}
+	}
+}
+
+func (r *fuseRecovery) remount(ctx context.Context, podContext *fpm.FusePodContext, socketPath string, op *mounter.MountOperation, attachBroken bool, broken []string) error {
+	if attachBroken {
+		if op.Source == "" {
+			return fmt.Errorf("the mount options of %s were lost when the node plugin restarted", op.Target)
+		}
+		manager := r.fusePodManagers[podContext.FuseType]
+		if manager == nil {
+			return fmt.Errorf("unknown fuse type %q", podContext.FuseType)
+		}
+		podContext.Context = ctx
+		if podContext.PodTemplateConfig == nil {
+			// rebuilt after restart, the pod cannot be created again without the volume attributes
+			if err := requireFusePod(manager, podContext); err != nil {
+				return err
+			}
+		}
+		// recreates the pod if it exited, or waits for it to be ready again
+		if _, err := manager.Create(podContext, op.Target); err != nil {
+			return fmt.Errorf("failed to recreate fuse pod: %w", err)
+		}
+		// unmounts the disconnected mountpoint
+		notMnt, err := mounterutils.IsNotMountPoint(r.rawMounter, op.Target)
+		if err != nil {
+			return err
+		}
+		if notMnt {
+			if err := r.newMounter(socketPath).ExtendedMount(ctx, op); err != nil {
+				return fmt.Errorf("failed to mount %s: %w", op.Target, err)
+			}
+		}
+	}
+
+	var errs []string
+	for _, target := range broken {
+		if err := r.rawMounter.Unmount(target); err != nil {
+			errs = append(errs, fmt.Sprintf("unmount %s: %v", target, err))
+			continue
+		}
+		if err := r.rawMounter.Mount(op.Target, target, "", []string{"bind"}); err != nil {
+			errs = append(errs, fmt.Sprintf("bind mount %s: %v", target, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to remount targets: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// requireFusePod fails if there is no fuse pod of the volume left running or starting.
+func requireFusePod(manager *ossfpm.OSSFusePodManager, podContext *fpm.FusePodContext) error {
+	pods, err := manager.List(podContext)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		if pod.DeletionTimestamp.IsZero() && pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			return nil
+		}
+	}
+	return errors.New("the fuse pod is gone, and it cannot be created again since the node plugin restarted")
+}
+
+func (r *fuseRecovery) event(pod *corev1.ObjectReference, eventType, reason, messageFmt string, args ...any) {
+	if pod == nil || r.recorder == nil {
+		return
+	}
+	r.recorder.Eventf(pod, eventType, reason, messageFmt, args...)
+}
@@ -0,0 +1,306 @@
+//go:build !windows
+
+package oss
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter"
+	fpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager"
+	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/proxy"
+	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	mountutils "k8s.io/mount-utils"
+)
+
+// proxyMounter records the mounts sent to the fuse pod.
+type proxyMounter struct {
+	*mountutils.FakeMounter
+	socketPath string
+	ops        []mounter.MountOperation
+	mountErr   error
+}
+
+func (m *proxyMounter) ExtendedMount(ctx context.Context, op *mounter.MountOperation) error {
+	m.ops = append(m.ops, *op)
+	if m.mountErr != nil {
+		return m.mountErr
+	}
+	return m.Mount(op.Source, op.Target, "fuse."+op.FsType, op.Options)
+}
+
+type recoveryTest struct {
+	recovery   *fuseRecovery
+	client     *fake.Clientset
+	rawMounter *mountutils.FakeMounter
+	proxy      *proxyMounter
+	recorder   *record.FakeRecorder
+	attachPath string
+	targetPath string
+
+	mu     sync.Mutex
+	broken map[string]bool
+}
+
+func (rt *recoveryTest) setBroken(paths ...string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.broken = map[string]bool{}
+	for _, p := range paths {
+		rt.broken[p] = true
+	}
+}
+
+func (rt *recoveryTest) events() []string {
+	var events []string
+	for {
+		select {
+		case e := <-rt.recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func newRecoveryTest(t *testing.T) *recoveryTest {
+	dir := t.TempDir()
+	rt := &recoveryTest{
+		attachPath: filepath.Join(dir, "attach"),
+		targetPath: filepath.Join(dir, "pods/uid-1/volumes/kubernetes.io~csi/pv-1/mount"),
+		recorder:   record.NewFakeRecorder(100),
+		broken:     map[string]bool{},
+	}
+	// the watch of NewClientset does not work with watchtools.Until in FusePodManager.Create
+	rt.client = fake.NewSimpleClientset()
+	rt.rawMounter = mountutils.NewFakeMounter([]mountutils.MountPoint{
+		{Device: "ossfs", Path: rt.attachPath, Type: "fuse.ossfs"},
+		{Device: "ossfs", Path: rt.targetPath, Type: "fuse.ossfs"},
+	})
+	rt.proxy = &proxyMounter{FakeMounter: rt.rawMounter}
+
+	m := setupTestNodeServer(t, rt.rawMounter, false).metadata
+	rt.recovery = newFuseRecovery(utils.NewVolumeLocks(), rt.rawMounter,
+		ossfpm.GetAllOSSFusePodManagers(utils.Config{}, m, rt.client, nil), rt.recorder)
+	rt.recovery.newMounter = func(socketPath string) mounter.Mounter {
+		rt.proxy.socketPath = socketPath
+		return rt.proxy
+	}
+	rt.recovery.isDisconnected = func(path string) bool {
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		return rt.broken[path]
+	}
+
+	rt.recovery.track(&csi.NodePublishVolumeRequest{
+		VolumeId:   "pv-1",
+		TargetPath: rt.targetPath,
+		VolumeContext: map[string]string{
+			"csi.storage.k8s.io/pod.name":      "app",
+			"csi.storage.k8s.io/pod.namespace": "default",
+			"csi.storage.k8s.io/pod.uid":       "uid-1",
+		},
+	}, &fpm.FusePodContext{
+		Context:           context.Background(),
+		Namespace:         fusePodNamespace,
+		NodeName:          "test-node",
+		VolumeId:          "pv-1",
+		FuseType:          "ossfs",
+		AuthConfig:        &fpm.AuthConfig{Secrets: map[string]string{"akId": "ak"}},
+		PodTemplateConfig: &fpm.PodTemplateConfig{},
+	}, "/run/fuse.ossfs/pv-1/mounter.sock", &mounter.MountOperation{
+		Source:  "bucket:/data",
+		Target:  rt.attachPath,
+		FsType:  "ossfs",
+		Options: []string{"allow_other"},
+		Secrets: map[string]string{"akId": "ak"},
+	})
+	return rt
+}
+
+// readyFusePod is the fuse pod of the attach path, as created by FusePodManager.
+func (rt *recoveryTest) readyFusePod(name string) *corev1.Pod {
+	pod := fusePod(name, true)
+	pod.Labels[fpm.FuseTypeLabelKey] = "ossfs"
+	pod.Labels[fpm.FuseMountPathHashLabelKey] = mounterutils.ComputeMountPathHash(rt.attachPath)
+	pod.Annotations = map[string]string{fpm.FuseMountPathAnnoKey: rt.attachPath}
+	// the fake clientset does not set it, but the watch of FusePodManager.Create needs it
+	pod.ResourceVersion = "1"
+	return pod
+}
+
+func TestFuseRecoveryHealthy(t *testing.T) {
+	rt := newRecoveryTest(t)
+	rt.recovery.recoverAll()
+	assert.Empty(t, rt.proxy.ops)
+	assert.Empty(t, rt.events())
+}
+
+func TestFuseRecoveryContainerRestarted(t *testing.T) {
+	rt := newRecoveryTest(t)
+	_, err := rt.client.CoreV1().Pods(fusePodNamespace).Create(context.Background(), rt.readyFusePod("fuse-1"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	rt.rawMounter.MountCheckErrors = map[string]error{rt.attachPath: syscall.ENOTCONN}
+	rt.setBroken(rt.attachPath, rt.targetPath)
+
+	rt.recovery.recoverAll()
+
+	require.Len(t, rt.proxy.ops, 1)
+	assert.Equal(t, "/run/fuse.ossfs/pv-1/mounter.sock", rt.proxy.socketPath)
+	assert.Equal(t, mounter.MountOperation{
+		Source:  "bucket:/data",
+		Target:  rt.attachPath,
+		FsType:  "ossfs",
+		Options: []string{"allow_other"},
+		Secrets: map[string]string{"akId": "ak"},
+	}, rt.proxy.ops[0])
+	log := rt.rawMounter.GetLog()
+	assert.Contains(t, log, mountutils.FakeAction{Action: mountutils.FakeActionUnmount, Target: rt.targetPath})
+	// FakeMounter logs the device of a bind mount as its source
+	assert.Contains(t, log, mountutils.FakeAction{Action: mountutils.FakeActionMount, Target: rt.targetPath, Source: "bucket:/data"})
+	assert.Equal(t, []string{
+		"Warning FuseMountDisconnected Volume pv-1 is disconnected from its ossfs client, recovering",
+		"Normal FuseMountRecovered Volume pv-1 is remounted, restart the pod if it still fails to access the volume",
+	}, rt.events())
+}
+
+func TestFuseRecoveryPodDeleted(t *testing.T) {
+	// e.g. evicted through the eviction API.
+	// The fake watch ignores the field selector, so a pod left Failed cannot be tested here.
+	rt := newRecoveryTest(t)
+	rt.client.PrependReactor("create", "pods", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		pod := action.(clientgotesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Name = "fuse-2"
+		pod.ResourceVersion = "1"
+		return false, pod, nil
+	})
+	go func() {
+		// simulate kubelet
+		for {
+			time.Sleep(100 * time.Millisecond)
+			pod, err := rt.client.CoreV1().Pods(fusePodNamespace).Get(context.Background(), "fuse-2", metav1.GetOptions{})
+			if err != nil {
+				continue
+			}
+			pod.Status.Phase = corev1.PodRunning
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			_, _ = rt.client.CoreV1().Pods(fusePodNamespace).UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+			return
+		}
+	}()
+	rt.rawMounter.MountCheckErrors = map[string]error{rt.attachPath: syscall.ENOTCONN}
+	rt.setBroken(rt.attachPath, rt.targetPath)
+
+	rt.recovery.recoverAll()
+
+	pod, err := rt.client.CoreV1().Pods(fusePodNamespace).Get(context.Background(), "fuse-2", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "test-node", pod.Spec.NodeName)
+	assert.Len(t, rt.proxy.ops, 1)
+	assert.Contains(t, rt.events(), "Normal FuseMountRecovered Volume pv-1 is remounted, restart the pod if it still fails to access the volume")
+}
+
+func TestFuseRecoveryUntracked(t *testing.T) {
+	rt := newRecoveryTest(t)
+	rt.recovery.untrackTarget("pv-1", rt.targetPath)
+	rt.setBroken(rt.targetPath)
+	rt.recovery.recoverAll()
+	assert.Empty(t, rt.events())
+
+	rt.recovery.untrack("pv-1")
+	rt.setBroken(rt.attachPath)
+	rt.recovery.recoverAll()
+	assert.Empty(t, rt.proxy.ops)
+}
+
+func TestFuseRecoveryRebuild(t *testing.T) {
+	rt := newRecoveryTest(t)
+	attachPath := mounterutils.GetAttachPath("pv-2")
+	targetPath := "/var/lib/kubelet/pods/uid-2/volumes/kubernetes.io~csi/pv-2/mount"
+	require.NoError(t, rt.rawMounter.Mount("ossfs", attachPath, "fuse.ossfs", nil))
+	require.NoError(t, rt.rawMounter.Mount(attachPath, targetPath, "", []string{"bind"}))
+	mountInfo := filepath.Join(t.TempDir(), "mountinfo")
+	require.NoError(t, os.WriteFile(mountInfo, []byte(
+		"310 30 0:200 / "+attachPath+" rw,relatime - fuse.ossfs ossfs rw\n"+
+			"311 30 0:200 / "+targetPath+" rw,relatime - fuse.ossfs ossfs rw\n"+
+			"312 30 0:201 / "+rt.attachPath+" rw,relatime - fuse.ossfs ossfs rw\n"), 0o644))
+	origPath := mountInfoPath
+	defer func() { mountInfoPath = origPath }()
+	mountInfoPath = mountInfo
+	rt.recovery.listMounts = func(ctx context.Context, socketPath string) ([]proxy.MountStatus, error) {
+		assert.Equal(t, mounterutils.GetMountProxySocketPath("pv-2"), socketPath)
+		return []proxy.MountStatus{{
+			Target:  attachPath,
+			Source:  "bucket:/data",
+			Options: []string{"allow_other,passwd_file=" + proxy.Redacted, "ro"},
+		}}, nil
+	}
+
+	pod := rt.readyFusePod("fuse-2")
+	pod.Labels[fpm.FuseVolumeIdLabelKey] = "pv-2"
+	pod.Labels[fpm.FuseMountPathHashLabelKey] = mounterutils.ComputeMountPathHash(attachPath)
+	pod.Annotations[fpm.FuseMountPathAnnoKey] = attachPath
+	shared := rt.readyFusePod("fuse-shared")
+	shared.Labels[fpm.FuseSharedHashLabelKey] = "hash"
+	for _, p := range []*corev1.Pod{rt.readyFusePod("fuse-1"), pod, shared} {
+		_, err := rt.client.CoreV1().Pods(fusePodNamespace).Create(context.Background(), p, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, rt.recovery.rebuild(context.Background(), rt.client, "test-node"))
+	assert.Equal(t, []string{"pv-1", "pv-2"}, slices.Sorted(maps.Keys(rt.recovery.volumes)))
+	// tracked by NodePublishVolume
+	assert.Equal(t, "bucket:/data", rt.recovery.volumes["pv-1"].mount.Source)
+	assert.Equal(t, map[string]*corev1.ObjectReference{targetPath: nil}, rt.recovery.volumes["pv-2"].targets)
+
+	rt.rawMounter.MountCheckErrors = map[string]error{attachPath: syscall.ENOTCONN}
+	rt.setBroken(attachPath, targetPath)
+	rt.recovery.recoverAll()
+	require.Len(t, rt.proxy.ops, 1)
+	assert.Equal(t, mounter.MountOperation{
+		Source:  "bucket:/data",
+		Target:  attachPath,
+		FsType:  "ossfs",
+		Options: []string{"allow_other", "ro"},
+	}, rt.proxy.ops[0])
+
+	// the fuse pod cannot be created again
+	require.NoError(t, rt.client.CoreV1().Pods(fusePodNamespace).Delete(context.Background(), "fuse-2", metav1.DeleteOptions{}))
+	rt.rawMounter.MountCheckErrors = map[string]error{attachPath: syscall.ENOTCONN}
+	rt.recovery.recoverAll()
+	assert.Len(t, rt.proxy.ops, 1)
+	pods, err := rt.client.CoreV1().Pods(fusePodNamespace).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, pods.Items, 2)
+}
+
+func TestStatfsTimeout(t *testing.T) {
+	dir := t.TempDir()
+	_, err := statfs(dir)
+	require.NoError(t, err)
+
+	// a statfs of the path is still stuck
+	pendingStatfs.Store(dir, struct{}{})
+	defer pendingStatfs.Delete(dir)
+	_, err = statfs(dir)
+	assert.ErrorIs(t, err, errStatfsTimeout)
+	assert.False(t, isDisconnected(dir))
+}
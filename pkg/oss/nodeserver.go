@@ -29,6 +29,7 @@ import (
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/features"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter"
+	fpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager"
 	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
 
 	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
@@ -51,6 +52,14 @@ type nodeServer struct {
 	ossfsPaths      map[string]string
 	common.GenericNodeServer
 	skipGlobalMount bool
+	// nil if the usage of volumes without fuse metrics is not reported
+	bucketStats *bucketStatCache
+	// nil without a kube client
+	fusePods *fusePods
+	// nil if OSSFuseRecovery is disabled
+	recovery *fuseRecovery
+	// nil to use mounter.NewProxyMounter
+	newProxyMounter func(socketPath string) mounter.Mounter
 }
 
 const (
@@ -71,6 +80,20 @@ func (ns *nodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetC
 				},
 			},
 		},
+		{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+				},
+			},
+		},
+		{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+				},
+			},
+		},
 	}}, nil
 }
 
@@ -164,6 +187,10 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		return nil, err
 	}
 
+	if runtimeType == RuntimeTypeRunC && opts.SharedFusePod {
+		return ns.publishSharedVolume(req, notMntTarget)
+	}
+
 	// Handle COCO scenario: do not support republish
 	if runtimeType == RuntimeTypeCOCO {
 		if !notMntTarget {
@@ -184,6 +211,12 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 		}
 	}
 
+	// Only mount-proxy-server can keep the FUSE connection, the MicroVM cmd mounter runs ossfs directly
+	if opts.FdPassing && runtimeType == RuntimeTypeMicroVM {
+		klog.Warningf("NodePublishVolume: fdPassing is not supported in MicroVM, ignored for %s", targetPath)
+		opts.FdPassing = false
+	}
+
 	mountSource := fmt.Sprintf("%s:%s", opts.MountBucket(), opts.Path)
 	needRotateToken := needRotateToken(opts.FuseType, authCfg.Secrets)
 
@@ -281,6 +314,7 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 			Secrets:     authCfg.Secrets,
 			MetricsPath: metricsPath,
 			Overlay:     opts.Overlay,
+			FdPassing:   opts.FdPassing,
 		})
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
@@ -316,11 +350,15 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 			Options:     mountOptions,
 			Secrets:     authCfg.Secrets,
 			MetricsPath: metricsPath,
+			FdPassing:   opts.FdPassing,
 		})
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
 		if !notMntTarget {
+			if ns.recovery != nil {
+				ns.recovery.rotateSecrets(req.VolumeId, authCfg.Secrets)
+			}
 			klog.Infof("NodePublishVolume: successfully rotated token for volume %s on %s", req.VolumeId, attachPath)
 			return &csi.NodePublishVolumeResponse{}, nil
 		}
@@ -336,6 +374,22 @@ func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublis
 	}
 	klog.Infof("NodePublishVolume: bind mounted %s to %s", attachPath, targetPath)
 
+	if ns.recovery != nil {
+		ns.recovery.track(req, &fpm.FusePodContext{
+			Namespace:         fusePodNamespace,
+			NodeName:          ns.nodeName,
+			VolumeId:          req.VolumeId,
+			FuseType:          opts.FuseType,
+			AuthConfig:        authCfg,
+			PodTemplateConfig: makePodTemplateConfig(opts),
+		}, socketPath, &mounter.MountOperation{
+			Source:  mountSource,
+			Target:  attachPath,
+			FsType:  opts.FuseType,
+			Options: mountOptions,
+			Secrets: authCfg.Secrets,
+		})
+	}
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
@@ -367,6 +421,9 @@ func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 		return nil, status.Errorf(codes.Internal, "failed to unmount target %q: %v", targetPath, err)
 	}
 	klog.Infof("NodeUnpublishVolume: Umount OSS Successful: %s", targetPath)
+	if ns.recovery != nil {
+		ns.recovery.untrackTarget(req.VolumeId, targetPath)
+	}
 
 	// Best-effort cleanup of overlay lower dir (FUSE mount).
 	// In RunD/Sandbox with overlay, mount-proxy-server mounted FUSE to a lower dir.
@@ -377,10 +434,29 @@ func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
+func (ns *nodeServer) proxyMounter(socketPath string) mounter.Mounter {
+	if ns.newProxyMounter != nil {
+		return ns.newProxyMounter(socketPath)
+	}
+	return mounter.NewForMounter(mounter.NewProxyMounter(socketPath, ns.rawMounter))
+}
+
 func (ns *nodeServer) NodeStageVolume(
 	ctx context.Context,
 	req *csi.NodeStageVolumeRequest) (
 	*csi.NodeStageVolumeResponse, error) {
+	// only volumes sharing fuse pods are staged, see stageSharedVolume
+	if !sharedFusePodRequested(req.VolumeContext) {
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+	klog.Infof("NodeStageVolume: starting to stage volume %s with a shared fuse pod", req.VolumeId)
+	if !ns.locks.TryAcquire(req.VolumeId) {
+		return nil, status.Errorf(codes.Aborted, "There is already an operation for %s", req.VolumeId)
+	}
+	defer ns.locks.Release(req.VolumeId)
+	if err := ns.stageSharedVolume(ctx, req); err != nil {
+		return nil, err
+	}
 	return &csi.NodeStageVolumeResponse{}, nil
 }
 
@@ -400,6 +476,13 @@ func (ns *nodeServer) NodeUnstageVolume(
 		return nil, status.Errorf(codes.Internal, "failed to unmount target %q: %v", attachPath, err)
 	}
 
+	if ns.recovery != nil {
+		ns.recovery.untrack(req.VolumeId)
+	}
+	if err := ns.unstageSharedVolume(ctx, req.VolumeId); err != nil {
+		return nil, err
+	}
+
 	// The metricsPath in fuse Pod will be cleaned and not allowed to update the metrics
 	utils.RemoveMetrics(metricsPathPrefix, req)
 
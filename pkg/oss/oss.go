@@ -19,11 +19,13 @@ limitations under the License.
 package oss
 
 import (
+	"context"
 	"os"
 
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud/metadata"
 	cnfsv1beta1 "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cnfs/v1beta1"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/common"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/features"
 	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
 	_ "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss/ossfs"
 	_ "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss/ossfs2"
@@ -31,6 +33,7 @@ import (
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/version"
 	k8sver "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
@@ -80,10 +83,12 @@ func NewServers(endpoint string, m metadata.MetadataProvider, serviceType utils.
 			cnfsGetter:      cnfsGetter,
 			metadata:        m,
 			fusePodManagers: fusePodManagers,
+			ossClient:       newOSSClient(m),
+			prefixes:        newPrefixCleaner(utils.NewEventRecorder(utils.EventComponentController)),
 		}
 	}
 	if serviceType&utils.Node != 0 {
-		servers.NodeServer = &nodeServer{
+		ns := &nodeServer{
 			metadata:        m,
 			locks:           utils.NewVolumeLocks(),
 			nodeName:        nodeName,
@@ -92,10 +97,24 @@ func NewServers(endpoint string, m metadata.MetadataProvider, serviceType utils.
 			skipGlobalMount: utils.GetSkipGlobalMount(false),
 			rawMounter:      mountutils.NewWithoutSystemd(""),
 			fusePodManagers: fusePodManagers,
+			bucketStats:     newBucketStatCache(newOSSClient(m)),
+			fusePods:        newFusePods(clientset, nodeName),
 			GenericNodeServer: common.GenericNodeServer{
 				NodeID: nodeName,
 			},
 		}
+		if features.FunctionalMutableFeatureGate.Enabled(features.OSSFuseRecovery) {
+			ns.recovery = newFuseRecovery(ns.locks, ns.rawMounter, fusePodManagers, utils.NewEventRecorder(utils.EventComponentNode))
+			go func() {
+				if clientset != nil {
+					if err := ns.recovery.rebuild(context.Background(), clientset, nodeName); err != nil {
+						klog.ErrorS(err, "failed to track the volumes published before restart")
+					}
+				}
+				wait.Forever(ns.recovery.recoverAll, fuseRecoveryInterval)
+			}()
+		}
+		servers.NodeServer = ns
 	}
 
 	return &servers
@@ -0,0 +1,358 @@
+//go:build !windows
+
+package oss
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	alicred "github.com/aliyun/credentials-go/credentials/providers"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/cloud/metadata"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/credentials"
+	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
+	osscloud "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/oss/cloud"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// StorageClass parameters of the dynamically provisioned prefixes, also kept in the volume context.
+const (
+	// volKeyCreatePrefix creates the prefix of each volume in the bucket, requires volumeAs=subpath
+	volKeyCreatePrefix = "createPrefix"
+	// volKeyCreateBucket creates the bucket if it does not exist, with the bucket* attributes below
+	volKeyCreateBucket         = "createBucket"
+	volKeyBucketStorageClass   = "bucketStorageClass"
+	volKeyBucketRedundancyType = "bucketRedundancyType"
+	volKeyBucketEncryption     = "bucketEncryption"
+	volKeyBucketKMSKeyID       = "bucketKmsKeyId"
+	volKeyOnDelete             = "onDelete"
+	volKeyArchivePrefix        = "archivePrefix"
+)
+
+const (
+	defaultArchivePrefix        = "archive/"
+	prefixCleanupPageSize       = 1000
+	prefixCleanupEventInterval  = 10000
+	prefixArchiveTransitionDays = 1
+)
+
+// prefixReclaimAction is what to do with the objects of a prefix when its volume is deleted with the Delete reclaim policy.
+type prefixReclaimAction string
+
+const (
+	// prefixReclaimDelete deletes all the objects of the prefix
+	prefixReclaimDelete prefixReclaimAction = "delete"
+	// prefixReclaimArchive moves the objects of the prefix under archivePrefix,
+	// with a lifecycle rule transitioning the objects under archivePrefix to the Archive storage class
+	prefixReclaimArchive prefixReclaimAction = "archive"
+	// prefixReclaimRename moves the objects of the prefix under archivePrefix
+	prefixReclaimRename prefixReclaimAction = "rename"
+)
+
+type prefixParams struct {
+	CreateBucket  bool
+	Bucket        osscloud.BucketConfiguration
+	Encryption    string
+	KMSKeyID      string
+	OnDelete      prefixReclaimAction
+	ArchivePrefix string
+}
+
+// parsePrefixParams returns nil if prefixes are not created for the volumes.
+func parsePrefixParams(params map[string]string) (*prefixParams, error) {
+	if create, _ := strconv.ParseBool(params[volKeyCreatePrefix]); !create {
+		return nil, nil
+	}
+	if VolumeAsType(strings.ToLower(params["volumeAs"])) != VolumeAsSubpath {
+		return nil, fmt.Errorf("%s requires volumeAs=subpath", volKeyCreatePrefix)
+	}
+	p := &prefixParams{
+		OnDelete:      prefixReclaimAction(strings.ToLower(params[volKeyOnDelete])),
+		ArchivePrefix: params[volKeyArchivePrefix],
+		Encryption:    params[volKeyBucketEncryption],
+		KMSKeyID:      params[volKeyBucketKMSKeyID],
+	}
+	switch p.OnDelete {
+	case "":
+		p.OnDelete = prefixReclaimDelete
+	case prefixReclaimDelete, prefixReclaimArchive, prefixReclaimRename:
+	default:
+		return nil, fmt.Errorf("invalid %s %q, only support delete, archive and rename", volKeyOnDelete, p.OnDelete)
+	}
+	if p.ArchivePrefix != "" && p.OnDelete == prefixReclaimDelete {
+		return nil, fmt.Errorf("%s is only used with %s=rename or archive", volKeyArchivePrefix, volKeyOnDelete)
+	}
+
+	if v := params[volKeyCreateBucket]; v != "" {
+		var err error
+		p.CreateBucket, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", volKeyCreateBucket, v, err)
+		}
+	}
+	p.Bucket.StorageClass = params[volKeyBucketStorageClass]
+	p.Bucket.DataRedundancyType = params[volKeyBucketRedundancyType]
+	if !p.CreateBucket && (p.Bucket.StorageClass != "" || p.Bucket.DataRedundancyType != "" || p.Encryption != "") {
+		return nil, fmt.Errorf("bucket attributes are only used with %s=true", volKeyCreateBucket)
+	}
+	switch p.Bucket.StorageClass {
+	case "", "Standard", "IA", "Archive", "ColdArchive", "DeepColdArchive":
+	default:
+		return nil, fmt.Errorf("invalid %s %q", volKeyBucketStorageClass, p.Bucket.StorageClass)
+	}
+	switch p.Bucket.DataRedundancyType {
+	case "", "LRS", "ZRS":
+	default:
+		return nil, fmt.Errorf("invalid %s %q, only support LRS and ZRS", volKeyBucketRedundancyType, p.Bucket.DataRedundancyType)
+	}
+	switch p.Encryption {
+	case "", "AES256", "SM4":
+		if p.KMSKeyID != "" {
+			return nil, fmt.Errorf("%s is only used with %s=KMS", volKeyBucketKMSKeyID, volKeyBucketEncryption)
+		}
+	case "KMS":
+	default:
+		return nil, fmt.Errorf("invalid %s %q, only support AES256, KMS and SM4", volKeyBucketEncryption, p.Encryption)
+	}
+	return p, nil
+}
+
+// objectPrefix is the prefix of the objects under the path of a volume.
+func objectPrefix(volumePath string) string {
+	return strings.TrimPrefix(path.Clean(volumePath), "/") + "/"
+}
+
+// newOSSClient returns a maker of clients of the bucket, with the credentials in the secrets, or the credentials of the controller.
+// The requests are signed with the signature V4 in the region of the bucket, unless sigVersion is v1.
+func newOSSClient(m metadata.MetadataProvider) func(opts *ossfpm.Options) (osscloud.Interface, error) {
+	return func(opts *ossfpm.Options) (osscloud.Interface, error) {
+		var (
+			provider alicred.CredentialsProvider
+			err      error
+		)
+		switch {
+		case opts.AkID != "" && opts.AkSecret != "":
+			provider, err = alicred.NewStaticAKCredentialsProviderBuilder().
+				WithAccessKeyId(opts.AkID).WithAccessKeySecret(opts.AkSecret).Build()
+		case opts.SecurityToken != "":
+			provider, err = alicred.NewStaticSTSCredentialsProviderBuilder().
+				WithAccessKeyId(opts.AccessKeyId).WithAccessKeySecret(opts.AccessKeySecret).WithSecurityToken(opts.SecurityToken).Build()
+		default:
+			provider, err = credentials.NewProvider()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials: %w", err)
+		}
+		var region string
+		if opts.SigVersion != ossfpm.SigV1 {
+			region = ossfpm.ResolveRegion(opts, m)
+		}
+		return osscloud.NewClient(opts.URL, region, opts.Bucket, provider), nil
+	}
+}
+
+// createPrefix creates the bucket if requested, and the directory object of the volume.
+func createPrefix(ctx context.Context, client osscloud.Interface, opts *ossfpm.Options, p *prefixParams) error {
+	logger := klog.FromContext(ctx)
+	if p.CreateBucket {
+		err := client.GetBucketInfo(ctx)
+		if osscloud.IsErrorCode(err, osscloud.ErrNoSuchBucket) {
+			logger.Info("creating bucket", "bucket", opts.Bucket, "storageClass", p.Bucket.StorageClass, "redundancy", p.Bucket.DataRedundancyType)
+			err = client.PutBucket(ctx, p.Bucket)
+			if err == nil && p.Encryption != "" {
+				err = client.PutBucketEncryption(ctx, p.Encryption, p.KMSKeyID)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", opts.Bucket, err)
+		}
+	}
+	// ossfs only mounts an existing directory
+	key := objectPrefix(opts.Path)
+	if err := client.PutObject(ctx, key, []byte{}); err != nil {
+		return fmt.Errorf("failed to create directory %s in bucket %s: %w", key, opts.Bucket, err)
+	}
+	logger.V(2).Info("created prefix", "bucket", opts.Bucket, "prefix", key)
+	return nil
+}
+
+type prefixCleanupJob struct {
+	done      chan struct{}
+	processed int
+	err       error
+}
+
+// prefixCleaner reclaims the prefixes of deleted volumes in background,
+// DeleteVolume is retried until the job finishes, keeping the PV around to show the progress in its events.
+type prefixCleaner struct {
+	recorder record.EventRecorder
+
+	mu   sync.Mutex
+	jobs map[string]*prefixCleanupJob
+	// lifecycleLocks serializes the read-modify-write of the lifecycle configuration of each bucket
+	lifecycleLocks map[string]*sync.Mutex
+}
+
+func newPrefixCleaner(recorder record.EventRecorder) *prefixCleaner {
+	return &prefixCleaner{
+		recorder:       recorder,
+		jobs:           map[string]*prefixCleanupJob{},
+		lifecycleLocks: map[string]*sync.Mutex{},
+	}
+}
+
+// reclaim starts the job for the volume if not already running, and returns nil only after it succeeds.
+func (c *prefixCleaner) reclaim(ctx context.Context, pv *corev1.PersistentVolume, client osscloud.Interface, opts *ossfpm.Options, action prefixReclaimAction, archivePrefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	job := c.jobs[pv.Name]
+	if job == nil {
+		job = &prefixCleanupJob{done: make(chan struct{})}
+		c.jobs[pv.Name] = job
+		prefix := objectPrefix(opts.Path)
+		c.recorder.Eventf(pv, corev1.EventTypeNormal, "PrefixReclaimStarted", "Reclaiming prefix %s of bucket %s with action %s", prefix, opts.Bucket, action)
+		// not bound to the request, which is cancelled on timeout
+		jobCtx := klog.NewContext(context.Background(), klog.FromContext(ctx))
+		go func() {
+			defer close(job.done)
+			var err error
+			switch action {
+			case prefixReclaimArchive:
+				err = c.addArchiveRule(jobCtx, client, opts.Bucket, archivePrefix)
+				if err == nil {
+					err = c.moveObjects(jobCtx, pv, job, client, prefix, archivePrefix)
+				}
+			case prefixReclaimRename:
+				err = c.moveObjects(jobCtx, pv, job, client, prefix, archivePrefix)
+			default:
+				err = c.moveObjects(jobCtx, pv, job, client, prefix, "")
+			}
+			c.mu.Lock()
+			job.err = err
+			c.mu.Unlock()
+			if err != nil {
+				c.recorder.Eventf(pv, corev1.EventTypeWarning, "PrefixReclaimFailed", "Failed to reclaim prefix %s, will retry: %v", prefix, err)
+			} else {
+				c.recorder.Eventf(pv, corev1.EventTypeNormal, "PrefixReclaimed", "Reclaimed prefix %s of bucket %s, %d objects processed", prefix, opts.Bucket, job.processed)
+			}
+		}()
+	}
+
+	select {
+	case <-job.done:
+		delete(c.jobs, pv.Name)
+		if job.err != nil {
+			return status.Errorf(codes.Internal, "failed to reclaim prefix: %v", job.err)
+		}
+		return nil
+	default:
+		return status.Errorf(codes.Aborted, "reclaiming prefix in progress, %d objects processed", job.processed)
+	}
+}
+
+// moveObjects copies the objects of the prefix under archivePrefix if not empty, and deletes them.
+// The listing restarts from the beginning of the prefix after each page, so that a job restarted after a failure continues with the objects left.
+func (c *prefixCleaner) moveObjects(ctx context.Context, pv *corev1.PersistentVolume, job *prefixCleanupJob, client osscloud.Interface, prefix, archivePrefix string) error {
+	logger := klog.FromContext(ctx)
+	for {
+		objects, err := client.ListObjects(ctx, prefix, prefixCleanupPageSize)
+		if err != nil {
+			return err
+		}
+		if len(objects) == 0 {
+			return nil
+		}
+		keys := make([]string, 0, len(objects))
+		for _, o := range objects {
+			if archivePrefix != "" {
+				dst := archivePrefix + o.Key
+				if err := client.CopyObject(ctx, o.Key, dst, o.Size); err != nil {
+					return fmt.Errorf("failed to copy %s to %s: %w", o.Key, dst, err)
+				}
+			}
+			keys = append(keys, o.Key)
+		}
+		if err := client.DeleteObjects(ctx, keys); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		before := job.processed
+		job.processed += len(keys)
+		processed := job.processed
+		c.mu.Unlock()
+		logger.V(2).Info("reclaimed objects", "prefix", prefix, "processed", processed)
+		if before/prefixCleanupEventInterval != processed/prefixCleanupEventInterval {
+			c.recorder.Eventf(pv, corev1.EventTypeNormal, "PrefixReclaimProgress", "%d objects of prefix %s processed", processed, prefix)
+		}
+	}
+}
+
+// addArchiveRule adds the lifecycle rule transitioning the objects under archivePrefix to the Archive storage class,
+// if not already added. A bucket has at most 1000 rules, so all the volumes archived under the same prefix share the rule.
+func (c *prefixCleaner) addArchiveRule(ctx context.Context, client osscloud.Interface, bucket, archivePrefix string) error {
+	c.mu.Lock()
+	lock := c.lifecycleLocks[bucket]
+	if lock == nil {
+		lock = &sync.Mutex{}
+		c.lifecycleLocks[bucket] = lock
+	}
+	c.mu.Unlock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	rules, err := client.GetBucketLifecycle(ctx)
+	if err != nil {
+		return err
+	}
+	// the ID of a rule is limited to 255 bytes, the prefix to 1023
+	id := fmt.Sprintf("csi-archive-%x", sha256.Sum256([]byte(archivePrefix)))
+	if slices.ContainsFunc(rules, func(r osscloud.LifecycleRule) bool { return r.ID == id }) {
+		return nil
+	}
+	rules = append(rules, osscloud.NewArchiveRule(id, archivePrefix, prefixArchiveTransitionDays))
+	return client.PutBucketLifecycle(ctx, rules)
+}
+
+// deletePrefixVolume reclaims the prefix of a volume created with createPrefix.
+func (cs *controllerServer) deletePrefixVolume(ctx context.Context, req *csi.DeleteVolumeRequest, pv *corev1.PersistentVolume) error {
+	attributes := pv.Spec.CSI.VolumeAttributes
+	p, err := parsePrefixParams(attributes)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if p == nil || pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimDelete {
+		return nil
+	}
+	opts, err := parseOptions(ctx, cs.cnfsGetter, attributes, req.Secrets, nil, false, "", false, cs.metadata)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	// never reclaim a path not created for this volume
+	if path.Base(opts.Path) != req.VolumeId {
+		return status.Errorf(codes.InvalidArgument, "unexpected path %q of volume %s", opts.Path, req.VolumeId)
+	}
+	client, err := cs.ossClient(opts)
+	if err != nil {
+		return status.Errorf(codes.Internal, "%v", err)
+	}
+	archivePrefix := ""
+	if p.OnDelete != prefixReclaimDelete {
+		archivePrefix = strings.TrimSuffix(cmp.Or(p.ArchivePrefix, defaultArchivePrefix), "/") + "/"
+		if strings.HasPrefix(archivePrefix, objectPrefix(opts.Path)) {
+			return status.Errorf(codes.InvalidArgument, "%s %q is inside the volume", volKeyArchivePrefix, archivePrefix)
+		}
+	}
+	return cs.prefixes.reclaim(ctx, pv, client, opts, p.OnDelete, archivePrefix)
+}
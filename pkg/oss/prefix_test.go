@@ -0,0 +1,326 @@
+//go:build !windows
+
+package oss
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
+	osscloud "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/oss/cloud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeBucket is an in-memory bucket
+type fakeBucket struct {
+	mu         sync.Mutex
+	exists     bool
+	config     *osscloud.BucketConfiguration
+	encryption string
+	objects    map[string]int64
+	rules      []osscloud.LifecycleRule
+}
+
+func (b *fakeBucket) GetBucketInfo(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.exists {
+		return &osscloud.ServiceError{StatusCode: 404, Code: osscloud.ErrNoSuchBucket}
+	}
+	return nil
+}
+
+func (b *fakeBucket) PutBucket(ctx context.Context, config osscloud.BucketConfiguration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.exists = true
+	b.config = &config
+	return nil
+}
+
+func (b *fakeBucket) PutBucketEncryption(ctx context.Context, algorithm, kmsKeyID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.encryption = algorithm + "/" + kmsKeyID
+	return nil
+}
+
+func (b *fakeBucket) PutObject(ctx context.Context, key string, body []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[key] = int64(len(body))
+	return nil
+}
+
+func (b *fakeBucket) ListObjects(ctx context.Context, prefix string, maxKeys int) ([]osscloud.Object, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var objects []osscloud.Object
+	for _, k := range slices.Sorted(maps.Keys(b.objects)) {
+		if strings.HasPrefix(k, prefix) && len(objects) < maxKeys {
+			objects = append(objects, osscloud.Object{Key: k, Size: b.objects[k]})
+		}
+	}
+	return objects, nil
+}
+
+func (b *fakeBucket) DeleteObjects(ctx context.Context, keys []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, k := range keys {
+		delete(b.objects, k)
+	}
+	return nil
+}
+
+func (b *fakeBucket) CopyObject(ctx context.Context, src, dst string, size int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.objects[dst] = b.objects[src]
+	return nil
+}
+
+func (b *fakeBucket) GetBucketLifecycle(ctx context.Context) ([]osscloud.LifecycleRule, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.rules), nil
+}
+
+func (b *fakeBucket) PutBucketLifecycle(ctx context.Context, rules []osscloud.LifecycleRule) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rules = rules
+	return nil
+}
+
+func (b *fakeBucket) GetBucketStat(ctx context.Context) (*osscloud.BucketStat, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stat := &osscloud.BucketStat{ObjectCount: int64(len(b.objects))}
+	for _, size := range b.objects {
+		stat.Storage += size
+	}
+	return stat, nil
+}
+
+func (b *fakeBucket) keys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Sorted(maps.Keys(b.objects))
+}
+
+func TestParsePrefixParams(t *testing.T) {
+	base := map[string]string{volKeyCreatePrefix: "true", "volumeAs": "subpath"}
+	with := func(kv ...string) map[string]string {
+		params := maps.Clone(base)
+		for i := 0; i < len(kv); i += 2 {
+			params[kv[i]] = kv[i+1]
+		}
+		return params
+	}
+
+	p, err := parsePrefixParams(map[string]string{"volumeAs": "subpath"})
+	require.NoError(t, err)
+	assert.Nil(t, p)
+
+	p, err = parsePrefixParams(base)
+	require.NoError(t, err)
+	assert.Equal(t, prefixReclaimDelete, p.OnDelete)
+
+	p, err = parsePrefixParams(with(volKeyCreateBucket, "true", volKeyBucketStorageClass, "IA", volKeyBucketRedundancyType, "ZRS",
+		volKeyBucketEncryption, "KMS", volKeyBucketKMSKeyID, "key-1", volKeyOnDelete, "rename", volKeyArchivePrefix, "trash/"))
+	require.NoError(t, err)
+	assert.Equal(t, &prefixParams{
+		CreateBucket:  true,
+		Bucket:        osscloud.BucketConfiguration{StorageClass: "IA", DataRedundancyType: "ZRS"},
+		Encryption:    "KMS",
+		KMSKeyID:      "key-1",
+		OnDelete:      prefixReclaimRename,
+		ArchivePrefix: "trash/",
+	}, p)
+
+	for _, invalid := range []map[string]string{
+		{volKeyCreatePrefix: "true"},
+		with(volKeyOnDelete, "recycle"),
+		with(volKeyArchivePrefix, "trash/"),
+		with(volKeyOnDelete, "delete", volKeyArchivePrefix, "trash/"),
+		with(volKeyBucketStorageClass, "IA"),
+		with(volKeyCreateBucket, "true", volKeyBucketRedundancyType, "GRS"),
+		with(volKeyCreateBucket, "true", volKeyBucketEncryption, "AES256", volKeyBucketKMSKeyID, "key-1"),
+	} {
+		_, err := parsePrefixParams(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func testPrefixServer(t *testing.T, bucket *fakeBucket, objs ...*corev1.PersistentVolume) *controllerServer {
+	clientset := fake.NewClientset()
+	for _, pv := range objs {
+		_, err := clientset.CoreV1().PersistentVolumes().Create(context.Background(), pv, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	return &controllerServer{
+		client:   clientset,
+		metadata: m,
+		ossClient: func(opts *ossfpm.Options) (osscloud.Interface, error) {
+			assert.Equal(t, "my-bucket", opts.Bucket)
+			return bucket, nil
+		},
+		prefixes: newPrefixCleaner(record.NewFakeRecorder(100)),
+	}
+}
+
+func TestCreatePrefixVolume(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string]int64{}}
+	cs := testPrefixServer(t, bucket)
+	resp, err := cs.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name: "pvc-1",
+		Parameters: map[string]string{
+			"bucket":                             "my-bucket",
+			"url":                                "oss-cn-beijing-internal.aliyuncs.com",
+			"path":                               "/data",
+			"volumeAs":                           "subpath",
+			volKeyCreatePrefix:                   "true",
+			volKeyCreateBucket:                   "true",
+			volKeyBucketStorageClass:             "IA",
+			volKeyBucketEncryption:               "AES256",
+			"csi.alibabacloud.com/reclaimPolicy": "Delete",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/data/pvc-1", resp.Volume.VolumeContext[volKeyPath])
+	assert.Equal(t, []string{"data/pvc-1/"}, bucket.keys())
+	assert.Equal(t, "IA", bucket.config.StorageClass)
+	assert.Equal(t, "AES256/", bucket.encryption)
+
+	// Delete reclaim policy requires createPrefix
+	_, err = cs.CreateVolume(context.Background(), &csi.CreateVolumeRequest{
+		Name:       "pvc-2",
+		Parameters: map[string]string{"csi.alibabacloud.com/reclaimPolicy": "Delete"},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func prefixPV(name string, policy corev1.PersistentVolumeReclaimPolicy, attributes map[string]string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: policy,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: driverName, VolumeHandle: name, VolumeAttributes: attributes},
+			},
+		},
+	}
+}
+
+// deleteUntilDone retries DeleteVolume like the external-provisioner
+func deleteUntilDone(t *testing.T, cs *controllerServer, volumeID string) {
+	require.Eventually(t, func() bool {
+		_, err := cs.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: volumeID})
+		if status.Code(err) == codes.Aborted {
+			return false
+		}
+		require.NoError(t, err)
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDeletePrefixVolume(t *testing.T) {
+	attributes := func(kv ...string) map[string]string {
+		a := map[string]string{
+			"bucket":           "my-bucket",
+			"url":              "oss-cn-beijing-internal.aliyuncs.com",
+			"path":             "/data/pvc-1",
+			"volumeAs":         "subpath",
+			volKeyCreatePrefix: "true",
+		}
+		for i := 0; i < len(kv); i += 2 {
+			a[kv[i]] = kv[i+1]
+		}
+		return a
+	}
+	newBucket := func() *fakeBucket {
+		objects := map[string]int64{"data/pvc-10/a": 1, "data/pvc-1/": 0}
+		for i := range 2500 {
+			objects[fmt.Sprintf("data/pvc-1/file-%d", i)] = 1
+		}
+		return &fakeBucket{objects: objects}
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		bucket := newBucket()
+		cs := testPrefixServer(t, bucket, prefixPV("pvc-1", corev1.PersistentVolumeReclaimDelete, attributes()))
+		deleteUntilDone(t, cs, "pvc-1")
+		assert.Equal(t, []string{"data/pvc-10/a"}, bucket.keys())
+
+		events := cs.prefixes.recorder.(*record.FakeRecorder).Events
+		assert.Contains(t, <-events, "PrefixReclaimStarted")
+		assert.Contains(t, <-events, "Reclaimed prefix data/pvc-1/ of bucket my-bucket, 2501 objects processed")
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		bucket := newBucket()
+		cs := testPrefixServer(t, bucket, prefixPV("pvc-1", corev1.PersistentVolumeReclaimDelete, attributes(volKeyOnDelete, "rename")))
+		deleteUntilDone(t, cs, "pvc-1")
+		keys := bucket.keys()
+		assert.Len(t, keys, 2502)
+		assert.Contains(t, keys, "archive/data/pvc-1/")
+		assert.Contains(t, keys, "data/pvc-10/a")
+	})
+
+	t.Run("rename into the volume", func(t *testing.T) {
+		cs := testPrefixServer(t, newBucket(), prefixPV("pvc-1", corev1.PersistentVolumeReclaimDelete,
+			attributes(volKeyOnDelete, "rename", volKeyArchivePrefix, "data/pvc-1/old")))
+		_, err := cs.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: "pvc-1"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		bucket := newBucket()
+		bucket.rules = []osscloud.LifecycleRule{{ID: "other"}}
+		cs := testPrefixServer(t, bucket, prefixPV("pvc-1", corev1.PersistentVolumeReclaimDelete, attributes(volKeyOnDelete, "archive")))
+		deleteUntilDone(t, cs, "pvc-1")
+		keys := bucket.keys()
+		assert.Len(t, keys, 2502)
+		assert.Contains(t, keys, "archive/data/pvc-1/")
+		require.Len(t, bucket.rules, 2)
+		assert.Equal(t, "archive/", bucket.rules[1].Prefix)
+
+		// one rule for all the volumes archived under the same prefix
+		bucket.objects["data/pvc-2/a"] = 1
+		cs = testPrefixServer(t, bucket, prefixPV("pvc-2", corev1.PersistentVolumeReclaimDelete, attributes(volKeyOnDelete, "archive", "path", "/data/pvc-2")))
+		deleteUntilDone(t, cs, "pvc-2")
+		assert.Contains(t, bucket.keys(), "archive/data/pvc-2/a")
+		assert.Len(t, bucket.rules, 2)
+	})
+
+	t.Run("retain", func(t *testing.T) {
+		bucket := newBucket()
+		cs := testPrefixServer(t, bucket, prefixPV("pvc-1", corev1.PersistentVolumeReclaimRetain, attributes()))
+		deleteUntilDone(t, cs, "pvc-1")
+		assert.Len(t, bucket.keys(), 2502)
+	})
+
+	t.Run("not created by the driver", func(t *testing.T) {
+		bucket := newBucket()
+		cs := testPrefixServer(t, bucket, prefixPV("pvc-1", corev1.PersistentVolumeReclaimDelete, map[string]string{
+			"bucket": "my-bucket", "url": "oss-cn-beijing-internal.aliyuncs.com", "path": "/data/pvc-1",
+		}))
+		deleteUntilDone(t, cs, "pvc-1")
+		assert.Len(t, bucket.keys(), 2502)
+	})
+}
@@ -0,0 +1,225 @@
+//go:build !windows
+
+package oss
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter"
+	fpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager"
+	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
+	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+	mountutils "k8s.io/mount-utils"
+)
+
+// RunC volumes with sharedFusePod=true are served by a fuse pod shared by all volumes on the node
+// with the same ossfpm.SharedFusePodHash:
+//   - NodeStageVolume creates or references the shared fuse pod, mounts the bucket on the attach path of the
+//     shared volume once, and bind mounts it to the attach path of the volume.
+//   - NodePublishVolume bind mounts the attach path of the volume to the target.
+//   - NodeUnstageVolume drops the reference, or unmounts the shared volume and deletes the shared fuse pod
+//     with the last one, so that the last reference stays on the pod until it is deleted.
+
+// sharedFusePodRequested reports whether the volume asks for a shared fuse pod,
+// without the cost of parseOptions for the volumes that do not.
+func sharedFusePodRequested(volumeContext map[string]string) bool {
+	for k, v := range volumeContext {
+		if strings.TrimSpace(strings.ToLower(k)) == "sharedfusepod" {
+			shared, _ := strconv.ParseBool(strings.TrimSpace(v))
+			return shared
+		}
+	}
+	return false
+}
+
+func (ns *nodeServer) stageSharedVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (err error) {
+	opts, err := parseOptions(ctx, ns.cnfsGetter, req.GetVolumeContext(), req.GetSecrets(), []*csi.VolumeCapability{req.GetVolumeCapability()}, false, "", true, ns.metadata)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := resolveAgenticBucketOptions(opts, ns.metadata); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	runtimeType, err := DetermineRuntimeType(opts.DirectAssigned, req.PublishContext[mountProxySocket], ns.skipGlobalMount)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "failed to determine runtime type: %v", err)
+	}
+	if runtimeType != RuntimeTypeRunC {
+		klog.Infof("NodeStageVolume: %s does not share fuse pods in runtime %s", req.VolumeId, runtimeType)
+		return nil
+	}
+	if ns.clientset == nil {
+		return status.Error(codes.FailedPrecondition, "shared fuse pods need access to the apiserver")
+	}
+
+	manager := ns.fusePodManagers[opts.FuseType]
+	if err := checkOssOptions(opts, manager); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	// the credentials of the shared fuse pod are never rotated, and the token would expire
+	if opts.SecurityToken != "" {
+		return status.Error(codes.InvalidArgument, "sharedFusePod does not support STS tokens in secrets")
+	}
+	authCfg, err := makeAuthConfig(opts, manager, ns.metadata, true)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	mountOptions, err := makeMountOptions(opts, manager, ns.metadata, req.VolumeCapability)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	mountOptions = manager.AddDefaultMountOptions(mountOptions)
+	ptCfg := makePodTemplateConfig(opts)
+
+	hash := ossfpm.SharedFusePodHash(opts, authCfg, ptCfg, mountOptions)
+	sharedVolumeId := fpm.SharedVolumeId(hash)
+	if !ns.locks.TryAcquire(sharedVolumeId) {
+		return status.Errorf(codes.Aborted, "There is already an operation for %s", sharedVolumeId)
+	}
+	defer ns.locks.Release(sharedVolumeId)
+
+	sharedAttachPath := mounterutils.GetAttachPath(sharedVolumeId)
+	attachPath := mounterutils.GetAttachPath(req.VolumeId)
+	podContext := &fpm.FusePodContext{
+		Context:           ctx,
+		Namespace:         fusePodNamespace,
+		NodeName:          ns.nodeName,
+		VolumeId:          req.VolumeId,
+		FuseType:          opts.FuseType,
+		AuthConfig:        authCfg,
+		PodTemplateConfig: ptCfg,
+	}
+	fusePod, err := manager.AcquireShared(podContext, hash, sharedAttachPath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to create shared %s pod: %v", opts.FuseType, err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		// not staged, NodeUnstageVolume may never be called to drop the reference
+		if cerr := mountutils.CleanupMountPoint(attachPath, ns.rawMounter, false); cerr != nil {
+			klog.Errorf("NodeStageVolume: failed to unmount %s: %v", attachPath, cerr)
+			return
+		}
+		if rerr := ns.releaseSharedVolume(podContext, manager, sharedVolumeId); rerr != nil {
+			klog.Errorf("NodeStageVolume: failed to release shared volume %s: %v", sharedVolumeId, rerr)
+		}
+	}()
+
+	notMnt, err := mounterutils.IsNotMountPoint(ns.rawMounter, sharedAttachPath)
+	if err != nil {
+		return err
+	}
+	if notMnt {
+		err = ns.proxyMounter(mounterutils.GetMountProxySocketPath(sharedVolumeId)).ExtendedMount(ctx, &mounter.MountOperation{
+			Source:    fmt.Sprintf("%s:%s", opts.MountBucket(), opts.Path),
+			Target:    sharedAttachPath,
+			FsType:    opts.FuseType,
+			Options:   mountOptions,
+			Secrets:   authCfg.Secrets,
+			FdPassing: opts.FdPassing,
+		})
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		klog.Infof("NodeStageVolume: mounted shared volume %s on %s by %s/%s", sharedVolumeId, sharedAttachPath, fusePod.Namespace, fusePod.Name)
+	}
+
+	notMnt, err = mounterutils.IsNotMountPoint(ns.rawMounter, attachPath)
+	if err != nil {
+		return err
+	}
+	if notMnt {
+		if err := ns.rawMounter.Mount(sharedAttachPath, attachPath, "", []string{"bind"}); err != nil {
+			return status.Errorf(codes.Internal, "bind mount failed: %v", err)
+		}
+	}
+	klog.Infof("NodeStageVolume: volume %s uses shared volume %s", req.VolumeId, sharedVolumeId)
+	return nil
+}
+
+// publishSharedVolume bind mounts the attach path prepared by stageSharedVolume to the target.
+// Secrets are not rotated, the shared fuse pod keeps the credentials it was mounted with.
+func (ns *nodeServer) publishSharedVolume(req *csi.NodePublishVolumeRequest, notMntTarget bool) (*csi.NodePublishVolumeResponse, error) {
+	if !notMntTarget {
+		klog.Infof("NodePublishVolume: %s already mounted", req.TargetPath)
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+	attachPath := mounterutils.GetAttachPath(req.VolumeId)
+	notMntAttach, err := mounterutils.IsNotMountPoint(ns.rawMounter, attachPath)
+	if err != nil {
+		return nil, err
+	}
+	if notMntAttach {
+		return nil, status.Errorf(codes.FailedPrecondition, "volume %s is not staged on %s", req.VolumeId, attachPath)
+	}
+	if err := ns.rawMounter.Mount(attachPath, req.TargetPath, "", []string{"bind"}); err != nil {
+		return nil, status.Errorf(codes.Internal, "bind mount failed: %v", err)
+	}
+	klog.Infof("NodePublishVolume: bind mounted %s to %s", attachPath, req.TargetPath)
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// unstageSharedVolume drops the reference of the volume to its shared fuse pod if any,
+// the attach path of the volume must have been unmounted.
+func (ns *nodeServer) unstageSharedVolume(ctx context.Context, volumeId string) error {
+	if ns.clientset == nil {
+		return nil
+	}
+	// NodeUnstageVolume does not know the fuse type
+	for _, fuseType := range slices.Sorted(maps.Keys(ns.fusePodManagers)) {
+		manager := ns.fusePodManagers[fuseType]
+		podContext := &fpm.FusePodContext{
+			Context:   ctx,
+			Namespace: fusePodNamespace,
+			NodeName:  ns.nodeName,
+			VolumeId:  volumeId,
+		}
+		sharedVolumeId, err := manager.SharedVolumeIdOf(podContext)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to get shared fuse pod: %v", err)
+		}
+		if sharedVolumeId == "" {
+			continue
+		}
+		if !ns.locks.TryAcquire(sharedVolumeId) {
+			return status.Errorf(codes.Aborted, "There is already an operation for %s", sharedVolumeId)
+		}
+		err = ns.releaseSharedVolume(podContext, manager, sharedVolumeId)
+		ns.locks.Release(sharedVolumeId)
+		return err
+	}
+	return nil
+}
+
+// releaseSharedVolume drops the reference of podContext.VolumeId to the shared volume.
+// The last one unmounts the shared volume and deletes its fuse pod.
+// The caller must hold the lock of sharedVolumeId.
+func (ns *nodeServer) releaseSharedVolume(podContext *fpm.FusePodContext, manager *ossfpm.OSSFusePodManager, sharedVolumeId string) error {
+	remaining, err := manager.ReleaseShared(podContext, sharedVolumeId)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to release shared fuse pod: %v", err)
+	}
+	klog.Infof("volume %s released shared volume %s, %d references left", podContext.VolumeId, sharedVolumeId, remaining)
+	if remaining > 0 {
+		return nil
+	}
+	sharedAttachPath := mounterutils.GetAttachPath(sharedVolumeId)
+	if err := mountutils.CleanupMountPoint(sharedAttachPath, ns.rawMounter, false); err != nil {
+		return status.Errorf(codes.Internal, "failed to unmount target %q: %v", sharedAttachPath, err)
+	}
+	if err := manager.DeleteShared(podContext, sharedVolumeId); err != nil {
+		return status.Errorf(codes.Internal, "failed to delete shared fuse pod: %v", err)
+	}
+	return nil
+}
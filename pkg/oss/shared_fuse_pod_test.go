@@ -0,0 +1,186 @@
+//go:build !windows
+
+package oss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter"
+	fpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager"
+	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
+	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgotesting "k8s.io/client-go/testing"
+	mountutils "k8s.io/mount-utils"
+)
+
+func TestSharedFusePodRequested(t *testing.T) {
+	assert.True(t, sharedFusePodRequested(map[string]string{"SharedFusePod": " true"}))
+	assert.False(t, sharedFusePodRequested(map[string]string{"sharedFusePod": "false"}))
+	assert.False(t, sharedFusePodRequested(map[string]string{"bucket": "bucket"}))
+}
+
+func TestSharedFusePodLifecycle(t *testing.T) {
+	baseDir := t.TempDir()
+	mounterutils.SetFuseAttachBaseDir(baseDir)
+	defer mounterutils.SetFuseAttachBaseDir("/run")
+
+	// the watch of NewClientset does not work with watchtools.Until in FusePodManager.Create
+	client := fake.NewSimpleClientset()
+	var created atomic.Int32
+	client.PrependReactor("create", "pods", func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		pod := action.(clientgotesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Name = fmt.Sprintf("fuse-%d", created.Add(1))
+		pod.ResourceVersion = "1"
+		return false, pod, nil
+	})
+	// the fake watch ignores the selectors, and would report other fuse pods ready
+	client.PrependWatchReactor("pods", func(action clientgotesting.Action) (bool, watch.Interface, error) {
+		restrictions := action.(clientgotesting.WatchAction).GetWatchRestrictions()
+		w, err := client.Tracker().Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		return true, watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+			pod, ok := e.Object.(*corev1.Pod)
+			return e, !ok || restrictions.Labels.Matches(labels.Set(pod.Labels)) &&
+				restrictions.Fields.Matches(fields.Set{"metadata.name": pod.Name, "spec.nodeName": pod.Spec.NodeName})
+		}), nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// simulate kubelet
+		for ctx.Err() == nil {
+			time.Sleep(50 * time.Millisecond)
+			pods, err := client.CoreV1().Pods(fusePodNamespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				continue
+			}
+			for _, pod := range pods.Items {
+				if fpm.IsFusePodReady(&pod) {
+					continue
+				}
+				pod.Status.Phase = corev1.PodRunning
+				pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+				_, _ = client.CoreV1().Pods(fusePodNamespace).UpdateStatus(ctx, &pod, metav1.UpdateOptions{})
+			}
+		}
+	}()
+
+	rawMounter := mountutils.NewFakeMounter(nil)
+	proxy := &proxyMounter{FakeMounter: rawMounter}
+	ns := setupTestNodeServer(t, rawMounter, false)
+	ns.clientset = client
+	ns.fusePodManagers = ossfpm.GetAllOSSFusePodManagers(utils.Config{}, ns.metadata, client, nil)
+	ns.newProxyMounter = func(socketPath string) mounter.Mounter {
+		proxy.socketPath = socketPath
+		return proxy
+	}
+
+	tryStage := func(volumeId, path string, secrets map[string]string) error {
+		_, err := ns.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+			VolumeId:          volumeId,
+			StagingTargetPath: filepath.Join(baseDir, "staging", volumeId),
+			PublishContext:    map[string]string{mountProxySocket: mounterutils.GetMountProxySocketPath(volumeId)},
+			VolumeContext: map[string]string{
+				"bucket":        "bucket",
+				"url":           "oss-cn-beijing-internal.aliyuncs.com",
+				"path":          path,
+				"sharedFusePod": "true",
+			},
+			Secrets: secrets,
+			VolumeCapability: &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER},
+			},
+		})
+		return err
+	}
+	stage := func(volumeId, path string) {
+		t.Helper()
+		require.NoError(t, tryStage(volumeId, path, map[string]string{"akId": "ak", "akSecret": "sk"}))
+	}
+	unstage := func(volumeId string) {
+		t.Helper()
+		_, err := ns.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{
+			VolumeId:          volumeId,
+			StagingTargetPath: filepath.Join(baseDir, "staging", volumeId),
+		})
+		require.NoError(t, err)
+	}
+	fusePods := func() []string {
+		t.Helper()
+		pods, err := client.CoreV1().Pods(fusePodNamespace).List(context.Background(), metav1.ListOptions{})
+		require.NoError(t, err)
+		var names []string
+		for _, pod := range pods.Items {
+			names = append(names, pod.Name)
+		}
+		return names
+	}
+
+	stage("pv-1", "/data")
+	stage("pv-2", "/data")
+	stage("pv-3", "/other")
+	assert.ElementsMatch(t, []string{"fuse-1", "fuse-2"}, fusePods())
+	// the bucket is mounted once for pv-1 and pv-2
+	require.Len(t, proxy.ops, 2)
+	assert.Equal(t, "bucket:/data", proxy.ops[0].Source)
+	sharedAttachPath := proxy.ops[0].Target
+	for _, volumeId := range []string{"pv-1", "pv-2"} {
+		notMnt, err := rawMounter.IsLikelyNotMountPoint(mounterutils.GetAttachPath(volumeId))
+		require.NoError(t, err)
+		assert.False(t, notMnt)
+	}
+
+	// pv-2 can be published from its attach path
+	targetPath := filepath.Join(baseDir, "target")
+	_, err := ns.publishSharedVolume(&csi.NodePublishVolumeRequest{VolumeId: "pv-2", TargetPath: targetPath}, true)
+	require.NoError(t, err)
+	_, err = ns.publishSharedVolume(&csi.NodePublishVolumeRequest{VolumeId: "pv-4", TargetPath: targetPath}, true)
+	assert.ErrorContains(t, err, "is not staged")
+
+	// csi-plugin restarted, references are loaded from the pod labels
+	ns.fusePodManagers = ossfpm.GetAllOSSFusePodManagers(utils.Config{}, ns.metadata, client, nil)
+	unstage("pv-1")
+	assert.ElementsMatch(t, []string{"fuse-1", "fuse-2"}, fusePods())
+	unstage("pv-2")
+	assert.ElementsMatch(t, []string{"fuse-2"}, fusePods())
+	assert.NoDirExists(t, sharedAttachPath)
+	unstage("pv-3")
+	assert.Empty(t, fusePods())
+
+	// STS tokens expire, the shared fuse pod would keep using them
+	err = tryStage("pv-5", "/data", map[string]string{
+		mounterutils.KeyAccessKeyId:     "ak",
+		mounterutils.KeyAccessKeySecret: "sk",
+		mounterutils.KeySecurityToken:   "token",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Empty(t, fusePods())
+
+	// a failed stage drops its reference, NodeUnstageVolume may never be called
+	proxy.mountErr = errors.New("mount failed")
+	err = tryStage("pv-5", "/data", map[string]string{"akId": "ak", "akSecret": "sk"})
+	assert.ErrorContains(t, err, "mount failed")
+	assert.Empty(t, fusePods())
+}
@@ -200,6 +200,12 @@ func parseOptions(ctx context.Context, cnfsGetter cnfsv1beta1.CNFSGetter, volOpt
 			} else {
 				klog.Warning(WrapOssError(ParamError, "the value(%q) of %q is invalid", v, k).Error())
 			}
+		case "sharedfusepod":
+			if res, err := strconv.ParseBool(value); err == nil {
+				opts.SharedFusePod = res
+			} else {
+				klog.Warning(WrapOssError(ParamError, "the value(%q) of %q is invalid", v, k).Error())
+			}
 		case "authtype":
 			opts.AuthType = strings.ToLower(value)
 		case "rolename", "ramrole":
@@ -260,6 +266,12 @@ func parseOptions(ctx context.Context, cnfsGetter cnfsv1beta1.CNFSGetter, volOpt
 			} else {
 				opts.Overlay = v
 			}
+		case "fdpassing":
+			if v, err := strconv.ParseBool(value); err != nil {
+				klog.Warning(WrapOssError(ParamError, "the value(%q) of %q is invalid, expect a boolean", value, k).Error())
+			} else {
+				opts.FdPassing = v
+			}
 		case "runtimeclass":
 			runtimeClassValue = value
 		// deprecated:
@@ -290,6 +302,11 @@ func parseOptions(ctx context.Context, cnfsGetter cnfsv1beta1.CNFSGetter, volOpt
 	if opts.FuseType == "" {
 		opts.FuseType = mounterutils.OssFsType
 	}
+	// ossfs can not be restarted on a kept FUSE connection
+	if opts.FdPassing && opts.FuseType != mounterutils.OssFs2Type {
+		klog.Warningf("fdPassing is only supported by %s, ignored for %s", mounterutils.OssFs2Type, opts.FuseType)
+		opts.FdPassing = false
+	}
 
 	// Resolve CNFS fallback before URL normalization:
 	// 1) Keep StorageClass/request values as the source of truth.
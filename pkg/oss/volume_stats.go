@@ -0,0 +1,327 @@
+//go:build !windows
+
+package oss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	fpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager"
+	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
+	mounterutils "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/utils"
+	osscloud "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/oss/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/ttlcache"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	mountutils "k8s.io/mount-utils"
+)
+
+// The statistics of a bucket are updated by OSS about once an hour,
+// there is no point in querying them more often.
+const bucketStatTTL = 10 * time.Minute
+
+// bucketVolumeTTL is how long the options of a volume read from its PV are cached.
+const bucketVolumeTTL = time.Hour
+
+// NodeGetVolumeStats reports the usage of the mounted prefix, and whether the fuse client is serving the volume.
+func (ns *nodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	logger := klog.FromContext(ctx)
+
+	condition := ns.volumeCondition(ctx, req.VolumeId, req.VolumePath)
+	resp := &csi.NodeGetVolumeStatsResponse{VolumeCondition: condition}
+	if condition.Abnormal {
+		// the fuse client is not serving, its metrics are stale
+		return resp, nil
+	}
+
+	usage, err := readFuseUsage(fuseMetricsDirs(req.VolumeId, req.VolumePath))
+	if err == nil {
+		resp.Usage = usage
+		return resp, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		logger.Error(err, "failed to read fuse metrics")
+	}
+	if ns.bucketStats != nil {
+		usage, err = ns.bucketUsage(ctx, req.VolumeId)
+		if err != nil {
+			logger.Error(err, "failed to get bucket statistics")
+		}
+		resp.Usage = usage
+	}
+	return resp, nil
+}
+
+// volumeCondition reports abnormal if the fuse pod of the volume on this node is not ready,
+// or the mountpoint is disconnected from its fuse client.
+func (ns *nodeServer) volumeCondition(ctx context.Context, volumeID, volumePath string) *csi.VolumeCondition {
+	logger := klog.FromContext(ctx)
+
+	var problems []string
+	if ns.fusePods != nil {
+		// only RunC volumes have fuse pods, but the runtime type is not known here
+		pods, err := ns.fusePods.ofVolume(ctx, volumeID)
+		if err != nil {
+			logger.Error(err, "failed to list fuse pods")
+		}
+		for _, pod := range pods {
+			if pod.DeletionTimestamp == nil && !fpm.IsFusePodReady(pod) {
+				problems = append(problems, fmt.Sprintf("fuse pod %s/%s is not ready", pod.Namespace, pod.Name))
+			}
+		}
+	}
+
+	if _, err := statfs(volumePath); err != nil {
+		switch {
+		case errors.Is(err, errStatfsTimeout):
+			problems = append(problems, fmt.Sprintf("mountpoint %s does not respond in %v", volumePath, statfsTimeout))
+		case mountutils.IsCorruptedMnt(err):
+			problems = append(problems, fmt.Sprintf("mountpoint %s is disconnected: %v", volumePath, err))
+		default:
+			logger.Error(err, "failed to statfs volume path", "path", volumePath)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &csi.VolumeCondition{Abnormal: true, Message: strings.Join(problems, "; ")}
+	}
+	return &csi.VolumeCondition{Abnormal: false, Message: "volume is healthy"}
+}
+
+// fusePodVolumeIndex indexes the fuse pods by the volume-id label values of the volumes they serve
+const fusePodVolumeIndex = "volume"
+
+// fusePodVolumes returns the volume-id label of the pod, and those of the volumes referencing it if it is a shared fuse pod,
+// whose own volume-id label is that of fpm.SharedVolumeId.
+func fusePodVolumes(obj any) ([]string, error) {
+	pod := obj.(*corev1.Pod)
+	volumes := []string{pod.Labels[fpm.FuseVolumeIdLabelKey]}
+	for key := range pod.Labels {
+		if ref, ok := strings.CutPrefix(key, fpm.FuseSharedRefLabelPrefix); ok {
+			volumes = append(volumes, ref)
+		}
+	}
+	return volumes, nil
+}
+
+// fusePods looks up the fuse pods of the volumes on the node,
+// from an informer started on the first lookup.
+type fusePods struct {
+	client   kubernetes.Interface
+	nodeName string
+
+	informerOnce sync.Once
+	pods         cache.Indexer
+	synced       cache.InformerSynced
+}
+
+func newFusePods(client kubernetes.Interface, nodeName string) *fusePods {
+	if client == nil {
+		return nil
+	}
+	return &fusePods{client: client, nodeName: nodeName}
+}
+
+func (p *fusePods) start() {
+	factory := informers.NewSharedInformerFactoryWithOptions(p.client, 0,
+		informers.WithNamespace(fusePodNamespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", p.nodeName).String()
+			options.LabelSelector = fpm.FuseVolumeIdLabelKey
+		}))
+	informer := factory.Core().V1().Pods().Informer()
+	// only fails once the informer is started
+	utilruntime.Must(informer.AddIndexers(cache.Indexers{fusePodVolumeIndex: fusePodVolumes}))
+	p.pods = informer.GetIndexer()
+	p.synced = informer.HasSynced
+	factory.Start(wait.NeverStop)
+}
+
+func (p *fusePods) ofVolume(ctx context.Context, volumeID string) ([]*corev1.Pod, error) {
+	p.informerOnce.Do(p.start)
+	if !cache.WaitForCacheSync(ctx.Done(), p.synced) {
+		return nil, fmt.Errorf("fuse pod informer not synced: %w", ctx.Err())
+	}
+	objs, err := p.pods.ByIndex(fusePodVolumeIndex, mounterutils.ComputeVolumeIdLabelVal(volumeID))
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*corev1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		pods = append(pods, obj.(*corev1.Pod))
+	}
+	return pods, nil
+}
+
+// fuseMetricsDirs returns the directories the fuse client may write metrics of the volume into,
+// see utils.WriteSharedMetricsInfo and utils.WriteMetricsInfo.
+func fuseMetricsDirs(volumeID, volumePath string) []string {
+	dirs := []string{utils.GetFuseMetricsMountDir(metricsPathPrefix, volumeID)}
+	// /var/lib/kubelet/pods/<pod-uid>/volumes/kubernetes.io~csi/<pv-name>/mount
+	segments := strings.Split(filepath.Clean(volumePath), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] == "pods" && len(segments) >= i+3 {
+			dirs = append(dirs, filepath.Join(metricsPathPrefix, segments[i+1], segments[len(segments)-2]))
+			break
+		}
+	}
+	return dirs
+}
+
+// readFuseUsage reads the capacity and inode counters from the first directory that has them.
+// For OSS, the used inodes is the number of objects.
+func readFuseUsage(dirs []string) ([]*csi.VolumeUsage, error) {
+	err := error(os.ErrNotExist)
+	for _, dir := range dirs {
+		var capacity, inodes *csi.VolumeUsage
+		capacity, err = readCounterUsage(filepath.Join(dir, utils.MetricsCapacityCounter), csi.VolumeUsage_BYTES)
+		if err != nil {
+			continue
+		}
+		inodes, err = readCounterUsage(filepath.Join(dir, utils.MetricsInodesCounter), csi.VolumeUsage_INODES)
+		if err != nil {
+			continue
+		}
+		return []*csi.VolumeUsage{capacity, inodes}, nil
+	}
+	return nil, err
+}
+
+// readCounterUsage parses a counter file of "<used> <available> <total>".
+func readCounterUsage(path string, unit csi.VolumeUsage_Unit) (*csi.VolumeUsage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid counter file %s: %q", path, line)
+	}
+	var values [3]int64
+	for i := range values {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid counter file %s: %w", path, err)
+		}
+		values[i] = int64(v)
+	}
+	return &csi.VolumeUsage{Unit: unit, Used: values[0], Available: values[1], Total: values[2]}, nil
+}
+
+// bucketUsage reports the statistics of the bucket for volumes mounting the whole bucket.
+// OSS only provides statistics of the whole bucket, so nothing is reported for a prefix.
+func (ns *nodeServer) bucketUsage(ctx context.Context, volumeID string) ([]*csi.VolumeUsage, error) {
+	opts, err := ns.bucketStats.volumes.Get(ctx, volumeID, func() (*ossfpm.Options, error) {
+		return ns.bucketVolumeOptions(ctx, volumeID)
+	})
+	if err != nil || opts == nil {
+		return nil, err
+	}
+	stat, err := ns.bucketStats.get(ctx, opts)
+	if err != nil || stat == nil {
+		return nil, err
+	}
+	return []*csi.VolumeUsage{
+		{Unit: csi.VolumeUsage_BYTES, Used: stat.Storage},
+		{Unit: csi.VolumeUsage_INODES, Used: stat.ObjectCount},
+	}, nil
+}
+
+// bucketVolumeOptions returns the options of the volume from its PV, or nil if it does not mount the whole bucket.
+func (ns *nodeServer) bucketVolumeOptions(ctx context.Context, volumeID string) (*ossfpm.Options, error) {
+	pv, err := ns.clientset.CoreV1().PersistentVolumes().Get(ctx, volumeID, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// statically provisioned volume named differently from its handle
+			return nil, nil
+		}
+		return nil, err
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.VolumeHandle != volumeID {
+		return nil, nil
+	}
+	// The node cannot read the secrets of the volume, use its own credentials.
+	opts, err := parseOptions(ctx, ns.cnfsGetter, pv.Spec.CSI.VolumeAttributes, nil, nil, false, "", true, ns.metadata)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Trim(opts.Path, "/") != "" {
+		return nil, nil
+	}
+	return opts, nil
+}
+
+// bucketStatCache queries the statistics of each bucket at most once per ttl, even if the query failed.
+type bucketStatCache struct {
+	ttl       time.Duration
+	newClient func(*ossfpm.Options) (osscloud.Interface, error)
+	// volumes caches the options of the volumes from their PVs, nil if not mounting the whole bucket.
+	// The attributes of a PV never change.
+	volumes *ttlcache.TTLCache[string, *ossfpm.Options]
+
+	mu      sync.Mutex
+	buckets map[string]*bucketStatEntry
+}
+
+type bucketStatEntry struct {
+	mu      sync.Mutex
+	fetched time.Time
+	stat    *osscloud.BucketStat
+	err     error
+}
+
+func newBucketStatCache(newClient func(*ossfpm.Options) (osscloud.Interface, error)) *bucketStatCache {
+	return &bucketStatCache{
+		ttl:       bucketStatTTL,
+		newClient: newClient,
+		volumes:   ttlcache.NewTTLCache[string, *ossfpm.Options](bucketVolumeTTL),
+		buckets:   map[string]*bucketStatEntry{},
+	}
+}
+
+func (c *bucketStatCache) get(ctx context.Context, opts *ossfpm.Options) (*osscloud.BucketStat, error) {
+	key := opts.URL + "/" + opts.Bucket
+	c.mu.Lock()
+	entry := c.buckets[key]
+	if entry == nil {
+		entry = &bucketStatEntry{}
+		c.buckets[key] = entry
+	}
+	c.mu.Unlock()
+
+	// concurrent callers of the same bucket wait for the only query
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if !entry.fetched.IsZero() && time.Since(entry.fetched) < c.ttl {
+		return entry.stat, entry.err
+	}
+	client, err := c.newClient(opts)
+	if err == nil {
+		entry.stat, err = client.GetBucketStat(ctx)
+	}
+	if err != nil {
+		entry.stat, entry.err = nil, fmt.Errorf("failed to get statistics of bucket %s: %w", opts.Bucket, err)
+	} else {
+		entry.err = nil
+	}
+	entry.fetched = time.Now()
+	return entry.stat, entry.err
+}
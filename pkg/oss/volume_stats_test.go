@@ -0,0 +1,231 @@
+//go:build !windows
+
+package oss
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	fpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager"
+	ossfpm "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/mounter/fuse_pod_manager/oss"
+	osscloud "github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/oss/cloud"
+	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	mountutils "k8s.io/mount-utils"
+)
+
+func TestFuseMetricsDirs(t *testing.T) {
+	assert.Equal(t, []string{
+		utils.GetFuseMetricsMountDir(metricsPathPrefix, "pv-1"),
+		"/host/var/run/ossfs/uid-1/pv-1",
+	}, fuseMetricsDirs("pv-1", "/var/lib/kubelet/pods/uid-1/volumes/kubernetes.io~csi/pv-1/mount"))
+	assert.Equal(t, []string{
+		utils.GetFuseMetricsMountDir(metricsPathPrefix, "pv-1"),
+	}, fuseMetricsDirs("pv-1", "/run/kata-containers/shared/direct-volumes/pv-1"))
+}
+
+func TestReadFuseUsage(t *testing.T) {
+	missing := t.TempDir()
+	invalid := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(invalid, utils.MetricsCapacityCounter), []byte("1 2"), 0o644))
+	valid := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(valid, utils.MetricsCapacityCounter), []byte("1024 3072 4096\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(valid, utils.MetricsInodesCounter), []byte("12 88 100"), 0o644))
+
+	usage, err := readFuseUsage([]string{missing, invalid, valid})
+	require.NoError(t, err)
+	assert.Equal(t, []*csi.VolumeUsage{
+		{Unit: csi.VolumeUsage_BYTES, Used: 1024, Available: 3072, Total: 4096},
+		{Unit: csi.VolumeUsage_INODES, Used: 12, Available: 88, Total: 100},
+	}, usage)
+
+	_, err = readFuseUsage([]string{missing})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = readFuseUsage([]string{invalid})
+	assert.ErrorContains(t, err, "invalid counter file")
+}
+
+type countingBucket struct {
+	fakeBucket
+	calls int
+	err   error
+}
+
+func (b *countingBucket) GetBucketStat(ctx context.Context) (*osscloud.BucketStat, error) {
+	b.calls++
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.fakeBucket.GetBucketStat(ctx)
+}
+
+func TestBucketStatCache(t *testing.T) {
+	bucket := &countingBucket{fakeBucket: fakeBucket{objects: map[string]int64{"a": 3, "b/c": 4}}}
+	cache := newBucketStatCache(func(*ossfpm.Options) (osscloud.Interface, error) { return bucket, nil })
+	opts := &ossfpm.Options{Bucket: "bucket", URL: "oss-cn-beijing-internal.aliyuncs.com"}
+
+	stat, err := cache.get(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, &osscloud.BucketStat{Storage: 7, ObjectCount: 2}, stat)
+	_, _ = cache.get(context.Background(), opts)
+	assert.Equal(t, 1, bucket.calls)
+
+	// failures are not retried before ttl either
+	cache.ttl = 0
+	bucket.err = errors.New("AccessDenied")
+	_, err = cache.get(context.Background(), opts)
+	assert.ErrorContains(t, err, "AccessDenied")
+	cache.ttl = time.Hour
+	_, err = cache.get(context.Background(), opts)
+	assert.ErrorContains(t, err, "AccessDenied")
+	assert.Equal(t, 2, bucket.calls)
+}
+
+func fusePod(name string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: fusePodNamespace,
+			Labels:    map[string]string{fpm.FuseVolumeIdLabelKey: "pv-1"},
+		},
+		Spec: corev1.PodSpec{NodeName: "test-node"},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+// sharedFusePod returns a shared fuse pod serving pv-1.
+func sharedFusePod(name string, ready bool) *corev1.Pod {
+	pod := fusePod(name, ready)
+	pod.Labels = map[string]string{
+		fpm.FuseVolumeIdLabelKey:              "shared-1",
+		fpm.FuseSharedRefLabelPrefix + "pv-1": "true",
+	}
+	return pod
+}
+
+func TestNodeGetVolumeStats(t *testing.T) {
+	volumePath := t.TempDir()
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-1"},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{CSI: &corev1.CSIPersistentVolumeSource{
+				Driver:           "ossplugin.csi.alibabacloud.com",
+				VolumeHandle:     "pv-1",
+				VolumeAttributes: map[string]string{"bucket": "bucket", "url": "oss-cn-beijing-internal.aliyuncs.com", "path": "/"},
+			}},
+		},
+	}
+	bucket := &fakeBucket{objects: map[string]int64{"a": 3, "b/c": 4}}
+
+	tests := []struct {
+		name         string
+		objects      []*corev1.Pod
+		path         string
+		wantAbnormal bool
+		wantMessage  string
+		stuck        bool
+		wantUsage    []*csi.VolumeUsage
+		wantNoUsage  bool
+	}{
+		{
+			name:    "bucket statistics",
+			objects: []*corev1.Pod{fusePod("fuse-1", true)},
+			path:    "/",
+			wantUsage: []*csi.VolumeUsage{
+				{Unit: csi.VolumeUsage_BYTES, Used: 7},
+				{Unit: csi.VolumeUsage_INODES, Used: 2},
+			},
+		},
+		{
+			name:        "prefix mounted",
+			path:        "/data",
+			wantNoUsage: true,
+		},
+		{
+			name:         "fuse pod not ready",
+			objects:      []*corev1.Pod{fusePod("fuse-1", false)},
+			path:         "/",
+			wantAbnormal: true,
+			wantMessage:  "fuse pod ack-csi-fuse/fuse-1 is not ready",
+			wantNoUsage:  true,
+		},
+		{
+			name:         "shared fuse pod not ready",
+			objects:      []*corev1.Pod{sharedFusePod("fuse-shared", false)},
+			path:         "/",
+			wantAbnormal: true,
+			wantMessage:  "fuse pod ack-csi-fuse/fuse-shared is not ready",
+			wantNoUsage:  true,
+		},
+		{
+			name:         "fuse client stuck",
+			objects:      []*corev1.Pod{fusePod("fuse-1", true)},
+			path:         "/",
+			stuck:        true,
+			wantAbnormal: true,
+			wantMessage:  "mountpoint " + volumePath + " does not respond in 5s",
+			wantNoUsage:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pv := pv.DeepCopy()
+			pv.Spec.CSI.VolumeAttributes["path"] = tt.path
+			client := fake.NewClientset(pv)
+			for _, pod := range tt.objects {
+				_, err := client.CoreV1().Pods(pod.Namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			ns := setupTestNodeServer(t, mountutils.NewFakeMounter(nil), false)
+			ns.clientset = client
+			ns.fusePods = newFusePods(client, "test-node")
+			ns.bucketStats = newBucketStatCache(func(*ossfpm.Options) (osscloud.Interface, error) { return bucket, nil })
+			if tt.stuck {
+				// a statfs of the path is still stuck
+				pendingStatfs.Store(volumePath, struct{}{})
+				defer pendingStatfs.Delete(volumePath)
+			}
+
+			for range 2 {
+				resp, err := ns.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+					VolumeId:   "pv-1",
+					VolumePath: volumePath,
+				})
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantAbnormal, resp.VolumeCondition.Abnormal)
+				if tt.wantMessage != "" {
+					assert.Equal(t, tt.wantMessage, resp.VolumeCondition.Message)
+				}
+				if tt.wantNoUsage {
+					assert.Empty(t, resp.Usage)
+				} else {
+					assert.Equal(t, tt.wantUsage, resp.Usage)
+				}
+			}
+			// the options of the volume are cached
+			pvGets := 0
+			for _, action := range client.Actions() {
+				if action.Matches("get", "persistentvolumes") {
+					pvGets++
+				}
+			}
+			assert.LessOrEqual(t, pvGets, 1)
+		})
+	}
+}
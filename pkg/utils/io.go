@@ -5,10 +5,6 @@ package utils
 import (
 	"errors"
 	"fmt"
-	"math"
-	"math/bits"
-	"strconv"
-	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/kubernetes-sigs/alibaba-cloud-csi-driver/pkg/utils/cgroup"
@@ -49,10 +45,10 @@ func (cg *PodCGroup) ApplyConfig(devicePath string, req *csi.NodePublishVolumeRe
 // readBPS: 100K
 // writeBPS: 1M
 func parseIOLimits(ctx map[string]string) (*cgroup.IOLimits, error) {
-	readIOPS := ctx["readIOPS"]
-	writeIOPS := ctx["writeIOPS"]
-	readBPS := ctx["readBPS"]
-	writeBPS := ctx["writeBPS"]
+	readIOPS := ctx[ReadIOPSKey]
+	writeIOPS := ctx[WriteIOPSKey]
+	readBPS := ctx[ReadBPSKey]
+	writeBPS := ctx[WriteBPSKey]
 
 	// if no quota set, return;
 	if readIOPS == "" && writeIOPS == "" && readBPS == "" && writeBPS == "" {
@@ -84,6 +80,29 @@ func parseIOLimits(ctx map[string]string) (*cgroup.IOLimits, error) {
 	return &limit, nil
 }
 
+// UpdateIOLimits sets the IO limits of the device major:minor in the cgroup of pod to those in params,
+// lifting any limit not in params. Used to change the limits of a published volume.
+func (cg *PodCGroup) UpdateIOLimits(podUID string, major, minor uint32, params map[string]string) error {
+	limits, err := parseIOLimits(params)
+	if err != nil {
+		return err
+	}
+	if limits == nil {
+		l := cgroup.MaxIOLimits()
+		limits = &l
+	}
+	fd, err := cg.slice.FindPodDir(podUID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := unix.Close(fd); err != nil {
+			klog.Errorf("close fd(%d) of pod %s failed: %v", fd, podUID, err)
+		}
+	}()
+	return cg.io.SetLimits(fd, major, minor, limits)
+}
+
 func (cg *PodCGroup) setVolumeIOLimit(devicePath string, req *csi.NodePublishVolumeRequest) (err error) {
 	limits, err := parseIOLimits(req.VolumeContext)
 	if err != nil {
@@ -122,47 +141,3 @@ func (cg *PodCGroup) setVolumeIOLimit(devicePath string, req *csi.NodePublishVol
 	klog.Infof("Successfully Set Volume(%s) IO Limit: %+v", req.VolumeId, limits)
 	return nil
 }
-
-func getBpsLimit(limit string) (uint64, error) {
-	if limit == "" {
-		return math.MaxUint64, nil
-	}
-
-	limit = strings.ToLower(limit)
-	var convertNumber uint64 = 1
-	switch limit[len(limit)-1] {
-	case 'k', 'K':
-		convertNumber = 1024
-	case 'm', 'M':
-		convertNumber = 1024 * 1024
-	case 'g', 'G':
-		convertNumber = 1024 * 1024 * 1024
-	}
-
-	intStr := limit
-	if convertNumber != 1 {
-		intStr = limit[:len(limit)-1]
-	}
-
-	intValue, err := strconv.ParseUint(intStr, 10, 64)
-	if err != nil {
-		return 0, err
-	}
-	hi, intValue := bits.Mul64(intValue, convertNumber)
-	if hi > 0 {
-		return 0, fmt.Errorf("%s %w", limit, strconv.ErrRange)
-	}
-	return intValue, nil
-}
-
-func getIOPSLimit(limit string) (uint32, error) {
-	if limit == "" {
-		return math.MaxUint32, nil
-	}
-
-	intValue, err := strconv.ParseUint(limit, 10, 32)
-	if err != nil {
-		return 0, err
-	}
-	return uint32(intValue), nil
-}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Keys of the IO limits of a volume, in its volume context.
+// The limits are applied to the cgroup of the pod on node.
+const (
+	ReadIOPSKey  = "readIOPS"
+	WriteIOPSKey = "writeIOPS"
+	ReadBPSKey   = "readBPS"
+	WriteBPSKey  = "writeBPS"
+)
+
+var IOLimitKeys = []string{ReadIOPSKey, WriteIOPSKey, ReadBPSKey, WriteBPSKey}
+
+// ValidateIOLimit checks the value of one of IOLimitKeys. An empty value means unlimited.
+func ValidateIOLimit(key, value string) error {
+	var err error
+	switch key {
+	case ReadIOPSKey, WriteIOPSKey:
+		_, err = getIOPSLimit(value)
+	case ReadBPSKey, WriteBPSKey:
+		_, err = getBpsLimit(value)
+	default:
+		return fmt.Errorf("unknown IO limit %s", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, value, err)
+	}
+	return nil
+}
+
+func getBpsLimit(limit string) (uint64, error) {
+	if limit == "" {
+		return math.MaxUint64, nil
+	}
+
+	limit = strings.ToLower(limit)
+	var convertNumber uint64 = 1
+	switch limit[len(limit)-1] {
+	case 'k', 'K':
+		convertNumber = 1024
+	case 'm', 'M':
+		convertNumber = 1024 * 1024
+	case 'g', 'G':
+		convertNumber = 1024 * 1024 * 1024
+	}
+
+	intStr := limit
+	if convertNumber != 1 {
+		intStr = limit[:len(limit)-1]
+	}
+
+	intValue, err := strconv.ParseUint(intStr, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	hi, intValue := bits.Mul64(intValue, convertNumber)
+	if hi > 0 {
+		return 0, fmt.Errorf("%s %w", limit, strconv.ErrRange)
+	}
+	return intValue, nil
+}
+
+func getIOPSLimit(limit string) (uint32, error) {
+	if limit == "" {
+		return math.MaxUint32, nil
+	}
+
+	intValue, err := strconv.ParseUint(limit, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(intValue), nil
+}
@@ -115,6 +115,12 @@ func (c *TTLCache[K, V]) compute(key K, pending *cacheEntry[V], fn func() (V, er
 	return value, err
 }
 
+// Delete evicts key, so the next Get computes it again. A computation in flight
+// still returns its result to its callers, but the result is not retained.
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.m.Delete(key)
+}
+
 // Store puts value into the cache directly, without running fn, replacing any
 // existing entry and (re)starting its TTL. Use it to populate the cache from a
 // value obtained elsewhere (e.g. a write-through after creating the resource).
@@ -325,3 +325,36 @@ func TestTTLCacheStore(t *testing.T) {
 	assert.Equal(t, 42, val)
 	assert.Equal(t, 0, int(calls.Load()), "stored value must be served without recomputing")
 }
+
+func TestTTLCacheDelete(t *testing.T) {
+	c := NewTTLCache[string, int](10 * time.Second)
+	c.Store("key1", 42)
+	c.Delete("key1")
+
+	val, err := c.Get(t.Context(), "key1", func() (int, error) {
+		return 99, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 99, val, "deleted value must be recomputed")
+
+	// A computation in flight still completes for its caller, but is not retained.
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan int)
+	go func() {
+		val, _ := c.Get(context.Background(), "key2", func() (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		})
+		done <- val
+	}()
+	<-started
+	c.Delete("key2")
+	close(release)
+	assert.Equal(t, 1, <-done)
+	val, err = c.Get(t.Context(), "key2", func() (int, error) {
+		return 2, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, val)
+}
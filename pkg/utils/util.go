@@ -199,6 +199,12 @@ func CommandOnNode(args ...string) *exec.Cmd {
 	return exec.Command("/usr/bin/nsenter", allArgs...)
 }
 
+// CommandOnNodeContext is like CommandOnNode, but the command is killed if ctx is done before it exits.
+func CommandOnNodeContext(ctx context.Context, args ...string) *exec.Cmd {
+	allArgs := append(NsenterArgs, args...)
+	return exec.CommandContext(ctx, "/usr/bin/nsenter", allArgs...)
+}
+
 // CreateDest create de destination dir
 func CreateDest(dest string) error {
 	fi, err := os.Lstat(dest)